@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	"github.com/jetstack/cert-manager-csi/pkg/certmanager"
+)
+
+type checkOptions struct {
+	kubeconfig string
+
+	namespace   string
+	issuerName  string
+	issuerKind  string
+	issuerGroup string
+
+	managedLabelKey string
+
+	timeout time.Duration
+}
+
+var checkOpts checkOptions
+
+func init() {
+	checkCmd.Flags().StringVar(&checkOpts.kubeconfig, "kubeconfig",
+		"", "path to a kubeconfig, the in cluster config is used if empty")
+
+	checkCmd.Flags().StringVar(&checkOpts.namespace, "namespace",
+		"default", "namespace to create the throwaway CertificateRequest in")
+
+	checkCmd.Flags().StringVar(&checkOpts.issuerName, "issuer",
+		"", "name of the issuer to request the certificate from")
+	checkCmd.MarkFlagRequired("issuer")
+
+	checkCmd.Flags().StringVar(&checkOpts.issuerKind, "issuer-kind",
+		"Issuer", "kind of the issuer to request the certificate from")
+
+	checkCmd.Flags().StringVar(&checkOpts.issuerGroup, "issuer-group",
+		"cert-manager.io", "group of the issuer to request the certificate from")
+
+	checkCmd.Flags().StringVar(&checkOpts.managedLabelKey, "managed-label-key",
+		options.DefaultManagedLabelKey, "label set to \"true\" on the CertificateRequest, as set by the driver")
+
+	checkCmd.Flags().DurationVar(&checkOpts.timeout, "timeout",
+		time.Second*30, "maximum time to wait for the CertificateRequest to become ready")
+
+	RootCmd.AddCommand(checkCmd)
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check that certificates can be issued by creating and deleting a throwaway CertificateRequest",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		restConfig, err := clientcmd.BuildConfigFromFlags("", checkOpts.kubeconfig)
+		if err != nil {
+			return err
+		}
+
+		cm, err := certmanager.NewForConfig(restConfig, &options.Options{
+			IssuanceTimeout: checkOpts.timeout,
+			ManagedLabelKey: checkOpts.managedLabelKey,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Errors are reported by Check, don't print usage as well.
+		cmd.SilenceUsage = true
+
+		return cm.Check(context.Background(), cmd.OutOrStdout(), checkOpts.namespace, cmmeta.ObjectReference{
+			Name:  checkOpts.issuerName,
+			Kind:  checkOpts.issuerKind,
+			Group: checkOpts.issuerGroup,
+		})
+	},
+}
@@ -0,0 +1,38 @@
+package options
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// fileModeValue implements pflag.Value for octal file permissions.
+type fileModeValue os.FileMode
+
+func newFileModeValue(val os.FileMode, p *os.FileMode) *fileModeValue {
+	*p = val
+	return (*fileModeValue)(p)
+}
+
+func (f *fileModeValue) Set(s string) error {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return fmt.Errorf("failed to parse octal file mode %q: %s", s, err)
+	}
+
+	if os.FileMode(mode)&^os.ModePerm != 0 {
+		return fmt.Errorf("file mode %q may only contain permission bits", s)
+	}
+
+	*f = fileModeValue(mode)
+
+	return nil
+}
+
+func (f *fileModeValue) String() string {
+	return fmt.Sprintf("%#o", uint32(*f))
+}
+
+func (f *fileModeValue) Type() string {
+	return "fileMode"
+}
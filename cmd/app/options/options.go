@@ -1,9 +1,47 @@
 package options
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/spf13/cobra"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+	"github.com/jetstack/cert-manager-csi/pkg/signer"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
 )
 
+// allowedMountOptions are the mount options that may be added to the read
+// only bind mount of volumes.
+var allowedMountOptions = map[string]bool{
+	"ro":          true,
+	"nosuid":      true,
+	"nodev":       true,
+	"noexec":      true,
+	"noatime":     true,
+	"nodiratime":  true,
+	"relatime":    true,
+	"strictatime": true,
+	"private":     true,
+	"rprivate":    true,
+	"slave":       true,
+	"rslave":      true,
+	"shared":      true,
+	"rshared":     true,
+	"unbindable":  true,
+	"runbindable": true,
+}
+
+// DefaultManagedLabelKey is the default label set to "true" on every
+// CertificateRequest created by the driver.
+const DefaultManagedLabelKey = "csi.cert-manager.io/managed"
+
 type Options struct {
 	NodeID     string
 	DriverName string
@@ -14,30 +52,706 @@ type Options struct {
 	// Root directory to write data and mount from.
 	DataRoot string
 
+	// Named data roots that volumes may select instead of DataRoot.
+	DataRootMap map[string]string
+
 	// Endpoint that Kubelet should connect to driver.
 	KubeletRegistrationEndpoint string
 
 	// Size in Mbytes to create the tmpfs file system to write and mount from.
 	TmpfsSize string
+
+	// Address to serve Prometheus metrics on. Disabled if empty.
+	MetricsBindAddress string
+
+	// Address to serve the admin endpoints, such as the status of all volumes,
+	// on. Disabled if empty.
+	AdminBindAddress string
+
+	// Include the namespace of volumes as a label of issuance metrics.
+	MetricsHighCardinality bool
+
+	// Path to an executable run on the host after each issuance or renewal.
+	PostIssueHook string
+
+	// Maximum time the post issue hook is allowed to run for.
+	PostIssueHookTimeout time.Duration
+
+	// Key defaults and policy of volumes, one of legacy, modern or fips.
+	// Key policy set by its own flag takes precedence.
+	SecurityLevel string
+
+	// Restrict key algorithms and sizes to those approved by FIPS 140-2.
+	FIPSMode bool
+
+	// Minimum size of rsa keys, and the ecdsa curves, that may be requested.
+	// Any of the supported curves may be requested if none are set.
+	MinRSAKeySize      int
+	AllowedECDSACurves []string
+
+	// Types of certificate, of client, server or peer, that volumes may
+	// request. Any type may be requested if none are set.
+	AllowedCertificateTypes []string
+
+	// Permissions used when creating the volume and mount directories.
+	DirPermissions os.FileMode
+
+	// Permissions applied to the target path directory created for the
+	// kubelet to mount into.
+	TargetPathPermissions os.FileMode
+
+	// Also write a copy of the volume metadata into the application mount.
+	MetadataInMount bool
+
+	// Sync files to disk after they are written, for data roots that are
+	// not on tmpfs.
+	FsyncFiles bool
+
+	// Options added to the read only bind mount of volumes, such as nosuid
+	// or a mount propagation.
+	MountOptions []string
+
+	// Write files into timestamped directories swapped in with a ..data
+	// symlink, so that all files of a volume are updated atomically.
+	AtomicDirLayout bool
+
+	// File names of the certificate, key and CA of volumes that don't set
+	// them, in place of those of their encoding and OS profile. Unset names
+	// keep their built in defaults.
+	DefaultCertFilename string
+	DefaultKeyFilename  string
+	DefaultCAFilename   string
+
+	// Watch CertificateRequests and re-issue volumes whose request has been
+	// deleted.
+	ReissueOnRequestDeletion bool
+
+	// Watch pods scheduled to the node and create the CertificateRequests of
+	// their volumes before they are published.
+	PrewarmCertificateRequests bool
+
+	// Share the certificate and key issued for a volume with further volumes
+	// of the same spec and namespace published within the TTL, rather than
+	// creating a request for each.
+	EnableIssuanceCache bool
+	IssuanceCacheTTL    time.Duration
+
+	// Leave the certificate duration to the issuer if not requested, rather
+	// than defaulting it.
+	RespectIssuerDuration bool
+
+	// Maximum time to wait for a CertificateRequest to become ready. Volumes
+	// issued synchronously are also bounded by the kubelet request timeout.
+	IssuanceTimeout time.Duration
+
+	// Total time to keep waiting on the same pending CertificateRequest of a
+	// volume across retried publishes, before it is re-created. Disabled if
+	// zero.
+	IssuanceDeadline time.Duration
+
+	// Path to a PEM encoded root CA appended to certificate chain files if not
+	// already present.
+	ChainRootCAFile string
+
+	// Time to wait after startup before removing volume directories that are
+	// no longer mounted. Disabled if zero.
+	OrphanGCGrace time.Duration
+
+	// Time to wait after a volume is unpublished before removing its
+	// directory, reusing its materials if republished in the meantime.
+	// Disabled if zero.
+	UnpublishGrace time.Duration
+
+	// Check the issuer of a volume is Ready before creating a
+	// CertificateRequest, failing fast if not.
+	PrecheckIssuer bool
+
+	// Maximum age of a volume's private key, after which it is rotated on the
+	// next renewal even if the volume reuses its private key. Disabled if
+	// zero.
+	MaxIdentityAge time.Duration
+
+	// Maximum time to retry discovering existing volumes at startup before
+	// serving. Discovery continues in the background afterwards.
+	DiscoverTimeout time.Duration
+
+	// Number of existing volumes read at once during discovery.
+	DiscoverConcurrency int
+
+	// Verify issued certificates are of the requested key, and include every
+	// requested name.
+	VerifyIssuedCert bool
+
+	// Log the subject and SANs of each issued certificate, at the given log
+	// verbosity level.
+	LogCertDetails      bool
+	LogCertDetailsLevel int
+
+	// Maximum backoff between failed renewal attempts of a certificate.
+	RenewRetryMaxBackoff time.Duration
+
+	// Fraction of a certificate's lifetime remaining when it is renewed, if
+	// the volume sets no renewal strategy.
+	AutoRenewBeforeFraction float64
+
+	// Globally disable the renewal of every certificate, in addition to
+	// volumes disabling their own. Auto renewal is also disabled while the
+	// file exists, checked at startup and on SIGHUP.
+	DisableAutoRenew     bool
+	DisableAutoRenewFile string
+
+	// Interval to reconcile the attributes ConfigMaps of live volumes.
+	// Disabled if zero.
+	ReconcileAttributesInterval time.Duration
+
+	// Re-issue certificates when reconciled attributes change more than how
+	// they are renewed.
+	ReissueOnAttributeChange bool
+
+	// Interval to reset the permissions and ownership of the data files of
+	// watched volumes if they have drifted. Disabled if zero.
+	ReconcileFilePermsInterval time.Duration
+
+	// Periodically re-mount volumes whose target path is no longer mounted,
+	// and re-issue those whose files have gone missing.
+	SelfHealMounts bool
+
+	// Interval to check the IPs of the pods of volumes tracking them, and
+	// re-issue their certificates should they change. Disabled if zero.
+	PodIPCheckInterval time.Duration
+
+	// Set an owner reference to the pod on each CertificateRequest so that it
+	// is garbage collected with the pod. If disabled, requests are only
+	// deleted when their volume is unpublished.
+	SetOwnerReference bool
+
+	// Endpoint of a signer plugin holding the private keys of volumes, which
+	// are then never written to disk. Keys are generated in memory if unset.
+	SignerPlugin string
+
+	// Path to a file of attribute defaults and profiles, reloaded on SIGHUP.
+	ProfilesFile string
+
+	// Path to a file of the issuers the pods of each namespace may request
+	// from, reloaded on SIGHUP. All issuers are allowed if unset.
+	IssuerPolicyFile string
+
+	// Path to a file of the domains the pods of each namespace may request
+	// DNS names and URI SANs for, reloaded on SIGHUP. All domains are allowed
+	// if unset.
+	SANPolicyFile string
+
+	// Reject volumes requesting DNS names or URI SANs other than those of
+	// their pod's own identity.
+	EnforcePodIdentitySANs bool
+
+	// DNS domain of the cluster, the only domain pod DNS names may end with
+	// under EnforcePodIdentitySANs.
+	ClusterDomain string
+
+	// Go template of the name recorded in the metadata of each volume,
+	// rendered with the metadata of its pod. Names are built as before if
+	// empty.
+	VolumeNameTemplate string
+
+	// Label set to "true" on every CertificateRequest created by the driver,
+	// so that approval policies can identify them.
+	ManagedLabelKey string
+
+	// Maximum number of subject alternative names a volume may request.
+	// Unlimited if zero.
+	MaxSANs int
+
+	// Audiences volumes may request pod service account tokens for. Service
+	// account tokens are disabled if empty.
+	TokenAudiences []string
+
+	// Directory on the node, shared with workloads, that CSRs provided by
+	// volumes are read from. Workload provided CSRs are disabled if empty.
+	CSRDir string
+
+	// Directory on the node, shared with workloads, that the common name and
+	// SANs of volumes may be read from at issuance. Reading request fields
+	// from files is disabled if empty.
+	DownwardAPIDir string
+
+	// Maximum time to wait for a file of the downward API directory to be
+	// written. Files are not waited for if zero.
+	DownwardAPIFileTimeout time.Duration
+
+	// Namespaces whose pods this driver serves. All namespaces are served if
+	// empty.
+	WatchNamespaces []string
+
+	// Maximum size in bytes of gRPC messages the server will receive and
+	// send. The gRPC defaults are used if zero.
+	GRPCMaxRecvMsgSize int
+	GRPCMaxSendMsgSize int
+
+	// Interval after which the server pings an idle client, and how long it
+	// waits for the ping to be acknowledged before closing the connection.
+	// Keepalive pings are disabled if the time is zero.
+	GRPCKeepaliveTime    time.Duration
+	GRPCKeepaliveTimeout time.Duration
 }
 
 func AddFlags(cmd *cobra.Command) *Options {
 	var opts Options
 
-	cmd.PersistentFlags().StringVar(&opts.NodeID, "node-id", "", "node ID")
-	cmd.MarkPersistentFlagRequired("node-id")
+	cmd.Flags().StringVar(&opts.NodeID, "node-id", "", "node ID")
+	cmd.MarkFlagRequired("node-id")
 
-	cmd.PersistentFlags().StringVar(&opts.Endpoint, "endpoint", "", "CSI endpoint")
-	cmd.MarkPersistentFlagRequired("endpoint")
+	cmd.Flags().StringVar(&opts.Endpoint, "endpoint", "", "CSI endpoint")
+	cmd.MarkFlagRequired("endpoint")
 
-	cmd.PersistentFlags().StringVar(&opts.DriverName, "driver-name",
+	cmd.Flags().StringVar(&opts.DriverName, "driver-name",
 		"csi.cert-manager.io", "name of the driver")
 
-	cmd.PersistentFlags().StringVar(&opts.DataRoot, "data-root",
+	cmd.Flags().StringVar(&opts.DataRoot, "data-root",
 		"/csi-data-dir", "directory to store ephemeral data")
 
-	cmd.PersistentFlags().StringVar(&opts.TmpfsSize, "tmpfs-size",
+	cmd.Flags().StringToStringVar(&opts.DataRootMap, "data-root-map",
+		nil, "named data roots, as name=path, that volumes may select with the csi.cert-manager.io/data-root attribute")
+
+	cmd.Flags().StringVar(&opts.TmpfsSize, "tmpfs-size",
 		"100", "size in Mbytes to create the tmpfs file system to store ephemeral data")
 
+	cmd.Flags().StringVar(&opts.MetricsBindAddress, "metrics-bind-address",
+		"", "address to serve Prometheus metrics on, disabled if empty")
+
+	cmd.Flags().StringVar(&opts.AdminBindAddress, "admin-bind-address",
+		"", "address to serve the admin endpoints, such as /volumes, on, disabled if empty")
+
+	cmd.Flags().BoolVar(&opts.MetricsHighCardinality, "metrics-high-cardinality",
+		false, "include the namespace of volumes as a label of issuance metrics")
+
+	cmd.Flags().StringVar(&opts.PostIssueHook, "post-issue-hook",
+		"", "path to an executable to run after each certificate issuance or renewal")
+
+	cmd.Flags().DurationVar(&opts.PostIssueHookTimeout, "post-issue-hook-timeout",
+		time.Second*30, "maximum time the post issue hook may run before it is killed")
+
+	cmd.Flags().StringVar(&opts.SecurityLevel, "security-level",
+		LegacySecurityLevel, "key defaults and policy of volumes, one of legacy, modern or fips, overridden by --min-rsa-key-size, --allowed-ecdsa-curves and --fips-mode")
+
+	cmd.Flags().BoolVar(&opts.FIPSMode, "fips-mode",
+		false, "only allow FIPS approved key algorithms and sizes to be requested")
+
+	cmd.Flags().IntVar(&opts.MinRSAKeySize, "min-rsa-key-size",
+		2048, "minimum size of rsa keys that may be requested")
+
+	cmd.Flags().StringSliceVar(&opts.AllowedECDSACurves, "allowed-ecdsa-curves",
+		[]string{"P-256", "P-384", "P-521"}, "ecdsa curves that keys may be requested with")
+
+	cmd.Flags().StringSliceVar(&opts.AllowedCertificateTypes, "allowed-certificate-types",
+		nil, "types of certificate volumes may request, of client, server or peer, any type may be requested if unset")
+
+	cmd.Flags().Var(newFileModeValue(0700, &opts.DirPermissions), "dir-permissions",
+		"octal permissions used when creating volume and mount directories")
+
+	cmd.Flags().Var(newFileModeValue(0700, &opts.TargetPathPermissions), "target-path-permissions",
+		"octal permissions applied to the target path directory created on publish")
+
+	cmd.Flags().BoolVar(&opts.MetadataInMount, "metadata-in-mount",
+		false, "also write a copy of the volume metadata file into the application mount")
+
+	cmd.Flags().BoolVar(&opts.FsyncFiles, "fsync-files",
+		false, "sync volume files to disk after they are written so that they survive a node crash, unnecessary if the data root is on tmpfs")
+
+	cmd.Flags().StringSliceVar(&opts.MountOptions, "mount-options",
+		nil, "comma separated options added to the read only bind mount of volumes, such as nosuid,nodev,noexec or a mount propagation")
+
+	cmd.Flags().BoolVar(&opts.AtomicDirLayout, "atomic-dir-layout",
+		false, "write volume files through a ..data symlink swapped atomically on every update, as Secret volumes do")
+
+	cmd.Flags().StringVar(&opts.DefaultCertFilename, "default-cert-filename",
+		"", "file name of the certificate of volumes that don't set csi.cert-manager.io/certificate-file, defaults to that of their encoding and OS profile if unset")
+
+	cmd.Flags().StringVar(&opts.DefaultKeyFilename, "default-key-filename",
+		"", "file name of the private key of volumes that don't set csi.cert-manager.io/privatekey-file, defaults to that of their encoding and OS profile if unset")
+
+	cmd.Flags().StringVar(&opts.DefaultCAFilename, "default-ca-filename",
+		"", "file name of the CA of volumes that don't set csi.cert-manager.io/ca-file, defaults to that of their encoding and OS profile if unset")
+
+	cmd.Flags().BoolVar(&opts.ReissueOnRequestDeletion, "reissue-on-request-deletion",
+		false, "watch CertificateRequests and re-issue certificates of volumes whose request has been deleted")
+
+	cmd.Flags().BoolVar(&opts.PrewarmCertificateRequests, "prewarm-certificate-requests",
+		false, "watch pods scheduled to the node and create the CertificateRequests of their volumes before they are published")
+
+	cmd.Flags().BoolVar(&opts.EnableIssuanceCache, "enable-issuance-cache",
+		false, "share the certificate and private key issued for a volume with further volumes of the same attributes and namespace, rather than creating a request for each")
+
+	cmd.Flags().DurationVar(&opts.IssuanceCacheTTL, "issuance-cache-ttl",
+		time.Minute*5, "how long an issued certificate is shared with further volumes of the same attributes and namespace, with --enable-issuance-cache")
+
+	cmd.Flags().BoolVar(&opts.RespectIssuerDuration, "respect-issuer-duration",
+		false, "leave the certificate duration to the issuer if a volume does not request one, rather than defaulting it")
+
+	cmd.Flags().DurationVar(&opts.IssuanceTimeout, "issuance-timeout",
+		time.Second*30, "maximum time to wait for a CertificateRequest to become ready")
+
+	cmd.Flags().DurationVar(&opts.IssuanceDeadline, "issuance-deadline",
+		0, "total time to keep waiting on the same pending CertificateRequest across retried publishes of a volume before re-creating it, disabled if zero")
+
+	cmd.Flags().StringVar(&opts.ChainRootCAFile, "chain-root-ca-file",
+		"", "path to a PEM encoded root CA to append to certificate chain files if not already present")
+
+	cmd.Flags().DurationVar(&opts.OrphanGCGrace, "orphan-gc-grace",
+		time.Minute*5, "time to wait after startup before removing volume directories that are no longer mounted, disabled if zero")
+
+	cmd.Flags().DurationVar(&opts.UnpublishGrace, "unpublish-grace",
+		0, "time to wait after a volume is unpublished before removing its directory, reusing its certificate if republished in the meantime, disabled if zero")
+
+	cmd.Flags().BoolVar(&opts.PrecheckIssuer, "precheck-issuer",
+		false, "fail fast if the cert-manager Issuer or ClusterIssuer of a volume is not Ready, rather than waiting for the request to time out")
+
+	cmd.Flags().DurationVar(&opts.MaxIdentityAge, "max-identity-age",
+		0, "maximum age of a volume's private key, after which it is rotated on the next renewal regardless of reuse-private-key, disabled if zero")
+
+	cmd.Flags().DurationVar(&opts.DiscoverTimeout, "discover-timeout",
+		time.Minute, "maximum time to retry discovering existing volumes at startup before serving")
+
+	cmd.Flags().IntVar(&opts.DiscoverConcurrency, "discover-concurrency",
+		renew.DefaultDiscoverConcurrency, "number of existing volumes read at once during discovery at startup")
+
+	cmd.Flags().BoolVar(&opts.VerifyIssuedCert, "verify-issued-cert",
+		true, "fail issuance if the certificate is not of the requested key, or is missing a requested name")
+
+	cmd.Flags().BoolVar(&opts.LogCertDetails, "log-cert-details",
+		false, "log the subject and SANs of each issued certificate for audit")
+
+	cmd.Flags().IntVar(&opts.LogCertDetailsLevel, "log-cert-details-level",
+		0, "log verbosity level to log certificate details at, with --log-cert-details")
+
+	cmd.Flags().DurationVar(&opts.RenewRetryMaxBackoff, "renew-retry-max-backoff",
+		renew.DefaultRetryMaxBackoff, "maximum backoff between failed renewal attempts of a certificate")
+
+	cmd.Flags().Float64Var(&opts.AutoRenewBeforeFraction, "auto-renew-before-fraction",
+		renew.DefaultAutoRenewBeforeFraction, "fraction of a certificate's lifetime remaining when it is renewed, if the volume sets no renew-before, renew-at or renew-schedule")
+
+	cmd.Flags().BoolVar(&opts.DisableAutoRenew, "disable-auto-renew",
+		false, "globally disable the renewal of every certificate, such as during issuer maintenance, holding due renewals until re-enabled")
+
+	cmd.Flags().StringVar(&opts.DisableAutoRenewFile, "disable-auto-renew-file",
+		"", "path to a file which, while it exists, globally disables auto renewal as --disable-auto-renew does, checked at startup and on SIGHUP")
+
+	cmd.Flags().DurationVar(&opts.ReconcileAttributesInterval, "reconcile-attributes-interval",
+		0, "interval to reconcile the attributes ConfigMaps of live volumes, disabled if zero")
+
+	cmd.Flags().BoolVar(&opts.ReissueOnAttributeChange, "reissue-on-attribute-change",
+		false, "re-issue certificates when reconciled attributes change more than how they are renewed")
+
+	cmd.Flags().DurationVar(&opts.ReconcileFilePermsInterval, "reconcile-file-perms-interval",
+		0, "interval to reset the permissions and ownership of the data files of watched volumes if they have drifted, disabled if zero")
+
+	cmd.Flags().BoolVar(&opts.SelfHealMounts, "self-heal-mounts",
+		false, "periodically re-mount the data root and watched volumes whose target path is no longer mounted, and re-issue those whose files have gone missing")
+
+	cmd.Flags().DurationVar(&opts.PodIPCheckInterval, "pod-ip-check-interval",
+		0, "interval to check the IPs of the pods of volumes with track-pod-ip set and re-issue their certificates should they change, disabled if zero")
+
+	cmd.Flags().StringVar(&opts.SignerPlugin, "signer-plugin",
+		"", "unix:// endpoint of a plugin holding the private keys of volumes and signing their CSRs, keys are generated in memory if unset")
+
+	cmd.Flags().BoolVar(&opts.SetOwnerReference, "set-owner-reference",
+		true, "set an owner reference to the pod on each CertificateRequest, if disabled requests are only deleted when their volume is unpublished")
+
+	cmd.Flags().StringVar(&opts.ProfilesFile, "profiles-file",
+		"", "path to a JSON file of volume attribute defaults and profiles, reloaded on SIGHUP")
+
+	cmd.Flags().StringVar(&opts.IssuerPolicyFile, "issuer-policy-file",
+		"", "path to a JSON file of the issuers the pods of each namespace may request from, reloaded on SIGHUP")
+
+	cmd.Flags().StringVar(&opts.SANPolicyFile, "san-policy-file",
+		"", "path to a JSON file of the domains the pods of each namespace may request DNS names and URI SANs for, reloaded on SIGHUP")
+
+	cmd.Flags().BoolVar(&opts.EnforcePodIdentitySANs, "enforce-pod-identity-sans",
+		false, "reject volumes requesting DNS names other than the pod's name and namespace, or URI SANs other than the SPIFFE ID of its service account")
+
+	cmd.Flags().StringVar(&opts.ClusterDomain, "cluster-domain",
+		"cluster.local", "DNS domain of the cluster, the only domain pod DNS names may end with under --enforce-pod-identity-sans")
+
+	cmd.Flags().StringVar(&opts.VolumeNameTemplate, "volume-name-template",
+		util.DefaultVolumeNameTemplate, "Go template of the name of each volume, rendered with .PodName, .Namespace, .PodUID, .ServiceAccountName, .NodeID and .VolumeID")
+
+	cmd.Flags().StringVar(&opts.ManagedLabelKey, "managed-label-key",
+		DefaultManagedLabelKey, "label set to \"true\" on every CertificateRequest created by the driver")
+
+	cmd.Flags().IntVar(&opts.MaxSANs, "max-sans",
+		100, "maximum number of DNS, IP and URI subject alternative names a volume may request, unlimited if zero")
+
+	cmd.Flags().StringSliceVar(&opts.TokenAudiences, "token-audience",
+		nil, "audience volumes may request pod service account tokens for, may be repeated, the first is used by default")
+
+	cmd.Flags().StringVar(&opts.CSRDir, "csr-dir",
+		"", "directory shared with workloads that csr-file attributes are read from, workload provided CSRs are disabled if empty")
+
+	cmd.Flags().StringVar(&opts.DownwardAPIDir, "downward-api-dir",
+		"", "directory shared with workloads that *-from-file attributes are read from, reading request fields from files is disabled if empty")
+
+	cmd.Flags().DurationVar(&opts.DownwardAPIFileTimeout, "downward-api-file-timeout",
+		time.Second*10, "maximum time to wait for a *-from-file attribute's file to be written, not waited for if zero")
+
+	cmd.Flags().StringSliceVar(&opts.WatchNamespaces, "watch-namespaces",
+		nil, "comma separated namespaces whose pods this driver serves, all namespaces if empty")
+
+	cmd.Flags().IntVar(&opts.GRPCMaxRecvMsgSize, "grpc-max-recv-msg-size",
+		0, "maximum size in bytes of gRPC messages received, gRPC default if zero")
+
+	cmd.Flags().IntVar(&opts.GRPCMaxSendMsgSize, "grpc-max-send-msg-size",
+		0, "maximum size in bytes of gRPC messages sent, gRPC default if zero")
+
+	cmd.Flags().DurationVar(&opts.GRPCKeepaliveTime, "grpc-keepalive-time",
+		0, "interval after which an idle gRPC connection is pinged, disabled if zero")
+
+	cmd.Flags().DurationVar(&opts.GRPCKeepaliveTimeout, "grpc-keepalive-timeout",
+		time.Second*20, "time to wait for a gRPC keepalive ping to be acknowledged before closing the connection")
+
 	return &opts
 }
+
+// Validate returns an error if any of the given options are invalid.
+func (o *Options) Validate() error {
+	var errs []string
+
+	if o.PostIssueHookTimeout <= 0 {
+		errs = append(errs, fmt.Sprintf("post-issue-hook-timeout must be greater than zero, got %s",
+			o.PostIssueHookTimeout))
+	}
+
+	if o.IssuanceTimeout <= 0 {
+		errs = append(errs, fmt.Sprintf("issuance-timeout must be greater than zero, got %s",
+			o.IssuanceTimeout))
+	}
+
+	if o.MaxIdentityAge < 0 {
+		errs = append(errs, fmt.Sprintf("max-identity-age may not be negative, got %s",
+			o.MaxIdentityAge))
+	}
+
+	if o.IssuanceDeadline < 0 {
+		errs = append(errs, fmt.Sprintf("issuance-deadline may not be negative, got %s",
+			o.IssuanceDeadline))
+	}
+
+	if o.DiscoverTimeout < 0 {
+		errs = append(errs, fmt.Sprintf("discover-timeout may not be negative, got %s",
+			o.DiscoverTimeout))
+	}
+
+	if o.DownwardAPIFileTimeout < 0 {
+		errs = append(errs, fmt.Sprintf("downward-api-file-timeout may not be negative, got %s",
+			o.DownwardAPIFileTimeout))
+	}
+
+	if o.UnpublishGrace < 0 {
+		errs = append(errs, fmt.Sprintf("unpublish-grace may not be negative, got %s",
+			o.UnpublishGrace))
+	}
+
+	if o.EnforcePodIdentitySANs {
+		for _, msg := range k8svalidation.IsDNS1123Subdomain(o.ClusterDomain) {
+			errs = append(errs, fmt.Sprintf("cluster-domain %q is invalid: %s",
+				o.ClusterDomain, msg))
+		}
+	}
+
+	if o.DiscoverConcurrency < 1 {
+		errs = append(errs, fmt.Sprintf("discover-concurrency must be at least 1, got %d",
+			o.DiscoverConcurrency))
+	}
+
+	if o.RenewRetryMaxBackoff <= 0 {
+		errs = append(errs, fmt.Sprintf("renew-retry-max-backoff must be greater than zero, got %s",
+			o.RenewRetryMaxBackoff))
+	}
+
+	if o.AutoRenewBeforeFraction <= 0 || o.AutoRenewBeforeFraction >= 1 {
+		errs = append(errs, fmt.Sprintf("auto-renew-before-fraction must be between 0 and 1 exclusive, got %g",
+			o.AutoRenewBeforeFraction))
+	}
+
+	errs = o.validateDataRootMap(errs)
+
+	if o.ReconcileAttributesInterval < 0 {
+		errs = append(errs, fmt.Sprintf("reconcile-attributes-interval may not be negative, got %s",
+			o.ReconcileAttributesInterval))
+	}
+
+	if o.EnableIssuanceCache && o.IssuanceCacheTTL <= 0 {
+		errs = append(errs, fmt.Sprintf("issuance-cache-ttl must be positive with --enable-issuance-cache, got %s",
+			o.IssuanceCacheTTL))
+	}
+
+	if len(o.VolumeNameTemplate) > 0 {
+		if _, err := util.ParseVolumeNameTemplate(o.VolumeNameTemplate); err != nil {
+			errs = append(errs, fmt.Sprintf("volume-name-template is invalid: %s", err))
+		}
+	}
+
+	if o.ReconcileFilePermsInterval < 0 {
+		errs = append(errs, fmt.Sprintf("reconcile-file-perms-interval may not be negative, got %s",
+			o.ReconcileFilePermsInterval))
+	}
+
+	if o.PodIPCheckInterval < 0 {
+		errs = append(errs, fmt.Sprintf("pod-ip-check-interval may not be negative, got %s",
+			o.PodIPCheckInterval))
+	}
+
+	for _, f := range []struct{ flag, name string }{
+		{"default-cert-filename", o.DefaultCertFilename},
+		{"default-key-filename", o.DefaultKeyFilename},
+		{"default-ca-filename", o.DefaultCAFilename},
+	} {
+		if strings.Contains(f.name, "..") || filepath.IsAbs(f.name) {
+			errs = append(errs, fmt.Sprintf("%s must be a path relative to the volume that doesn't contain '..', got %q",
+				f.flag, f.name))
+		}
+	}
+
+	if o.DirPermissions&0002 != 0 {
+		errs = append(errs, fmt.Sprintf("dir-permissions may not be world writable, got %#o",
+			uint32(o.DirPermissions)))
+	}
+
+	if o.TargetPathPermissions&0002 != 0 {
+		errs = append(errs, fmt.Sprintf("target-path-permissions may not be world writable, got %#o",
+			uint32(o.TargetPathPermissions)))
+	}
+
+	for _, msg := range k8svalidation.IsQualifiedName(o.ManagedLabelKey) {
+		errs = append(errs, fmt.Sprintf("managed-label-key %q is invalid: %s",
+			o.ManagedLabelKey, msg))
+	}
+
+	if _, ok := securityLevels[o.SecurityLevel]; !ok && len(o.SecurityLevel) > 0 {
+		errs = append(errs, fmt.Sprintf("security-level must be one of %q, %q or %q, got %q",
+			LegacySecurityLevel, ModernSecurityLevel, FIPSSecurityLevel, o.SecurityLevel))
+	}
+
+	if o.MinRSAKeySize != 0 && (o.MinRSAKeySize < 2048 || o.MinRSAKeySize > 8192) {
+		errs = append(errs, fmt.Sprintf("min-rsa-key-size must be between 2048 and 8192, got %d",
+			o.MinRSAKeySize))
+	}
+
+	for _, curve := range o.AllowedECDSACurves {
+		if curve != "P-256" && curve != "P-384" && curve != "P-521" {
+			errs = append(errs, fmt.Sprintf("allowed-ecdsa-curves must be of \"P-256\", \"P-384\" or \"P-521\", got %q",
+				curve))
+		}
+	}
+
+	for _, certType := range o.AllowedCertificateTypes {
+		if certType != "client" && certType != "server" && certType != "peer" {
+			errs = append(errs, fmt.Sprintf("allowed-certificate-types must be of \"client\", \"server\" or \"peer\", got %q",
+				certType))
+		}
+	}
+
+	if len(o.AdminBindAddress) > 0 && o.AdminBindAddress == o.MetricsBindAddress {
+		errs = append(errs, fmt.Sprintf("admin-bind-address must differ from metrics-bind-address, got %q",
+			o.AdminBindAddress))
+	}
+
+	if o.LogCertDetailsLevel < 0 {
+		errs = append(errs, fmt.Sprintf("log-cert-details-level may not be negative, got %d",
+			o.LogCertDetailsLevel))
+	}
+
+	if o.MaxSANs < 0 {
+		errs = append(errs, fmt.Sprintf("max-sans may not be negative, got %d",
+			o.MaxSANs))
+	}
+
+	for _, aud := range o.TokenAudiences {
+		if len(strings.TrimSpace(aud)) == 0 {
+			errs = append(errs, "token-audience may not be empty")
+			break
+		}
+	}
+
+	for _, ns := range o.WatchNamespaces {
+		for _, msg := range k8svalidation.IsDNS1123Label(ns) {
+			errs = append(errs, fmt.Sprintf("watch-namespaces %q is invalid: %s", ns, msg))
+		}
+	}
+
+	for _, opt := range o.MountOptions {
+		switch {
+		case opt == "rw":
+			errs = append(errs, "mount-options may not include rw, volumes are always mounted read only")
+		case !allowedMountOptions[opt]:
+			errs = append(errs, fmt.Sprintf("mount-options %q is not allowed", opt))
+		}
+	}
+
+	if len(o.SignerPlugin) > 0 {
+		if _, err := signer.ParseEndpoint(o.SignerPlugin); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if o.GRPCMaxRecvMsgSize < 0 {
+		errs = append(errs, fmt.Sprintf("grpc-max-recv-msg-size may not be negative, got %d",
+			o.GRPCMaxRecvMsgSize))
+	}
+
+	if o.GRPCMaxSendMsgSize < 0 {
+		errs = append(errs, fmt.Sprintf("grpc-max-send-msg-size may not be negative, got %d",
+			o.GRPCMaxSendMsgSize))
+	}
+
+	if o.GRPCKeepaliveTime < 0 {
+		errs = append(errs, fmt.Sprintf("grpc-keepalive-time may not be negative, got %s",
+			o.GRPCKeepaliveTime))
+	}
+
+	if o.GRPCKeepaliveTime > 0 && o.GRPCKeepaliveTimeout <= 0 {
+		errs = append(errs, fmt.Sprintf("grpc-keepalive-timeout must be greater than zero when keepalive is enabled, got %s",
+			o.GRPCKeepaliveTimeout))
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// validateDataRootMap errors if a named data root has an invalid name, is not
+// an absolute path, or is shared with another data root.
+func (o *Options) validateDataRootMap(errs []string) []string {
+	var names []string
+	for name := range o.DataRootMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	roots := map[string]string{
+		filepath.Clean(o.DataRoot): "--data-root",
+	}
+
+	for _, name := range names {
+		path := o.DataRootMap[name]
+
+		for _, msg := range k8svalidation.IsDNS1123Label(name) {
+			errs = append(errs, fmt.Sprintf("data-root-map name %q is invalid: %s", name, msg))
+		}
+
+		if !filepath.IsAbs(path) {
+			errs = append(errs, fmt.Sprintf("data-root-map %q must be an absolute path, got %q", name, path))
+			continue
+		}
+
+		if other, ok := roots[filepath.Clean(path)]; ok {
+			errs = append(errs, fmt.Sprintf("data-root-map %q may not use the same path as %s, got %q", name, other, path))
+			continue
+		}
+
+		roots[filepath.Clean(path)] = fmt.Sprintf("data-root-map %q", name)
+	}
+
+	return errs
+}
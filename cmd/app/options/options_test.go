@@ -0,0 +1,418 @@
+package options
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestValidateMountOptions(t *testing.T) {
+	for name, test := range map[string]struct {
+		mountOptions []string
+		expErr       string
+	}{
+		"no mount options should not error": {
+			nil,
+			"",
+		},
+		"allowed mount options should not error": {
+			[]string{"nosuid", "nodev", "noexec", "rprivate"},
+			"",
+		},
+		"rw should error": {
+			[]string{"nosuid", "rw"},
+			"mount-options may not include rw, volumes are always mounted read only",
+		},
+		"an unknown mount option should error": {
+			[]string{"suid"},
+			`mount-options "suid" is not allowed`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := &Options{
+				PostIssueHookTimeout:    time.Second,
+				IssuanceTimeout:         time.Second,
+				ManagedLabelKey:         DefaultManagedLabelKey,
+				RenewRetryMaxBackoff:    time.Minute,
+				DiscoverConcurrency:     1,
+				MountOptions:            test.mountOptions,
+				AutoRenewBeforeFraction: 0.5,
+			}
+
+			err := opts.Validate()
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateAutoRenewBeforeFraction(t *testing.T) {
+	for name, test := range map[string]struct {
+		fraction float64
+		expErr   string
+	}{
+		"a fraction between 0 and 1 should not error": {
+			1.0 / 3,
+			"",
+		},
+		"zero should error": {
+			0,
+			"auto-renew-before-fraction must be between 0 and 1 exclusive, got 0",
+		},
+		"one should error": {
+			1,
+			"auto-renew-before-fraction must be between 0 and 1 exclusive, got 1",
+		},
+		"a negative fraction should error": {
+			-0.5,
+			"auto-renew-before-fraction must be between 0 and 1 exclusive, got -0.5",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := &Options{
+				PostIssueHookTimeout:    time.Second,
+				IssuanceTimeout:         time.Second,
+				ManagedLabelKey:         DefaultManagedLabelKey,
+				RenewRetryMaxBackoff:    time.Minute,
+				DiscoverConcurrency:     1,
+				AutoRenewBeforeFraction: test.fraction,
+			}
+
+			err := opts.Validate()
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateDataRootMap(t *testing.T) {
+	for name, test := range map[string]struct {
+		dataRootMap map[string]string
+		expErr      string
+	}{
+		"no data root map should not error": {
+			nil,
+			"",
+		},
+		"distinct absolute data roots should not error": {
+			map[string]string{"encrypted": "/var/lib/csi-encrypted", "tmpfs": "/csi-tmpfs"},
+			"",
+		},
+		"an invalid name should error": {
+			map[string]string{"Encrypted": "/var/lib/csi-encrypted"},
+			`data-root-map name "Encrypted" is invalid`,
+		},
+		"a relative path should error": {
+			map[string]string{"encrypted": "csi-encrypted"},
+			`data-root-map "encrypted" must be an absolute path, got "csi-encrypted"`,
+		},
+		"the path of the default data root should error": {
+			map[string]string{"encrypted": "/csi-data-dir/"},
+			`data-root-map "encrypted" may not use the same path as --data-root, got "/csi-data-dir/"`,
+		},
+		"a shared path should error": {
+			map[string]string{"a": "/var/lib/csi", "b": "/var/lib/csi"},
+			`data-root-map "b" may not use the same path as data-root-map "a", got "/var/lib/csi"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := &Options{
+				DataRoot:    "/csi-data-dir",
+				DataRootMap: test.dataRootMap,
+			}
+
+			errs := opts.validateDataRootMap(nil)
+			if len(test.expErr) == 0 {
+				if len(errs) > 0 {
+					t.Errorf("unexpected error: %s", errs)
+				}
+				return
+			}
+
+			if !strings.Contains(strings.Join(errs, ", "), test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, errs)
+			}
+		})
+	}
+}
+
+func TestValidateVolumeNameTemplate(t *testing.T) {
+	for name, test := range map[string]struct {
+		template string
+		expErr   string
+	}{
+		"no template should not error": {
+			"",
+			"",
+		},
+		"a valid template should not error": {
+			"{{.Namespace}}-{{.PodName}}-{{.VolumeID}}",
+			"",
+		},
+		"a template of an unknown field should error": {
+			"{{.Pod}}",
+			"volume-name-template is invalid",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := &Options{
+				PostIssueHookTimeout:    time.Second,
+				IssuanceTimeout:         time.Second,
+				ManagedLabelKey:         DefaultManagedLabelKey,
+				RenewRetryMaxBackoff:    time.Minute,
+				DiscoverConcurrency:     1,
+				AutoRenewBeforeFraction: 0.5,
+				VolumeNameTemplate:      test.template,
+			}
+
+			err := opts.Validate()
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateDefaultFilenames(t *testing.T) {
+	for name, test := range map[string]struct {
+		certFilename string
+		caFilename   string
+		expErr       string
+	}{
+		"no default file names should not error": {
+			"",
+			"",
+			"",
+		},
+		"relative default file names should not error": {
+			"tls.crt",
+			"certs/ca.crt",
+			"",
+		},
+		"a default file name leaving the volume should error": {
+			"../tls.crt",
+			"",
+			"default-cert-filename must be a path relative to the volume",
+		},
+		"an absolute default file name should error": {
+			"",
+			"/etc/ca.crt",
+			"default-ca-filename must be a path relative to the volume",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := &Options{
+				PostIssueHookTimeout:    time.Second,
+				IssuanceTimeout:         time.Second,
+				ManagedLabelKey:         DefaultManagedLabelKey,
+				RenewRetryMaxBackoff:    time.Minute,
+				DiscoverConcurrency:     1,
+				AutoRenewBeforeFraction: 0.5,
+				DefaultCertFilename:     test.certFilename,
+				DefaultCAFilename:       test.caFilename,
+			}
+
+			err := opts.Validate()
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateAllowedCertificateTypes(t *testing.T) {
+	for name, test := range map[string]struct {
+		certTypes []string
+		expErr    string
+	}{
+		"no types should not error": {
+			nil,
+			"",
+		},
+		"known types should not error": {
+			[]string{"client", "server", "peer"},
+			"",
+		},
+		"an unknown type should error": {
+			[]string{"client", "mtls"},
+			`allowed-certificate-types must be of "client", "server" or "peer", got "mtls"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := &Options{
+				PostIssueHookTimeout:    time.Second,
+				IssuanceTimeout:         time.Second,
+				ManagedLabelKey:         DefaultManagedLabelKey,
+				RenewRetryMaxBackoff:    time.Minute,
+				DiscoverConcurrency:     1,
+				AutoRenewBeforeFraction: 0.5,
+				AllowedCertificateTypes: test.certTypes,
+			}
+
+			err := opts.Validate()
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
+func TestGRPCFlags(t *testing.T) {
+	for name, test := range map[string]struct {
+		args                []string
+		expMaxRecvMsgSize   int
+		expMaxSendMsgSize   int
+		expKeepaliveTime    time.Duration
+		expKeepaliveTimeout time.Duration
+	}{
+		"no flags should use the gRPC defaults": {
+			args:                nil,
+			expKeepaliveTimeout: time.Second * 20,
+		},
+		"message sizes should be set": {
+			args: []string{
+				"--grpc-max-recv-msg-size=8388608",
+				"--grpc-max-send-msg-size=1048576",
+			},
+			expMaxRecvMsgSize:   8388608,
+			expMaxSendMsgSize:   1048576,
+			expKeepaliveTimeout: time.Second * 20,
+		},
+		"keepalive should be set": {
+			args: []string{
+				"--grpc-keepalive-time=1m",
+				"--grpc-keepalive-timeout=5s",
+			},
+			expKeepaliveTime:    time.Minute,
+			expKeepaliveTimeout: time.Second * 5,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			opts := AddFlags(cmd)
+
+			if err := cmd.ParseFlags(test.args); err != nil {
+				t.Fatal(err)
+			}
+
+			if opts.GRPCMaxRecvMsgSize != test.expMaxRecvMsgSize {
+				t.Errorf("unexpected max recv msg size, exp=%d got=%d",
+					test.expMaxRecvMsgSize, opts.GRPCMaxRecvMsgSize)
+			}
+
+			if opts.GRPCMaxSendMsgSize != test.expMaxSendMsgSize {
+				t.Errorf("unexpected max send msg size, exp=%d got=%d",
+					test.expMaxSendMsgSize, opts.GRPCMaxSendMsgSize)
+			}
+
+			if opts.GRPCKeepaliveTime != test.expKeepaliveTime {
+				t.Errorf("unexpected keepalive time, exp=%s got=%s",
+					test.expKeepaliveTime, opts.GRPCKeepaliveTime)
+			}
+
+			if opts.GRPCKeepaliveTimeout != test.expKeepaliveTimeout {
+				t.Errorf("unexpected keepalive timeout, exp=%s got=%s",
+					test.expKeepaliveTimeout, opts.GRPCKeepaliveTimeout)
+			}
+		})
+	}
+}
+
+func TestValidateGRPCOptions(t *testing.T) {
+	for name, test := range map[string]struct {
+		maxRecvMsgSize   int
+		maxSendMsgSize   int
+		keepaliveTime    time.Duration
+		keepaliveTimeout time.Duration
+		expErr           string
+	}{
+		"the gRPC defaults should not error": {
+			keepaliveTimeout: time.Second * 20,
+		},
+		"a negative max recv msg size should error": {
+			maxRecvMsgSize: -1,
+			expErr:         "grpc-max-recv-msg-size may not be negative, got -1",
+		},
+		"a negative max send msg size should error": {
+			maxSendMsgSize: -1,
+			expErr:         "grpc-max-send-msg-size may not be negative, got -1",
+		},
+		"a negative keepalive time should error": {
+			keepaliveTime: -time.Second,
+			expErr:        "grpc-keepalive-time may not be negative, got -1s",
+		},
+		"keepalive without a timeout should error": {
+			keepaliveTime: time.Minute,
+			expErr:        "grpc-keepalive-timeout must be greater than zero when keepalive is enabled, got 0s",
+		},
+		"a keepalive timeout without keepalive should not error": {
+			keepaliveTimeout: 0,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := &Options{
+				PostIssueHookTimeout:    time.Second,
+				IssuanceTimeout:         time.Second,
+				ManagedLabelKey:         DefaultManagedLabelKey,
+				RenewRetryMaxBackoff:    time.Minute,
+				DiscoverConcurrency:     1,
+				AutoRenewBeforeFraction: 0.5,
+				GRPCMaxRecvMsgSize:      test.maxRecvMsgSize,
+				GRPCMaxSendMsgSize:      test.maxSendMsgSize,
+				GRPCKeepaliveTime:       test.keepaliveTime,
+				GRPCKeepaliveTimeout:    test.keepaliveTimeout,
+			}
+
+			err := opts.Validate()
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package options
+
+import (
+	"github.com/spf13/cobra"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+const (
+	// LegacySecurityLevel defaults to RSA 2048 bit keys, and allows any key
+	// of at least that strength.
+	LegacySecurityLevel = "legacy"
+
+	// ModernSecurityLevel defaults to ECDSA P-256 keys, and requires RSA keys
+	// of at least 3072 bits.
+	ModernSecurityLevel = "modern"
+
+	// FIPSSecurityLevel defaults to RSA 3072 bit keys, and enables FIPS mode.
+	FIPSSecurityLevel = "fips"
+)
+
+// securityLevel holds the key defaults and policy of a security level.
+type securityLevel struct {
+	keyAlgorithm  string
+	rsaKeySize    string
+	ecdsaKeySize  string
+	minRSAKeySize int
+	allowedCurves []string
+	fipsMode      bool
+}
+
+var securityLevels = map[string]securityLevel{
+	LegacySecurityLevel: {
+		keyAlgorithm:  csiapi.RSAKeyAlgorithm,
+		rsaKeySize:    "2048",
+		ecdsaKeySize:  "256",
+		minRSAKeySize: 2048,
+		allowedCurves: []string{"P-256", "P-384", "P-521"},
+	},
+	ModernSecurityLevel: {
+		keyAlgorithm:  csiapi.ECDSAKeyAlgorithm,
+		rsaKeySize:    "3072",
+		ecdsaKeySize:  "256",
+		minRSAKeySize: 3072,
+		allowedCurves: []string{"P-256", "P-384"},
+	},
+	FIPSSecurityLevel: {
+		keyAlgorithm:  csiapi.RSAKeyAlgorithm,
+		rsaKeySize:    "3072",
+		ecdsaKeySize:  "256",
+		minRSAKeySize: 3072,
+		allowedCurves: []string{"P-256", "P-384", "P-521"},
+		fipsMode:      true,
+	},
+}
+
+// level returns the security level of the options, the legacy level if
+// unset.
+func (o *Options) level() securityLevel {
+	if level, ok := securityLevels[o.SecurityLevel]; ok {
+		return level
+	}
+
+	return securityLevels[LegacySecurityLevel]
+}
+
+// ApplySecurityLevel sets the key policy of the security level on the
+// options, unless set by its own flag.
+func (o *Options) ApplySecurityLevel(cmd *cobra.Command) {
+	level := o.level()
+
+	if !cmd.Flags().Changed("min-rsa-key-size") {
+		o.MinRSAKeySize = level.minRSAKeySize
+	}
+
+	if !cmd.Flags().Changed("allowed-ecdsa-curves") {
+		o.AllowedECDSACurves = level.allowedCurves
+	}
+
+	if !cmd.Flags().Changed("fips-mode") {
+		o.FIPSMode = level.fipsMode
+	}
+}
+
+// DefaultKeyAlgorithm returns the key algorithm of volumes that don't set
+// one, by the security level.
+func (o *Options) DefaultKeyAlgorithm() string {
+	return o.level().keyAlgorithm
+}
+
+// DefaultKeySize returns the size of keys of the given algorithm for volumes
+// that don't set one, by the security level.
+func (o *Options) DefaultKeySize(algorithm string) string {
+	switch algorithm {
+	case csiapi.RSAKeyAlgorithm:
+		return o.level().rsaKeySize
+	case csiapi.ECDSAKeyAlgorithm:
+		return o.level().ecdsaKeySize
+	default:
+		return ""
+	}
+}
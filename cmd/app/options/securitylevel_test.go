@@ -0,0 +1,103 @@
+package options
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestApplySecurityLevel(t *testing.T) {
+	for name, test := range map[string]struct {
+		args             []string
+		expMinRSAKeySize int
+		expCurves        []string
+		expFIPSMode      bool
+		expKeyAlgorithm  string
+		expRSAKeySize    string
+	}{
+		"the default level should be legacy": {
+			args:             nil,
+			expMinRSAKeySize: 2048,
+			expCurves:        []string{"P-256", "P-384", "P-521"},
+			expFIPSMode:      false,
+			expKeyAlgorithm:  "rsa",
+			expRSAKeySize:    "2048",
+		},
+		"the modern level should default to ecdsa": {
+			args:             []string{"--security-level=modern"},
+			expMinRSAKeySize: 3072,
+			expCurves:        []string{"P-256", "P-384"},
+			expFIPSMode:      false,
+			expKeyAlgorithm:  "ecdsa",
+			expRSAKeySize:    "3072",
+		},
+		"the fips level should enable fips mode": {
+			args:             []string{"--security-level=fips"},
+			expMinRSAKeySize: 3072,
+			expCurves:        []string{"P-256", "P-384", "P-521"},
+			expFIPSMode:      true,
+			expKeyAlgorithm:  "rsa",
+			expRSAKeySize:    "3072",
+		},
+		"policy flags should override the level": {
+			args: []string{"--security-level=fips", "--min-rsa-key-size=4096",
+				"--allowed-ecdsa-curves=P-384", "--fips-mode=false"},
+			expMinRSAKeySize: 4096,
+			expCurves:        []string{"P-384"},
+			expFIPSMode:      false,
+			expKeyAlgorithm:  "rsa",
+			expRSAKeySize:    "3072",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			opts := AddFlags(cmd)
+
+			if err := cmd.ParseFlags(test.args); err != nil {
+				t.Fatal(err)
+			}
+
+			opts.ApplySecurityLevel(cmd)
+
+			if opts.MinRSAKeySize != test.expMinRSAKeySize {
+				t.Errorf("unexpected min rsa key size, exp=%d got=%d", test.expMinRSAKeySize, opts.MinRSAKeySize)
+			}
+
+			if !reflect.DeepEqual(opts.AllowedECDSACurves, test.expCurves) {
+				t.Errorf("unexpected allowed curves, exp=%v got=%v", test.expCurves, opts.AllowedECDSACurves)
+			}
+
+			if opts.FIPSMode != test.expFIPSMode {
+				t.Errorf("unexpected fips mode, exp=%t got=%t", test.expFIPSMode, opts.FIPSMode)
+			}
+
+			if alg := opts.DefaultKeyAlgorithm(); alg != test.expKeyAlgorithm {
+				t.Errorf("unexpected default key algorithm, exp=%s got=%s", test.expKeyAlgorithm, alg)
+			}
+
+			if size := opts.DefaultKeySize("rsa"); size != test.expRSAKeySize {
+				t.Errorf("unexpected default rsa key size, exp=%s got=%s", test.expRSAKeySize, size)
+			}
+		})
+	}
+}
+
+func TestValidateSecurityLevel(t *testing.T) {
+	opts := &Options{
+		PostIssueHookTimeout:    time.Second,
+		IssuanceTimeout:         time.Second,
+		ManagedLabelKey:         DefaultManagedLabelKey,
+		RenewRetryMaxBackoff:    time.Minute,
+		DiscoverConcurrency:     1,
+		AutoRenewBeforeFraction: 0.5,
+		SecurityLevel:           "paranoid",
+	}
+
+	expErr := `security-level must be one of "legacy", "modern" or "fips", got "paranoid"`
+	if err := opts.Validate(); err == nil || !strings.Contains(err.Error(), expErr) {
+		t.Errorf("unexpected error, exp=%s got=%v", expErr, err)
+	}
+}
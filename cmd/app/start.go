@@ -2,11 +2,16 @@ package app
 
 import (
 	"flag"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 
 	"github.com/jetstack/cert-manager-csi/cmd/app/options"
 	"github.com/jetstack/cert-manager-csi/pkg/driver"
+	"github.com/jetstack/cert-manager-csi/pkg/metrics"
 )
 
 var (
@@ -18,7 +23,7 @@ func init() {
 
 	flag.CommandLine.Parse([]string{})
 
-	RootCmd.Flags().AddGoFlagSet(flag.CommandLine)
+	RootCmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
 
 	opts = options.AddFlags(RootCmd)
 }
@@ -27,12 +32,64 @@ var RootCmd = &cobra.Command{
 	Use:   "cert-manager-csi",
 	Short: "Container Storage Interface driver to issue certificates from Cert-Manager",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		d, err := driver.New(opts.DriverName, opts.NodeID, opts.Endpoint, opts.DataRoot, opts.TmpfsSize)
+		opts.ApplySecurityLevel(cmd)
+
+		if err := opts.Validate(); err != nil {
+			return err
+		}
+
+		if len(opts.MetricsBindAddress) > 0 {
+			go metrics.Serve(opts.MetricsBindAddress)
+		}
+
+		d, err := driver.New(opts)
 		if err != nil {
 			return err
 		}
 
+		if len(opts.AdminBindAddress) > 0 {
+			go d.NodeServer().ServeAdmin(opts.AdminBindAddress)
+		}
+
+		go reloadOnSIGHUP(d.NodeServer())
+
 		d.Run()
 		return nil
 	},
 }
+
+// reloadOnSIGHUP reloads the profiles, issuer policy and SAN policy files, and
+// the auto renewal switch, of the node server every time the process receives
+// a SIGHUP.
+func reloadOnSIGHUP(ns *driver.NodeServer) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+
+	for range sigCh {
+		glog.Infof("driver: received SIGHUP, reloading profiles, issuer policy, san policy and auto renewal switch")
+
+		if err := ns.ReloadProfiles(); err != nil {
+			glog.Errorf("driver: failed to reload profiles, keeping previous: %s", err)
+		} else {
+			glog.Infof("driver: profiles reloaded")
+		}
+
+		if err := ns.ReloadIssuerPolicy(); err != nil {
+			glog.Errorf("driver: failed to reload issuer policy, keeping previous: %s", err)
+		} else {
+			glog.Infof("driver: issuer policy reloaded")
+		}
+
+		if err := ns.ReloadSANPolicy(); err != nil {
+			glog.Errorf("driver: failed to reload san policy, keeping previous: %s", err)
+		} else {
+			glog.Infof("driver: san policy reloaded")
+		}
+
+		if err := ns.ReloadAutoRenew(); err != nil {
+			glog.Errorf("driver: failed to reload auto renewal switch, keeping previous: %s", err)
+		} else {
+			glog.Infof("driver: auto renewal switch reloaded")
+		}
+	}
+}
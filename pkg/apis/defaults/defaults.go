@@ -1,32 +1,59 @@
 package defaults
 
 import (
-	"time"
-
 	"github.com/jetstack/cert-manager/pkg/apis/certmanager"
 	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
 
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
 	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
 )
 
-func SetDefaultAttributes(attr map[string]string) (map[string]string, error) {
+func SetDefaultAttributes(attr map[string]string, opts *options.Options) (map[string]string, error) {
 	setDefaultIfEmpty(attr, csiapi.IssuerKindKey, cmapi.IssuerKind)
 	setDefaultIfEmpty(attr, csiapi.IssuerGroupKey, certmanager.GroupName)
 
+	if len(attr[csiapi.FallbackIssuerNameKey]) > 0 {
+		setDefaultIfEmpty(attr, csiapi.FallbackIssuerKindKey, cmapi.IssuerKind)
+		setDefaultIfEmpty(attr, csiapi.FallbackIssuerGroupKey, certmanager.GroupName)
+	}
+
 	setDefaultIfEmpty(attr, csiapi.IsCAKey, "false")
-	setDefaultIfEmpty(attr, csiapi.DurationKey, cmapi.DefaultCertificateDuration.String())
+	if !opts.RespectIssuerDuration {
+		setDefaultIfEmpty(attr, csiapi.DurationKey, cmapi.DefaultCertificateDuration.String())
+	}
+
+	// Key defaults follow the security level.
+	setDefaultIfEmpty(attr, csiapi.KeyAlgorithmKey, opts.DefaultKeyAlgorithm())
+	if size := opts.DefaultKeySize(attr[csiapi.KeyAlgorithmKey]); len(size) > 0 {
+		setDefaultIfEmpty(attr, csiapi.KeySizeKey, size)
+	}
+
+	setDefaultIfEmpty(attr, csiapi.EncodingKey, csiapi.PEMEncoding)
+
+	setDefaultIfEmpty(attr, csiapi.CAFileKey, opts.DefaultCAFilename)
+	setDefaultIfEmpty(attr, csiapi.CertFileKey, opts.DefaultCertFilename)
+	setDefaultIfEmpty(attr, csiapi.KeyFileKey, opts.DefaultKeyFilename)
+
+	// Windows associates the .crt extension with certificates in either
+	// encoding.
+	if attr[csiapi.OSProfileKey] == csiapi.WindowsOSProfile {
+		setDefaultIfEmpty(attr, csiapi.CAFileKey, "ca.crt")
+		setDefaultIfEmpty(attr, csiapi.CertFileKey, "tls.crt")
+		setDefaultIfEmpty(attr, csiapi.KeyFileKey, "tls.key")
+	}
+	if attr[csiapi.EncodingKey] == csiapi.DEREncoding {
+		setDefaultIfEmpty(attr, csiapi.CAFileKey, "ca.der")
+		setDefaultIfEmpty(attr, csiapi.CertFileKey, "crt.der")
+		setDefaultIfEmpty(attr, csiapi.KeyFileKey, "key.der")
+	}
 
 	setDefaultIfEmpty(attr, csiapi.CAFileKey, "ca.pem")
 	setDefaultIfEmpty(attr, csiapi.CertFileKey, "crt.pem")
 	setDefaultIfEmpty(attr, csiapi.KeyFileKey, "key.pem")
 
-	// TODO (@joshvanl): add a smarter defaulting mechanism
-	dur, err := time.ParseDuration(attr[string(csiapi.DurationKey)])
-	if err != nil {
-		return nil, err
-	}
-	dur = dur / 3
-	setDefaultIfEmpty(attr, csiapi.RenewBeforeKey, dur.String())
+	// renew-before is not defaulted from the requested duration. With no
+	// renewal strategy set, the renewer derives it as a fraction of the issued
+	// certificate's lifetime, set by --auto-renew-before-fraction.
 
 	return attr, nil
 }
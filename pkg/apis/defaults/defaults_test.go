@@ -0,0 +1,230 @@
+package defaults
+
+import (
+	"testing"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestSetDefaultAttributesDuration(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr           map[string]string
+		opts           *options.Options
+		expDuration    string
+		expRenewBefore string
+	}{
+		"an unset duration should be defaulted": {
+			attr:           map[string]string{},
+			opts:           new(options.Options),
+			expDuration:    "2160h0m0s",
+			expRenewBefore: "",
+		},
+		"an unset duration should be left to the issuer if respected": {
+			attr:           map[string]string{},
+			opts:           &options.Options{RespectIssuerDuration: true},
+			expDuration:    "",
+			expRenewBefore: "",
+		},
+		"a set duration should be kept if the issuer duration is respected": {
+			attr: map[string]string{
+				csiapi.DurationKey: "30h",
+			},
+			opts:           &options.Options{RespectIssuerDuration: true},
+			expDuration:    "30h",
+			expRenewBefore: "",
+		},
+		"a set renew-before should be kept": {
+			attr: map[string]string{
+				csiapi.DurationKey:    "30h",
+				csiapi.RenewBeforeKey: "5h",
+			},
+			opts:           new(options.Options),
+			expDuration:    "30h",
+			expRenewBefore: "5h",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attr, err := SetDefaultAttributes(test.attr, test.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got := attr[csiapi.DurationKey]; got != test.expDuration {
+				t.Errorf("unexpected duration, exp=%q got=%q", test.expDuration, got)
+			}
+
+			if got := attr[csiapi.RenewBeforeKey]; got != test.expRenewBefore {
+				t.Errorf("unexpected renew before, exp=%q got=%q", test.expRenewBefore, got)
+			}
+		})
+	}
+}
+
+func TestSetDefaultAttributesOSProfile(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr                   map[string]string
+		expCA, expCert, expKey string
+	}{
+		"no os profile should default to pem file names": {
+			attr:    map[string]string{},
+			expCA:   "ca.pem",
+			expCert: "crt.pem",
+			expKey:  "key.pem",
+		},
+		"the windows os profile should default to windows file names": {
+			attr: map[string]string{
+				csiapi.OSProfileKey: csiapi.WindowsOSProfile,
+			},
+			expCA:   "ca.crt",
+			expCert: "tls.crt",
+			expKey:  "tls.key",
+		},
+		"the windows os profile should keep set file names": {
+			attr: map[string]string{
+				csiapi.OSProfileKey: csiapi.WindowsOSProfile,
+				csiapi.CertFileKey:  "server.cer",
+			},
+			expCA:   "ca.crt",
+			expCert: "server.cer",
+			expKey:  "tls.key",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attr, err := SetDefaultAttributes(test.attr, new(options.Options))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			for k, exp := range map[string]string{
+				csiapi.CAFileKey:   test.expCA,
+				csiapi.CertFileKey: test.expCert,
+				csiapi.KeyFileKey:  test.expKey,
+			} {
+				if got := attr[k]; got != exp {
+					t.Errorf("unexpected %s, exp=%q got=%q", k, exp, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSetDefaultAttributesDefaultFilenames(t *testing.T) {
+	opts := &options.Options{
+		DefaultCertFilename: "tls.crt",
+		DefaultKeyFilename:  "tls.key",
+		DefaultCAFilename:   "ca.crt",
+	}
+
+	for name, test := range map[string]struct {
+		attr                   map[string]string
+		expCA, expCert, expKey string
+	}{
+		"no file names should default to those of the flags": {
+			attr:    map[string]string{},
+			expCA:   "ca.crt",
+			expCert: "tls.crt",
+			expKey:  "tls.key",
+		},
+		"the flags should take precedence over the defaults of the encoding": {
+			attr: map[string]string{
+				csiapi.EncodingKey: csiapi.DEREncoding,
+			},
+			expCA:   "ca.crt",
+			expCert: "tls.crt",
+			expKey:  "tls.key",
+		},
+		"set file names should be kept": {
+			attr: map[string]string{
+				csiapi.CertFileKey: "server.pem",
+				csiapi.KeyFileKey:  "server-key.pem",
+			},
+			expCA:   "ca.crt",
+			expCert: "server.pem",
+			expKey:  "server-key.pem",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attr, err := SetDefaultAttributes(test.attr, opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			for k, exp := range map[string]string{
+				csiapi.CAFileKey:   test.expCA,
+				csiapi.CertFileKey: test.expCert,
+				csiapi.KeyFileKey:  test.expKey,
+			} {
+				if got := attr[k]; got != exp {
+					t.Errorf("unexpected %s, exp=%q got=%q", k, exp, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSetDefaultAttributesSecurityLevel(t *testing.T) {
+	for name, test := range map[string]struct {
+		level        string
+		attr         map[string]string
+		expAlgorithm string
+		expSize      string
+	}{
+		"no level should default to rsa 2048": {
+			level:        "",
+			attr:         map[string]string{},
+			expAlgorithm: csiapi.RSAKeyAlgorithm,
+			expSize:      "2048",
+		},
+		"legacy should default to rsa 2048": {
+			level:        options.LegacySecurityLevel,
+			attr:         map[string]string{},
+			expAlgorithm: csiapi.RSAKeyAlgorithm,
+			expSize:      "2048",
+		},
+		"modern should default to ecdsa P-256": {
+			level:        options.ModernSecurityLevel,
+			attr:         map[string]string{},
+			expAlgorithm: csiapi.ECDSAKeyAlgorithm,
+			expSize:      "256",
+		},
+		"modern should default rsa keys to 3072": {
+			level: options.ModernSecurityLevel,
+			attr: map[string]string{
+				csiapi.KeyAlgorithmKey: csiapi.RSAKeyAlgorithm,
+			},
+			expAlgorithm: csiapi.RSAKeyAlgorithm,
+			expSize:      "3072",
+		},
+		"fips should default to rsa 3072": {
+			level:        options.FIPSSecurityLevel,
+			attr:         map[string]string{},
+			expAlgorithm: csiapi.RSAKeyAlgorithm,
+			expSize:      "3072",
+		},
+		"a set key should override the level": {
+			level: options.FIPSSecurityLevel,
+			attr: map[string]string{
+				csiapi.KeyAlgorithmKey: csiapi.ECDSAKeyAlgorithm,
+				csiapi.KeySizeKey:      "384",
+			},
+			expAlgorithm: csiapi.ECDSAKeyAlgorithm,
+			expSize:      "384",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attr, err := SetDefaultAttributes(test.attr, &options.Options{SecurityLevel: test.level})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got := attr[csiapi.KeyAlgorithmKey]; got != test.expAlgorithm {
+				t.Errorf("unexpected key algorithm, exp=%q got=%q", test.expAlgorithm, got)
+			}
+
+			if got := attr[csiapi.KeySizeKey]; got != test.expSize {
+				t.Errorf("unexpected key size, exp=%q got=%q", test.expSize, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package defaults
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jetstack/cert-manager-csi/pkg/apis"
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// Profiles holds operator provided attribute defaults. Defaults are applied
+// to every volume, and the attributes of a named profile are applied to
+// volumes selecting it with the profile attribute. Attributes set on the
+// volume always take precedence.
+type Profiles struct {
+	Defaults map[string]string            `json:"defaults,omitempty"`
+	Profiles map[string]map[string]string `json:"profiles,omitempty"`
+}
+
+// ProfileLoader loads Profiles from a file and allows them to be atomically
+// reloaded at runtime.
+type ProfileLoader struct {
+	path     string
+	profiles atomic.Value
+}
+
+// NewProfileLoader returns a ProfileLoader that has loaded the profiles file
+// at the given path.
+func NewProfileLoader(path string) (*ProfileLoader, error) {
+	l := &ProfileLoader{
+		path: path,
+	}
+
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Reload reads and validates the profiles file. If the file is invalid, the
+// previously loaded profiles are kept and an error is returned.
+func (l *ProfileLoader) Reload() error {
+	b, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to read profiles file %q: %s", l.path, err)
+	}
+
+	profiles := new(Profiles)
+	if err := json.Unmarshal(b, profiles); err != nil {
+		return fmt.Errorf("failed to parse profiles file %q: %s", l.path, err)
+	}
+
+	if err := profiles.validate(); err != nil {
+		return fmt.Errorf("invalid profiles file %q: %s", l.path, err)
+	}
+
+	l.profiles.Store(profiles)
+
+	return nil
+}
+
+// Profiles returns the currently loaded profiles.
+func (l *ProfileLoader) Profiles() *Profiles {
+	return l.profiles.Load().(*Profiles)
+}
+
+// Apply sets the attributes of the selected profile, and then the defaults,
+// for any attribute not already set on the volume.
+func (p *Profiles) Apply(attr map[string]string) (map[string]string, error) {
+	if name := attr[csiapi.ProfileKey]; len(name) > 0 {
+		profile, ok := p.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("%s %q does not exist", csiapi.ProfileKey, name)
+		}
+
+		for k, v := range profile {
+			setDefaultIfEmpty(attr, k, v)
+		}
+	}
+
+	for k, v := range p.Defaults {
+		setDefaultIfEmpty(attr, k, v)
+	}
+
+	return attr, nil
+}
+
+func (p *Profiles) validate() error {
+	var errs []string
+
+	errs = profileAttributes("defaults", p.Defaults, errs)
+
+	var names []string
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		errs = profileAttributes(fmt.Sprintf("profile %q", name), p.Profiles[name], errs)
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func profileAttributes(name string, attr map[string]string, errs []string) []string {
+	var keys []string
+	for k := range attr {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch {
+		case !strings.HasPrefix(k, apis.GroupName+"/"):
+			errs = append(errs, fmt.Sprintf("%s: attribute %q is not a %s attribute",
+				name, k, apis.GroupName))
+		case k == csiapi.ProfileKey || k == csiapi.NodeIDKey:
+			errs = append(errs, fmt.Sprintf("%s: attribute %q may not be set by a profile",
+				name, k))
+		}
+	}
+
+	return errs
+}
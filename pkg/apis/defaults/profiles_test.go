@@ -0,0 +1,135 @@
+package defaults
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestProfilesApply(t *testing.T) {
+	profiles := &Profiles{
+		Defaults: map[string]string{
+			csiapi.IssuerNameKey: "default-issuer",
+			csiapi.DurationKey:   "24h",
+		},
+		Profiles: map[string]map[string]string{
+			"web": {
+				csiapi.IssuerNameKey:      "web-issuer",
+				csiapi.CertificateTypeKey: "server",
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		attr     map[string]string
+		expAttr  map[string]string
+		expError bool
+	}{
+		"if no profile then defaults are applied": {
+			attr: map[string]string{},
+			expAttr: map[string]string{
+				csiapi.IssuerNameKey: "default-issuer",
+				csiapi.DurationKey:   "24h",
+			},
+		},
+		"if profile then profile takes precedence over defaults": {
+			attr: map[string]string{
+				csiapi.ProfileKey: "web",
+			},
+			expAttr: map[string]string{
+				csiapi.ProfileKey:         "web",
+				csiapi.IssuerNameKey:      "web-issuer",
+				csiapi.CertificateTypeKey: "server",
+				csiapi.DurationKey:        "24h",
+			},
+		},
+		"if attribute set then takes precedence over profile": {
+			attr: map[string]string{
+				csiapi.ProfileKey:    "web",
+				csiapi.IssuerNameKey: "my-issuer",
+			},
+			expAttr: map[string]string{
+				csiapi.ProfileKey:         "web",
+				csiapi.IssuerNameKey:      "my-issuer",
+				csiapi.CertificateTypeKey: "server",
+				csiapi.DurationKey:        "24h",
+			},
+		},
+		"if profile does not exist then error": {
+			attr: map[string]string{
+				csiapi.ProfileKey: "foo",
+			},
+			expError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			attr, err := profiles.Apply(test.attr)
+			if test.expError != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expError, err)
+			}
+
+			if !test.expError && !reflect.DeepEqual(test.expAttr, attr) {
+				t.Errorf("unexpected attributes, exp=%v got=%v", test.expAttr, attr)
+			}
+		})
+	}
+}
+
+func TestProfileLoaderReload(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-profiles-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "profiles.json")
+
+	writeFile := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(`{"defaults": {"csi.cert-manager.io/issuer-name": "ca-issuer"}}`)
+
+	l, err := NewProfileLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := l.Profiles().Defaults[csiapi.IssuerNameKey]; name != "ca-issuer" {
+		t.Errorf("unexpected default issuer name, exp=ca-issuer got=%s", name)
+	}
+
+	for _, bad := range []string{
+		`{"defaults": `,
+		`{"defaults": {"foo": "bar"}}`,
+		`{"profiles": {"web": {"csi.cert-manager.io/profile": "web"}}}`,
+	} {
+		writeFile(bad)
+
+		if err := l.Reload(); err == nil {
+			t.Errorf("expected error reloading %q", bad)
+		}
+
+		if name := l.Profiles().Defaults[csiapi.IssuerNameKey]; name != "ca-issuer" {
+			t.Errorf("expected previous profiles to be kept, got issuer name %q", name)
+		}
+	}
+
+	writeFile(`{"defaults": {"csi.cert-manager.io/issuer-name": "new-issuer"}}`)
+
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if name := l.Profiles().Defaults[csiapi.IssuerNameKey]; name != "new-issuer" {
+		t.Errorf("unexpected default issuer name, exp=new-issuer got=%s", name)
+	}
+}
@@ -1,7 +1,24 @@
 package v1alpha1
 
+import "time"
+
 const (
 	MetaDataFileName = "metadata.json"
+	StatusFileName   = "status.json"
+
+	// Private key of a CertificateRequest still pending across retried
+	// publishes, kept next to the metadata file.
+	PendingKeyFileName = "pending-key.pem"
+
+	// Files written with CAToolingFilesKey.
+	CASerialFileName = "serial"
+	CAIndexFileName  = "index.txt"
+)
+
+const (
+	// SpecHashAnnotationKey is set on CertificateRequests with a hash of the
+	// volume attributes the request was created from.
+	SpecHashAnnotationKey = "csi.cert-manager.io/spec-hash"
 )
 
 const (
@@ -9,13 +26,23 @@ const (
 	CSIPodNamespaceKey = "csi.storage.k8s.io/pod.namespace"
 	CSIPodUIDKey       = "csi.storage.k8s.io/pod.uid"
 	CSIEphemeralKey    = "csi.storage.k8s.io/ephemeral"
+
+	CSIServiceAccountNameKey = "csi.storage.k8s.io/serviceAccount.name"
 )
 
 const (
+	ProfileKey string = "csi.cert-manager.io/profile"
+
 	IssuerNameKey  string = "csi.cert-manager.io/issuer-name"
 	IssuerKindKey  string = "csi.cert-manager.io/issuer-kind"
 	IssuerGroupKey string = "csi.cert-manager.io/issuer-group"
 
+	// The fallback issuer is used if the request to the issuer fails or times
+	// out.
+	FallbackIssuerNameKey  string = "csi.cert-manager.io/fallback-issuer-name"
+	FallbackIssuerKindKey  string = "csi.cert-manager.io/fallback-issuer-kind"
+	FallbackIssuerGroupKey string = "csi.cert-manager.io/fallback-issuer-group"
+
 	CommonNameKey string = "csi.cert-manager.io/common-name"
 	DNSNamesKey   string = "csi.cert-manager.io/dns-names"
 	IPSANsKey     string = "csi.cert-manager.io/ip-sans"
@@ -23,13 +50,169 @@ const (
 	DurationKey   string = "csi.cert-manager.io/duration"
 	IsCAKey       string = "csi.cert-manager.io/is-ca"
 
+	// DNSNamesTemplateKey is a Go template of further comma separated DNS
+	// names, rendered with the metadata of the pod.
+	DNSNamesTemplateKey string = "csi.cert-manager.io/dns-names-template"
+
+	KeyAlgorithmKey string = "csi.cert-manager.io/key-algorithm"
+	KeySizeKey      string = "csi.cert-manager.io/key-size"
+
+	SubjectSerialNumberKey    string = "csi.cert-manager.io/subject-serial-number"
+	SubjectStreetAddressesKey string = "csi.cert-manager.io/subject-street-addresses"
+
+	// ExtraExtensionsKey is a comma separated list of OID=value pairs of
+	// further extensions added to the CSR, where value is the base64 encoded
+	// DER of the extension value. For example the certificate template of
+	// Active Directory Certificate Services issuers.
+	ExtraExtensionsKey string = "csi.cert-manager.io/extra-extensions"
+
+	KeyUsagesKey       string = "csi.cert-manager.io/key-usages"
+	CertificateTypeKey string = "csi.cert-manager.io/certificate-type"
+
+	EncodingKey string = "csi.cert-manager.io/encoding"
+
 	CAFileKey   string = "csi.cert-manager.io/ca-file"
 	CertFileKey string = "csi.cert-manager.io/certificate-file"
 	KeyFileKey  string = "csi.cert-manager.io/privatekey-file"
 
+	ChainFileKey string = "csi.cert-manager.io/chain-file"
+
+	FingerprintFileKey string = "csi.cert-manager.io/fingerprint-file"
+
+	// TouchFileOnRenewKey is the name of an empty file whose modification
+	// time is updated after each issuance, so that applications may detect
+	// rotations by watching a single file.
+	TouchFileOnRenewKey string = "csi.cert-manager.io/touch-file-on-renew"
+
+	// SplitCAKey also writes each certificate of the CA bundle to its own
+	// file, numbered from the name of the CA file.
+	SplitCAKey string = "csi.cert-manager.io/split-ca"
+
+	// CAToolingFilesKey writes an OpenSSL CA serial and index file alongside
+	// the certificate of is-ca volumes.
+	CAToolingFilesKey string = "csi.cert-manager.io/ca-tooling-files"
+
 	RenewBeforeKey      string = "csi.cert-manager.io/renew-before"
 	DisableAutoRenewKey string = "csi.cert-manager.io/disable-auto-renew"
 	ReusePrivateKey     string = "csi.cert-manager.io/reuse-private-key"
+
+	RenewAtKey       string = "csi.cert-manager.io/renew-at"
+	RenewScheduleKey string = "csi.cert-manager.io/renew-schedule"
+
+	CARefreshIntervalKey string = "csi.cert-manager.io/ca-refresh-interval"
+
+	AsyncIssuanceKey       string = "csi.cert-manager.io/async-issuance"
+	BootstrapSelfSignedKey string = "csi.cert-manager.io/bootstrap-self-signed"
+
+	// NodeIDKey is set by the driver to the ID of the node the volume is
+	// published on. Any user supplied value is overwritten.
+	NodeIDKey           string = "csi.cert-manager.io/node-id"
+	NodeURISANPrefixKey string = "csi.cert-manager.io/node-uri-san-prefix"
+
+	// CorrelationIDKey is an opaque ID set on the CertificateRequest as an
+	// annotation, and echoed into the status file and metrics of the volume.
+	CorrelationIDKey string = "csi.cert-manager.io/correlation-id"
+
+	RequestAnnotationsKey string = "csi.cert-manager.io/request-annotations"
+	RequestLabelsKey      string = "csi.cert-manager.io/request-labels"
+
+	// ServiceAccountTokenKey is also the annotation the SHA-256 hash of the
+	// pod's service account token is set on when requested. The token itself
+	// is never set on the CertificateRequest.
+	ServiceAccountTokenKey         string = "csi.cert-manager.io/service-account-token"
+	ServiceAccountTokenFileKey     string = "csi.cert-manager.io/service-account-token-file"
+	ServiceAccountTokenAudienceKey string = "csi.cert-manager.io/service-account-token-audience"
+
+	// ServiceAccountAnnotationKey is the annotation the namespaced name of the
+	// pod's service account is set on along with its token hash.
+	ServiceAccountAnnotationKey string = "csi.cert-manager.io/service-account"
+
+	// CSRFileKey is the path, relative to the driver's CSR directory, of a
+	// PEM encoded CSR provided by the workload. The driver submits it as is
+	// and writes no private key.
+	CSRFileKey string = "csi.cert-manager.io/csr-file"
+
+	// CommonNameFromFileKey, DNSNamesFromFileKey and IPSANsFromFileKey are
+	// paths, relative to the driver's downward API directory, of files the
+	// common name and SANs of the request are read from at issuance. SANs
+	// read from files are added to those of the attributes.
+	CommonNameFromFileKey string = "csi.cert-manager.io/common-name-from-file"
+	DNSNamesFromFileKey   string = "csi.cert-manager.io/dns-names-from-file"
+	IPSANsFromFileKey     string = "csi.cert-manager.io/ip-sans-from-file"
+
+	// TrackPodIPKey adds the current IPs of the pod to the IP SANs, and
+	// re-issues the certificate should they change.
+	TrackPodIPKey string = "csi.cert-manager.io/track-pod-ip"
+
+	// AttributesConfigMapKey is the name of a ConfigMap in the pod's
+	// namespace whose data overrides the volume's attributes. Changes to it
+	// are reconciled into the live volume.
+	AttributesConfigMapKey string = "csi.cert-manager.io/attributes-configmap"
+
+	// DataRootKey selects the named data root, of --data-root-map, that the
+	// volume is stored in.
+	DataRootKey string = "csi.cert-manager.io/data-root"
+
+	// KeySecretKey is the name of a Secret in the pod's namespace that stores
+	// the private key of each pod, keyed by pod name, so that a pod's key
+	// survives it being rescheduled.
+	KeySecretKey string = "csi.cert-manager.io/key-secret"
+
+	// OSProfileKey selects the file layout of the volume for the operating
+	// system of the workload. The windows profile writes PEM files with CRLF
+	// line endings and defaults file names to Windows extensions.
+	OSProfileKey string = "csi.cert-manager.io/os-profile"
+
+	// KeyDeliveryKey selects how the private key is delivered to the
+	// workload. The fifo delivery writes the key into a named pipe once, so
+	// that it is never stored in a regular file.
+	KeyDeliveryKey string = "csi.cert-manager.io/key-delivery"
+)
+
+// OutputFileKeys are the attributes of the filenames written into the data
+// directory of a volume.
+var OutputFileKeys = []string{
+	CertFileKey,
+	KeyFileKey,
+	CAFileKey,
+	ChainFileKey,
+	FingerprintFileKey,
+	ServiceAccountTokenFileKey,
+	TouchFileOnRenewKey,
+}
+
+// DownwardAPIFileKeys are the attributes of the files request fields are read
+// from at issuance.
+var DownwardAPIFileKeys = []string{
+	CommonNameFromFileKey,
+	DNSNamesFromFileKey,
+	IPSANsFromFileKey,
+}
+
+const (
+	RSAKeyAlgorithm   = "rsa"
+	ECDSAKeyAlgorithm = "ecdsa"
+)
+
+const (
+	ServerCertificateType = "server"
+	ClientCertificateType = "client"
+	PeerCertificateType   = "peer"
+)
+
+const (
+	PEMEncoding = "pem"
+	DEREncoding = "der"
+)
+
+const (
+	LinuxOSProfile   = "linux"
+	WindowsOSProfile = "windows"
+)
+
+const (
+	FileKeyDelivery = "file"
+	FIFOKeyDelivery = "fifo"
 )
 
 type MetaData struct {
@@ -43,4 +226,38 @@ type MetaData struct {
 	TargetPath string `json:"targetPath"`
 
 	Attributes map[string]string `json:"attributes"`
+
+	// time the volume's current private key was generated
+	IdentityCreated time.Time `json:"identityCreated"`
+
+	// time the CertificateRequest of the volume was created, while it is
+	// still pending and resumed across retried publishes
+	RequestCreated *time.Time `json:"requestCreated,omitempty"`
+
+	// private key of the volume is held by the signer plugin
+	KeyExternal bool `json:"keyExternal,omitempty"`
+
+	// issuer that signed the volume's current certificate
+	Issuer *IssuerRef `json:"issuer,omitempty"`
+
+	// attributes the volume was published with, kept so that the overrides
+	// of its attributes ConfigMap may be re-applied
+	PublishAttributes map[string]string `json:"publishAttributes,omitempty"`
+}
+
+// IssuerRef references the issuer that signed a volume's certificate.
+type IssuerRef struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Group string `json:"group"`
+}
+
+// Status is the renewal status of a volume's certificate.
+type Status struct {
+	// expiry of the current certificate
+	NotAfter time.Time `json:"notAfter"`
+	// time the certificate is scheduled to be renewed
+	NextRenewal time.Time `json:"nextRenewal"`
+	// user supplied correlation ID of the volume
+	CorrelationID string `json:"correlationID,omitempty"`
 }
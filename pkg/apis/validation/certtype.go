@@ -0,0 +1,37 @@
+package validation
+
+import (
+	"fmt"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// CheckCertificateType returns an error if the type of certificate the
+// attributes request is not one of the allowed types. Certificates of no
+// type, usable for neither client nor server auth, are always allowed. Any
+// type is allowed if none are given.
+func CheckCertificateType(attr map[string]string, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	certType := util.CertificateType(attr)
+	if len(certType) == 0 {
+		return nil
+	}
+
+	for _, a := range allowed {
+		if a == certType {
+			return nil
+		}
+	}
+
+	if certType == csiapi.PeerCertificateType && len(attr[csiapi.CertificateTypeKey]) == 0 {
+		return fmt.Errorf("%s certificates are not allowed by --allowed-certificate-types %q, set %s or %s to request a narrower certificate",
+			certType, allowed, csiapi.CertificateTypeKey, csiapi.KeyUsagesKey)
+	}
+
+	return fmt.Errorf("%s certificates are not allowed by --allowed-certificate-types %q",
+		certType, allowed)
+}
@@ -0,0 +1,68 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestCheckCertificateType(t *testing.T) {
+	for name, test := range map[string]struct {
+		allowed []string
+		attr    map[string]string
+		expErr  string
+	}{
+		"any type should be allowed if none are set": {
+			allowed: nil,
+			attr:    map[string]string{csiapi.CertificateTypeKey: csiapi.ServerCertificateType},
+		},
+		"an allowed type should be allowed": {
+			allowed: []string{"client"},
+			attr:    map[string]string{csiapi.CertificateTypeKey: csiapi.ClientCertificateType},
+		},
+		"a type not allowed should error": {
+			allowed: []string{"client"},
+			attr:    map[string]string{csiapi.CertificateTypeKey: csiapi.ServerCertificateType},
+			expErr:  "server certificates are not allowed",
+		},
+		"key usages of a type not allowed should error": {
+			allowed: []string{"client"},
+			attr:    map[string]string{csiapi.KeyUsagesKey: "digital signature,server auth"},
+			expErr:  "server certificates are not allowed",
+		},
+		"a peer certificate should not be allowed by client and server": {
+			allowed: []string{"client", "server"},
+			attr:    map[string]string{csiapi.CertificateTypeKey: csiapi.PeerCertificateType},
+			expErr:  "peer certificates are not allowed",
+		},
+		"the any key usage should be a peer certificate": {
+			allowed: []string{"client"},
+			attr:    map[string]string{csiapi.KeyUsagesKey: "any"},
+			expErr:  "peer certificates are not allowed",
+		},
+		"no extended key usages should be a peer certificate": {
+			allowed: []string{"client"},
+			attr:    map[string]string{},
+			expErr:  "set csi.cert-manager.io/certificate-type or csi.cert-manager.io/key-usages",
+		},
+		"a certificate for neither client nor server auth should be allowed": {
+			allowed: []string{"client"},
+			attr:    map[string]string{csiapi.KeyUsagesKey: "digital signature,code signing"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := CheckCertificateType(test.attr, test.allowed)
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
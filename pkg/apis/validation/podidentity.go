@@ -0,0 +1,99 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// CheckPodIdentitySANs returns an error naming each DNS name and URI SAN of
+// the volume attributes that is not of the pod's own identity, so that a pod
+// may not impersonate another. DNS names must be the pod's name, optionally
+// followed by its namespace or its headless service and namespace, such as
+// "web-0.my-ns.svc" or "web-0.web.my-ns.svc", themselves optionally followed
+// by the cluster domain. URI SANs, including the node URI SAN built from its
+// prefix, must be the SPIFFE ID of the pod's service account, of any trust
+// domain. Names read from files or a workload provided CSR can't be checked,
+// so are rejected.
+func CheckPodIdentitySANs(attr map[string]string, clusterDomain string) error {
+	podName := strings.ToLower(attr[csiapi.CSIPodNameKey])
+	namespace := strings.ToLower(attr[csiapi.CSIPodNamespaceKey])
+	serviceAccount := attr[csiapi.CSIServiceAccountNameKey]
+
+	var errs []string
+
+	for _, k := range []string{csiapi.DNSNamesFromFileKey, csiapi.CSRFileKey} {
+		if len(attr[k]) > 0 {
+			errs = append(errs, fmt.Sprintf("%s may not be used with --enforce-pod-identity-sans, its names can't be checked against the pod's identity",
+				k))
+		}
+	}
+
+	dnsNames, err := util.DNSNames(attr)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range dnsNames {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+
+		if !podDNSName(podName, namespace, clusterDomain, name) {
+			errs = append(errs, fmt.Sprintf("pod %s/%s may not request certificates for dns name %q, which is not of its own identity",
+				namespace, podName, name))
+		}
+	}
+
+	uris, err := util.ParseURISANs(attr)
+	if err != nil {
+		return err
+	}
+
+	for _, uri := range uris {
+		if uri.Scheme != "spiffe" || len(serviceAccount) == 0 || uri.User != nil ||
+			len(uri.RawQuery) > 0 || uri.ForceQuery || len(uri.Fragment) > 0 ||
+			uri.Path != fmt.Sprintf("/ns/%s/sa/%s", namespace, serviceAccount) {
+			errs = append(errs, fmt.Sprintf("pod %s/%s may not request certificates for uri %q, which is not the spiffe id of its service account %q",
+				namespace, podName, uri, serviceAccount))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// podDNSName returns true if the DNS name is that of the pod: its name,
+// "<pod>.<namespace>", "<pod>.<namespace>.svc" or
+// "<pod>.<service>.<namespace>.svc", the last two optionally followed by the
+// cluster domain.
+func podDNSName(podName, namespace, clusterDomain, name string) bool {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(name, ".")), ".")
+	if labels[0] != podName {
+		return false
+	}
+
+	var rest []string
+	switch {
+	case len(labels) == 1:
+		return true
+	case len(labels) == 2:
+		return labels[1] == namespace
+	case labels[1] == namespace && labels[2] == "svc":
+		rest = labels[3:]
+	case len(labels) >= 4 && labels[2] == namespace && labels[3] == "svc":
+		rest = labels[4:]
+	default:
+		return false
+	}
+
+	domain := strings.ToLower(strings.TrimSuffix(clusterDomain, "."))
+	return len(rest) == 0 || strings.Join(rest, ".") == domain
+}
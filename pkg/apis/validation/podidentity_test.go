@@ -0,0 +1,144 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestCheckPodIdentitySANs(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr   map[string]string
+		expErr string
+	}{
+		"no sans should be allowed": {
+			attr: map[string]string{},
+		},
+		"dns names of the pod should be allowed": {
+			attr: map[string]string{
+				csiapi.DNSNamesKey: "web-0,web-0.team-a,Web-0.Team-A.svc,web-0.team-a.svc.cluster.local.",
+			},
+		},
+		"a dns name of the pod's headless service should be allowed": {
+			attr: map[string]string{
+				csiapi.DNSNamesKey: "web-0.web.team-a.svc.cluster.local",
+			},
+		},
+		"a dns name of the pod in another domain should error": {
+			attr: map[string]string{
+				csiapi.DNSNamesKey: "web-0.team-a.svc.evil.com",
+			},
+			expErr: `dns name "web-0.team-a.svc.evil.com"`,
+		},
+		"a dns name of the pod's headless service in another domain should error": {
+			attr: map[string]string{
+				csiapi.DNSNamesKey: "web-0.web.team-a.svc.cluster.local.evil.com",
+			},
+			expErr: `dns name "web-0.web.team-a.svc.cluster.local.evil.com"`,
+		},
+		"a dns name of another pod should error": {
+			attr: map[string]string{
+				csiapi.DNSNamesKey: "web-0.team-a,web-1.team-a",
+			},
+			expErr: `pod team-a/web-0 may not request certificates for dns name "web-1.team-a"`,
+		},
+		"a dns name of another namespace should error": {
+			attr: map[string]string{
+				csiapi.DNSNamesKey: "web-0.team-b.svc.cluster.local",
+			},
+			expErr: `dns name "web-0.team-b.svc.cluster.local"`,
+		},
+		"a dns name naming the namespace as a service of another should error": {
+			attr: map[string]string{
+				csiapi.DNSNamesKey: "web-0.team-a.team-b.svc",
+			},
+			expErr: `dns name "web-0.team-a.team-b.svc"`,
+		},
+		"a wildcard dns name should error": {
+			attr: map[string]string{
+				csiapi.DNSNamesKey: "*.team-a.svc",
+			},
+			expErr: `dns name "*.team-a.svc"`,
+		},
+		"a templated dns name of the pod should be allowed": {
+			attr: map[string]string{
+				csiapi.DNSNamesTemplateKey: "{{.PodName}}.{{.Namespace}}.svc",
+			},
+		},
+		"the spiffe id of the pod's service account should be allowed": {
+			attr: map[string]string{
+				csiapi.URISANsKey: "spiffe://cluster.local/ns/team-a/sa/web",
+			},
+		},
+		"the spiffe id of another service account should error": {
+			attr: map[string]string{
+				csiapi.URISANsKey: "spiffe://cluster.local/ns/team-a/sa/admin",
+			},
+			expErr: `uri "spiffe://cluster.local/ns/team-a/sa/admin"`,
+		},
+		"a uri that is not a spiffe id should error": {
+			attr: map[string]string{
+				csiapi.URISANsKey: "https://team-a.example.com/ns/team-a/sa/web",
+			},
+			expErr: `uri "https://team-a.example.com/ns/team-a/sa/web"`,
+		},
+		"a spiffe id without the pod's service account should error": {
+			attr: map[string]string{
+				csiapi.CSIServiceAccountNameKey: "",
+				csiapi.URISANsKey:               "spiffe://cluster.local/ns/team-a/sa/",
+			},
+			expErr: "which is not the spiffe id of its service account",
+		},
+		"the node uri san should error": {
+			attr: map[string]string{
+				csiapi.NodeURISANPrefixKey: "spiffe://cluster.local/node/",
+				csiapi.NodeIDKey:           "node-1",
+			},
+			expErr: `uri "spiffe://cluster.local/node/node-1"`,
+		},
+		"a node uri san prefix turning the node id into a query should error": {
+			attr: map[string]string{
+				csiapi.CSIServiceAccountNameKey: "admin",
+				csiapi.NodeURISANPrefixKey:      "spiffe://cluster.local/ns/team-a/sa/admin?",
+				csiapi.NodeIDKey:                "node-1",
+			},
+			expErr: `uri "spiffe://cluster.local/ns/team-a/sa/admin?node-1"`,
+		},
+		"a spiffe id of the pod's service account with a fragment should error": {
+			attr: map[string]string{
+				csiapi.URISANsKey: "spiffe://cluster.local/ns/team-a/sa/web#admin",
+			},
+			expErr: `uri "spiffe://cluster.local/ns/team-a/sa/web#admin"`,
+		},
+		"dns names from a file should error": {
+			attr: map[string]string{
+				csiapi.DNSNamesFromFileKey: "my-app/dns-names",
+			},
+			expErr: "csi.cert-manager.io/dns-names-from-file may not be used with --enforce-pod-identity-sans",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attr := map[string]string{
+				csiapi.CSIPodNameKey:            "web-0",
+				csiapi.CSIPodNamespaceKey:       "team-a",
+				csiapi.CSIServiceAccountNameKey: "web",
+			}
+			for k, v := range test.attr {
+				attr[k] = v
+			}
+
+			err := CheckPodIdentitySANs(attr, "cluster.local")
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
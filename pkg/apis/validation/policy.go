@@ -0,0 +1,166 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// AnyNamespace is the policy key of the issuers allowed for namespaces
+// without a policy of their own.
+const AnyNamespace = "*"
+
+// IssuerPolicy restricts the issuers the pods of each namespace may request
+// certificates from. Pods of namespaces without a policy, and no AnyNamespace
+// policy, may not request from any issuer.
+type IssuerPolicy struct {
+	Namespaces map[string][]AllowedIssuer `json:"namespaces"`
+}
+
+// AllowedIssuer matches issuers by name, kind and group. A name of "*", or an
+// empty kind or group, matches any.
+type AllowedIssuer struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+// IssuerPolicyLoader loads an IssuerPolicy from a file and allows it to be
+// atomically reloaded at runtime.
+type IssuerPolicyLoader struct {
+	path   string
+	policy atomic.Value
+}
+
+// NewIssuerPolicyLoader returns an IssuerPolicyLoader that has loaded the
+// issuer policy file at the given path.
+func NewIssuerPolicyLoader(path string) (*IssuerPolicyLoader, error) {
+	l := &IssuerPolicyLoader{
+		path: path,
+	}
+
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Reload reads and validates the issuer policy file. If the file is invalid,
+// the previously loaded policy is kept and an error is returned.
+func (l *IssuerPolicyLoader) Reload() error {
+	b, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to read issuer policy file %q: %s", l.path, err)
+	}
+
+	policy := new(IssuerPolicy)
+	if err := json.Unmarshal(b, policy); err != nil {
+		return fmt.Errorf("failed to parse issuer policy file %q: %s", l.path, err)
+	}
+
+	if err := policy.validate(); err != nil {
+		return fmt.Errorf("invalid issuer policy file %q: %s", l.path, err)
+	}
+
+	l.policy.Store(policy)
+
+	return nil
+}
+
+// Policy returns the currently loaded issuer policy.
+func (l *IssuerPolicyLoader) Policy() *IssuerPolicy {
+	return l.policy.Load().(*IssuerPolicy)
+}
+
+// Check returns an error if the issuer, or fallback issuer, of the volume
+// attributes is not allowed for the pod's namespace.
+func (p *IssuerPolicy) Check(attr map[string]string) error {
+	namespace := attr[csiapi.CSIPodNamespaceKey]
+
+	allowed, ok := p.Namespaces[namespace]
+	if !ok {
+		allowed, ok = p.Namespaces[AnyNamespace]
+	}
+	if !ok {
+		return fmt.Errorf("namespace %q may not request certificates from any issuer", namespace)
+	}
+
+	var errs []string
+
+	issuers := []AllowedIssuer{{
+		Name:  attr[csiapi.IssuerNameKey],
+		Kind:  attr[csiapi.IssuerKindKey],
+		Group: attr[csiapi.IssuerGroupKey],
+	}}
+	if len(attr[csiapi.FallbackIssuerNameKey]) > 0 {
+		issuers = append(issuers, AllowedIssuer{
+			Name:  attr[csiapi.FallbackIssuerNameKey],
+			Kind:  attr[csiapi.FallbackIssuerKindKey],
+			Group: attr[csiapi.FallbackIssuerGroupKey],
+		})
+	}
+
+	for _, issuer := range issuers {
+		if !issuerAllowed(allowed, issuer) {
+			errs = append(errs, fmt.Sprintf("namespace %q may not request certificates from %s %q (%s)",
+				namespace, issuer.Kind, issuer.Name, issuer.Group))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func issuerAllowed(allowed []AllowedIssuer, issuer AllowedIssuer) bool {
+	for _, a := range allowed {
+		if (a.Name == "*" || a.Name == issuer.Name) &&
+			(len(a.Kind) == 0 || a.Kind == issuer.Kind) &&
+			(len(a.Group) == 0 || a.Group == issuer.Group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *IssuerPolicy) validate() error {
+	var errs []string
+
+	var namespaces []string
+	for namespace := range p.Namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		if namespace != AnyNamespace {
+			for _, msg := range k8svalidation.IsDNS1123Label(namespace) {
+				errs = append(errs, fmt.Sprintf("namespace %q is invalid: %s", namespace, msg))
+			}
+		}
+
+		for i, issuer := range p.Namespaces[namespace] {
+			if len(issuer.Name) == 0 {
+				errs = append(errs, fmt.Sprintf("namespace %q: issuer %d: name required", namespace, i))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package validation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestIssuerPolicyCheck(t *testing.T) {
+	policy := &IssuerPolicy{
+		Namespaces: map[string][]AllowedIssuer{
+			"team-a": {
+				{Name: "team-a-issuer", Kind: "Issuer", Group: "cert-manager.io"},
+				{Name: "shared-issuer", Kind: "ClusterIssuer"},
+			},
+			"team-b": {
+				{Name: "*", Group: "cert-manager.io"},
+			},
+			AnyNamespace: {
+				{Name: "shared-issuer", Kind: "ClusterIssuer"},
+			},
+		},
+	}
+
+	attr := func(namespace, name, kind, group string) map[string]string {
+		return map[string]string{
+			csiapi.CSIPodNamespaceKey: namespace,
+			csiapi.IssuerNameKey:      name,
+			csiapi.IssuerKindKey:      kind,
+			csiapi.IssuerGroupKey:     group,
+		}
+	}
+
+	tests := map[string]struct {
+		attr     map[string]string
+		expError bool
+	}{
+		"an allowed issuer should not error": {
+			attr: attr("team-a", "team-a-issuer", "Issuer", "cert-manager.io"),
+		},
+		"an allowed issuer matching any group should not error": {
+			attr: attr("team-a", "shared-issuer", "ClusterIssuer", "cert-manager.io"),
+		},
+		"an issuer of another kind should error": {
+			attr:     attr("team-a", "team-a-issuer", "ClusterIssuer", "cert-manager.io"),
+			expError: true,
+		},
+		"an issuer not listed should error": {
+			attr:     attr("team-a", "team-b-issuer", "Issuer", "cert-manager.io"),
+			expError: true,
+		},
+		"a wildcard name should allow any issuer of the group": {
+			attr: attr("team-b", "team-b-issuer", "Issuer", "cert-manager.io"),
+		},
+		"a wildcard name should not allow issuers of other groups": {
+			attr:     attr("team-b", "team-b-issuer", "Issuer", "out.of.tree.foo"),
+			expError: true,
+		},
+		"a namespace without a policy should use the wildcard namespace policy": {
+			attr: attr("team-c", "shared-issuer", "ClusterIssuer", "cert-manager.io"),
+		},
+		"a namespace without a policy should error for other issuers": {
+			attr:     attr("team-c", "team-a-issuer", "Issuer", "cert-manager.io"),
+			expError: true,
+		},
+		"a fallback issuer not allowed should error": {
+			attr: map[string]string{
+				csiapi.CSIPodNamespaceKey:     "team-a",
+				csiapi.IssuerNameKey:          "team-a-issuer",
+				csiapi.IssuerKindKey:          "Issuer",
+				csiapi.IssuerGroupKey:         "cert-manager.io",
+				csiapi.FallbackIssuerNameKey:  "team-b-issuer",
+				csiapi.FallbackIssuerKindKey:  "Issuer",
+				csiapi.FallbackIssuerGroupKey: "cert-manager.io",
+			},
+			expError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := policy.Check(test.attr)
+			if test.expError != (err != nil) {
+				t.Errorf("unexpected error, exp=%t got=%v", test.expError, err)
+			}
+		})
+	}
+
+	t.Run("without a wildcard namespace policy other namespaces should error", func(t *testing.T) {
+		policy := &IssuerPolicy{
+			Namespaces: map[string][]AllowedIssuer{
+				"team-a": {{Name: "*"}},
+			},
+		}
+
+		if err := policy.Check(attr("team-c", "team-a-issuer", "Issuer", "cert-manager.io")); err == nil {
+			t.Error("expected error for namespace without a policy")
+		}
+	})
+}
+
+func TestIssuerPolicyLoaderReload(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-issuer-policy-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "policy.json")
+
+	writeFile := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(`{"namespaces": {"team-a": [{"name": "ca-issuer"}]}}`)
+
+	l, err := NewIssuerPolicyLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(l.Policy().Namespaces["team-a"]); n != 1 {
+		t.Errorf("unexpected number of allowed issuers, exp=1 got=%d", n)
+	}
+
+	for _, bad := range []string{
+		`{"namespaces": `,
+		`{"namespaces": {"Team_A": [{"name": "ca-issuer"}]}}`,
+		`{"namespaces": {"team-a": [{"kind": "Issuer"}]}}`,
+	} {
+		writeFile(bad)
+
+		if err := l.Reload(); err == nil {
+			t.Errorf("expected error reloading %q", bad)
+		}
+
+		if n := len(l.Policy().Namespaces["team-a"]); n != 1 {
+			t.Errorf("expected previous policy to be kept, got %d allowed issuers", n)
+		}
+	}
+}
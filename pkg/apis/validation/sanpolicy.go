@@ -0,0 +1,191 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// SANPolicy restricts the domains the pods of each namespace may request DNS
+// names and URI SANs for. Pods of namespaces without a policy, and no
+// AnyNamespace policy, may not request any DNS names or URI SANs.
+type SANPolicy struct {
+	// Allowed domains by namespace. A domain allows itself and its
+	// subdomains, and a domain prefixed with "*." only its subdomains.
+	Namespaces map[string][]string `json:"namespaces"`
+}
+
+// SANPolicyLoader loads a SANPolicy from a file and allows it to be
+// atomically reloaded at runtime.
+type SANPolicyLoader struct {
+	path   string
+	policy atomic.Value
+}
+
+// NewSANPolicyLoader returns a SANPolicyLoader that has loaded the SAN policy
+// file at the given path.
+func NewSANPolicyLoader(path string) (*SANPolicyLoader, error) {
+	l := &SANPolicyLoader{
+		path: path,
+	}
+
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Reload reads and validates the SAN policy file. If the file is invalid, the
+// previously loaded policy is kept and an error is returned.
+func (l *SANPolicyLoader) Reload() error {
+	b, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to read san policy file %q: %s", l.path, err)
+	}
+
+	policy := new(SANPolicy)
+	if err := json.Unmarshal(b, policy); err != nil {
+		return fmt.Errorf("failed to parse san policy file %q: %s", l.path, err)
+	}
+
+	if err := policy.validate(); err != nil {
+		return fmt.Errorf("invalid san policy file %q: %s", l.path, err)
+	}
+
+	l.policy.Store(policy)
+
+	return nil
+}
+
+// Policy returns the currently loaded SAN policy.
+func (l *SANPolicyLoader) Policy() *SANPolicy {
+	return l.policy.Load().(*SANPolicy)
+}
+
+// Check returns an error naming each DNS name and URI SAN of the volume
+// attributes that is not within a domain allowed for the pod's namespace.
+// Names read from files or a workload provided CSR can't be checked, so are
+// rejected.
+func (p *SANPolicy) Check(attr map[string]string) error {
+	namespace := attr[csiapi.CSIPodNamespaceKey]
+
+	allowed, ok := p.Namespaces[namespace]
+	if !ok {
+		allowed = p.Namespaces[AnyNamespace]
+	}
+
+	var errs []string
+
+	for _, k := range []string{csiapi.DNSNamesFromFileKey, csiapi.CSRFileKey} {
+		if len(attr[k]) > 0 {
+			errs = append(errs, fmt.Sprintf("%s may not be used in namespace %q, its names can't be checked against the san policy",
+				k, namespace))
+		}
+	}
+
+	dnsNames, err := util.DNSNames(attr)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range dnsNames {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+
+		if !domainAllowed(allowed, name) {
+			errs = append(errs, fmt.Sprintf("namespace %q may not request certificates for dns name %q",
+				namespace, name))
+		}
+	}
+
+	uris, err := util.ParseURISANs(attr)
+	if err != nil {
+		return err
+	}
+
+	for _, uri := range uris {
+		host := uri.Hostname()
+		if len(host) == 0 {
+			errs = append(errs, fmt.Sprintf("namespace %q may not request certificates for uri %q without a host",
+				namespace, uri))
+			continue
+		}
+
+		if !domainAllowed(allowed, host) {
+			errs = append(errs, fmt.Sprintf("namespace %q may not request certificates for uri %q",
+				namespace, uri))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// domainAllowed returns true if the name, which may be a wildcard, is within
+// one of the allowed domains.
+func domainAllowed(allowed []string, name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	for _, domain := range allowed {
+		domain = strings.ToLower(domain)
+
+		if strings.HasPrefix(domain, "*.") {
+			if strings.HasSuffix(name, domain[1:]) {
+				return true
+			}
+
+			continue
+		}
+
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *SANPolicy) validate() error {
+	var errs []string
+
+	var namespaces []string
+	for namespace := range p.Namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		if namespace != AnyNamespace {
+			for _, msg := range k8svalidation.IsDNS1123Label(namespace) {
+				errs = append(errs, fmt.Sprintf("namespace %q is invalid: %s", namespace, msg))
+			}
+		}
+
+		for _, domain := range p.Namespaces[namespace] {
+			for _, msg := range k8svalidation.IsDNS1123Subdomain(strings.ToLower(strings.TrimPrefix(domain, "*."))) {
+				errs = append(errs, fmt.Sprintf("namespace %q: domain %q is invalid: %s", namespace, domain, msg))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
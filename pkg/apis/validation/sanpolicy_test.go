@@ -0,0 +1,162 @@
+package validation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestSANPolicyCheck(t *testing.T) {
+	policy := &SANPolicy{
+		Namespaces: map[string][]string{
+			"team-a": {"team-a.example.com", "*.apps.example.com"},
+			"team-b": {"team-b.example.com"},
+			AnyNamespace: {
+				"shared.example.com",
+			},
+		},
+	}
+
+	for name, test := range map[string]struct {
+		namespace string
+		attr      map[string]string
+		expErr    string
+	}{
+		"an exact match should be allowed": {
+			namespace: "team-a",
+			attr:      map[string]string{csiapi.DNSNamesKey: "team-a.example.com"},
+		},
+		"a subdomain should be allowed": {
+			namespace: "team-a",
+			attr:      map[string]string{csiapi.DNSNamesKey: "foo.team-a.example.com,Bar.Team-A.example.com."},
+		},
+		"a wildcard within an allowed domain should be allowed": {
+			namespace: "team-a",
+			attr:      map[string]string{csiapi.DNSNamesKey: "*.team-a.example.com"},
+		},
+		"a subdomain of a wildcard domain should be allowed": {
+			namespace: "team-a",
+			attr:      map[string]string{csiapi.DNSNamesKey: "foo.apps.example.com,*.apps.example.com"},
+		},
+		"the apex of a wildcard domain should be denied": {
+			namespace: "team-a",
+			attr:      map[string]string{csiapi.DNSNamesKey: "apps.example.com"},
+			expErr:    `namespace "team-a" may not request certificates for dns name "apps.example.com"`,
+		},
+		"a suffix not on a label boundary should be denied": {
+			namespace: "team-a",
+			attr:      map[string]string{csiapi.DNSNamesKey: "evilteam-a.example.com"},
+			expErr:    `namespace "team-a" may not request certificates for dns name "evilteam-a.example.com"`,
+		},
+		"a wildcard of a parent domain should be denied": {
+			namespace: "team-a",
+			attr:      map[string]string{csiapi.DNSNamesKey: "*.example.com"},
+			expErr:    `namespace "team-a" may not request certificates for dns name "*.example.com"`,
+		},
+		"another namespace's domain should be denied": {
+			namespace: "team-b",
+			attr:      map[string]string{csiapi.DNSNamesKey: "team-b.example.com,team-a.example.com"},
+			expErr:    `namespace "team-b" may not request certificates for dns name "team-a.example.com"`,
+		},
+		"a uri within an allowed domain should be allowed": {
+			namespace: "team-b",
+			attr:      map[string]string{csiapi.URISANsKey: "spiffe://team-b.example.com/ns/team-b/sa/default"},
+		},
+		"a uri outside the allowed domains should be denied": {
+			namespace: "team-b",
+			attr:      map[string]string{csiapi.URISANsKey: "spiffe://example.com/ns/team-b"},
+			expErr:    `namespace "team-b" may not request certificates for uri "spiffe://example.com/ns/team-b"`,
+		},
+		"a uri without a host should be denied": {
+			namespace: "team-b",
+			attr:      map[string]string{csiapi.URISANsKey: "urn:team-b:foo"},
+			expErr:    `namespace "team-b" may not request certificates for uri "urn:team-b:foo" without a host`,
+		},
+		"a namespace without a policy should use the any namespace policy": {
+			namespace: "team-c",
+			attr:      map[string]string{csiapi.DNSNamesKey: "foo.shared.example.com"},
+		},
+		"a namespace without a policy should be denied other domains": {
+			namespace: "team-c",
+			attr:      map[string]string{csiapi.DNSNamesKey: "team-a.example.com"},
+			expErr:    `namespace "team-c" may not request certificates for dns name "team-a.example.com"`,
+		},
+		"ip sans should not be restricted": {
+			namespace: "team-a",
+			attr:      map[string]string{csiapi.IPSANsKey: "10.0.0.1"},
+		},
+		"dns names read from files should be denied": {
+			namespace: "team-a",
+			attr:      map[string]string{csiapi.DNSNamesFromFileKey: "dns-names"},
+			expErr:    `csi.cert-manager.io/dns-names-from-file may not be used in namespace "team-a", its names can't be checked against the san policy`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attr := map[string]string{
+				csiapi.CSIPodNamespaceKey: test.namespace,
+			}
+			for k, v := range test.attr {
+				attr[k] = v
+			}
+
+			err := policy.Check(attr)
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
+func TestSANPolicyLoaderReload(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-san-policy-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "policy.json")
+
+	writeFile := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(`{"namespaces": {"team-a": ["team-a.example.com"]}}`)
+
+	l, err := NewSANPolicyLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(l.Policy().Namespaces["team-a"]); n != 1 {
+		t.Errorf("unexpected number of allowed domains, exp=1 got=%d", n)
+	}
+
+	for _, bad := range []string{
+		`{"namespaces": `,
+		`{"namespaces": {"Team_A": ["team-a.example.com"]}}`,
+		`{"namespaces": {"team-a": ["team_a.example.com"]}}`,
+	} {
+		writeFile(bad)
+
+		if err := l.Reload(); err == nil {
+			t.Errorf("expected error reloading %q", bad)
+		}
+
+		if n := len(l.Policy().Namespaces["team-a"]); n != 1 {
+			t.Errorf("expected previous policy to be kept, got %d allowed domains", n)
+		}
+	}
+}
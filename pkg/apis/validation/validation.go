@@ -1,32 +1,146 @@
 package validation
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
 	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+var validKeyUsages = map[cmapi.KeyUsage]bool{
+	cmapi.UsageSigning:            true,
+	cmapi.UsageDigitalSignature:   true,
+	cmapi.UsageContentCommittment: true,
+	cmapi.UsageKeyEncipherment:    true,
+	cmapi.UsageKeyAgreement:       true,
+	cmapi.UsageDataEncipherment:   true,
+	cmapi.UsageCertSign:           true,
+	cmapi.UsageCRLSign:            true,
+	cmapi.UsageEncipherOnly:       true,
+	cmapi.UsageDecipherOnly:       true,
+	cmapi.UsageAny:                true,
+	cmapi.UsageServerAuth:         true,
+	cmapi.UsageClientAuth:         true,
+	cmapi.UsageCodeSigning:        true,
+	cmapi.UsageEmailProtection:    true,
+	cmapi.UsageSMIME:              true,
+	cmapi.UsageIPsecEndSystem:     true,
+	cmapi.UsageIPsecTunnel:        true,
+	cmapi.UsageIPsecUser:          true,
+	cmapi.UsageTimestamping:       true,
+	cmapi.UsageOCSPSigning:        true,
+	cmapi.UsageMicrosoftSGC:       true,
+	cmapi.UsageNetscapSGC:         true,
+}
+
+const (
+	// Upper bounds of subject attributes, as defined in RFC 5280 and X.520.
+	maxSerialNumberLength  = 64
+	maxStreetAddressLength = 128
 )
 
-func ValidateAttributes(attr map[string]string) error {
+// maxCorrelationIDLength bounds correlation IDs, which are also used as a
+// metric label value.
+const maxCorrelationIDLength = 128
+
+func ValidateAttributes(attr map[string]string, opts *options.Options) error {
 	var errs []string
 
 	if len(attr[csiapi.IssuerNameKey]) == 0 {
 		errs = append(errs, fmt.Sprintf("%s field required", csiapi.IssuerNameKey))
 	}
 
+	errs = fallbackIssuer(attr, errs)
+
 	errs = boolValue(attr[csiapi.IsCAKey], csiapi.IsCAKey, errs)
 
+	keyErrs := keyAlgorithm(attr[csiapi.KeyAlgorithmKey], attr[csiapi.KeySizeKey], opts.FIPSMode, nil)
+	if len(keyErrs) == 0 && len(attr[csiapi.KeyAlgorithmKey]) > 0 {
+		size, _ := strconv.Atoi(attr[csiapi.KeySizeKey])
+		keyErrs = keyStrength(attr[csiapi.KeyAlgorithmKey], size, opts.MinRSAKeySize, opts.AllowedECDSACurves, keyErrs)
+	}
+	errs = append(errs, keyErrs...)
+
+	errs = maxLength(attr[csiapi.SubjectSerialNumberKey], csiapi.SubjectSerialNumberKey, maxSerialNumberLength, errs)
+	for _, street := range util.ParseStringList(attr[csiapi.SubjectStreetAddressesKey]) {
+		errs = maxLength(street, csiapi.SubjectStreetAddressesKey, maxStreetAddressLength, errs)
+	}
+
+	errs = ipAddresses(attr[csiapi.IPSANsKey], errs)
+	errs = extraExtensions(attr, errs)
+	errs = dnsNamesTemplate(attr, errs)
+	errs = maxSANs(attr, opts.MaxSANs, errs)
+
+	errs = keyUsages(attr[csiapi.KeyUsagesKey], attr[csiapi.CertificateTypeKey], errs)
+
+	errs = nodeURISAN(attr[csiapi.NodeURISANPrefixKey], attr[csiapi.NodeIDKey], errs)
+
+	errs = correlationID(attr[csiapi.CorrelationIDKey], errs)
+
 	errs = durationParse(attr[csiapi.DurationKey], csiapi.DurationKey, errs)
 
-	errs = filepathBreakout(attr[csiapi.CAFileKey], csiapi.CAFileKey, errs)
-	errs = filepathBreakout(attr[csiapi.CertFileKey], csiapi.CertFileKey, errs)
-	errs = filepathBreakout(attr[csiapi.KeyFileKey], csiapi.KeyFileKey, errs)
+	errs = encoding(attr[csiapi.EncodingKey], errs)
+	errs = osProfile(attr[csiapi.OSProfileKey], errs)
+
+	for _, k := range csiapi.OutputFileKeys {
+		errs = filepathBreakout(attr[k], k, errs)
+	}
+	errs = outputFiles(attr, opts.MetadataInMount, errs)
 
-	errs = durationParse(attr[csiapi.RenewBeforeKey], csiapi.RenewBeforeKey, errs)
+	errs = boolValue(attr[csiapi.SplitCAKey], csiapi.SplitCAKey, errs)
+	if attr[csiapi.SplitCAKey] == "true" && len(attr[csiapi.CAFileKey]) == 0 {
+		errs = append(errs, fmt.Sprintf("%s requires %s to be set",
+			csiapi.SplitCAKey, csiapi.CAFileKey))
+	}
+
+	errs = boolValue(attr[csiapi.CAToolingFilesKey], csiapi.CAToolingFilesKey, errs)
+	if attr[csiapi.CAToolingFilesKey] == "true" && attr[csiapi.IsCAKey] != "true" {
+		errs = append(errs, fmt.Sprintf("%s requires %s to be true",
+			csiapi.CAToolingFilesKey, csiapi.IsCAKey))
+	}
+
+	errs = renewBefore(attr[csiapi.RenewBeforeKey], errs)
+	errs = renewStrategy(attr, errs)
 	errs = boolValue(attr[csiapi.DisableAutoRenewKey], csiapi.DisableAutoRenewKey, errs)
 	errs = boolValue(attr[csiapi.ReusePrivateKey], csiapi.ReusePrivateKey, errs)
+	errs = durationParse(attr[csiapi.CARefreshIntervalKey], csiapi.CARefreshIntervalKey, errs)
+	errs = boolValue(attr[csiapi.AsyncIssuanceKey], csiapi.AsyncIssuanceKey, errs)
+	errs = boolValue(attr[csiapi.BootstrapSelfSignedKey], csiapi.BootstrapSelfSignedKey, errs)
+	if attr[csiapi.BootstrapSelfSignedKey] == "true" && attr[csiapi.AsyncIssuanceKey] != "true" {
+		errs = append(errs, fmt.Sprintf("%s requires %s to be true",
+			csiapi.BootstrapSelfSignedKey, csiapi.AsyncIssuanceKey))
+	}
+
+	errs = annotations(attr[csiapi.RequestAnnotationsKey], csiapi.RequestAnnotationsKey, errs)
+	errs = labels(attr[csiapi.RequestLabelsKey], csiapi.RequestLabelsKey, errs)
+
+	errs = boolValue(attr[csiapi.ServiceAccountTokenKey], csiapi.ServiceAccountTokenKey, errs)
+	errs = serviceAccountToken(attr, opts.TokenAudiences, errs)
+
+	errs = filepathBreakout(attr[csiapi.CSRFileKey], csiapi.CSRFileKey, errs)
+	errs = csrFile(attr, opts.CSRDir, errs)
+	errs = downwardAPIFiles(attr, opts.DownwardAPIDir, errs)
+	errs = signerPlugin(attr, opts.SignerPlugin, errs)
+	errs = attributesConfigMap(attr[csiapi.AttributesConfigMapKey], opts.ReconcileAttributesInterval, errs)
+	errs = trackPodIP(attr, opts.PodIPCheckInterval, errs)
+	errs = dataRoot(attr[csiapi.DataRootKey], opts.DataRootMap, errs)
+	errs = keySecret(attr, opts.SignerPlugin, errs)
+	errs = keyDelivery(attr, opts, errs)
 
 	if len(errs) > 0 {
 		return errors.New(strings.Join(errs, ", "))
@@ -44,6 +158,677 @@ func filepathBreakout(s, k string, errs []string) []string {
 	return errs
 }
 
+// outputFiles errors if two files written into the volume resolve to the same
+// name, or one would be written inside the other.
+func outputFiles(attr map[string]string, metadataInMount bool, errs []string) []string {
+	type output struct {
+		key, name string
+	}
+
+	var outputs []output
+	for _, k := range csiapi.OutputFileKeys {
+		if len(attr[k]) > 0 {
+			outputs = append(outputs, output{k, filepath.Clean(attr[k])})
+		}
+	}
+
+	if attr[csiapi.CAToolingFilesKey] == "true" {
+		outputs = append(outputs,
+			output{csiapi.CAToolingFilesKey, csiapi.CASerialFileName},
+			output{csiapi.CAToolingFilesKey, csiapi.CAIndexFileName},
+		)
+	}
+
+	if metadataInMount {
+		outputs = append(outputs, output{"--metadata-in-mount", csiapi.MetaDataFileName})
+	}
+
+	for i, a := range outputs {
+		for _, b := range outputs[i+1:] {
+			switch {
+			case a.name == b.name:
+				errs = append(errs, fmt.Sprintf("%s and %s may not both write to %q",
+					a.key, b.key, a.name))
+			case strings.HasPrefix(b.name, a.name+"/"), strings.HasPrefix(a.name, b.name+"/"):
+				errs = append(errs, fmt.Sprintf("%s %q and %s %q may not be written inside one another",
+					a.key, a.name, b.key, b.name))
+			}
+		}
+	}
+
+	return errs
+}
+
+func attributesConfigMap(name string, reconcileInterval time.Duration, errs []string) []string {
+	if len(name) == 0 {
+		return errs
+	}
+
+	if reconcileInterval <= 0 {
+		errs = append(errs, fmt.Sprintf("%s may not be set without --reconcile-attributes-interval",
+			csiapi.AttributesConfigMapKey))
+	}
+
+	for _, msg := range k8svalidation.IsDNS1123Subdomain(name) {
+		errs = append(errs, fmt.Sprintf("%s %q is invalid: %s",
+			csiapi.AttributesConfigMapKey, name, msg))
+	}
+
+	return errs
+}
+
+func trackPodIP(attr map[string]string, checkInterval time.Duration, errs []string) []string {
+	errs = boolValue(attr[csiapi.TrackPodIPKey], csiapi.TrackPodIPKey, errs)
+	if attr[csiapi.TrackPodIPKey] != "true" {
+		return errs
+	}
+
+	if checkInterval <= 0 {
+		errs = append(errs, fmt.Sprintf("%s may not be set without --pod-ip-check-interval",
+			csiapi.TrackPodIPKey))
+	}
+
+	if len(attr[csiapi.CSRFileKey]) > 0 {
+		errs = append(errs, fmt.Sprintf("%s may not be set with %s",
+			csiapi.TrackPodIPKey, csiapi.CSRFileKey))
+	}
+
+	return errs
+}
+
+func dataRoot(name string, dataRootMap map[string]string, errs []string) []string {
+	if len(name) == 0 {
+		return errs
+	}
+
+	if _, ok := dataRootMap[name]; !ok {
+		errs = append(errs, fmt.Sprintf("%s %q is not a data root of --data-root-map",
+			csiapi.DataRootKey, name))
+	}
+
+	return errs
+}
+
+func keyAlgorithm(algorithm, size string, fipsMode bool, errs []string) []string {
+	if len(algorithm) == 0 {
+		return errs
+	}
+
+	sizeI, err := strconv.Atoi(size)
+	if err != nil {
+		return append(errs, fmt.Sprintf("%s must be a valid integer: %s",
+			csiapi.KeySizeKey, err))
+	}
+
+	switch algorithm {
+	case csiapi.RSAKeyAlgorithm:
+		if sizeI < 2048 || sizeI > 8192 {
+			errs = append(errs, fmt.Sprintf("%s for rsa keys must be between 2048 and 8192, got %d",
+				csiapi.KeySizeKey, sizeI))
+		} else if fipsMode && sizeI != 2048 && sizeI != 3072 && sizeI != 4096 {
+			errs = append(errs, fmt.Sprintf("%s for rsa keys must be one of 2048, 3072 or 4096 in FIPS mode, got %d",
+				csiapi.KeySizeKey, sizeI))
+		}
+
+	case csiapi.ECDSAKeyAlgorithm:
+		if sizeI != 256 && sizeI != 384 && sizeI != 521 {
+			errs = append(errs, fmt.Sprintf("%s for ecdsa keys must be one of 256, 384 or 521, got %d",
+				csiapi.KeySizeKey, sizeI))
+		}
+
+	default:
+		errs = append(errs, fmt.Sprintf("%s must be one of %q or %q, got %q",
+			csiapi.KeyAlgorithmKey, csiapi.RSAKeyAlgorithm, csiapi.ECDSAKeyAlgorithm, algorithm))
+	}
+
+	return errs
+}
+
+// keyStrength checks a key of the given algorithm and size, in bits, keeps to
+// the minimum key strength policy of --min-rsa-key-size and
+// --allowed-ecdsa-curves.
+func keyStrength(algorithm string, size, minRSAKeySize int, allowedCurves []string, errs []string) []string {
+	switch algorithm {
+	case csiapi.RSAKeyAlgorithm:
+		if size < minRSAKeySize {
+			errs = append(errs, fmt.Sprintf("rsa keys must be at least %d bits by policy, got %d",
+				minRSAKeySize, size))
+		}
+
+	case csiapi.ECDSAKeyAlgorithm:
+		if len(allowedCurves) == 0 {
+			return errs
+		}
+
+		curve := fmt.Sprintf("P-%d", size)
+		for _, allowed := range allowedCurves {
+			if curve == allowed {
+				return errs
+			}
+		}
+
+		errs = append(errs, fmt.Sprintf("ecdsa curve %s is not allowed by policy, must be one of %s",
+			curve, strings.Join(allowedCurves, ", ")))
+	}
+
+	return errs
+}
+
+func encoding(s string, errs []string) []string {
+	if len(s) == 0 {
+		return errs
+	}
+
+	if s != csiapi.PEMEncoding && s != csiapi.DEREncoding {
+		errs = append(errs, fmt.Sprintf("%s must be one of %q or %q, got %q",
+			csiapi.EncodingKey, csiapi.PEMEncoding, csiapi.DEREncoding, s))
+	}
+
+	return errs
+}
+
+func osProfile(s string, errs []string) []string {
+	if len(s) == 0 {
+		return errs
+	}
+
+	if s != csiapi.LinuxOSProfile && s != csiapi.WindowsOSProfile {
+		errs = append(errs, fmt.Sprintf("%s must be one of %q or %q, got %q",
+			csiapi.OSProfileKey, csiapi.LinuxOSProfile, csiapi.WindowsOSProfile, s))
+	}
+
+	return errs
+}
+
+// dnsNamesTemplate checks the DNS names template of the volume renders, with
+// the metadata of its pod, to valid DNS names.
+func dnsNamesTemplate(attr map[string]string, errs []string) []string {
+	rendered, err := util.RenderDNSNamesTemplate(attr)
+	if err != nil {
+		return append(errs, fmt.Sprintf("%s is invalid: %s", csiapi.DNSNamesTemplateKey, err))
+	}
+
+	for _, name := range rendered {
+		for _, msg := range k8svalidation.IsDNS1123Subdomain(strings.TrimPrefix(name, "*.")) {
+			errs = append(errs, fmt.Sprintf("%s rendered invalid name %q: %s",
+				csiapi.DNSNamesTemplateKey, name, msg))
+		}
+	}
+
+	return errs
+}
+
+func ipAddresses(ips string, errs []string) []string {
+	if len(ips) == 0 {
+		return errs
+	}
+
+	for _, ip := range strings.Split(ips, ",") {
+		if net.ParseIP(strings.TrimSpace(ip)) == nil {
+			errs = append(errs, fmt.Sprintf("%s contains invalid ip address %q",
+				csiapi.IPSANsKey, ip))
+		}
+	}
+
+	return errs
+}
+
+// extraExtensions checks the extra extensions are valid, and are not set with
+// a workload provided CSR which carries its own extensions.
+func extraExtensions(attr map[string]string, errs []string) []string {
+	if len(attr[csiapi.ExtraExtensionsKey]) == 0 {
+		return errs
+	}
+
+	if _, err := util.ParseExtraExtensions(attr[csiapi.ExtraExtensionsKey]); err != nil {
+		errs = append(errs, fmt.Sprintf("%s: %s", csiapi.ExtraExtensionsKey, err))
+	}
+
+	if len(attr[csiapi.CSRFileKey]) > 0 {
+		errs = append(errs, fmt.Sprintf("%s may not be set with %s",
+			csiapi.ExtraExtensionsKey, csiapi.CSRFileKey))
+	}
+
+	return errs
+}
+
+// keyUsages validates the explicit key usages and certificate type. Since the
+// certificate type is a shorthand for a set of key usages, both may not be set
+// on the same volume.
+func keyUsages(usages, certType string, errs []string) []string {
+	if len(usages) > 0 && len(certType) > 0 {
+		return append(errs, fmt.Sprintf("%s and %s may not both be set",
+			csiapi.KeyUsagesKey, csiapi.CertificateTypeKey))
+	}
+
+	for _, usage := range util.ParseStringList(usages) {
+		if !validKeyUsages[cmapi.KeyUsage(strings.TrimSpace(usage))] {
+			errs = append(errs, fmt.Sprintf("%s contains unknown key usage %q",
+				csiapi.KeyUsagesKey, usage))
+		}
+	}
+
+	switch certType {
+	case "", csiapi.ServerCertificateType, csiapi.ClientCertificateType, csiapi.PeerCertificateType:
+	default:
+		errs = append(errs, fmt.Sprintf("%s must be one of %q, %q or %q, got %q",
+			csiapi.CertificateTypeKey, csiapi.ServerCertificateType,
+			csiapi.ClientCertificateType, csiapi.PeerCertificateType, certType))
+	}
+
+	return errs
+}
+
+func nodeURISAN(prefix, nodeID string, errs []string) []string {
+	if len(prefix) == 0 {
+		return errs
+	}
+
+	if len(nodeID) == 0 {
+		return append(errs, fmt.Sprintf("%s requires the node id to be known",
+			csiapi.NodeURISANPrefixKey))
+	}
+
+	uri, err := url.Parse(prefix + nodeID)
+	if err != nil {
+		return append(errs, fmt.Sprintf("%s must form a valid uri with the node id: %s",
+			csiapi.NodeURISANPrefixKey, err))
+	}
+
+	if !uri.IsAbs() {
+		errs = append(errs, fmt.Sprintf("%s must form an absolute uri with the node id, got %q",
+			csiapi.NodeURISANPrefixKey, uri))
+	}
+
+	return errs
+}
+
+func renewStrategy(attr map[string]string, errs []string) []string {
+	var set []string
+	for _, k := range []string{csiapi.RenewBeforeKey, csiapi.RenewAtKey, csiapi.RenewScheduleKey} {
+		if len(attr[k]) > 0 {
+			set = append(set, k)
+		}
+	}
+
+	if len(set) > 1 {
+		errs = append(errs, fmt.Sprintf("only one of %s may be set",
+			strings.Join(set, ", ")))
+	}
+
+	if s := attr[csiapi.RenewAtKey]; len(s) > 0 {
+		if _, err := renew.ParseRenewAt(s); err != nil {
+			errs = append(errs, fmt.Sprintf("%s %s", csiapi.RenewAtKey, err))
+		}
+	}
+
+	if s := attr[csiapi.RenewScheduleKey]; len(s) > 0 {
+		schedule, err := renew.ParseSchedule(s)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s must be a valid schedule: %s", csiapi.RenewScheduleKey, err))
+		} else if schedule.Next(time.Now()).IsZero() {
+			errs = append(errs, fmt.Sprintf("%s never fires, got %q", csiapi.RenewScheduleKey, s))
+		}
+	}
+
+	return errs
+}
+
+func serviceAccountToken(attr map[string]string, audiences []string, errs []string) []string {
+	audience := attr[csiapi.ServiceAccountTokenAudienceKey]
+
+	if attr[csiapi.ServiceAccountTokenKey] != "true" && len(attr[csiapi.ServiceAccountTokenFileKey]) == 0 {
+		if len(audience) > 0 {
+			errs = append(errs, fmt.Sprintf("%s requires %s or %s to be set",
+				csiapi.ServiceAccountTokenAudienceKey, csiapi.ServiceAccountTokenKey, csiapi.ServiceAccountTokenFileKey))
+		}
+
+		return errs
+	}
+
+	if len(audiences) == 0 {
+		return append(errs, "service account tokens are disabled, no --token-audience configured")
+	}
+
+	if len(attr[csiapi.CSIServiceAccountNameKey]) == 0 {
+		errs = append(errs, fmt.Sprintf("service account tokens require %s to be set in context",
+			csiapi.CSIServiceAccountNameKey))
+	}
+
+	if len(audience) == 0 {
+		return errs
+	}
+
+	for _, aud := range audiences {
+		if aud == audience {
+			return errs
+		}
+	}
+
+	return append(errs, fmt.Sprintf("%s must be one of %q, got %q",
+		csiapi.ServiceAccountTokenAudienceKey, audiences, audience))
+}
+
+// csrFile checks a workload provided CSR can be used. The CSR is written by
+// the workload once its volume is mounted, so issuance must be async, and
+// the driver holds no private key to reuse or self sign with.
+func csrFile(attr map[string]string, csrDir string, errs []string) []string {
+	if len(attr[csiapi.CSRFileKey]) == 0 {
+		return errs
+	}
+
+	if len(csrDir) == 0 {
+		return append(errs, "workload provided CSRs are disabled, no --csr-dir configured")
+	}
+
+	if attr[csiapi.AsyncIssuanceKey] != "true" {
+		errs = append(errs, fmt.Sprintf("%s requires %s to be true",
+			csiapi.CSRFileKey, csiapi.AsyncIssuanceKey))
+	}
+
+	for _, k := range []string{csiapi.ReusePrivateKey, csiapi.BootstrapSelfSignedKey} {
+		if attr[k] == "true" {
+			errs = append(errs, fmt.Sprintf("%s may not be set with %s", k, csiapi.CSRFileKey))
+		}
+	}
+
+	return errs
+}
+
+// downwardAPIFiles checks the files request fields are read from stay within
+// the downward API directory, and don't conflict with the request's other
+// sources of the same fields.
+func downwardAPIFiles(attr map[string]string, downwardAPIDir string, errs []string) []string {
+	var set bool
+	for _, k := range csiapi.DownwardAPIFileKeys {
+		if len(attr[k]) == 0 {
+			continue
+		}
+		set = true
+
+		errs = filepathBreakout(attr[k], k, errs)
+		if filepath.IsAbs(attr[k]) {
+			errs = append(errs, fmt.Sprintf("%s must be relative to the downward API directory, got %q",
+				k, attr[k]))
+		}
+
+		if len(attr[csiapi.CSRFileKey]) > 0 {
+			errs = append(errs, fmt.Sprintf("%s may not be set with %s", k, csiapi.CSRFileKey))
+		}
+	}
+
+	if !set {
+		return errs
+	}
+
+	if len(downwardAPIDir) == 0 {
+		errs = append(errs, "reading request fields from files is disabled, no --downward-api-dir configured")
+	}
+
+	if len(attr[csiapi.CommonNameFromFileKey]) > 0 && len(attr[csiapi.CommonNameKey]) > 0 {
+		errs = append(errs, fmt.Sprintf("%s may not be set with %s",
+			csiapi.CommonNameFromFileKey, csiapi.CommonNameKey))
+	}
+
+	return errs
+}
+
+// ValidateDownwardAPIFileValues checks the values read from the file of the
+// given downward API file attribute.
+func ValidateDownwardAPIFileValues(key string, values []string) error {
+	var errs []string
+
+	if len(values) == 0 {
+		errs = append(errs, "no values")
+	}
+
+	switch key {
+	case csiapi.CommonNameFromFileKey:
+		if len(values) > 1 {
+			errs = append(errs, fmt.Sprintf("expected a single common name, got %d", len(values)))
+		}
+
+	case csiapi.DNSNamesFromFileKey:
+		for _, name := range values {
+			for _, msg := range k8svalidation.IsDNS1123Subdomain(strings.TrimPrefix(name, "*.")) {
+				errs = append(errs, fmt.Sprintf("invalid dns name %q: %s", name, msg))
+			}
+		}
+
+	case csiapi.IPSANsFromFileKey:
+		for _, ip := range values {
+			if net.ParseIP(ip) == nil {
+				errs = append(errs, fmt.Sprintf("invalid ip address %q", ip))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// ValidateMaxSANs errors if the attributes request more than the maximum
+// number of subject alternative names. There is no limit if max is zero.
+func ValidateMaxSANs(attr map[string]string, max int) error {
+	if errs := maxSANs(attr, max, nil); len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// signerPlugin checks volumes whose key is held by the signer plugin don't
+// need the driver to hold the key.
+func signerPlugin(attr map[string]string, signerPlugin string, errs []string) []string {
+	if len(signerPlugin) == 0 || len(attr[csiapi.CSRFileKey]) > 0 {
+		return errs
+	}
+
+	for _, k := range []string{csiapi.ReusePrivateKey, csiapi.BootstrapSelfSignedKey} {
+		if attr[k] == "true" {
+			errs = append(errs, fmt.Sprintf("%s may not be set with --signer-plugin", k))
+		}
+	}
+
+	return errs
+}
+
+// keyDelivery checks the key delivery is known, and that keys delivered
+// through a named pipe are generated by the driver and never need to be read
+// back or copied.
+func keyDelivery(attr map[string]string, opts *options.Options, errs []string) []string {
+	switch attr[csiapi.KeyDeliveryKey] {
+	case "", csiapi.FileKeyDelivery:
+		return errs
+	case csiapi.FIFOKeyDelivery:
+	default:
+		return append(errs, fmt.Sprintf("%s must be one of %q or %q, got %q",
+			csiapi.KeyDeliveryKey, csiapi.FileKeyDelivery, csiapi.FIFOKeyDelivery, attr[csiapi.KeyDeliveryKey]))
+	}
+
+	for _, k := range []string{csiapi.ReusePrivateKey, csiapi.BootstrapSelfSignedKey} {
+		if attr[k] == "true" {
+			errs = append(errs, fmt.Sprintf("%s may not be set with %s %s",
+				k, csiapi.KeyDeliveryKey, csiapi.FIFOKeyDelivery))
+		}
+	}
+
+	for _, k := range []string{csiapi.CSRFileKey, csiapi.KeySecretKey} {
+		if len(attr[k]) > 0 {
+			errs = append(errs, fmt.Sprintf("%s may not be set with %s %s",
+				k, csiapi.KeyDeliveryKey, csiapi.FIFOKeyDelivery))
+		}
+	}
+
+	if len(opts.SignerPlugin) > 0 {
+		errs = append(errs, fmt.Sprintf("%s %s may not be used with --signer-plugin",
+			csiapi.KeyDeliveryKey, csiapi.FIFOKeyDelivery))
+	}
+
+	if opts.AtomicDirLayout {
+		errs = append(errs, fmt.Sprintf("%s %s may not be used with --atomic-dir-layout",
+			csiapi.KeyDeliveryKey, csiapi.FIFOKeyDelivery))
+	}
+
+	return errs
+}
+
+// keySecret checks volumes with a key Secret have a key held by the driver.
+// The stored key is always reused, so it may not be reused from the volume.
+func keySecret(attr map[string]string, signerPlugin string, errs []string) []string {
+	name := attr[csiapi.KeySecretKey]
+	if len(name) == 0 {
+		return errs
+	}
+
+	for _, msg := range k8svalidation.IsDNS1123Subdomain(name) {
+		errs = append(errs, fmt.Sprintf("%s %q is invalid: %s",
+			csiapi.KeySecretKey, name, msg))
+	}
+
+	if len(attr[csiapi.CSRFileKey]) > 0 {
+		errs = append(errs, fmt.Sprintf("%s may not be set with %s",
+			csiapi.KeySecretKey, csiapi.CSRFileKey))
+	}
+
+	if len(signerPlugin) > 0 {
+		errs = append(errs, fmt.Sprintf("%s may not be set with --signer-plugin",
+			csiapi.KeySecretKey))
+	}
+
+	if len(attr[csiapi.ReusePrivateKey]) > 0 {
+		errs = append(errs, fmt.Sprintf("%s may not be set with %s, the stored key is always reused",
+			csiapi.ReusePrivateKey, csiapi.KeySecretKey))
+	}
+
+	if len(attr[csiapi.CSIPodNameKey]) == 0 {
+		errs = append(errs, fmt.Sprintf("%s requires the pod name, %s",
+			csiapi.KeySecretKey, csiapi.CSIPodNameKey))
+	}
+
+	return errs
+}
+
+// ValidateCSR checks a workload provided CSR is correctly signed and keeps to
+// the same key and subject alternative name constraints as volumes.
+func ValidateCSR(csr *x509.CertificateRequest, fipsMode bool, maxSANs, minRSAKeySize int, allowedCurves []string) error {
+	var errs []string
+
+	if err := csr.CheckSignature(); err != nil {
+		errs = append(errs, fmt.Sprintf("invalid signature: %s", err))
+	}
+
+	switch pub := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		size := pub.N.BitLen()
+		if size < 2048 || size > 8192 {
+			errs = append(errs, fmt.Sprintf("rsa keys must be between 2048 and 8192 bits, got %d", size))
+		} else if fipsMode && size != 2048 && size != 3072 && size != 4096 {
+			errs = append(errs, fmt.Sprintf("rsa keys must be one of 2048, 3072 or 4096 bits in FIPS mode, got %d", size))
+		} else {
+			errs = keyStrength(csiapi.RSAKeyAlgorithm, size, minRSAKeySize, allowedCurves, errs)
+		}
+
+	case *ecdsa.PublicKey:
+		size := pub.Curve.Params().BitSize
+		if size != 256 && size != 384 && size != 521 {
+			errs = append(errs, fmt.Sprintf("ecdsa keys must be one of 256, 384 or 521 bits, got %d", size))
+		} else {
+			errs = keyStrength(csiapi.ECDSAKeyAlgorithm, size, minRSAKeySize, allowedCurves, errs)
+		}
+
+	default:
+		errs = append(errs, fmt.Sprintf("public key algorithm must be rsa or ecdsa, got %s", csr.PublicKeyAlgorithm))
+	}
+
+	count := len(csr.DNSNames) + len(csr.IPAddresses) + len(csr.URIs)
+	if maxSANs > 0 && count > maxSANs {
+		errs = append(errs, fmt.Sprintf("too many subject alternative names requested, maximum %d, got %d",
+			maxSANs, count))
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// maxSANs guards the issuer against requests with a huge number of subject
+// alternative names. There is no limit if max is zero.
+func maxSANs(attr map[string]string, max int, errs []string) []string {
+	count := len(util.ParseStringList(attr[csiapi.DNSNamesKey])) +
+		len(util.ParseStringList(attr[csiapi.IPSANsKey])) +
+		len(util.ParseStringList(attr[csiapi.URISANsKey]))
+
+	if len(attr[csiapi.NodeURISANPrefixKey]) > 0 {
+		count++
+	}
+
+	// Templates that fail to render are reported by dnsNamesTemplate.
+	if rendered, err := util.RenderDNSNamesTemplate(attr); err == nil {
+		count += len(rendered)
+	}
+
+	if max > 0 && count > max {
+		errs = append(errs, fmt.Sprintf("too many subject alternative names requested, maximum %d, got %d",
+			max, count))
+	}
+
+	return errs
+}
+
+// fallbackIssuer checks the fallback issuer is named if any of its fields are
+// set, and differs from the issuer.
+func fallbackIssuer(attr map[string]string, errs []string) []string {
+	name := attr[csiapi.FallbackIssuerNameKey]
+	kind := attr[csiapi.FallbackIssuerKindKey]
+	group := attr[csiapi.FallbackIssuerGroupKey]
+
+	if len(name) == 0 {
+		if len(kind) > 0 || len(group) > 0 {
+			errs = append(errs, fmt.Sprintf("%s field required if %s or %s is set",
+				csiapi.FallbackIssuerNameKey, csiapi.FallbackIssuerKindKey, csiapi.FallbackIssuerGroupKey))
+		}
+
+		return errs
+	}
+
+	if name == attr[csiapi.IssuerNameKey] && kind == attr[csiapi.IssuerKindKey] &&
+		group == attr[csiapi.IssuerGroupKey] {
+		errs = append(errs, "fallback issuer must differ from the issuer")
+	}
+
+	return errs
+}
+
+// correlationID checks a correlation ID is an opaque string of printable,
+// non space, ASCII characters of a reasonable length.
+func correlationID(s string, errs []string) []string {
+	errs = maxLength(s, csiapi.CorrelationIDKey, maxCorrelationIDLength, errs)
+
+	for _, r := range s {
+		if r <= ' ' || r > '~' {
+			return append(errs, fmt.Sprintf("%s may only contain printable ASCII characters other than space, got %q",
+				csiapi.CorrelationIDKey, s))
+		}
+	}
+
+	return errs
+}
+
+func maxLength(s, k string, max int, errs []string) []string {
+	if len(s) > max {
+		errs = append(errs, fmt.Sprintf("%s values may not be longer than %d characters, got %d",
+			k, max, len(s)))
+	}
+
+	return errs
+}
+
 func durationParse(s, k string, errs []string) []string {
 	if len(s) == 0 {
 		return errs
@@ -57,6 +842,26 @@ func durationParse(s, k string, errs []string) []string {
 	return errs
 }
 
+// renewBefore checks the renew before duration is valid and greater than
+// zero, since renewal is scheduled that long before expiry.
+func renewBefore(s string, errs []string) []string {
+	if len(s) == 0 {
+		return errs
+	}
+
+	before, err := time.ParseDuration(s)
+	if err != nil {
+		return durationParse(s, csiapi.RenewBeforeKey, errs)
+	}
+
+	if before <= 0 {
+		errs = append(errs, fmt.Sprintf("%s must be greater than zero, got %q",
+			csiapi.RenewBeforeKey, s))
+	}
+
+	return errs
+}
+
 func boolValue(s, k string, errs []string) []string {
 	if len(s) == 0 {
 		return errs
@@ -69,3 +874,42 @@ func boolValue(s, k string, errs []string) []string {
 
 	return errs
 }
+
+func annotations(s, k string, errs []string) []string {
+	kvs, err := util.ParseKeyValues(s)
+	if err != nil {
+		return append(errs, fmt.Sprintf("%s must be a comma separated list of key=value pairs: %s",
+			k, err))
+	}
+
+	for key := range kvs {
+		for _, msg := range k8svalidation.IsQualifiedName(strings.ToLower(key)) {
+			errs = append(errs, fmt.Sprintf("%s has invalid annotation key %q: %s",
+				k, key, msg))
+		}
+	}
+
+	return errs
+}
+
+func labels(s, k string, errs []string) []string {
+	kvs, err := util.ParseKeyValues(s)
+	if err != nil {
+		return append(errs, fmt.Sprintf("%s must be a comma separated list of key=value pairs: %s",
+			k, err))
+	}
+
+	for key, value := range kvs {
+		for _, msg := range k8svalidation.IsQualifiedName(key) {
+			errs = append(errs, fmt.Sprintf("%s has invalid label key %q: %s",
+				k, key, msg))
+		}
+
+		for _, msg := range k8svalidation.IsValidLabelValue(value) {
+			errs = append(errs, fmt.Sprintf("%s has invalid label value %q for key %q: %s",
+				k, value, key, msg))
+		}
+	}
+
+	return errs
+}
@@ -1,10 +1,19 @@
 package validation
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"errors"
 	"strings"
 	"testing"
 
+	"github.com/spf13/cobra"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
 	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
 )
 
@@ -43,11 +52,85 @@ func TestValidateCertManagerAttributes(t *testing.T) {
 			},
 			expError: nil,
 		},
+		"ca tooling files with is-ca should return no error": {
+			attr: map[string]string{
+				csiapi.IssuerNameKey:     "test-issuer",
+				csiapi.CommonNameKey:     "foo.bar",
+				csiapi.IsCAKey:           "true",
+				csiapi.CAToolingFilesKey: "true",
+			},
+			expError: nil,
+		},
+		"ca tooling files without is-ca should error": {
+			attr: map[string]string{
+				csiapi.IssuerNameKey:     "test-issuer",
+				csiapi.CommonNameKey:     "foo.bar",
+				csiapi.IsCAKey:           "false",
+				csiapi.CAToolingFilesKey: "true",
+			},
+			expError: errors.New(
+				"csi.cert-manager.io/ca-tooling-files requires csi.cert-manager.io/is-ca to be true"),
+		},
+		"split ca with a ca file should return no error": {
+			attr: map[string]string{
+				csiapi.IssuerNameKey: "test-issuer",
+				csiapi.CommonNameKey: "foo.bar",
+				csiapi.CAFileKey:     "ca.crt",
+				csiapi.SplitCAKey:    "true",
+			},
+			expError: nil,
+		},
+		"split ca without a ca file should error": {
+			attr: map[string]string{
+				csiapi.IssuerNameKey: "test-issuer",
+				csiapi.CommonNameKey: "foo.bar",
+				csiapi.SplitCAKey:    "true",
+			},
+			expError: errors.New(
+				"csi.cert-manager.io/split-ca requires csi.cert-manager.io/ca-file to be set"),
+		},
+		"split ca with a bad bool should error": {
+			attr: map[string]string{
+				csiapi.IssuerNameKey: "test-issuer",
+				csiapi.CommonNameKey: "foo.bar",
+				csiapi.CAFileKey:     "ca.crt",
+				csiapi.SplitCAKey:    "yes",
+			},
+			expError: errors.New(
+				"csi.cert-manager.io/split-ca may only be set to 'true' for 'false'"),
+		},
+		"an attributes configmap without reconciling should error": {
+			attr: map[string]string{
+				csiapi.IssuerNameKey:          "test-issuer",
+				csiapi.CommonNameKey:          "foo.bar",
+				csiapi.AttributesConfigMapKey: "test-attributes",
+			},
+			expError: errors.New(
+				"csi.cert-manager.io/attributes-configmap may not be set without --reconcile-attributes-interval"),
+		},
+		"tracking the pod ip without checking it should error": {
+			attr: map[string]string{
+				csiapi.IssuerNameKey: "test-issuer",
+				csiapi.CommonNameKey: "foo.bar",
+				csiapi.TrackPodIPKey: "true",
+			},
+			expError: errors.New(
+				"csi.cert-manager.io/track-pod-ip may not be set without --pod-ip-check-interval"),
+		},
+		"an unknown data root should error": {
+			attr: map[string]string{
+				csiapi.IssuerNameKey: "test-issuer",
+				csiapi.CommonNameKey: "foo.bar",
+				csiapi.DataRootKey:   "encrypted",
+			},
+			expError: errors.New(
+				`csi.cert-manager.io/data-root "encrypted" is not a data root of --data-root-map`),
+		},
 	}
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			err := ValidateAttributes(test.attr)
+			err := ValidateAttributes(test.attr, new(options.Options))
 			if test.expError == nil {
 				if err != nil {
 					t.Errorf("unexpected error, got=%s",
@@ -65,6 +148,120 @@ func TestValidateCertManagerAttributes(t *testing.T) {
 	}
 }
 
+func TestValidateAttributesKeyStrength(t *testing.T) {
+	opts := &options.Options{
+		MinRSAKeySize:      3072,
+		AllowedECDSACurves: []string{"P-384", "P-521"},
+	}
+
+	for name, test := range map[string]struct {
+		algorithm, size string
+		expErr          string
+	}{
+		"rsa at the minimum size should not error": {
+			"rsa", "3072",
+			"",
+		},
+		"rsa below the minimum size should error": {
+			"rsa", "2048",
+			"rsa keys must be at least 3072 bits by policy, got 2048",
+		},
+		"rsa 1024 should only error on the supported sizes": {
+			"rsa", "1024",
+			"csi.cert-manager.io/key-size for rsa keys must be between 2048 and 8192, got 1024",
+		},
+		"an allowed curve should not error": {
+			"ecdsa", "384",
+			"",
+		},
+		"a disallowed curve should error": {
+			"ecdsa", "256",
+			"ecdsa curve P-256 is not allowed by policy, must be one of P-384, P-521",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateAttributes(map[string]string{
+				csiapi.IssuerNameKey:   "test-issuer",
+				csiapi.CommonNameKey:   "foo.bar",
+				csiapi.KeyAlgorithmKey: test.algorithm,
+				csiapi.KeySizeKey:      test.size,
+			}, opts)
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != test.expErr {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateAttributesSecurityLevel(t *testing.T) {
+	for name, test := range map[string]struct {
+		level, algorithm, size string
+		expErr                 string
+	}{
+		"legacy should allow rsa 2048": {
+			options.LegacySecurityLevel, "rsa", "2048",
+			"",
+		},
+		"legacy should allow P-521": {
+			options.LegacySecurityLevel, "ecdsa", "521",
+			"",
+		},
+		"modern should allow P-256": {
+			options.ModernSecurityLevel, "ecdsa", "256",
+			"",
+		},
+		"modern should reject rsa 2048": {
+			options.ModernSecurityLevel, "rsa", "2048",
+			"rsa keys must be at least 3072 bits by policy, got 2048",
+		},
+		"modern should reject P-521": {
+			options.ModernSecurityLevel, "ecdsa", "521",
+			"ecdsa curve P-521 is not allowed by policy, must be one of P-256, P-384",
+		},
+		"fips should allow rsa 3072": {
+			options.FIPSSecurityLevel, "rsa", "3072",
+			"",
+		},
+		"fips should reject rsa 2048": {
+			options.FIPSSecurityLevel, "rsa", "2048",
+			"rsa keys must be at least 3072 bits by policy, got 2048",
+		},
+		"fips should reject rsa sizes not approved by FIPS": {
+			options.FIPSSecurityLevel, "rsa", "6144",
+			"csi.cert-manager.io/key-size for rsa keys must be one of 2048, 3072 or 4096 in FIPS mode, got 6144",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := &options.Options{SecurityLevel: test.level}
+			opts.ApplySecurityLevel(&cobra.Command{})
+
+			err := ValidateAttributes(map[string]string{
+				csiapi.IssuerNameKey:   "test-issuer",
+				csiapi.CommonNameKey:   "foo.bar",
+				csiapi.KeyAlgorithmKey: test.algorithm,
+				csiapi.KeySizeKey:      test.size,
+			}, opts)
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != test.expErr {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
 func TestFilePathBreakOut(t *testing.T) {
 	for name, test := range map[string]struct {
 		s       string
@@ -102,6 +299,82 @@ func TestFilePathBreakOut(t *testing.T) {
 	}
 }
 
+func TestOutputFiles(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr            map[string]string
+		metadataInMount bool
+		expErrs         string
+	}{
+		"distinct filenames should not error": {
+			attr: map[string]string{
+				csiapi.CertFileKey:  "crt.pem",
+				csiapi.KeyFileKey:   "key.pem",
+				csiapi.CAFileKey:    "ca.pem",
+				csiapi.ChainFileKey: "certs/chain.pem",
+			},
+			expErrs: "",
+		},
+		"colliding filenames should error": {
+			attr: map[string]string{
+				csiapi.CertFileKey: "crt.pem",
+				csiapi.KeyFileKey:  "key.pem",
+				csiapi.CAFileKey:   "crt.pem",
+			},
+			expErrs: `csi.cert-manager.io/certificate-file and csi.cert-manager.io/ca-file may not both write to "crt.pem"`,
+		},
+		"filenames colliding once cleaned should error": {
+			attr: map[string]string{
+				csiapi.CertFileKey:        "certs/crt.pem",
+				csiapi.FingerprintFileKey: "certs/./crt.pem",
+			},
+			expErrs: `csi.cert-manager.io/certificate-file and csi.cert-manager.io/fingerprint-file may not both write to "certs/crt.pem"`,
+		},
+		"a touch file colliding with the certificate should error": {
+			attr: map[string]string{
+				csiapi.CertFileKey:         "crt.pem",
+				csiapi.TouchFileOnRenewKey: "crt.pem",
+			},
+			expErrs: `csi.cert-manager.io/certificate-file and csi.cert-manager.io/touch-file-on-renew may not both write to "crt.pem"`,
+		},
+		"a filename inside another should error": {
+			attr: map[string]string{
+				csiapi.CertFileKey:  "crt.pem",
+				csiapi.ChainFileKey: "crt.pem/chain.pem",
+			},
+			expErrs: `csi.cert-manager.io/certificate-file "crt.pem" and csi.cert-manager.io/chain-file "crt.pem/chain.pem" may not be written inside one another`,
+		},
+		"a filename colliding with the metadata file in the mount should error": {
+			attr: map[string]string{
+				csiapi.ServiceAccountTokenFileKey: "metadata.json",
+			},
+			metadataInMount: true,
+			expErrs:         `csi.cert-manager.io/service-account-token-file and --metadata-in-mount may not both write to "metadata.json"`,
+		},
+		"a filename colliding with the ca tooling files should error": {
+			attr: map[string]string{
+				csiapi.ChainFileKey:      "index.txt",
+				csiapi.CAToolingFilesKey: "true",
+			},
+			expErrs: `csi.cert-manager.io/chain-file and csi.cert-manager.io/ca-tooling-files may not both write to "index.txt"`,
+		},
+		"a filename of the metadata file without it in the mount should not error": {
+			attr: map[string]string{
+				csiapi.ServiceAccountTokenFileKey: "metadata.json",
+			},
+			expErrs: "",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := outputFiles(test.attr, test.metadataInMount, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
 func TestDurationParse(t *testing.T) {
 	for name, test := range map[string]struct {
 		s       string
@@ -131,6 +404,47 @@ func TestDurationParse(t *testing.T) {
 	}
 }
 
+func TestRenewBefore(t *testing.T) {
+	for name, test := range map[string]struct {
+		s       string
+		expErrs string
+	}{
+		"no renew before should not error": {
+			"",
+			"",
+		},
+		"a positive renew before should not error": {
+			"1h",
+			"",
+		},
+		"the smallest positive renew before should not error": {
+			"1ns",
+			"",
+		},
+		"a zero renew before should error": {
+			"0s",
+			`csi.cert-manager.io/renew-before must be greater than zero, got "0s"`,
+		},
+		"a negative renew before should error": {
+			"-1h",
+			`csi.cert-manager.io/renew-before must be greater than zero, got "-1h"`,
+		},
+		"a bad renew before should error": {
+			"foo",
+			`csi.cert-manager.io/renew-before must be a valid duration string: time: invalid duration "foo"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := renewBefore(test.s, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
 func TestBoolValue(t *testing.T) {
 	for name, test := range map[string]struct {
 		s       string
@@ -171,3 +485,1093 @@ func TestBoolValue(t *testing.T) {
 		})
 	}
 }
+
+func TestAnnotations(t *testing.T) {
+	for name, test := range map[string]struct {
+		s       string
+		expErrs string
+	}{
+		"no annotations should not error": {
+			"",
+			"",
+		},
+		"valid annotations should not error": {
+			"foo=bar,example.com/approve=true",
+			"",
+		},
+		"an empty value should not error": {
+			"foo=",
+			"",
+		},
+		"a missing '=' should error": {
+			"foo=bar,bar",
+			`T must be a comma separated list of key=value pairs: expected key=value pair, got "bar"`,
+		},
+		"an empty key should error": {
+			"=bar",
+			`T must be a comma separated list of key=value pairs: expected key=value pair, got "=bar"`,
+		},
+		"an invalid key should error": {
+			"foo bar=true",
+			`T has invalid annotation key "foo bar": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := annotations(test.s, "T", nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestLabels(t *testing.T) {
+	for name, test := range map[string]struct {
+		s       string
+		expErrs string
+	}{
+		"no labels should not error": {
+			"",
+			"",
+		},
+		"valid labels should not error": {
+			"foo=bar,example.com/pool=internal",
+			"",
+		},
+		"a missing '=' should error": {
+			"foo",
+			`T must be a comma separated list of key=value pairs: expected key=value pair, got "foo"`,
+		},
+		"a label value that is too long should error": {
+			"foo=" + strings.Repeat("a", 64),
+			`T has invalid label value "` + strings.Repeat("a", 64) + `" for key "foo": must be no more than 63 characters`,
+		},
+		"an invalid label key should error": {
+			"-foo=bar",
+			`T has invalid label key "-foo": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := labels(test.s, "T", nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestMaxLength(t *testing.T) {
+	for name, test := range map[string]struct {
+		s       string
+		expErrs string
+	}{
+		"no value should not error": {
+			"",
+			"",
+		},
+		"a value at the limit should not error": {
+			strings.Repeat("a", 5),
+			"",
+		},
+		"a value over the limit should error": {
+			strings.Repeat("a", 6),
+			"T values may not be longer than 5 characters, got 6",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := maxLength(test.s, "T", 5, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestKeyAlgorithm(t *testing.T) {
+	for name, test := range map[string]struct {
+		algorithm, size string
+		fipsMode        bool
+		expErrs         string
+	}{
+		"no algorithm should not error": {
+			"", "", false,
+			"",
+		},
+		"rsa 2048 should not error": {
+			"rsa", "2048", false,
+			"",
+		},
+		"rsa 1024 should error": {
+			"rsa", "1024", false,
+			"csi.cert-manager.io/key-size for rsa keys must be between 2048 and 8192, got 1024",
+		},
+		"ecdsa 384 should not error": {
+			"ecdsa", "384", false,
+			"",
+		},
+		"ecdsa 224 should error": {
+			"ecdsa", "224", false,
+			"csi.cert-manager.io/key-size for ecdsa keys must be one of 256, 384 or 521, got 224",
+		},
+		"a bad key size should error": {
+			"rsa", "foo", false,
+			`csi.cert-manager.io/key-size must be a valid integer: strconv.Atoi: parsing "foo": invalid syntax`,
+		},
+		"an unknown algorithm should error": {
+			"dsa", "2048", false,
+			`csi.cert-manager.io/key-algorithm must be one of "rsa" or "ecdsa", got "dsa"`,
+		},
+		"rsa 3072 should not error in FIPS mode": {
+			"rsa", "3072", true,
+			"",
+		},
+		"rsa 6144 should not error outside of FIPS mode": {
+			"rsa", "6144", false,
+			"",
+		},
+		"rsa 6144 should error in FIPS mode": {
+			"rsa", "6144", true,
+			"csi.cert-manager.io/key-size for rsa keys must be one of 2048, 3072 or 4096 in FIPS mode, got 6144",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := keyAlgorithm(test.algorithm, test.size, test.fipsMode, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestKeyStrength(t *testing.T) {
+	for name, test := range map[string]struct {
+		algorithm     string
+		size          int
+		minRSAKeySize int
+		allowedCurves []string
+		expErrs       string
+	}{
+		"no policy should not error": {
+			"rsa", 2048, 0, nil,
+			"",
+		},
+		"rsa at the minimum size should not error": {
+			"rsa", 3072, 3072, nil,
+			"",
+		},
+		"rsa above the minimum size should not error": {
+			"rsa", 4096, 3072, nil,
+			"",
+		},
+		"rsa one bit below the minimum size should error": {
+			"rsa", 3071, 3072, nil,
+			"rsa keys must be at least 3072 bits by policy, got 3071",
+		},
+		"rsa below the minimum size should error": {
+			"rsa", 2048, 4096, nil,
+			"rsa keys must be at least 4096 bits by policy, got 2048",
+		},
+		"no allowed curves should allow any curve": {
+			"ecdsa", 256, 4096, nil,
+			"",
+		},
+		"an allowed curve should not error": {
+			"ecdsa", 384, 0, []string{"P-384", "P-521"},
+			"",
+		},
+		"a disallowed curve should error": {
+			"ecdsa", 256, 0, []string{"P-384", "P-521"},
+			"ecdsa curve P-256 is not allowed by policy, must be one of P-384, P-521",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := keyStrength(test.algorithm, test.size, test.minRSAKeySize, test.allowedCurves, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestNodeURISAN(t *testing.T) {
+	for name, test := range map[string]struct {
+		prefix, nodeID string
+		expErrs        string
+	}{
+		"no prefix should not error": {
+			"", "node-1",
+			"",
+		},
+		"a valid prefix should not error": {
+			"spiffe://cluster.local/node/", "node-1",
+			"",
+		},
+		"a prefix without a node id should error": {
+			"spiffe://cluster.local/node/", "",
+			"csi.cert-manager.io/node-uri-san-prefix requires the node id to be known",
+		},
+		"a relative prefix should error": {
+			"node/", "node-1",
+			`csi.cert-manager.io/node-uri-san-prefix must form an absolute uri with the node id, got "node/node-1"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := nodeURISAN(test.prefix, test.nodeID, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestMaxSANs(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr    map[string]string
+		expErrs string
+	}{
+		"no sans should not error": {
+			map[string]string{},
+			"",
+		},
+		"sans at the maximum should not error": {
+			map[string]string{
+				csiapi.DNSNamesKey: "a.foo,b.foo",
+				csiapi.IPSANsKey:   "10.0.0.1",
+			},
+			"",
+		},
+		"sans over the maximum should error": {
+			map[string]string{
+				csiapi.DNSNamesKey: "a.foo,b.foo",
+				csiapi.URISANsKey:  "spiffe://foo/a,spiffe://foo/b",
+			},
+			"too many subject alternative names requested, maximum 3, got 4",
+		},
+		"the node uri san should count towards the maximum": {
+			map[string]string{
+				csiapi.DNSNamesKey:         "a.foo,b.foo",
+				csiapi.IPSANsKey:           "10.0.0.1",
+				csiapi.NodeURISANPrefixKey: "spiffe://cluster.local/node/",
+			},
+			"too many subject alternative names requested, maximum 3, got 4",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := maxSANs(test.attr, 3, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestRenewStrategy(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr    map[string]string
+		expErrs string
+	}{
+		"a single strategy should not error": {
+			map[string]string{
+				csiapi.RenewAtKey: "66%",
+			},
+			"",
+		},
+		"multiple strategies should error": {
+			map[string]string{
+				csiapi.RenewBeforeKey:   "1h",
+				csiapi.RenewScheduleKey: "0 3 * * *",
+			},
+			"only one of csi.cert-manager.io/renew-before, csi.cert-manager.io/renew-schedule may be set",
+		},
+		"a percentage without a percent sign should error": {
+			map[string]string{
+				csiapi.RenewAtKey: "66",
+			},
+			"csi.cert-manager.io/renew-at must be a percentage ending in '%'",
+		},
+		"an invalid schedule should error": {
+			map[string]string{
+				csiapi.RenewScheduleKey: "0 25 * * *",
+			},
+			`csi.cert-manager.io/renew-schedule must be a valid schedule: hour field "25" out of range 0-23`,
+		},
+		"a schedule that never fires should error": {
+			map[string]string{
+				csiapi.RenewScheduleKey: "0 0 31 4 *",
+			},
+			`csi.cert-manager.io/renew-schedule never fires, got "0 0 31 4 *"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := renewStrategy(test.attr, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr      map[string]string
+		audiences []string
+		expErrs   string
+	}{
+		"no token requested should not error": {
+			map[string]string{},
+			nil,
+			"",
+		},
+		"an audience without a token requested should error": {
+			map[string]string{
+				csiapi.ServiceAccountTokenAudienceKey: "vault",
+			},
+			[]string{"vault"},
+			"csi.cert-manager.io/service-account-token-audience requires csi.cert-manager.io/service-account-token or csi.cert-manager.io/service-account-token-file to be set",
+		},
+		"a token requested without configured audiences should error": {
+			map[string]string{
+				csiapi.ServiceAccountTokenKey:   "true",
+				csiapi.CSIServiceAccountNameKey: "default",
+			},
+			nil,
+			"service account tokens are disabled, no --token-audience configured",
+		},
+		"a token requested without a service account name should error": {
+			map[string]string{
+				csiapi.ServiceAccountTokenFileKey: "token",
+			},
+			[]string{"vault"},
+			"service account tokens require csi.storage.k8s.io/serviceAccount.name to be set in context",
+		},
+		"a token requested with the default audience should not error": {
+			map[string]string{
+				csiapi.ServiceAccountTokenFileKey: "token",
+				csiapi.CSIServiceAccountNameKey:   "default",
+			},
+			[]string{"vault"},
+			"",
+		},
+		"a token requested with a configured audience should not error": {
+			map[string]string{
+				csiapi.ServiceAccountTokenKey:         "true",
+				csiapi.ServiceAccountTokenAudienceKey: "issuer",
+				csiapi.CSIServiceAccountNameKey:       "default",
+			},
+			[]string{"vault", "issuer"},
+			"",
+		},
+		"a token requested with an unknown audience should error": {
+			map[string]string{
+				csiapi.ServiceAccountTokenKey:         "true",
+				csiapi.ServiceAccountTokenAudienceKey: "foo",
+				csiapi.CSIServiceAccountNameKey:       "default",
+			},
+			[]string{"vault"},
+			`csi.cert-manager.io/service-account-token-audience must be one of ["vault"], got "foo"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := serviceAccountToken(test.attr, test.audiences, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestCSRFile(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr    map[string]string
+		csrDir  string
+		expErrs string
+	}{
+		"no csr file should not error": {
+			map[string]string{},
+			"",
+			"",
+		},
+		"a csr file without a csr dir should error": {
+			map[string]string{
+				csiapi.CSRFileKey:       "csr.pem",
+				csiapi.AsyncIssuanceKey: "true",
+			},
+			"",
+			"workload provided CSRs are disabled, no --csr-dir configured",
+		},
+		"a csr file without async issuance should error": {
+			map[string]string{
+				csiapi.CSRFileKey: "csr.pem",
+			},
+			"/csr",
+			"csi.cert-manager.io/csr-file requires csi.cert-manager.io/async-issuance to be true",
+		},
+		"a csr file reusing the private key should error": {
+			map[string]string{
+				csiapi.CSRFileKey:       "csr.pem",
+				csiapi.AsyncIssuanceKey: "true",
+				csiapi.ReusePrivateKey:  "true",
+			},
+			"/csr",
+			"csi.cert-manager.io/reuse-private-key may not be set with csi.cert-manager.io/csr-file",
+		},
+		"a csr file with async issuance should not error": {
+			map[string]string{
+				csiapi.CSRFileKey:       "csr.pem",
+				csiapi.AsyncIssuanceKey: "true",
+			},
+			"/csr",
+			"",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := csrFile(test.attr, test.csrDir, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestDownwardAPIFiles(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr           map[string]string
+		downwardAPIDir string
+		expErrs        string
+	}{
+		"no downward API files should not error": {
+			map[string]string{},
+			"",
+			"",
+		},
+		"a downward API file without a downward API dir should error": {
+			map[string]string{
+				csiapi.IPSANsFromFileKey: "pod-ip",
+			},
+			"",
+			"reading request fields from files is disabled, no --downward-api-dir configured",
+		},
+		"a downward API file breaking out of the directory should error": {
+			map[string]string{
+				csiapi.IPSANsFromFileKey: "../pod-ip",
+			},
+			"/downward-api",
+			"csi.cert-manager.io/ip-sans-from-file filepaths may not contain '..'",
+		},
+		"an absolute downward API file should error": {
+			map[string]string{
+				csiapi.DNSNamesFromFileKey: "/etc/hostname",
+			},
+			"/downward-api",
+			`csi.cert-manager.io/dns-names-from-file must be relative to the downward API directory, got "/etc/hostname"`,
+		},
+		"a downward API file with a csr file should error": {
+			map[string]string{
+				csiapi.IPSANsFromFileKey: "pod-ip",
+				csiapi.CSRFileKey:        "csr.pem",
+			},
+			"/downward-api",
+			"csi.cert-manager.io/ip-sans-from-file may not be set with csi.cert-manager.io/csr-file",
+		},
+		"a common name file with a common name should error": {
+			map[string]string{
+				csiapi.CommonNameFromFileKey: "common-name",
+				csiapi.CommonNameKey:         "foo.bar",
+			},
+			"/downward-api",
+			"csi.cert-manager.io/common-name-from-file may not be set with csi.cert-manager.io/common-name",
+		},
+		"downward API files with a downward API dir should not error": {
+			map[string]string{
+				csiapi.IPSANsFromFileKey:     "pod-ip",
+				csiapi.DNSNamesFromFileKey:   "pod/dns-names",
+				csiapi.CommonNameFromFileKey: "common-name",
+				csiapi.IPSANsKey:             "1.2.3.4",
+			},
+			"/downward-api",
+			"",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := downwardAPIFiles(test.attr, test.downwardAPIDir, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestExtraExtensions(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr    map[string]string
+		expErrs string
+	}{
+		"no extra extensions should not error": {
+			map[string]string{},
+			"",
+		},
+		"valid extra extensions should not error": {
+			map[string]string{
+				csiapi.ExtraExtensionsKey: "1.3.6.1.4.1.311.20.2=DAlXZWJTZXJ2ZXI=",
+			},
+			"",
+		},
+		"an invalid oid should error": {
+			map[string]string{
+				csiapi.ExtraExtensionsKey: "1.foo=BQA=",
+			},
+			`csi.cert-manager.io/extra-extensions: invalid oid "1.foo", arc "foo" is not a non-negative integer`,
+		},
+		"extra extensions with a csr file should error": {
+			map[string]string{
+				csiapi.ExtraExtensionsKey: "1.2.3=BQA=",
+				csiapi.CSRFileKey:         "csr.pem",
+			},
+			"csi.cert-manager.io/extra-extensions may not be set with csi.cert-manager.io/csr-file",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := extraExtensions(test.attr, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestKeyDelivery(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr    map[string]string
+		opts    *options.Options
+		expErrs string
+	}{
+		"no key delivery should not error": {
+			map[string]string{},
+			new(options.Options),
+			"",
+		},
+		"file key delivery should not error": {
+			map[string]string{
+				csiapi.KeyDeliveryKey:  csiapi.FileKeyDelivery,
+				csiapi.ReusePrivateKey: "true",
+			},
+			new(options.Options),
+			"",
+		},
+		"fifo key delivery should not error": {
+			map[string]string{
+				csiapi.KeyDeliveryKey: csiapi.FIFOKeyDelivery,
+			},
+			new(options.Options),
+			"",
+		},
+		"an unknown key delivery should error": {
+			map[string]string{
+				csiapi.KeyDeliveryKey: "socket",
+			},
+			new(options.Options),
+			`csi.cert-manager.io/key-delivery must be one of "file" or "fifo", got "socket"`,
+		},
+		"fifo key delivery reusing the private key should error": {
+			map[string]string{
+				csiapi.KeyDeliveryKey:  csiapi.FIFOKeyDelivery,
+				csiapi.ReusePrivateKey: "true",
+			},
+			new(options.Options),
+			"csi.cert-manager.io/reuse-private-key may not be set with csi.cert-manager.io/key-delivery fifo",
+		},
+		"fifo key delivery with a csr file should error": {
+			map[string]string{
+				csiapi.KeyDeliveryKey: csiapi.FIFOKeyDelivery,
+				csiapi.CSRFileKey:     "csr.pem",
+			},
+			new(options.Options),
+			"csi.cert-manager.io/csr-file may not be set with csi.cert-manager.io/key-delivery fifo",
+		},
+		"fifo key delivery with the atomic layout should error": {
+			map[string]string{
+				csiapi.KeyDeliveryKey: csiapi.FIFOKeyDelivery,
+			},
+			&options.Options{AtomicDirLayout: true},
+			"csi.cert-manager.io/key-delivery fifo may not be used with --atomic-dir-layout",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := keyDelivery(test.attr, test.opts, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestValidateCSR(t *testing.T) {
+	newCSR := func(key crypto.Signer, dnsNames ...string) *x509.CertificateRequest {
+		der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			DNSNames: dnsNames,
+		}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return csr
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	badSignature := newCSR(ecKey, "foo.bar")
+	badSignature.Signature[len(badSignature.Signature)-1]++
+
+	rsa2048Key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ec384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, test := range map[string]struct {
+		csr           *x509.CertificateRequest
+		maxSANs       int
+		minRSAKeySize int
+		allowedCurves []string
+		expErr        string
+	}{
+		"a valid csr should not error": {
+			newCSR(ecKey, "foo.bar"),
+			1, 0, nil,
+			"",
+		},
+		"a csr with a bad signature should error": {
+			badSignature,
+			1, 0, nil,
+			"invalid signature: x509: ECDSA verification failure",
+		},
+		"a csr with a small rsa key should error": {
+			newCSR(rsaKey),
+			0, 0, nil,
+			"rsa keys must be between 2048 and 8192 bits, got 1024",
+		},
+		"a csr with too many names should error": {
+			newCSR(ecKey, "foo.bar", "bar.foo"),
+			1, 0, nil,
+			"too many subject alternative names requested, maximum 1, got 2",
+		},
+		"a csr with an rsa key of the minimum size should not error": {
+			newCSR(rsa2048Key),
+			0, 2048, nil,
+			"",
+		},
+		"a csr with an rsa key below the minimum size should error": {
+			newCSR(rsa2048Key),
+			0, 3072, nil,
+			"rsa keys must be at least 3072 bits by policy, got 2048",
+		},
+		"a csr with an allowed curve should not error": {
+			newCSR(ec384Key),
+			0, 0, []string{"P-384"},
+			"",
+		},
+		"a csr with a disallowed curve should error": {
+			newCSR(ecKey),
+			0, 0, []string{"P-384", "P-521"},
+			"ecdsa curve P-256 is not allowed by policy, must be one of P-384, P-521",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateCSR(test.csr, false, test.maxSANs, test.minRSAKeySize, test.allowedCurves)
+			if (err == nil && len(test.expErr) > 0) || (err != nil && err.Error() != test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
+func TestFallbackIssuer(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr    map[string]string
+		expErrs string
+	}{
+		"no fallback issuer should not error": {
+			map[string]string{},
+			"",
+		},
+		"a fallback issuer differing from the issuer should not error": {
+			map[string]string{
+				csiapi.IssuerNameKey:          "ca-issuer",
+				csiapi.IssuerKindKey:          "Issuer",
+				csiapi.IssuerGroupKey:         "cert-manager.io",
+				csiapi.FallbackIssuerNameKey:  "ca-issuer",
+				csiapi.FallbackIssuerKindKey:  "ClusterIssuer",
+				csiapi.FallbackIssuerGroupKey: "cert-manager.io",
+			},
+			"",
+		},
+		"a fallback kind without a name should error": {
+			map[string]string{
+				csiapi.FallbackIssuerKindKey: "Issuer",
+			},
+			"csi.cert-manager.io/fallback-issuer-name field required if csi.cert-manager.io/fallback-issuer-kind or csi.cert-manager.io/fallback-issuer-group is set",
+		},
+		"a fallback issuer equal to the issuer should error": {
+			map[string]string{
+				csiapi.IssuerNameKey:          "ca-issuer",
+				csiapi.IssuerKindKey:          "Issuer",
+				csiapi.IssuerGroupKey:         "cert-manager.io",
+				csiapi.FallbackIssuerNameKey:  "ca-issuer",
+				csiapi.FallbackIssuerKindKey:  "Issuer",
+				csiapi.FallbackIssuerGroupKey: "cert-manager.io",
+			},
+			"fallback issuer must differ from the issuer",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := fallbackIssuer(test.attr, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestSignerPlugin(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr         map[string]string
+		signerPlugin string
+		expErrs      string
+	}{
+		"no signer plugin should not error": {
+			map[string]string{
+				csiapi.ReusePrivateKey: "true",
+			},
+			"",
+			"",
+		},
+		"a signer plugin should not error": {
+			map[string]string{},
+			"unix:///run/signer.sock",
+			"",
+		},
+		"reusing the private key with a signer plugin should error": {
+			map[string]string{
+				csiapi.ReusePrivateKey: "true",
+			},
+			"unix:///run/signer.sock",
+			"csi.cert-manager.io/reuse-private-key may not be set with --signer-plugin",
+		},
+		"a bootstrap certificate with a signer plugin should error": {
+			map[string]string{
+				csiapi.BootstrapSelfSignedKey: "true",
+			},
+			"unix:///run/signer.sock",
+			"csi.cert-manager.io/bootstrap-self-signed may not be set with --signer-plugin",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := signerPlugin(test.attr, test.signerPlugin, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestKeySecret(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr         map[string]string
+		signerPlugin string
+		expErrs      string
+	}{
+		"no key secret should not error": {
+			map[string]string{
+				csiapi.ReusePrivateKey: "true",
+			},
+			"",
+			"",
+		},
+		"a key secret should not error": {
+			map[string]string{
+				csiapi.KeySecretKey:  "web-keys",
+				csiapi.CSIPodNameKey: "web-0",
+			},
+			"",
+			"",
+		},
+		"an invalid key secret name should error": {
+			map[string]string{
+				csiapi.KeySecretKey:  "Web_Keys",
+				csiapi.CSIPodNameKey: "web-0",
+			},
+			"",
+			`csi.cert-manager.io/key-secret "Web_Keys" is invalid: a DNS-1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`,
+		},
+		"a key secret with a workload provided CSR should error": {
+			map[string]string{
+				csiapi.KeySecretKey:  "web-keys",
+				csiapi.CSIPodNameKey: "web-0",
+				csiapi.CSRFileKey:    "web.csr",
+			},
+			"",
+			"csi.cert-manager.io/key-secret may not be set with csi.cert-manager.io/csr-file",
+		},
+		"a key secret with a signer plugin should error": {
+			map[string]string{
+				csiapi.KeySecretKey:  "web-keys",
+				csiapi.CSIPodNameKey: "web-0",
+			},
+			"unix:///run/signer.sock",
+			"csi.cert-manager.io/key-secret may not be set with --signer-plugin",
+		},
+		"a key secret with reuse private key should error": {
+			map[string]string{
+				csiapi.KeySecretKey:    "web-keys",
+				csiapi.CSIPodNameKey:   "web-0",
+				csiapi.ReusePrivateKey: "false",
+			},
+			"",
+			"csi.cert-manager.io/reuse-private-key may not be set with csi.cert-manager.io/key-secret, the stored key is always reused",
+		},
+		"a key secret without the pod name should error": {
+			map[string]string{
+				csiapi.KeySecretKey: "web-keys",
+			},
+			"",
+			"csi.cert-manager.io/key-secret requires the pod name, csi.storage.k8s.io/pod.name",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := keySecret(test.attr, test.signerPlugin, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	for name, test := range map[string]struct {
+		id      string
+		expErrs string
+	}{
+		"no correlation id should not error": {
+			"",
+			"",
+		},
+		"an opaque id should not error": {
+			"trace-1234:abcd/EF_56",
+			"",
+		},
+		"an id containing a space should error": {
+			"foo bar",
+			`csi.cert-manager.io/correlation-id may only contain printable ASCII characters other than space, got "foo bar"`,
+		},
+		"an id containing non ASCII characters should error": {
+			"foo\u00e9",
+			`csi.cert-manager.io/correlation-id may only contain printable ASCII characters other than space, got "fooé"`,
+		},
+		"an id that is too long should error": {
+			strings.Repeat("a", 129),
+			"csi.cert-manager.io/correlation-id values may not be longer than 128 characters, got 129",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := correlationID(test.id, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestKeyUsages(t *testing.T) {
+	for name, test := range map[string]struct {
+		usages, certType string
+		expErrs          string
+	}{
+		"no usages or type should not error": {
+			"", "",
+			"",
+		},
+		"known usages should not error": {
+			"digital signature,server auth", "",
+			"",
+		},
+		"an unknown usage should error": {
+			"server auth,foo", "",
+			`csi.cert-manager.io/key-usages contains unknown key usage "foo"`,
+		},
+		"a known certificate type should not error": {
+			"", "peer",
+			"",
+		},
+		"an unknown certificate type should error": {
+			"", "foo",
+			`csi.cert-manager.io/certificate-type must be one of "server", "client" or "peer", got "foo"`,
+		},
+		"both usages and certificate type should error": {
+			"server auth", "server",
+			"csi.cert-manager.io/key-usages and csi.cert-manager.io/certificate-type may not both be set",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := keyUsages(test.usages, test.certType, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestDNSNamesTemplate(t *testing.T) {
+	for name, test := range map[string]struct {
+		template string
+		expErrs  string
+	}{
+		"no template should not error": {
+			"",
+			"",
+		},
+		"a valid template should not error": {
+			"{{.PodName}}.web.{{.Namespace}}.svc,*.{{.Namespace}}.svc",
+			"",
+		},
+		"a template that fails to parse should error": {
+			"{{.PodName",
+			"csi.cert-manager.io/dns-names-template is invalid: failed to parse dns names template: template: dns-names:1: unclosed action",
+		},
+		"a template rendering an invalid name should error": {
+			"{{.PodName}}_{{.Namespace}}",
+			`csi.cert-manager.io/dns-names-template rendered invalid name "web-0_sandbox": a DNS-1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := dnsNamesTemplate(map[string]string{
+				csiapi.CSIPodNameKey:       "web-0",
+				csiapi.CSIPodNamespaceKey:  "sandbox",
+				csiapi.DNSNamesTemplateKey: test.template,
+			}, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestOSProfile(t *testing.T) {
+	for name, test := range map[string]struct {
+		profile string
+		expErrs string
+	}{
+		"no os profile should not error": {
+			"",
+			"",
+		},
+		"the linux os profile should not error": {
+			"linux",
+			"",
+		},
+		"the windows os profile should not error": {
+			"windows",
+			"",
+		},
+		"an unknown os profile should error": {
+			"darwin",
+			`csi.cert-manager.io/os-profile must be one of "linux" or "windows", got "darwin"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := osProfile(test.profile, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
+
+func TestIPAddresses(t *testing.T) {
+	for name, test := range map[string]struct {
+		ips     string
+		expErrs string
+	}{
+		"no ip addresses should not error": {
+			"",
+			"",
+		},
+		"valid ipv4 and ipv6 addresses should not error": {
+			"192.0.0.1, 2001:db8::1,::ffff:192.0.0.2",
+			"",
+		},
+		"an invalid ip address should error": {
+			"192.0.0.1,192.0.0.300",
+			`csi.cert-manager.io/ip-sans contains invalid ip address "192.0.0.300"`,
+		},
+		"an empty entry should error": {
+			"192.0.0.1,",
+			`csi.cert-manager.io/ip-sans contains invalid ip address ""`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			errs := ipAddresses(test.ips, nil)
+
+			if test.expErrs != strings.Join(errs, "") {
+				t.Errorf("unexpected error returned, exp=%s got=%s",
+					test.expErrs, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// issuanceCacheEntry is the certificate and key issued for a volume, which
+// are written into further volumes of the same spec until the entry expires.
+type issuanceCacheEntry struct {
+	// Held while the certificate is issued, so that volumes of the same spec
+	// wait for it rather than each creating a request of their own.
+	mu sync.Mutex
+
+	req             *issuedRequest
+	identityCreated time.Time
+}
+
+// issuanceCacheable returns true if the certificate of the volume may be
+// shared with other volumes of the same spec. Volumes whose request depends
+// on more than their attributes and namespace, such as on files written by
+// the pod, its IPs or its service account token, are never shared, nor are
+// volumes whose key is not written by the driver or is kept per pod in a
+// Secret. Volumes refreshing their CA do so from their own request, so are not
+// shared either.
+func (c *CertManager) issuanceCacheable(vol *csiapi.MetaData) bool {
+	if c.issuanceCacheTTL <= 0 || !util.WritesPrivateKey(vol) || util.DeliversKeyByFIFO(vol) {
+		return false
+	}
+
+	if len(vol.Attributes[csiapi.ServiceAccountTokenFileKey]) > 0 || len(vol.Attributes[csiapi.CARefreshIntervalKey]) > 0 ||
+		vol.Attributes[csiapi.ServiceAccountTokenKey] == "true" || len(vol.Attributes[csiapi.KeySecretKey]) > 0 ||
+		vol.Attributes[csiapi.TrackPodIPKey] == "true" {
+		return false
+	}
+
+	for _, k := range csiapi.DownwardAPIFileKeys {
+		if len(vol.Attributes[k]) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// issuanceCacheKey returns the key volumes are cached by. The spec hash
+// includes DNS names rendered from the pod, so volumes whose names differ by
+// pod never share a certificate.
+func issuanceCacheKey(vol *csiapi.MetaData) string {
+	return vol.Attributes[csiapi.CSIPodNamespaceKey] + "/" + util.SpecHash(vol.Attributes)
+}
+
+// createNewCertificateCached writes the cached certificate and key of the
+// volume's spec into the volume if there is one, otherwise issues a new
+// certificate and caches it for --issuance-cache-ttl.
+func (c *CertManager) createNewCertificateCached(ctx context.Context, vol *csiapi.MetaData) (*x509.Certificate, error) {
+	// A request pre-created for the volume is already being issued, so is
+	// used instead.
+	if keyBundle := c.takePrewarmedKey(vol); keyBundle != nil {
+		return c.createNewCertificate(ctx, vol, keyBundle)
+	}
+
+	key := issuanceCacheKey(vol)
+	entry := c.issuanceCacheEntry(key)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.req != nil && c.checkRequestCertificate(entry.req.cr) == nil {
+		glog.Infof("cert-manager: reusing cached certificate of CertificateRequest %s/%s for volume %s",
+			entry.req.cr.Namespace, entry.req.cr.Name, vol.ID)
+
+		// Nothing was created or waited for.
+		req := *entry.req
+		req.createDuration, req.waitDuration = 0, 0
+
+		vol.IdentityCreated = entry.identityCreated
+
+		return c.writeCertificate(vol, &req)
+	}
+
+	req, err := c.requestCertificate(ctx, vol, nil)
+	if err != nil {
+		c.forgetIssuanceCacheEntry(key, entry)
+		return nil, err
+	}
+
+	cert, err := c.writeCertificate(vol, req)
+	if err != nil {
+		c.forgetIssuanceCacheEntry(key, entry)
+		return nil, err
+	}
+
+	entry.req = req
+	entry.identityCreated = vol.IdentityCreated
+
+	time.AfterFunc(c.issuanceCacheTTL, func() {
+		c.forgetIssuanceCacheEntry(key, entry)
+	})
+
+	return cert, nil
+}
+
+// issuanceCacheEntry returns the cache entry of the given key, adding an
+// empty entry if there is none.
+func (c *CertManager) issuanceCacheEntry(key string) *issuanceCacheEntry {
+	c.issuanceCacheMu.Lock()
+	defer c.issuanceCacheMu.Unlock()
+
+	if c.issuanceCache == nil {
+		c.issuanceCache = make(map[string]*issuanceCacheEntry)
+	}
+
+	entry, ok := c.issuanceCache[key]
+	if !ok {
+		entry = new(issuanceCacheEntry)
+		c.issuanceCache[key] = entry
+	}
+
+	return entry
+}
+
+// forgetIssuanceCacheEntry removes the entry from the cache, if it has not
+// already been replaced.
+func (c *CertManager) forgetIssuanceCacheEntry(key string, entry *issuanceCacheEntry) {
+	c.issuanceCacheMu.Lock()
+	defer c.issuanceCacheMu.Unlock()
+
+	if c.issuanceCache[key] == entry {
+		delete(c.issuanceCache, key)
+	}
+}
@@ -0,0 +1,227 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestCreateNewCertificateIssuanceCache(t *testing.T) {
+	newAttr := func(namespace, dnsNames string) map[string]string {
+		return map[string]string{
+			csiapi.CSIPodNamespaceKey: namespace,
+			csiapi.IssuerNameKey:      "test-issuer",
+			csiapi.DNSNamesKey:        dnsNames,
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+			csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:         "256",
+		}
+	}
+
+	for name, test := range map[string]struct {
+		ttl         time.Duration
+		sleep       time.Duration
+		secondAttr  map[string]string
+		expShared   bool
+		expRequests int
+	}{
+		"a volume of the same spec and namespace should share the certificate": {
+			ttl:         time.Minute,
+			secondAttr:  newAttr("test-namespace", "foo.bar"),
+			expShared:   true,
+			expRequests: 1,
+		},
+		"a volume of a different spec should not share the certificate": {
+			ttl:         time.Minute,
+			secondAttr:  newAttr("test-namespace", "bar.foo"),
+			expRequests: 2,
+		},
+		"a volume of a different namespace should not share the certificate": {
+			ttl:         time.Minute,
+			secondAttr:  newAttr("other-namespace", "foo.bar"),
+			expRequests: 2,
+		},
+		"a volume reading names from files should not share the certificate": {
+			ttl: time.Minute,
+			secondAttr: func() map[string]string {
+				attr := newAttr("test-namespace", "foo.bar")
+				attr[csiapi.IPSANsFromFileKey] = "pod-ip"
+				return attr
+			}(),
+			expRequests: 2,
+		},
+		"a volume published after the ttl should not share the certificate": {
+			ttl:         time.Millisecond * 50,
+			sleep:       time.Millisecond * 200,
+			secondAttr:  newAttr("test-namespace", "foo.bar"),
+			expRequests: 2,
+		},
+		"a volume of the same spec should not share the certificate with the cache disabled": {
+			secondAttr:  newAttr("test-namespace", "foo.bar"),
+			expRequests: 2,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-issuance-cache-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			if err := ioutil.WriteFile(filepath.Join(dir, "pod-ip"), []byte("10.0.0.1"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			client := cmfake.NewSimpleClientset()
+			signOnCreate(t, client)
+
+			c := &CertManager{
+				cmClient:         client,
+				issuanceTimeout:  time.Second * 5,
+				issuanceCacheTTL: test.ttl,
+				downwardAPIDir:   dir,
+				clock:            clock.RealClock{},
+			}
+
+			first := &csiapi.MetaData{
+				ID:         "first-id",
+				Path:       filepath.Join(dir, "first-id"),
+				Attributes: newAttr("test-namespace", "foo.bar"),
+			}
+			second := &csiapi.MetaData{
+				ID:         "second-id",
+				Path:       filepath.Join(dir, "second-id"),
+				Attributes: test.secondAttr,
+			}
+
+			if _, err := c.CreateNewCertificate(context.TODO(), first, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			time.Sleep(test.sleep)
+
+			if _, err := c.CreateNewCertificate(context.TODO(), second, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			var requests int
+			for _, action := range client.Actions() {
+				if action.Matches("create", "certificaterequests") {
+					requests++
+				}
+			}
+
+			if requests != test.expRequests {
+				t.Errorf("unexpected number of CertificateRequests created, exp=%d got=%d",
+					test.expRequests, requests)
+			}
+
+			for _, path := range []func(*csiapi.MetaData) string{util.CertPath, util.KeyPath} {
+				firstBytes, err := ioutil.ReadFile(path(first))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				secondBytes, err := ioutil.ReadFile(path(second))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if shared := bytes.Equal(firstBytes, secondBytes); shared != test.expShared {
+					t.Errorf("unexpected sharing of %s, exp=%t got=%t",
+						filepath.Base(path(first)), test.expShared, shared)
+				}
+			}
+		})
+	}
+}
+
+func TestIssuanceCacheable(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr         map[string]string
+		keyExternal  bool
+		expCacheable bool
+	}{
+		"a volume of only attributes should be cacheable": {
+			attr:         map[string]string{},
+			expCacheable: true,
+		},
+		"a volume requesting the pod's service account token should not be cacheable": {
+			attr: map[string]string{
+				csiapi.ServiceAccountTokenKey: "true",
+			},
+		},
+		"a volume not requesting the pod's service account token should be cacheable": {
+			attr: map[string]string{
+				csiapi.ServiceAccountTokenKey: "false",
+			},
+			expCacheable: true,
+		},
+		"a volume reading a service account token file should not be cacheable": {
+			attr: map[string]string{
+				csiapi.ServiceAccountTokenFileKey: "token",
+			},
+		},
+		"a volume keeping its key in a secret should not be cacheable": {
+			attr: map[string]string{
+				csiapi.KeySecretKey: "pod-keys",
+			},
+		},
+		"a volume tracking the pod's ips should not be cacheable": {
+			attr: map[string]string{
+				csiapi.TrackPodIPKey: "true",
+			},
+		},
+		"a volume refreshing its ca should not be cacheable": {
+			attr: map[string]string{
+				csiapi.CARefreshIntervalKey: "1h",
+			},
+		},
+		"a volume reading names from files should not be cacheable": {
+			attr: map[string]string{
+				csiapi.DNSNamesFromFileKey: "dns-names",
+			},
+		},
+		"a volume providing its csr should not be cacheable": {
+			attr: map[string]string{
+				csiapi.CSRFileKey: "csr.pem",
+			},
+		},
+		"a volume delivering its key by fifo should not be cacheable": {
+			attr: map[string]string{
+				csiapi.KeyDeliveryKey: csiapi.FIFOKeyDelivery,
+			},
+		},
+		"a volume with an external key should not be cacheable": {
+			attr:        map[string]string{},
+			keyExternal: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := &CertManager{
+				issuanceCacheTTL: time.Minute,
+			}
+
+			vol := &csiapi.MetaData{
+				ID:          "test-id",
+				Attributes:  test.attr,
+				KeyExternal: test.keyExternal,
+			}
+
+			if cacheable := c.issuanceCacheable(vol); cacheable != test.expCacheable {
+				t.Errorf("unexpected cacheable, exp=%t got=%t", test.expCacheable, cacheable)
+			}
+		})
+	}
+}
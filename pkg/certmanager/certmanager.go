@@ -1,13 +1,18 @@
 package certmanager
 
 import (
+	"context"
+	"crypto"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -18,257 +23,1246 @@ import (
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
 	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/apis/validation"
+	"github.com/jetstack/cert-manager-csi/pkg/metrics"
+	"github.com/jetstack/cert-manager-csi/pkg/signer"
 	"github.com/jetstack/cert-manager-csi/pkg/util"
 )
 
+// durationTruncatedTolerance is the fraction of the requested duration a
+// certificate may be shorter by, such as from the issuer backdating it,
+// before it is considered truncated.
+const durationTruncatedTolerance = 0.05
+
+// signerProbeTimeout is the time to wait for the signer plugin to become
+// ready at startup.
+const signerProbeTimeout = time.Second * 30
+
 type CertManager struct {
-	cmClient cmclient.Interface
+	cmClient   cmclient.Interface
+	kubeClient kubernetes.Interface
+
+	// Client of the cert-manager v1 API, not included in cmClient.
+	dynamicClient dynamic.Interface
+
+	postIssueHook        string
+	postIssueHookTimeout time.Duration
+
+	metadataInMount bool
+
+	// Sync volume files to disk after they are written.
+	fsyncFiles bool
+
+	issuanceTimeout time.Duration
+
+	// PEM encoded root CA appended to chain files.
+	chainRootCA []byte
+
+	// Audiences pod service account tokens may be requested for.
+	tokenAudiences []string
+
+	// Label set to "true" on every created CertificateRequest.
+	managedLabelKey string
+
+	// cert-manager API version served by the cluster.
+	apiVersion string
+
+	// Directory workload provided CSRs are read from, and the constraints
+	// they must keep to.
+	csrDir        string
+	fipsMode      bool
+	maxSANs       int
+	minRSAKeySize int
+	allowedCurves []string
+
+	// Directory the common name and SANs of volumes may be read from, and
+	// how long to wait for their files to be written.
+	downwardAPIDir         string
+	downwardAPIFileTimeout time.Duration
+
+	// Maximum age of a reused private key before it is rotated.
+	maxIdentityAge time.Duration
+
+	// Check the issuer is Ready before creating requests.
+	precheckIssuer bool
+
+	// Set the pod as owner of requests.
+	setOwnerReference bool
+
+	// Verify issued certificates match the key and names of their request.
+	verifyIssuedCert bool
+
+	// Log the details of issued certificates at the given verbosity.
+	logCertDetails      bool
+	logCertDetailsLevel glog.Level
+
+	// Signs the CSRs of volumes with external keys.
+	signer signer.Interface
+
+	// Label issuance metrics with the namespace of the volume.
+	metricsHighCardinality bool
+
+	// Private keys of pre-created requests, by volume ID, held until the
+	// volume is published.
+	prewarmMu     sync.Mutex
+	prewarmedKeys map[string]prewarmedKey
+
+	// Time after a request was created that a request still pending when
+	// waiting for it was interrupted is resumed by retries, rather than
+	// re-created. Disabled if zero.
+	issuanceDeadline time.Duration
+
+	// Certificates and keys shared between volumes of the same spec, by
+	// namespace and spec hash, and how long each is shared for. Disabled if
+	// the TTL is zero.
+	issuanceCacheTTL time.Duration
+	issuanceCacheMu  sync.Mutex
+	issuanceCache    map[string]*issuanceCacheEntry
+
+	// Named pipes delivering the private keys of volumes, by volume ID.
+	keyFIFOsMu sync.Mutex
+	keyFIFOs   map[string]*util.KeyFIFO
+
+	// CAs of the issuers of staged volumes, by namespace and issuer.
+	stagedCAsMu sync.Mutex
+	stagedCAs   map[string]*stagedCA
+
+	// CertificateRequests being deleted by the driver itself, by namespace
+	// and name, whose deletions are not reported by
+	// WatchCertificateRequestDeletions. Only recorded once watching.
+	ownDeletesMu      sync.Mutex
+	ownDeletes        map[string]struct{}
+	watchingDeletions bool
+
+	clock clock.Clock
 }
 
-func New() (*CertManager, error) {
+func New(opts *options.Options) (*CertManager, error) {
 	restConfig, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, err
 	}
 
+	return NewForConfig(restConfig, opts)
+}
+
+// NewForConfig returns a CertManager using clients built from the given REST
+// config.
+func NewForConfig(restConfig *rest.Config, opts *options.Options) (*CertManager, error) {
 	cmClient, err := cmclient.NewForConfig(restConfig)
 	if err != nil {
 		return nil, err
 	}
 
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewWithClient(cmClient, kubeClient, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.dynamicClient = dynamicClient
+
+	return c, nil
+}
+
+// NewWithClient returns a CertManager using the given cert-manager and
+// Kubernetes clients.
+func NewWithClient(cmClient cmclient.Interface, kubeClient kubernetes.Interface, opts *options.Options) (*CertManager, error) {
+	var chainRootCA []byte
+	if len(opts.ChainRootCAFile) > 0 {
+		var err error
+		chainRootCA, err = ioutil.ReadFile(opts.ChainRootCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chain root ca file: %s", err)
+		}
+
+		if _, err := pki.DecodeX509CertificateBytes(chainRootCA); err != nil {
+			return nil, fmt.Errorf("failed to parse chain root ca file: %s", err)
+		}
+	}
+
+	var keySigner signer.Interface
+	if len(opts.SignerPlugin) > 0 {
+		plugin, err := signer.NewPlugin(opts.SignerPlugin, signerProbeTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		keySigner = plugin
+	}
+
+	var issuanceCacheTTL time.Duration
+	if opts.EnableIssuanceCache {
+		issuanceCacheTTL = opts.IssuanceCacheTTL
+	}
+
 	return &CertManager{
-		cmClient: cmClient,
+		cmClient:               cmClient,
+		kubeClient:             kubeClient,
+		postIssueHook:          opts.PostIssueHook,
+		postIssueHookTimeout:   opts.PostIssueHookTimeout,
+		metadataInMount:        opts.MetadataInMount,
+		fsyncFiles:             opts.FsyncFiles,
+		issuanceTimeout:        opts.IssuanceTimeout,
+		chainRootCA:            chainRootCA,
+		tokenAudiences:         opts.TokenAudiences,
+		managedLabelKey:        opts.ManagedLabelKey,
+		csrDir:                 opts.CSRDir,
+		downwardAPIDir:         opts.DownwardAPIDir,
+		downwardAPIFileTimeout: opts.DownwardAPIFileTimeout,
+		fipsMode:               opts.FIPSMode,
+		maxSANs:                opts.MaxSANs,
+		minRSAKeySize:          opts.MinRSAKeySize,
+		allowedCurves:          opts.AllowedECDSACurves,
+		maxIdentityAge:         opts.MaxIdentityAge,
+		precheckIssuer:         opts.PrecheckIssuer,
+		setOwnerReference:      opts.SetOwnerReference,
+		verifyIssuedCert:       opts.VerifyIssuedCert,
+		logCertDetails:         opts.LogCertDetails,
+		logCertDetailsLevel:    glog.Level(opts.LogCertDetailsLevel),
+		signer:                 keySigner,
+		issuanceCacheTTL:       issuanceCacheTTL,
+		issuanceDeadline:       opts.IssuanceDeadline,
+		clock:                  clock.RealClock{},
+
+		metricsHighCardinality: opts.MetricsHighCardinality,
 	}, nil
 }
 
-func (c *CertManager) CreateNewCertificate(vol *csiapi.MetaData, keyBundle *util.KeyBundle) (*x509.Certificate, error) {
+// CreateNewCertificate requests and writes a certificate for the volume using
+// the given key. If the key is nil, an existing request for the key already
+// written to the volume is reused, otherwise a new key is only generated once
+// a new request is needed. If the volume provides its own CSR, no private key
+// file is written.
+func (c *CertManager) CreateNewCertificate(ctx context.Context, vol *csiapi.MetaData, keyBundle *util.KeyBundle) (*x509.Certificate, error) {
+	var cert *x509.Certificate
+	var err error
+	if keyBundle == nil && c.issuanceCacheable(vol) {
+		cert, err = c.createNewCertificateCached(ctx, vol)
+	} else {
+		cert, err = c.createNewCertificate(ctx, vol, keyBundle)
+	}
+	c.recordIssuance(metrics.OperationIssue, vol, err)
+	return cert, err
+}
+
+func (c *CertManager) createNewCertificate(ctx context.Context, vol *csiapi.MetaData, keyBundle *util.KeyBundle) (*x509.Certificate, error) {
+	req, err := c.requestCertificate(ctx, vol, keyBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.writeCertificate(vol, req)
+}
+
+// issuedRequest is a Ready CertificateRequest of a volume, the key it was made
+// with, and how long it took to be created and become Ready.
+type issuedRequest struct {
+	cr             *cmapi.CertificateRequest
+	keyBundle      *util.KeyBundle
+	createDuration time.Duration
+	waitDuration   time.Duration
+}
+
+// requestCertificate reuses or creates the CertificateRequest of the volume,
+// and waits for it to become Ready.
+func (c *CertManager) requestCertificate(ctx context.Context, vol *csiapi.MetaData, keyBundle *util.KeyBundle) (*issuedRequest, error) {
 	attr := vol.Attributes
 	namespace := attr[csiapi.CSIPodNamespaceKey]
 
+	_, fallbackRef := issuerRefs(attr)
+
+	createStart := time.Now()
+
+	// A request pre-created before the volume was published was made with a
+	// key held in memory.
+	if keyBundle == nil {
+		keyBundle = c.takePrewarmedKey(vol)
+	}
+
+	// A request still pending on a previous attempt is resumed.
+	if keyBundle == nil && c.issuanceDeadline > 0 {
+		keyBundle = c.pendingKey(vol)
+	}
+
 	// Check if a certificate request exists and matches the current volume spec
-	ok, err := c.checkExistingCertificateRequest(vol)
+	existing, existingKey, err := c.checkExistingCertificateRequest(vol, keyBundle)
 	if err != nil {
 		return nil, err
 	}
 
-	// Not ok so create a new certificate request
-	if !ok {
-		uris, err := util.ParseURIs(attr[csiapi.URISANsKey])
+	if existing != nil {
+		keyBundle = existingKey
+	}
+
+	// No matching request so create a new certificate request
+	if existing == nil {
+		keyBundle, err = c.createCertificateRequest(ctx, vol, keyBundle)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		ips := util.ParseIPAddresses(attr[csiapi.IPSANsKey])
+	createDuration := time.Since(createStart)
+	metrics.IssuancePhaseDuration.WithLabelValues(metrics.PhaseCreate).Observe(createDuration.Seconds())
 
-		dnsNames := strings.Split(attr[csiapi.DNSNamesKey], ",")
-		commonName := attr[csiapi.CommonNameKey]
+	waitStart := time.Now()
 
-		duration := cmapi.DefaultCertificateDuration
-		if durStr, ok := attr[csiapi.DurationKey]; ok {
-			duration, err = time.ParseDuration(durStr)
-			if err != nil {
-				return nil, err
+	// An existing request that is already Ready, such as when a pod's
+	// containers restart, is used straight away.
+	cr := existing
+	if cr != nil && util.CertificateRequestReady(cr) {
+		glog.Infof("cert-manager: reusing Ready CertificateRequest %s", vol.ID)
+	} else {
+		glog.Infof("cert-manager: waiting for CertificateRequest to become ready %s", vol.ID)
+		cr, err = c.waitForCertificateRequestReady(ctx, vol.ID, namespace, c.issuanceTimeout)
+		if err != nil && fallbackRef != nil && cr != nil && cr.Spec.IssuerRef != *fallbackRef && ctx.Err() == nil {
+			glog.Errorf("cert-manager: CertificateRequest %s failed, retrying with fallback issuer %s %q: %s",
+				vol.ID, fallbackRef.Kind, fallbackRef.Name, err)
+			cr, err = c.fallbackCertificateRequest(ctx, cr, *fallbackRef)
+		}
+		if err != nil {
+			if c.issuanceDeadline > 0 && keyBundle != nil && cr != nil && requestPending(cr) {
+				if holdErr := c.holdPendingRequest(vol, cr, keyBundle); holdErr != nil {
+					glog.Errorf("cert-manager: failed to hold pending CertificateRequest %s: %s", vol.ID, holdErr)
+				}
 			}
+
+			return nil, requestError(namespace, vol.ID, err)
 		}
+	}
 
-		isCA := false
-		if isCAStr, ok := attr[csiapi.IsCAKey]; ok {
-			switch strings.ToLower(isCAStr) {
-			case "true":
-				isCA = true
-			case "false":
-				isCA = false
-			}
+	if vol.RequestCreated != nil {
+		c.forgetPendingRequest(vol)
+	}
+
+	usedRef := cr.Spec.IssuerRef
+	fallback := fallbackRef != nil && usedRef == *fallbackRef
+	metrics.IssuedCertificates.WithLabelValues(usedRef.Name, usedRef.Kind, usedRef.Group,
+		strconv.FormatBool(fallback)).Inc()
+
+	waitDuration := time.Since(waitStart)
+	metrics.IssuancePhaseDuration.WithLabelValues(metrics.PhaseWait).Observe(waitDuration.Seconds())
+
+	return &issuedRequest{
+		cr:             cr,
+		keyBundle:      keyBundle,
+		createDuration: createDuration,
+		waitDuration:   waitDuration,
+	}, nil
+}
+
+// writeCertificate writes the certificate of the Ready CertificateRequest,
+// along with its key, into the volume.
+func (c *CertManager) writeCertificate(vol *csiapi.MetaData, req *issuedRequest) (*x509.Certificate, error) {
+	attr := vol.Attributes
+	namespace := attr[csiapi.CSIPodNamespaceKey]
+	cr, keyBundle := req.cr, req.keyBundle
+
+	vol.Issuer = &csiapi.IssuerRef{
+		Name:  cr.Spec.IssuerRef.Name,
+		Kind:  cr.Spec.IssuerRef.Kind,
+		Group: cr.Spec.IssuerRef.Group,
+	}
+
+	// Nothing is written for a certificate that can't be decoded. The
+	// request is deleted and re-created on the next attempt.
+	cert, err := decodeIssuedCertificate(cr)
+	if err != nil {
+		return nil, requestError(namespace, cr.Name, err)
+	}
+
+	if c.verifyIssuedCert {
+		if err := verifyIssuedCertificate(cr, cert); err != nil {
+			return nil, requestError(namespace, cr.Name,
+				fmt.Errorf("certificate issued for CertificateRequest %s/%s does not match its request: %s",
+					namespace, cr.Name, err))
 		}
+	}
 
-		csr := &x509.CertificateRequest{
-			Subject: pkix.Name{
-				CommonName: commonName,
-			},
-			DNSNames:           dnsNames,
-			IPAddresses:        ips,
-			URIs:               uris,
-			PublicKey:          keyBundle.PrivateKey.Public(),
-			PublicKeyAlgorithm: keyBundle.PublicKeyAlgorithm,
-			SignatureAlgorithm: keyBundle.SignatureAlgorithm,
+	writeStart := time.Now()
+
+	// Write metadata to file
+	if err := util.WriteMetaDataFile(vol, c.metadataInMount, c.fsyncFiles); err != nil {
+		return nil, err
+	}
+
+	glog.V(4).Infof("cert-manager: metadata written to file %s", util.MetaDataPath(vol))
+
+	// All files are written together so that, with the atomic layout,
+	// readers never see a mix of old and new files.
+	files := make(map[string][]byte)
+
+	certBytes, err := util.EncodeVolumeFile(cr.Status.Certificate, attr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode certificate: %s", err)
+	}
+	files[attr[csiapi.CertFileKey]] = certBytes
+
+	// Volumes of a staged issuer share its CA.
+	ca := c.stagedCAOf(namespace, cr.Spec.IssuerRef, cr.Status.CA)
+
+	if len(ca) > 0 {
+		caBytes, err := util.EncodeVolumeFile(ca, attr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode ca: %s", err)
 		}
+		files[attr[csiapi.CAFileKey]] = caBytes
+	}
 
-		csrPEM, err := util.EncodeCSR(csr, keyBundle.PrivateKey)
+	if attr[csiapi.SplitCAKey] == "true" {
+		caFiles, err := util.SplitCAFiles(vol, ca)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to split ca: %s", err)
 		}
-		// Build certificate request for volume
-		cr := &cmapi.CertificateRequest{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      vol.ID,
-				Namespace: namespace,
-				OwnerReferences: []metav1.OwnerReference{
-					metav1.OwnerReference{
-						APIVersion:         "core/v1",
-						BlockOwnerDeletion: util.BoolPointer(true),
-						Controller:         util.BoolPointer(false),
-						Kind:               "Pod",
-						Name:               vol.Attributes[csiapi.CSIPodNamespaceKey],
-						UID:                types.UID(vol.Attributes[csiapi.CSIPodUIDKey]),
-					},
-				},
-			},
-			Spec: cmapi.CertificateRequestSpec{
-				CSRPEM: csrPEM,
-				IsCA:   isCA,
-				Duration: &metav1.Duration{
-					Duration: duration,
-				},
-				IssuerRef: cmmeta.ObjectReference{
-					Name:  attr[csiapi.IssuerNameKey],
-					Kind:  attr[csiapi.IssuerKindKey],
-					Group: attr[csiapi.IssuerGroupKey],
-				},
-			},
+
+		for name, b := range caFiles {
+			files[name] = b
 		}
+	}
 
-		// if it doesn't exit yet then create it
-		cr, err = c.cmClient.CertmanagerV1alpha2().CertificateRequests(namespace).Create(cr)
+	if len(attr[csiapi.ChainFileKey]) > 0 {
+		chainPEM, err := util.BuildChain(cr.Status.Certificate, ca, c.chainRootCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build certificate chain: %s", err)
+		}
+
+		chainBytes, err := util.EncodeVolumeFile(chainPEM, attr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode certificate chain: %s", err)
+		}
+		files[attr[csiapi.ChainFileKey]] = chainBytes
+	}
+
+	if len(attr[csiapi.ServiceAccountTokenFileKey]) > 0 {
+		token, err := c.requestServiceAccountToken(vol)
 		if err != nil {
 			return nil, err
 		}
+		files[attr[csiapi.ServiceAccountTokenFileKey]] = []byte(token)
 	}
 
-	glog.Infof("cert-manager: created CertificateRequest %s", vol.ID)
+	checkDurationTruncated(vol, cert)
 
-	glog.Infof("cert-manager: waiting for CertificateRequest to become ready %s", vol.ID)
-	cr, err := c.waitForCertificateRequestReady(vol.ID, namespace, time.Second*30)
-	if err != nil {
-		return nil, err
+	if len(attr[csiapi.FingerprintFileKey]) > 0 {
+		files[attr[csiapi.FingerprintFileKey]] = util.Fingerprint(cert)
 	}
 
-	// Write metadata to file
-	metaDataBytes, err := json.Marshal(vol)
-	if err != nil {
-		return nil, err
+	if attr[csiapi.CAToolingFilesKey] == "true" {
+		serial, err := util.CASerial()
+		if err != nil {
+			return nil, err
+		}
+		files[csiapi.CASerialFileName] = serial
+		files[csiapi.CAIndexFileName] = []byte{}
 	}
 
-	metaPath := filepath.Join(vol.Path, csiapi.MetaDataFileName)
-	if err := ioutil.WriteFile(metaPath, metaDataBytes, 0600); err != nil {
-		return nil, err
+	// The touch file is created with the other files, and touched once they
+	// have all been written.
+	if len(attr[csiapi.TouchFileOnRenewKey]) > 0 {
+		files[attr[csiapi.TouchFileOnRenewKey]] = []byte{}
 	}
 
-	glog.V(4).Infof("cert-manager: metadata written to file %s", metaPath)
-
-	certPath := util.CertPath(vol)
+	var keyBytes []byte
+	if keyBundle != nil {
+		keyBytes, err = util.EncodeVolumeFile(keyBundle.PEM, attr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode private key: %s", err)
+		}
 
-	if err := util.WriteFile(certPath, cr.Status.Certificate, 0600); err != nil {
-		return nil, err
+		if !util.DeliversKeyByFIFO(vol) {
+			files[attr[csiapi.KeyFileKey]] = keyBytes
+		}
 	}
 
-	if len(cr.Status.CA) > 0 {
-		caPath := util.CAPath(vol)
+	if err := util.WriteDataFiles(vol, files, c.fsyncFiles); err != nil {
+		return nil, fmt.Errorf("failed to write certificate files: %s", err)
+	}
 
-		if err := util.WriteFile(caPath, cr.Status.CA, 0600); err != nil {
-			return nil, err
+	if len(attr[csiapi.TouchFileOnRenewKey]) > 0 {
+		now := time.Now()
+		if err := os.Chtimes(util.TouchFilePath(vol), now, now); err != nil {
+			return nil, fmt.Errorf("failed to touch file: %s", err)
 		}
 	}
 
-	cert, err := pki.DecodeX509CertificateBytes(cr.Status.Certificate)
-	if err != nil {
-		return nil, err
+	glog.Infof("cert-manager: certificate written to file %s", util.CertPath(vol))
+	if keyBundle != nil {
+		if util.DeliversKeyByFIFO(vol) {
+			if err := c.deliverKey(vol, keyBytes); err != nil {
+				return nil, err
+			}
+
+			glog.Infof("cert-manager: private key awaiting reader of named pipe: %s", util.KeyPath(vol))
+		} else {
+			glog.Infof("cert-manager: private key written to file: %s", util.KeyPath(vol))
+		}
 	}
 
-	glog.Infof("cert-manager: certificate written to file %s", certPath)
+	writeDuration := time.Since(writeStart)
+	metrics.IssuancePhaseDuration.WithLabelValues(metrics.PhaseWrite).Observe(writeDuration.Seconds())
 
-	keyPath := util.KeyPath(vol)
-	if err := util.WriteFile(keyPath, keyBundle.PEM, 0600); err != nil {
-		return nil, fmt.Errorf("faild to write key data to file: %s", err)
+	if c.logCertDetails {
+		glog.V(c.logCertDetailsLevel).Infof("cert-manager: issued certificate %s", certDetails(vol, cert))
 	}
 
-	glog.Infof("cert-manager: private key written to file: %s", keyPath)
+	glog.V(2).Infof("cert-manager: issuance timings volume=%s correlation-id=%q create=%s wait=%s write=%s",
+		vol.ID, attr[csiapi.CorrelationIDKey], req.createDuration, req.waitDuration, writeDuration)
+
+	c.runPostIssueHook(vol)
 
 	return cert, nil
 }
 
+// certDetails formats the subject and SANs of the issued certificate of the
+// volume as key=value fields for audit.
+func certDetails(vol *csiapi.MetaData, cert *x509.Certificate) string {
+	attr := vol.Attributes
+
+	var ips, uris []string
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+
+	return fmt.Sprintf("volume=%s namespace=%s pod=%s correlation-id=%q serial=%s subject=%q issuer=%q not-before=%s not-after=%s dns-names=%q ip-addresses=%q email-addresses=%q uris=%q",
+		vol.ID, attr[csiapi.CSIPodNamespaceKey], attr[csiapi.CSIPodNameKey], attr[csiapi.CorrelationIDKey],
+		cert.SerialNumber.Text(16), cert.Subject.String(), cert.Issuer.String(),
+		cert.NotBefore.UTC().Format(time.RFC3339), cert.NotAfter.UTC().Format(time.RFC3339),
+		strings.Join(cert.DNSNames, ","), strings.Join(ips, ","),
+		strings.Join(cert.EmailAddresses, ","), strings.Join(uris, ","))
+}
+
 func (c *CertManager) RenewCertificate(vol *csiapi.MetaData) (*x509.Certificate, error) {
+	cert, err := c.renewCertificate(vol)
+	c.recordIssuance(metrics.OperationRenew, vol, err)
+	return cert, err
+}
+
+func (c *CertManager) renewCertificate(vol *csiapi.MetaData) (*x509.Certificate, error) {
 	var err error
 	var keyBundle *util.KeyBundle
 
 	glog.Infof("cert-manager: renewing certicate %s", vol.ID)
 
-	if b, ok := vol.Attributes[csiapi.ReusePrivateKey]; !ok || b != "true" {
-		keyBundle, err = util.NewRSAKey()
+	// The existing request holds the certificate being renewed, so a new
+	// request is always made.
+	if err := c.deleteCertificateRequest(vol, metrics.DeleteReasonRenew); err != nil {
+		return nil, err
+	}
+
+	// The key is held by the workload or the signer plugin, which decides
+	// whether it is rotated.
+	if !util.WritesPrivateKey(vol) {
+		return c.createNewCertificate(context.Background(), vol, nil)
+	}
+
+	reuse := vol.Attributes[csiapi.ReusePrivateKey] == "true"
+	if reuse && c.identityExpired(vol) {
+		glog.Infof("cert-manager: private key of volume %s is older than %s, rotating", vol.ID, c.maxIdentityAge)
+		reuse = false
+	}
+
+	if !reuse {
+		keyBundle, err = c.NewKey(vol)
+		if err != nil {
+			return nil, err
+		}
+
+	} else {
+		keyBundle, err = readKeyBundle(vol)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := c.createNewCertificate(context.Background(), vol, keyBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+// createCertificateRequest creates the CertificateRequest of the volume,
+// signed by the given key. A key is generated if nil, unless it is held by the
+// workload or the signer plugin. It returns the key the request was made with.
+func (c *CertManager) createCertificateRequest(ctx context.Context, vol *csiapi.MetaData, keyBundle *util.KeyBundle) (*util.KeyBundle, error) {
+	attr := vol.Attributes
+	namespace := attr[csiapi.CSIPodNamespaceKey]
+
+	issuerRef, fallbackRef := issuerRefs(attr)
+
+	if c.precheckIssuer {
+		if err := c.checkIssuerReady(namespace, issuerRef); err != nil {
+			if fallbackRef == nil {
+				return nil, err
+			}
+
+			glog.Errorf("cert-manager: using fallback issuer for CertificateRequest %s: %s", vol.ID, err)
+			issuerRef = *fallbackRef
+		}
+	}
+
+	var err error
+	if keyBundle == nil && util.WritesPrivateKey(vol) {
+		keyBundle, err = c.NewKey(vol)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The common name and SANs may be read from files at issuance.
+	sanAttr, err := c.readDownwardAPIFiles(attr)
+	if err != nil {
+		return nil, err
+	}
+
+	uris, err := util.ParseURISANs(sanAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := util.ParseIPAddresses(sanAttr[csiapi.IPSANsKey])
+
+	podIPs, err := c.trackedPodIPs(vol)
+	if err != nil {
+		return nil, err
+	}
+	ips = append(ips, podIPs...)
+
+	dnsNames, err := util.DNSNames(sanAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	commonName := sanAttr[csiapi.CommonNameKey]
+
+	extraExtensions, err := util.ParseExtraExtensions(attr[csiapi.ExtraExtensionsKey])
+	if err != nil {
+		return nil, err
+	}
+
+	// Leave the duration to the issuer if not requested.
+	var duration *metav1.Duration
+	if durStr, ok := attr[csiapi.DurationKey]; ok {
+		dur, err := time.ParseDuration(durStr)
 		if err != nil {
 			return nil, err
 		}
+		duration = &metav1.Duration{Duration: dur}
+	}
+
+	isCA := false
+	if isCAStr, ok := attr[csiapi.IsCAKey]; ok {
+		switch strings.ToLower(isCAStr) {
+		case "true":
+			isCA = true
+		case "false":
+			isCA = false
+		}
+	}
 
+	var csrPEM []byte
+	if len(attr[csiapi.CSRFileKey]) > 0 {
+		csrPEM, err = c.readCSRFile(attr[csiapi.CSRFileKey])
+		if err != nil {
+			return nil, err
+		}
 	} else {
+		csr := &x509.CertificateRequest{
+			Subject: pkix.Name{
+				CommonName:    commonName,
+				SerialNumber:  attr[csiapi.SubjectSerialNumberKey],
+				StreetAddress: util.ParseStringList(attr[csiapi.SubjectStreetAddressesKey]),
+			},
+			DNSNames:        dnsNames,
+			IPAddresses:     ips,
+			URIs:            uris,
+			ExtraExtensions: extraExtensions,
+		}
 
-		keyBytes, err := ioutil.ReadFile(util.KeyPath(vol))
+		var key crypto.Signer
+		if keyBundle != nil {
+			key = keyBundle.PrivateKey
+			csr.PublicKeyAlgorithm = keyBundle.PublicKeyAlgorithm
+			csr.SignatureAlgorithm = keyBundle.SignatureAlgorithm
+		} else {
+			key, err = c.externalSigner(ctx, vol)
+			if err != nil {
+				return nil, err
+			}
+		}
+		csr.PublicKey = key.Public()
 
+		csrPEM, err = util.EncodeCSR(csr, key)
 		if err != nil {
 			return nil, err
 		}
 
-		sk, err := pki.DecodePKCS1PrivateKeyBytes(keyBytes)
+		if keyBundle == nil {
+			if err := c.validateExternalCSR(vol, csrPEM); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	annotations, err := util.ParseKeyValues(attr[csiapi.RequestAnnotationsKey])
+	if err != nil {
+		return nil, err
+	}
+
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[csiapi.SpecHashAnnotationKey] = util.SpecHash(attr)
+	if nodeID := attr[csiapi.NodeIDKey]; len(nodeID) > 0 {
+		annotations[csiapi.NodeIDKey] = nodeID
+	}
+	if id := attr[csiapi.CorrelationIDKey]; len(id) > 0 {
+		annotations[csiapi.CorrelationIDKey] = id
+	}
+
+	if attr[csiapi.ServiceAccountTokenKey] == "true" {
+		token, err := c.requestServiceAccountToken(vol)
 		if err != nil {
 			return nil, err
 		}
 
-		keyBundle = &util.KeyBundle{
-			PEM:                keyBytes,
-			PrivateKey:         sk,
-			SignatureAlgorithm: x509.SHA256WithRSA,
-			PublicKeyAlgorithm: x509.RSA,
+		// Requests may be read by anyone allowed to read requests in the
+		// namespace, so only identify the token. The issuer verifies a token
+		// it is given out of band, such as from the token file, against it.
+		annotations[csiapi.ServiceAccountTokenKey] = tokenHash(token)
+		annotations[csiapi.ServiceAccountAnnotationKey] = attr[csiapi.CSIPodNamespaceKey] + "/" + attr[csiapi.CSIServiceAccountNameKey]
+	}
+
+	labels, err := util.ParseKeyValues(attr[csiapi.RequestLabelsKey])
+	if err != nil {
+		return nil, err
+	}
+	labels = c.setManagedLabel(labels)
+
+	// Build certificate request for volume
+	cr := &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            vol.ID,
+			Namespace:       namespace,
+			Annotations:     annotations,
+			Labels:          labels,
+			OwnerReferences: c.ownerReferences(vol),
+		},
+		Spec: cmapi.CertificateRequestSpec{
+			CSRPEM:    csrPEM,
+			IsCA:      isCA,
+			Usages:    util.ParseKeyUsages(attr),
+			Duration:  duration,
+			IssuerRef: issuerRef,
+		},
+	}
+
+	// if it doesn't exit yet then create it
+	cr, err = c.certificateRequests(namespace).Create(cr)
+	if err != nil {
+		return nil, apiError(err, "create", certificateRequestsResource, namespace)
+	}
+	c.recordRequestCreated(namespace)
+
+	glog.Infof("cert-manager: created CertificateRequest %s", vol.ID)
+
+	return keyBundle, nil
+}
+
+// recordIssuance counts the result of issuing or renewing the certificate of
+// the volume by its issuer.
+func (c *CertManager) recordIssuance(operation string, vol *csiapi.MetaData, err error) {
+	var namespace string
+	if c.metricsHighCardinality {
+		namespace = vol.Attributes[csiapi.CSIPodNamespaceKey]
+	}
+
+	result := metrics.ResultSuccess
+	if err != nil {
+		result = metrics.ResultError
+	}
+
+	metrics.Issuance.WithLabelValues(operation, vol.Attributes[csiapi.IssuerNameKey],
+		vol.Attributes[csiapi.IssuerKindKey], namespace, result).Inc()
+}
+
+// recordRequestCreated counts a CertificateRequest created in the namespace.
+func (c *CertManager) recordRequestCreated(namespace string) {
+	if !c.metricsHighCardinality {
+		namespace = ""
+	}
+
+	metrics.CertificateRequestsCreated.WithLabelValues(namespace).Inc()
+}
+
+// recordRequestDeleted counts a CertificateRequest deleted from the
+// namespace for the given reason.
+func (c *CertManager) recordRequestDeleted(namespace, reason string) {
+	if !c.metricsHighCardinality {
+		namespace = ""
+	}
+
+	metrics.CertificateRequestsDeleted.WithLabelValues(namespace, reason).Inc()
+}
+
+// checkDurationTruncated warns if the certificate was issued with a
+// significantly shorter duration than requested, such as when capped by the
+// issuer. Renewal is scheduled from the certificate's actual lifetime.
+func checkDurationTruncated(vol *csiapi.MetaData, cert *x509.Certificate) {
+	durStr, ok := vol.Attributes[csiapi.DurationKey]
+	if !ok {
+		return
+	}
+
+	requested, err := time.ParseDuration(durStr)
+	if err != nil {
+		return
+	}
+
+	actual := cert.NotAfter.Sub(cert.NotBefore)
+	if requested-actual <= time.Duration(float64(requested)*durationTruncatedTolerance) {
+		return
+	}
+
+	glog.Warningf("cert-manager: certificate of volume %s issued with duration %s, shorter than the requested %s",
+		vol.ID, actual, requested)
+	metrics.DurationTruncated.Inc()
+}
+
+// verifyIssuedCertificate returns an error if the certificate is not of the
+// public key of the request's CSR, or doesn't include every name the CSR
+// requested.
+func verifyIssuedCertificate(cr *cmapi.CertificateRequest, cert *x509.Certificate) error {
+	csr, err := pki.DecodeX509CertificateRequestBytes(cr.Spec.CSRPEM)
+	if err != nil {
+		return fmt.Errorf("failed to decode CSR: %s", err)
+	}
+
+	ok, err := util.PublicKeysEqual(csr.PublicKey, cert.PublicKey)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("public key does not match the CSR")
+	}
+
+	var errs []string
+
+	if cn := csr.Subject.CommonName; len(cn) > 0 && cn != cert.Subject.CommonName {
+		errs = append(errs, fmt.Sprintf("common name %q missing, got %q", cn, cert.Subject.CommonName))
+	}
+
+	errs = missingNames("DNS name", csr.DNSNames, cert.DNSNames, errs)
+	errs = missingNames("email address", csr.EmailAddresses, cert.EmailAddresses, errs)
+
+	var csrIPs, certIPs []string
+	for _, ip := range csr.IPAddresses {
+		csrIPs = append(csrIPs, ip.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		certIPs = append(certIPs, ip.String())
+	}
+	errs = missingNames("IP address", csrIPs, certIPs, errs)
+
+	var csrURIs, certURIs []string
+	for _, uri := range csr.URIs {
+		csrURIs = append(csrURIs, uri.String())
+	}
+	for _, uri := range cert.URIs {
+		certURIs = append(certURIs, uri.String())
+	}
+	errs = missingNames("URI", csrURIs, certURIs, errs)
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// missingNames appends an error for each requested name not in issued.
+func missingNames(kind string, requested, issued []string, errs []string) []string {
+	has := make(map[string]bool)
+	for _, name := range issued {
+		has[name] = true
+	}
+
+	for _, name := range requested {
+		if !has[name] {
+			errs = append(errs, fmt.Sprintf("%s %q missing", kind, name))
 		}
 	}
 
-	cert, err := c.CreateNewCertificate(vol, keyBundle)
+	return errs
+}
+
+// issuerRefs returns the issuer of the volume, and its fallback issuer if set.
+func issuerRefs(attr map[string]string) (cmmeta.ObjectReference, *cmmeta.ObjectReference) {
+	issuerRef := cmmeta.ObjectReference{
+		Name:  attr[csiapi.IssuerNameKey],
+		Kind:  attr[csiapi.IssuerKindKey],
+		Group: attr[csiapi.IssuerGroupKey],
+	}
+
+	if len(attr[csiapi.FallbackIssuerNameKey]) == 0 {
+		return issuerRef, nil
+	}
+
+	return issuerRef, &cmmeta.ObjectReference{
+		Name:  attr[csiapi.FallbackIssuerNameKey],
+		Kind:  attr[csiapi.FallbackIssuerKindKey],
+		Group: attr[csiapi.FallbackIssuerGroupKey],
+	}
+}
+
+// fallbackCertificateRequest replaces the failed CertificateRequest with one
+// for the fallback issuer, and waits for it to become ready.
+func (c *CertManager) fallbackCertificateRequest(ctx context.Context, cr *cmapi.CertificateRequest, fallbackRef cmmeta.ObjectReference) (*cmapi.CertificateRequest, error) {
+	client := c.certificateRequests(cr.Namespace)
+
+	err := c.deleteOwnCertificateRequest(cr.Namespace, cr.Name)
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return nil, apiError(err, "delete", certificateRequestsResource, cr.Namespace)
+	}
+	if err == nil {
+		c.recordRequestDeleted(cr.Namespace, metrics.DeleteReasonFallback)
+	}
+
+	fallback := &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            cr.Name,
+			Namespace:       cr.Namespace,
+			Annotations:     cr.Annotations,
+			Labels:          cr.Labels,
+			OwnerReferences: cr.OwnerReferences,
+		},
+		Spec: *cr.Spec.DeepCopy(),
+	}
+	fallback.Spec.IssuerRef = fallbackRef
+
+	if _, err := client.Create(fallback); err != nil {
+		return nil, apiError(err, "create", certificateRequestsResource, cr.Namespace)
+	}
+	c.recordRequestCreated(cr.Namespace)
+
+	return c.waitForCertificateRequestReady(ctx, cr.Name, cr.Namespace, c.issuanceTimeout)
+}
+
+// readKeyBundle reads the private key written to the volume. Keys delivered
+// through a named pipe are never stored, so can't be read back.
+func readKeyBundle(vol *csiapi.MetaData) (*util.KeyBundle, error) {
+	if util.DeliversKeyByFIFO(vol) {
+		return nil, fmt.Errorf("private key of volume %s is delivered through a named pipe and not stored", vol.ID)
+	}
+
+	keyBytes, err := ioutil.ReadFile(util.KeyPath(vol))
 	if err != nil {
 		return nil, err
 	}
 
-	return cert, nil
+	sk, keyPEM, err := util.DecodePrivateKey(keyBytes, vol.Attributes[csiapi.EncodingKey])
+	if err != nil {
+		return nil, err
+	}
+
+	return util.KeyBundleFromSigner(sk, keyPEM)
+}
+
+// identityExpired returns true if the private key of the volume is older than
+// the maximum identity age. Keys of an unknown age are always expired.
+func (c *CertManager) identityExpired(vol *csiapi.MetaData) bool {
+	if c.maxIdentityAge <= 0 {
+		return false
+	}
+
+	return vol.IdentityCreated.IsZero() || c.clock.Since(vol.IdentityCreated) >= c.maxIdentityAge
 }
 
-func (c *CertManager) checkExistingCertificateRequest(vol *csiapi.MetaData) (bool, error) {
+// readCSRFile reads and validates a workload provided CSR from the CSR
+// directory, returning it PEM encoded.
+func (c *CertManager) readCSRFile(name string) ([]byte, error) {
+	path := filepath.Join(c.csrDir, name)
+
+	csrPEM, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csr file: %s", err)
+	}
+
+	csr, err := pki.DecodeX509CertificateRequestBytes(csrPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse csr file %s: %s", path, err)
+	}
+
+	if err := validation.ValidateCSR(csr, c.fipsMode, c.maxSANs, c.minRSAKeySize, c.allowedCurves); err != nil {
+		return nil, fmt.Errorf("invalid csr file %s: %s", path, err)
+	}
+
+	return csrPEM, nil
+}
+
+// externalSigner returns the signer plugin's signer of the volume's key.
+func (c *CertManager) externalSigner(ctx context.Context, vol *csiapi.MetaData) (crypto.Signer, error) {
+	if c.signer == nil {
+		return nil, fmt.Errorf("private key of volume %s is external, but no --signer-plugin configured", vol.ID)
+	}
+
+	return c.signer.Signer(ctx, vol)
+}
+
+// validateExternalCSR checks the CSR signed by the signer plugin is valid and
+// of a key volumes may request.
+func (c *CertManager) validateExternalCSR(vol *csiapi.MetaData, csrPEM []byte) error {
+	csr, err := pki.DecodeX509CertificateRequestBytes(csrPEM)
+	if err != nil {
+		return err
+	}
+
+	// SANs have already been checked against the volume attributes.
+	if err := validation.ValidateCSR(csr, c.fipsMode, 0, c.minRSAKeySize, c.allowedCurves); err != nil {
+		return fmt.Errorf("invalid CSR signed by signer plugin for volume %s: %s", vol.ID, err)
+	}
+
+	return nil
+}
+
+// ownerReferences returns the pod of the volume as owner of its
+// CertificateRequest, or nil if owner references are disabled.
+func (c *CertManager) ownerReferences(vol *csiapi.MetaData) []metav1.OwnerReference {
+	if !c.setOwnerReference {
+		return nil
+	}
+
+	return []metav1.OwnerReference{
+		metav1.OwnerReference{
+			APIVersion:         "core/v1",
+			BlockOwnerDeletion: util.BoolPointer(true),
+			Controller:         util.BoolPointer(false),
+			Kind:               "Pod",
+			Name:               vol.Attributes[csiapi.CSIPodNameKey],
+			UID:                types.UID(vol.Attributes[csiapi.CSIPodUIDKey]),
+		},
+	}
+}
+
+// SetsOwnerReference returns true if CertificateRequests are owned by the pod
+// of their volume, and so garbage collected with it.
+func (c *CertManager) SetsOwnerReference() bool {
+	return c.setOwnerReference
+}
+
+// setManagedLabel sets the managed label, if configured, on the given labels
+// overriding any user provided value.
+func (c *CertManager) setManagedLabel(labels map[string]string) map[string]string {
+	if len(c.managedLabelKey) == 0 {
+		return labels
+	}
+
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[c.managedLabelKey] = "true"
+
+	return labels
+}
+
+// Probe returns an error if the cert-manager API cannot be reached.
+func (c *CertManager) Probe() error {
+	_, err := c.certificateRequests(metav1.NamespaceAll).List(metav1.ListOptions{
+		Limit: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list CertificateRequests: %s", err)
+	}
+
+	return nil
+}
+
+// DeleteCertificateRequest deletes the CertificateRequest of the given volume,
+// if it exists, as the volume is unpublished.
+func (c *CertManager) DeleteCertificateRequest(vol *csiapi.MetaData) error {
+	return c.deleteCertificateRequest(vol, metrics.DeleteReasonUnpublish)
+}
+
+func (c *CertManager) deleteCertificateRequest(vol *csiapi.MetaData, reason string) error {
+	namespace := vol.Attributes[csiapi.CSIPodNamespaceKey]
+
+	err := c.deleteOwnCertificateRequest(namespace, vol.ID)
+	if k8sErrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete CertificateRequest %s/%s: %s", namespace, vol.ID, err)
+	}
+	c.recordRequestDeleted(namespace, reason)
+
+	return nil
+}
+
+// FetchCA returns the CA of the CertificateRequest of the given volume.
+func (c *CertManager) FetchCA(vol *csiapi.MetaData) ([]byte, error) {
+	namespace := vol.Attributes[csiapi.CSIPodNamespaceKey]
+
+	cr, err := c.certificateRequests(namespace).Get(vol.ID, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return cr.Status.CA, nil
+}
+
+// checkExistingCertificateRequest returns the CertificateRequest of the
+// volume, and the private key it was requested with, if it exists and matches
+// the volume spec. The request must have been made with the given key, or the
+// key written to the volume if nil, and if Ready its certificate must not
+// have expired. Requests that don't match are deleted.
+func (c *CertManager) checkExistingCertificateRequest(vol *csiapi.MetaData, keyBundle *util.KeyBundle) (*cmapi.CertificateRequest, *util.KeyBundle, error) {
 	namespace := vol.Attributes[csiapi.CSIPodNamespaceKey]
 
 	// get current certificate request
-	cr, err := c.cmClient.CertmanagerV1alpha2().CertificateRequests(namespace).Get(vol.ID, metav1.GetOptions{})
+	cr, err := c.certificateRequests(namespace).Get(vol.ID, metav1.GetOptions{})
 	if err != nil {
 		if !k8sErrors.IsNotFound(err) {
-			return false, err
+			return nil, nil, apiError(err, "get", certificateRequestsResource, namespace)
 		}
 
 		// certificate request doesn't exist so create a new one
-		return false, nil
+		return nil, nil, nil
+	}
+
+	// If the certificate request was created from the same attributes then it
+	// matches, otherwise fall back to comparing the request to the spec.
+	hash, ok := cr.Annotations[csiapi.SpecHashAnnotationKey]
+	if ok && hash != util.SpecHash(vol.Attributes) {
+		err = fmt.Errorf("spec hash %q does not match %q", hash, util.SpecHash(vol.Attributes))
+	} else if !ok {
+		err = util.CertificateRequestMatchesSpec(cr, vol.Attributes)
+	}
+
+	// Unless the key is held by the workload or the signer plugin, the
+	// request is only of use if its private key is available.
+	if err == nil && util.WritesPrivateKey(vol) {
+		keyBundle, err = requestKeyBundle(cr, vol, keyBundle)
+	}
+
+	// A Ready request is only of use if its certificate is still valid.
+	if err == nil && util.CertificateRequestReady(cr) {
+		err = c.checkRequestCertificate(cr)
 	}
 
 	// If certificate request doesn't match the volume spec then delete the current one
-	if err := util.CertificateRequestMatchesSpec(cr, vol.Attributes); err != nil {
+	if err != nil {
 		glog.Infof("cert-manager: deleting existing CertificateRequest since it doesn't match spec %s: %s", vol.ID, err)
-		err = c.cmClient.CertmanagerV1alpha2().CertificateRequests(namespace).Delete(vol.ID, &metav1.DeleteOptions{})
+		err = c.deleteOwnCertificateRequest(namespace, vol.ID)
+		if err != nil {
+			return nil, nil, apiError(err, "delete", certificateRequestsResource, namespace)
+		}
+		c.recordRequestDeleted(namespace, metrics.DeleteReasonMismatch)
+
+		return nil, nil, nil
+	}
+
+	return cr, keyBundle, nil
+}
+
+// checkRequestCertificate returns an error if the certificate of the Ready
+// CertificateRequest can't be decoded or has expired.
+func (c *CertManager) checkRequestCertificate(cr *cmapi.CertificateRequest) error {
+	cert, err := decodeIssuedCertificate(cr)
+	if err != nil {
+		return err
+	}
+
+	if !c.clock.Now().Before(cert.NotAfter) {
+		return fmt.Errorf("certificate of request expired at %s", cert.NotAfter)
+	}
+
+	return nil
+}
+
+// decodeIssuedCertificate decodes the certificate of the Ready
+// CertificateRequest, returning a clear error should the issuer have set it
+// empty or invalid.
+func decodeIssuedCertificate(cr *cmapi.CertificateRequest) (*x509.Certificate, error) {
+	if len(cr.Status.Certificate) == 0 {
+		return nil, fmt.Errorf("issuer returned an empty certificate for CertificateRequest %s/%s",
+			cr.Namespace, cr.Name)
+	}
+
+	cert, err := pki.DecodeX509CertificateBytes(cr.Status.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("issuer returned an invalid certificate for CertificateRequest %s/%s: %s",
+			cr.Namespace, cr.Name, err)
+	}
+
+	return cert, nil
+}
+
+// requestKeyBundle returns the given key, or the key written to the volume if
+// nil, if the CertificateRequest was made with it.
+func requestKeyBundle(cr *cmapi.CertificateRequest, vol *csiapi.MetaData, keyBundle *util.KeyBundle) (*util.KeyBundle, error) {
+	if keyBundle == nil {
+		var err error
+		keyBundle, err = readKeyBundle(vol)
 		if err != nil {
-			return false, err
+			return nil, fmt.Errorf("private key of request not available: %s", err)
 		}
+	}
+
+	csr, err := pki.DecodeX509CertificateRequestBytes(cr.Spec.CSRPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate request PEM: %s", err)
+	}
 
-		return false, nil
+	ok, err := util.PublicKeysEqual(keyBundle.PrivateKey.Public(), csr.PublicKey)
+	if err != nil || !ok {
+		return nil, errors.New("request was not made with the private key")
 	}
 
-	return true, nil
+	return keyBundle, nil
 }
 
-func (c *CertManager) waitForCertificateRequestReady(name, ns string, timeout time.Duration) (*cmapi.CertificateRequest, error) {
+// waitForCertificateRequestReady polls the CertificateRequest until it
+// becomes ready, fails, the timeout expires or the given context is
+// cancelled.
+func (c *CertManager) waitForCertificateRequestReady(ctx context.Context, name, ns string, timeout time.Duration) (*cmapi.CertificateRequest, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	var cr *cmapi.CertificateRequest
-	err := wait.PollImmediate(time.Second, timeout,
+	err := wait.PollImmediateUntil(time.Second,
 		func() (bool, error) {
 
 			glog.V(4).Infof("cert-manager: polling CertificateRequest %s/%s for ready status", name, ns)
 
 			var err error
-			cr, err = c.cmClient.CertmanagerV1alpha2().CertificateRequests(ns).Get(name, metav1.GetOptions{})
+			cr, err = c.certificateRequests(ns).Get(name, metav1.GetOptions{})
 			if err != nil {
+				err = apiError(err, "get", certificateRequestsResource, ns)
+				if _, ok := APIErrorCode(err); ok {
+					return false, err
+				}
+
 				return false, fmt.Errorf("error getting CertificateRequest %s: %v", name, err)
 			}
 
@@ -281,9 +1275,14 @@ func (c *CertManager) waitForCertificateRequestReady(name, ns string, timeout ti
 			}
 
 			return true, nil
-		},
+		}, ctx.Done(),
 	)
 
+	if err == wait.ErrWaitTimeout && ctx.Err() != nil {
+		return cr, fmt.Errorf("failed waiting for CertificateRequest %s/%s to become ready: %s",
+			ns, name, ctx.Err())
+	}
+
 	if err != nil {
 		return cr, err
 	}
@@ -0,0 +1,1840 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	coretesting "k8s.io/client-go/testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/metrics"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestWaitForCertificateRequestReadyContextCancelled(t *testing.T) {
+	cr := &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-id",
+			Namespace: "test-namespace",
+		},
+	}
+
+	c := &CertManager{
+		cmClient: cmfake.NewSimpleClientset(cr),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	_, err := c.waitForCertificateRequestReady(ctx, "test-id", "test-namespace", time.Second*30)
+	if err == nil {
+		t.Fatal("expected error waiting for CertificateRequest with cancelled context, got none")
+	}
+
+	if !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("expected context cancelled error, got=%s", err)
+	}
+
+	if since := time.Since(start); since > time.Second*5 {
+		t.Errorf("expected wait to abort promptly on cancelled context, took %s", since)
+	}
+}
+
+func TestCheckExistingCertificateRequestSpecHash(t *testing.T) {
+	attr := map[string]string{
+		csiapi.CSIPodNamespaceKey: "test-namespace",
+		csiapi.IssuerNameKey:      "test-issuer",
+		csiapi.DNSNamesKey:        "foo.bar",
+	}
+
+	changedAttr := map[string]string{
+		csiapi.CSIPodNamespaceKey: "test-namespace",
+		csiapi.IssuerNameKey:      "test-issuer",
+		csiapi.DNSNamesKey:        "bar.foo",
+	}
+
+	requestKey, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csrPEM, err := util.EncodeCSR(&x509.CertificateRequest{
+		PublicKey:          requestKey.PrivateKey.Public(),
+		PublicKeyAlgorithm: requestKey.PublicKeyAlgorithm,
+		SignatureAlgorithm: requestKey.SignatureAlgorithm,
+	}, requestKey.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, test := range map[string]struct {
+		hash      string
+		keyBundle *util.KeyBundle
+		expOK     bool
+		expDelete bool
+	}{
+		"if the spec hash matches then the request should be kept": {
+			hash:      util.SpecHash(attr),
+			keyBundle: requestKey,
+			expOK:     true,
+			expDelete: false,
+		},
+		"if the spec hash differs then the request should be deleted": {
+			hash:      util.SpecHash(changedAttr),
+			keyBundle: requestKey,
+			expOK:     false,
+			expDelete: true,
+		},
+		"if the request was made with another key then it should be deleted": {
+			hash:      util.SpecHash(attr),
+			keyBundle: otherKey,
+			expOK:     false,
+			expDelete: true,
+		},
+		"if the key of the request is not available then it should be deleted": {
+			hash:      util.SpecHash(attr),
+			keyBundle: nil,
+			expOK:     false,
+			expDelete: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cr := &cmapi.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-id",
+					Namespace: "test-namespace",
+					Annotations: map[string]string{
+						csiapi.SpecHashAnnotationKey: test.hash,
+					},
+				},
+				Spec: cmapi.CertificateRequestSpec{
+					CSRPEM: csrPEM,
+				},
+			}
+
+			client := cmfake.NewSimpleClientset(cr)
+			c := &CertManager{
+				cmClient: client,
+			}
+
+			existing, _, err := c.checkExistingCertificateRequest(&csiapi.MetaData{
+				ID:         "test-id",
+				Path:       "/does-not-exist",
+				Attributes: attr,
+			}, test.keyBundle)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if ok := existing != nil; ok != test.expOK {
+				t.Errorf("unexpected ok, exp=%t got=%t", test.expOK, ok)
+			}
+
+			var deleted bool
+			for _, action := range client.Actions() {
+				if action.GetVerb() == "delete" {
+					deleted = true
+				}
+			}
+
+			if deleted != test.expDelete {
+				t.Errorf("unexpected delete, exp=%t got=%t", test.expDelete, deleted)
+			}
+		})
+	}
+}
+
+func TestCreateNewCertificateManagedLabel(t *testing.T) {
+	for name, test := range map[string]struct {
+		managedLabelKey string
+		requestLabels   string
+		expLabels       map[string]string
+	}{
+		"if no managed label key then only request labels should be set": {
+			managedLabelKey: "",
+			requestLabels:   "foo=bar",
+			expLabels:       map[string]string{"foo": "bar"},
+		},
+		"if a managed label key then the label should be set": {
+			managedLabelKey: "csi.cert-manager.io/managed",
+			requestLabels:   "foo=bar",
+			expLabels: map[string]string{
+				"foo":                         "bar",
+				"csi.cert-manager.io/managed": "true",
+			},
+		},
+		"the managed label should override a request label": {
+			managedLabelKey: "example.com/csi",
+			requestLabels:   "example.com/csi=false",
+			expLabels:       map[string]string{"example.com/csi": "true"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := cmfake.NewSimpleClientset()
+			c := &CertManager{
+				cmClient:        client,
+				managedLabelKey: test.managedLabelKey,
+				issuanceTimeout: time.Second,
+			}
+
+			keyBundle, err := util.NewECDSAKey(256)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			vol := &csiapi.MetaData{
+				ID: "test-id",
+				Attributes: map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.IssuerNameKey:      "test-issuer",
+					csiapi.DNSNamesKey:        "foo.bar",
+					csiapi.RequestLabelsKey:   test.requestLabels,
+				},
+			}
+
+			// The request is never signed so only its creation is of interest.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			if _, err := c.CreateNewCertificate(ctx, vol, keyBundle); err == nil {
+				t.Fatal("expected error waiting for unsigned CertificateRequest, got none")
+			}
+
+			cr, err := client.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(cr.Labels, test.expLabels) {
+				t.Errorf("unexpected labels, exp=%v got=%v", test.expLabels, cr.Labels)
+			}
+		})
+	}
+}
+
+func TestCreateNewCertificateCSRFile(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-csr-file-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	csrDir := filepath.Join(dir, "csr")
+	volPath := filepath.Join(dir, "vol")
+	if err := os.MkdirAll(csrDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	workloadKey, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csrPEM, err := util.EncodeCSR(&x509.CertificateRequest{
+		DNSNames:           []string{"foo.bar"},
+		PublicKey:          workloadKey.PrivateKey.Public(),
+		PublicKeyAlgorithm: workloadKey.PublicKeyAlgorithm,
+		SignatureAlgorithm: workloadKey.SignatureAlgorithm,
+	}, workloadKey.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(csrDir, "csr.pem"), csrPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := cmfake.NewSimpleClientset()
+	signOnCreate(t, client)
+
+	c := &CertManager{
+		cmClient:        client,
+		issuanceTimeout: time.Second * 5,
+		csrDir:          csrDir,
+	}
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: volPath,
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "test-issuer",
+			csiapi.CSRFileKey:         "csr.pem",
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+		},
+	}
+
+	cert, err := c.CreateNewCertificate(context.TODO(), vol, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := pki.PublicKeyMatchesCertificate(workloadKey.PrivateKey.Public(), cert)
+	if err != nil || !ok {
+		t.Errorf("expected certificate to be signed for the workload's key: %v", err)
+	}
+
+	cr, err := client.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(cr.Spec.CSRPEM, csrPEM) {
+		t.Errorf("expected the workload's CSR to be submitted, got=%s", cr.Spec.CSRPEM)
+	}
+
+	if _, err := os.Stat(util.CertPath(vol)); err != nil {
+		t.Errorf("expected certificate file to be written: %s", err)
+	}
+
+	if _, err := os.Stat(util.KeyPath(vol)); !os.IsNotExist(err) {
+		t.Errorf("expected no private key file to be written, got: %v", err)
+	}
+}
+
+func TestRenewCertificateMaxIdentityAge(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, test := range map[string]struct {
+		maxIdentityAge  time.Duration
+		reuseKey        string
+		identityCreated time.Time
+		expRotate       bool
+	}{
+		"if no max identity age then the key should be reused": {
+			maxIdentityAge:  0,
+			reuseKey:        "true",
+			identityCreated: now.Add(-time.Hour * 24 * 365),
+			expRotate:       false,
+		},
+		"if the key is younger than the max identity age then it should be reused": {
+			maxIdentityAge:  time.Hour * 24,
+			reuseKey:        "true",
+			identityCreated: now.Add(-time.Hour),
+			expRotate:       false,
+		},
+		"if the key is older than the max identity age then it should be rotated": {
+			maxIdentityAge:  time.Hour * 24,
+			reuseKey:        "true",
+			identityCreated: now.Add(-time.Hour * 25),
+			expRotate:       true,
+		},
+		"if the age of the key is unknown then it should be rotated": {
+			maxIdentityAge:  time.Hour * 24,
+			reuseKey:        "true",
+			identityCreated: time.Time{},
+			expRotate:       true,
+		},
+		"if the key is not reused then it should be rotated": {
+			maxIdentityAge:  0,
+			reuseKey:        "false",
+			identityCreated: now.Add(-time.Hour),
+			expRotate:       true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-identity-age-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			client := cmfake.NewSimpleClientset()
+			signOnCreate(t, client)
+
+			c := &CertManager{
+				cmClient:        client,
+				issuanceTimeout: time.Second * 5,
+				maxIdentityAge:  test.maxIdentityAge,
+				clock:           clock.NewFakeClock(now),
+			}
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.IssuerNameKey:      "test-issuer",
+					csiapi.CertFileKey:        "crt.pem",
+					csiapi.KeyFileKey:         "key.pem",
+					csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+					csiapi.KeySizeKey:         "256",
+					csiapi.ReusePrivateKey:    test.reuseKey,
+				},
+				IdentityCreated: test.identityCreated,
+			}
+
+			keyBundle, err := util.NewECDSAKey(256)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := util.WriteFile(util.KeyPath(vol), keyBundle.PEM, 0600, false); err != nil {
+				t.Fatal(err)
+			}
+
+			cert, err := c.RenewCertificate(vol)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reused, err := pki.PublicKeyMatchesCertificate(keyBundle.PrivateKey.Public(), cert)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if reused == test.expRotate {
+				t.Errorf("unexpected key rotation, exp=%t got=%t", test.expRotate, !reused)
+			}
+
+			expIdentityCreated := test.identityCreated
+			if test.expRotate {
+				expIdentityCreated = now
+			}
+
+			if !vol.IdentityCreated.Equal(expIdentityCreated) {
+				t.Errorf("unexpected identity created time, exp=%s got=%s",
+					expIdentityCreated, vol.IdentityCreated)
+			}
+		})
+	}
+}
+
+func TestRenewCertificateReuseKeyEncodings(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkcs8 := func(key interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+
+	for name, test := range map[string]struct {
+		block     *pem.Block
+		key       crypto.Signer
+		algorithm string
+		size      string
+		expPKAlg  x509.PublicKeyAlgorithm
+	}{
+		"pkcs#1 rsa key should be reused": {
+			&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)},
+			rsaKey, csiapi.RSAKeyAlgorithm, "2048", x509.RSA,
+		},
+		"pkcs#8 rsa key should be reused": {
+			&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8(rsaKey)},
+			rsaKey, csiapi.RSAKeyAlgorithm, "2048", x509.RSA,
+		},
+		"ec key should be reused": {
+			&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER},
+			ecKey, csiapi.ECDSAKeyAlgorithm, "256", x509.ECDSA,
+		},
+		"pkcs#8 ec key should be reused": {
+			&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8(ecKey)},
+			ecKey, csiapi.ECDSAKeyAlgorithm, "256", x509.ECDSA,
+		},
+		"pkcs#8 ed25519 key should be reused": {
+			&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8(edKey)},
+			edKey, csiapi.ECDSAKeyAlgorithm, "256", x509.Ed25519,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-reuse-key-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			client := cmfake.NewSimpleClientset()
+			signOnCreate(t, client)
+
+			c := &CertManager{
+				cmClient:         client,
+				issuanceTimeout:  time.Second * 5,
+				verifyIssuedCert: true,
+				clock:            clock.RealClock{},
+			}
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.IssuerNameKey:      "test-issuer",
+					csiapi.CertFileKey:        "crt.pem",
+					csiapi.KeyFileKey:         "key.pem",
+					csiapi.KeyAlgorithmKey:    test.algorithm,
+					csiapi.KeySizeKey:         test.size,
+					csiapi.ReusePrivateKey:    "true",
+				},
+			}
+
+			keyPEM := pem.EncodeToMemory(test.block)
+			if err := util.WriteFile(util.KeyPath(vol), keyPEM, 0600, false); err != nil {
+				t.Fatal(err)
+			}
+
+			cert, err := c.RenewCertificate(vol)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cert.PublicKeyAlgorithm != test.expPKAlg {
+				t.Errorf("unexpected public key algorithm, exp=%s got=%s",
+					test.expPKAlg, cert.PublicKeyAlgorithm)
+			}
+
+			reused, err := util.PublicKeysEqual(test.key.Public(), cert.PublicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reused {
+				t.Error("expected the existing private key to be reused")
+			}
+
+			gotKeyPEM, err := ioutil.ReadFile(util.KeyPath(vol))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gotKeyPEM, keyPEM) {
+				t.Errorf("expected key file to be unchanged, exp=%s got=%s", keyPEM, gotKeyPEM)
+			}
+		})
+	}
+}
+
+func TestCreateNewCertificateExistingRequest(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-existing-request-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "test-issuer",
+			csiapi.DNSNamesKey:        "foo.bar",
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+		},
+	}
+
+	keyBundle, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := cmfake.NewSimpleClientset()
+	signOnCreate(t, client)
+
+	c := &CertManager{
+		cmClient:        client,
+		issuanceTimeout: time.Second * 5,
+		clock:           clock.RealClock{},
+	}
+
+	// The first publish attempt requests and writes the certificate.
+	if _, err := c.CreateNewCertificate(context.TODO(), vol, keyBundle); err != nil {
+		t.Fatal(err)
+	}
+	client.ClearActions()
+
+	// A retried publish should reuse the request and the key written to the
+	// volume, rather than generating a new key and request.
+	cert, err := c.CreateNewCertificate(context.TODO(), vol, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request is already Ready, so it is only fetched once.
+	var gets int
+	for _, action := range client.Actions() {
+		switch verb := action.GetVerb(); verb {
+		case "create", "delete":
+			t.Errorf("expected existing CertificateRequest to be reused, got %s", verb)
+		case "get":
+			gets++
+		}
+	}
+
+	if gets != 1 {
+		t.Errorf("expected Ready CertificateRequest to be fetched once, got %d", gets)
+	}
+
+	ok, err := pki.PublicKeyMatchesCertificate(keyBundle.PrivateKey.Public(), cert)
+	if err != nil || !ok {
+		t.Errorf("expected certificate to match the existing private key: %v", err)
+	}
+
+	keyBytes, err := ioutil.ReadFile(util.KeyPath(vol))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(keyBytes, keyBundle.PEM) {
+		t.Error("expected private key of the volume to be unchanged")
+	}
+}
+
+func TestCreateNewCertificateOwnerReference(t *testing.T) {
+	for name, test := range map[string]struct {
+		setOwnerReference bool
+		expOwners         int
+	}{
+		"if owner references are enabled, the pod should own the request": {
+			setOwnerReference: true,
+			expOwners:         1,
+		},
+		"if owner references are disabled, the request should have no owner": {
+			setOwnerReference: false,
+			expOwners:         0,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-owner-reference-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.CSIPodNameKey:      "test-pod",
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.CSIPodUIDKey:       "test-uid",
+					csiapi.IssuerNameKey:      "test-issuer",
+					csiapi.DNSNamesKey:        "foo.bar",
+					csiapi.CertFileKey:        "crt.pem",
+					csiapi.KeyFileKey:         "key.pem",
+					csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+					csiapi.KeySizeKey:         "256",
+				},
+			}
+
+			client := cmfake.NewSimpleClientset()
+			signOnCreate(t, client)
+
+			c := &CertManager{
+				cmClient:          client,
+				issuanceTimeout:   time.Second * 5,
+				setOwnerReference: test.setOwnerReference,
+				clock:             clock.RealClock{},
+			}
+
+			if _, err := c.CreateNewCertificate(context.TODO(), vol, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			cr, err := client.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(cr.OwnerReferences) != test.expOwners {
+				t.Fatalf("unexpected number of owner references, exp=%d got=%d",
+					test.expOwners, len(cr.OwnerReferences))
+			}
+
+			if test.expOwners == 0 {
+				return
+			}
+
+			owner := cr.OwnerReferences[0]
+			if owner.Name != "test-pod" || owner.UID != "test-uid" {
+				t.Errorf("expected request to be owned by pod test-pod (test-uid), got %s (%s)",
+					owner.Name, owner.UID)
+			}
+		})
+	}
+}
+
+// fakeSigner holds a single key for every volume.
+type fakeSigner struct {
+	key crypto.Signer
+}
+
+func (f *fakeSigner) Signer(context.Context, *csiapi.MetaData) (crypto.Signer, error) {
+	return f.key, nil
+}
+
+func (f *fakeSigner) Probe(context.Context) error {
+	return nil
+}
+
+func TestCreateNewCertificateExternalKey(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-external-key-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "test-issuer",
+			csiapi.DNSNamesKey:        "foo.bar",
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+			csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:         "256",
+		},
+		KeyExternal: true,
+	}
+
+	keyBundle, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := cmfake.NewSimpleClientset()
+	signOnCreate(t, client)
+
+	c := &CertManager{
+		cmClient:        client,
+		issuanceTimeout: time.Second * 5,
+		signer:          &fakeSigner{key: keyBundle.PrivateKey},
+		clock:           clock.RealClock{},
+	}
+
+	cert, err := c.CreateNewCertificate(context.TODO(), vol, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := pki.PublicKeyMatchesCertificate(keyBundle.PrivateKey.Public(), cert)
+	if err != nil || !ok {
+		t.Errorf("expected certificate to be of the external key: %v", err)
+	}
+
+	if _, err := os.Stat(util.KeyPath(vol)); !os.IsNotExist(err) {
+		t.Errorf("expected no private key to be written to the volume, got: %v", err)
+	}
+}
+
+func TestCreateNewCertificateFallbackIssuer(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-fallback-issuer-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey:     "test-namespace",
+			csiapi.IssuerNameKey:          "test-issuer",
+			csiapi.IssuerKindKey:          cmapi.IssuerKind,
+			csiapi.IssuerGroupKey:         "cert-manager.io",
+			csiapi.FallbackIssuerNameKey:  "test-fallback-issuer",
+			csiapi.FallbackIssuerKindKey:  cmapi.ClusterIssuerKind,
+			csiapi.FallbackIssuerGroupKey: "cert-manager.io",
+			csiapi.DNSNamesKey:            "foo.bar",
+			csiapi.CertFileKey:            "crt.pem",
+			csiapi.KeyFileKey:             "key.pem",
+			csiapi.KeyAlgorithmKey:        csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:             "256",
+		},
+	}
+
+	client := cmfake.NewSimpleClientset()
+
+	// Requests to the primary issuer are marked as failed after being signed.
+	client.PrependReactor("create", "certificaterequests", func(action coretesting.Action) (bool, runtime.Object, error) {
+		cr := action.(coretesting.CreateAction).GetObject().(*cmapi.CertificateRequest)
+		if cr.Spec.IssuerRef.Name == "test-issuer" {
+			cr.Status.Certificate = nil
+			cr.Status.Conditions = []cmapi.CertificateRequestCondition{
+				{
+					Type:    cmapi.CertificateRequestConditionReady,
+					Status:  cmmeta.ConditionFalse,
+					Reason:  "Failed",
+					Message: "issuer unavailable",
+				},
+			}
+		}
+
+		return false, nil, nil
+	})
+	signOnCreate(t, client)
+
+	c := &CertManager{
+		cmClient:        client,
+		issuanceTimeout: time.Second * 5,
+		clock:           clock.RealClock{},
+	}
+
+	if _, err := c.CreateNewCertificate(context.TODO(), vol, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cr, err := client.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cr.Spec.IssuerRef.Name != "test-fallback-issuer" {
+		t.Errorf("expected CertificateRequest to use the fallback issuer, got %q", cr.Spec.IssuerRef.Name)
+	}
+
+	expIssuer := &csiapi.IssuerRef{
+		Name:  "test-fallback-issuer",
+		Kind:  cmapi.ClusterIssuerKind,
+		Group: "cert-manager.io",
+	}
+	if !reflect.DeepEqual(vol.Issuer, expIssuer) {
+		t.Errorf("unexpected issuer recorded, exp=%+v got=%+v", expIssuer, vol.Issuer)
+	}
+}
+
+func TestCreateNewCertificateExistingRequestExpired(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-existing-request-expired-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "test-issuer",
+			csiapi.DNSNamesKey:        "foo.bar",
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+			csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:         "256",
+		},
+	}
+
+	client := cmfake.NewSimpleClientset()
+	signOnCreate(t, client)
+
+	fakeClock := clock.NewFakeClock(time.Now())
+	c := &CertManager{
+		cmClient:        client,
+		issuanceTimeout: time.Second * 5,
+		clock:           fakeClock,
+	}
+
+	if _, err := c.CreateNewCertificate(context.TODO(), vol, nil); err != nil {
+		t.Fatal(err)
+	}
+	client.ClearActions()
+
+	// Certificates are signed for an hour, after which the Ready request is
+	// of no use and must be replaced.
+	fakeClock.Step(time.Hour * 2)
+
+	if _, err := c.CreateNewCertificate(context.TODO(), vol, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var created, deleted bool
+	for _, action := range client.Actions() {
+		switch action.GetVerb() {
+		case "create":
+			created = true
+		case "delete":
+			deleted = true
+		}
+	}
+
+	if !created || !deleted {
+		t.Errorf("expected expired CertificateRequest to be replaced, created=%t deleted=%t", created, deleted)
+	}
+}
+
+func TestCheckDurationTruncated(t *testing.T) {
+	notBefore := time.Now()
+
+	for name, test := range map[string]struct {
+		duration     string
+		lifetime     time.Duration
+		expTruncated bool
+	}{
+		"no requested duration should not be truncated": {
+			duration: "",
+			lifetime: time.Hour,
+		},
+		"the requested duration should not be truncated": {
+			duration: "24h",
+			lifetime: time.Hour * 24,
+		},
+		"a slightly shorter duration should not be truncated": {
+			duration: "24h",
+			lifetime: time.Hour*24 - time.Minute,
+		},
+		"a shorter duration should be truncated": {
+			duration:     "2160h",
+			lifetime:     time.Hour * 24,
+			expTruncated: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attr := map[string]string{}
+			if len(test.duration) > 0 {
+				attr[csiapi.DurationKey] = test.duration
+			}
+
+			before := testutil.ToFloat64(metrics.DurationTruncated)
+
+			checkDurationTruncated(&csiapi.MetaData{
+				ID:         "test-id",
+				Attributes: attr,
+			}, &x509.Certificate{
+				NotBefore: notBefore,
+				NotAfter:  notBefore.Add(test.lifetime),
+			})
+
+			truncated := testutil.ToFloat64(metrics.DurationTruncated) > before
+			if truncated != test.expTruncated {
+				t.Errorf("unexpected truncated, exp=%t got=%t", test.expTruncated, truncated)
+			}
+		})
+	}
+}
+
+func TestRecordIssuance(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-record-issuance-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "record-issuance-issuer",
+			csiapi.IssuerKindKey:      cmapi.IssuerKind,
+			csiapi.DNSNamesKey:        "foo.bar",
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+			csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:         "256",
+		},
+	}
+
+	client := cmfake.NewSimpleClientset()
+	signOnCreate(t, client)
+
+	c := &CertManager{
+		cmClient:               client,
+		issuanceTimeout:        time.Second * 5,
+		clock:                  clock.RealClock{},
+		metricsHighCardinality: true,
+	}
+
+	count := func(operation, namespace, result string) float64 {
+		return testutil.ToFloat64(metrics.Issuance.WithLabelValues(operation,
+			"record-issuance-issuer", cmapi.IssuerKind, namespace, result))
+	}
+
+	if _, err := c.CreateNewCertificate(context.TODO(), vol, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.RenewCertificate(vol); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(metrics.OperationIssue, "test-namespace", metrics.ResultSuccess); n != 1 {
+		t.Errorf("unexpected number of successful issuances, exp=1 got=%v", n)
+	}
+
+	// Renewals are only counted as renewals.
+	if n := count(metrics.OperationRenew, "test-namespace", metrics.ResultSuccess); n != 1 {
+		t.Errorf("unexpected number of successful renewals, exp=1 got=%v", n)
+	}
+
+	// Without high cardinality metrics the namespace is not recorded.
+	c.metricsHighCardinality = false
+	c.recordIssuance(metrics.OperationIssue, vol, errors.New("failed"))
+
+	if n := count(metrics.OperationIssue, "", metrics.ResultError); n != 1 {
+		t.Errorf("unexpected number of failed issuances without namespace, exp=1 got=%v", n)
+	}
+}
+
+func TestRecordCertificateRequestChurn(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-request-churn-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey: "churn-namespace",
+			csiapi.IssuerNameKey:      "ca-issuer",
+			csiapi.DNSNamesKey:        "foo.bar",
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+			csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:         "256",
+		},
+	}
+
+	client := cmfake.NewSimpleClientset()
+	signOnCreate(t, client)
+
+	c := &CertManager{
+		cmClient:               client,
+		issuanceTimeout:        time.Second * 5,
+		clock:                  clock.RealClock{},
+		metricsHighCardinality: true,
+	}
+
+	created := func() float64 {
+		return testutil.ToFloat64(metrics.CertificateRequestsCreated.WithLabelValues("churn-namespace"))
+	}
+	deleted := func(reason string) float64 {
+		return testutil.ToFloat64(metrics.CertificateRequestsDeleted.WithLabelValues("churn-namespace", reason))
+	}
+
+	if _, err := c.CreateNewCertificate(context.TODO(), vol, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The existing request no longer matches the spec, so is replaced.
+	vol.Attributes[csiapi.DNSNamesKey] = "foo.baz"
+	if _, err := c.CreateNewCertificate(context.TODO(), vol, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.RenewCertificate(vol); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting a request that no longer exists is not counted.
+	for i := 0; i < 2; i++ {
+		if err := c.DeleteCertificateRequest(vol); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := created(); n != 3 {
+		t.Errorf("unexpected number of created requests, exp=3 got=%v", n)
+	}
+
+	for reason, exp := range map[string]float64{
+		metrics.DeleteReasonMismatch:  1,
+		metrics.DeleteReasonRenew:     1,
+		metrics.DeleteReasonUnpublish: 1,
+		metrics.DeleteReasonFallback:  0,
+	} {
+		if n := deleted(reason); n != exp {
+			t.Errorf("unexpected number of requests deleted for %s, exp=%v got=%v", reason, exp, n)
+		}
+	}
+}
+
+// signOnCreate signs CertificateRequests as they are created with the given
+// client.
+func signOnCreate(t *testing.T, client *cmfake.Clientset) {
+	signKey, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.PrependReactor("create", "certificaterequests", func(action coretesting.Action) (bool, runtime.Object, error) {
+		cr := action.(coretesting.CreateAction).GetObject().(*cmapi.CertificateRequest)
+		signCertificateRequest(t, signKey, cr)
+		return false, nil, nil
+	})
+}
+
+func TestProbe(t *testing.T) {
+	for name, test := range map[string]struct {
+		listErr error
+		expErr  bool
+	}{
+		"if CertificateRequests can be listed then no error": {
+			expErr: false,
+		},
+		"if CertificateRequests can't be listed then error": {
+			listErr: errors.New("connection refused"),
+			expErr:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := cmfake.NewSimpleClientset()
+			if test.listErr != nil {
+				client.PrependReactor("list", "certificaterequests", func(coretesting.Action) (bool, runtime.Object, error) {
+					return true, nil, test.listErr
+				})
+			}
+
+			c := &CertManager{
+				cmClient: client,
+				clock:    clock.RealClock{},
+			}
+
+			err := c.Probe()
+			if test.expErr != (err != nil) {
+				t.Errorf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifyIssuedCertificate(t *testing.T) {
+	key, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri, err := url.Parse("spiffe://cluster.local/ns/foo/sa/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     pkix.Name{CommonName: "foo.bar"},
+		DNSNames:    []string{"foo.bar", "car.bar"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:        []*url.URL{uri},
+	}, key.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cr := &cmapi.CertificateRequest{
+		Spec: cmapi.CertificateRequestSpec{
+			CSRPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}),
+		},
+	}
+
+	genCert := func(pub crypto.PublicKey, mod func(*x509.Certificate)) *x509.Certificate {
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "foo.bar"},
+			DNSNames:     []string{"foo.bar", "car.bar"},
+			IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+			URIs:         []*url.URL{uri},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		if mod != nil {
+			mod(tmpl)
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, pub, otherKey.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return cert
+	}
+
+	for name, test := range map[string]struct {
+		cert   *x509.Certificate
+		expErr string
+	}{
+		"a matching certificate should not error": {
+			cert: genCert(key.PrivateKey.Public(), nil),
+		},
+		"a certificate with further names should not error": {
+			cert: genCert(key.PrivateKey.Public(), func(tmpl *x509.Certificate) {
+				tmpl.DNSNames = append(tmpl.DNSNames, "extra.bar")
+			}),
+		},
+		"a certificate of another key should error": {
+			cert:   genCert(otherKey.PrivateKey.Public(), nil),
+			expErr: "public key does not match the CSR",
+		},
+		"a certificate missing requested names should error": {
+			cert: genCert(key.PrivateKey.Public(), func(tmpl *x509.Certificate) {
+				tmpl.DNSNames = []string{"foo.bar"}
+				tmpl.IPAddresses = nil
+			}),
+			expErr: `DNS name "car.bar" missing, IP address "10.0.0.1" missing`,
+		},
+		"a certificate of another common name should error": {
+			cert: genCert(key.PrivateKey.Public(), func(tmpl *x509.Certificate) {
+				tmpl.Subject.CommonName = "car.bar"
+			}),
+			expErr: `common name "foo.bar" missing, got "car.bar"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := verifyIssuedCertificate(cr, test.cert)
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != test.expErr {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
+func TestCreateNewCertificateVerifyIssuedCert(t *testing.T) {
+	for name, test := range map[string]struct {
+		ipSANs string
+		expErr bool
+	}{
+		"a certificate with every requested name should be written": {
+			expErr: false,
+		},
+		"a certificate missing a requested name should not be written": {
+			// The test signer only copies DNS names.
+			ipSANs: "10.0.0.1",
+			expErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-verify-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.IssuerNameKey:      "test-issuer",
+					csiapi.DNSNamesKey:        "foo.bar",
+					csiapi.IPSANsKey:          test.ipSANs,
+					csiapi.CertFileKey:        "crt.pem",
+					csiapi.KeyFileKey:         "key.pem",
+					csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+					csiapi.KeySizeKey:         "256",
+				},
+			}
+
+			client := cmfake.NewSimpleClientset()
+			signOnCreate(t, client)
+
+			c := &CertManager{
+				cmClient:         client,
+				issuanceTimeout:  time.Second * 5,
+				verifyIssuedCert: true,
+				clock:            clock.RealClock{},
+			}
+
+			_, err = c.CreateNewCertificate(context.TODO(), vol, nil)
+			if test.expErr != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			_, err = os.Stat(util.CertPath(vol))
+			if written := err == nil; written == test.expErr {
+				t.Errorf("unexpected certificate file, exp written=%t got=%t", !test.expErr, written)
+			}
+		})
+	}
+}
+
+func TestCertDetails(t *testing.T) {
+	uri, err := url.Parse("spiffe://cluster.local/ns/test-namespace/sa/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vol := &csiapi.MetaData{
+		ID: "test-id",
+		Attributes: map[string]string{
+			csiapi.CSIPodNameKey:      "web-0",
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.CorrelationIDKey:   "order-1234",
+		},
+	}
+
+	cert := &x509.Certificate{
+		SerialNumber:   big.NewInt(255),
+		Subject:        pkix.Name{CommonName: "foo.bar", Organization: []string{"Jetstack"}},
+		Issuer:         pkix.Name{CommonName: "ca-issuer"},
+		NotBefore:      time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:       time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC),
+		DNSNames:       []string{"foo.bar", "car.bar"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		EmailAddresses: []string{"web@foo.bar"},
+		URIs:           []*url.URL{uri},
+	}
+
+	exp := `volume=test-id namespace=test-namespace pod=web-0 correlation-id="order-1234" serial=ff ` +
+		`subject="CN=foo.bar,O=Jetstack" issuer="CN=ca-issuer" ` +
+		`not-before=2020-01-01T00:00:00Z not-after=2020-01-01T01:00:00Z ` +
+		`dns-names="foo.bar,car.bar" ip-addresses="10.0.0.1" email-addresses="web@foo.bar" ` +
+		`uris="spiffe://cluster.local/ns/test-namespace/sa/web"`
+
+	if got := certDetails(vol, cert); got != exp {
+		t.Errorf("unexpected certificate details,\nexp=%s\ngot=%s", exp, got)
+	}
+}
+
+func TestCreateNewCertificateDNSNamesTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-template-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNameKey:       "web-0",
+			csiapi.CSIPodNamespaceKey:  "test-namespace",
+			csiapi.IssuerNameKey:       "test-issuer",
+			csiapi.IssuerKindKey:       "Issuer",
+			csiapi.IssuerGroupKey:      "cert-manager.io",
+			csiapi.DNSNamesKey:         "foo.bar",
+			csiapi.DNSNamesTemplateKey: "{{.PodName}}.web.{{.Namespace}}.svc",
+			csiapi.CertFileKey:         "crt.pem",
+			csiapi.KeyFileKey:          "key.pem",
+			csiapi.KeyAlgorithmKey:     csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:          "256",
+		},
+	}
+
+	client := cmfake.NewSimpleClientset()
+	signOnCreate(t, client)
+
+	c := &CertManager{
+		cmClient:        client,
+		issuanceTimeout: time.Second * 5,
+		clock:           clock.RealClock{},
+	}
+
+	cert, err := c.CreateNewCertificate(context.TODO(), vol, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expNames := []string{"foo.bar", "web-0.web.test-namespace.svc"}
+	if !util.StringsMatch(expNames, cert.DNSNames) {
+		t.Errorf("unexpected dns names, exp=%v got=%v", expNames, cert.DNSNames)
+	}
+
+	// The request should match the volume, including the rendered names.
+	cr, err := client.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get(vol.ID, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.CertificateRequestMatchesSpec(cr, vol.Attributes); err != nil {
+		t.Errorf("expected request to match volume spec: %s", err)
+	}
+}
+
+func TestCreateNewCertificateExtraExtensions(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-extra-extensions-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "test-issuer",
+			csiapi.IssuerKindKey:      "Issuer",
+			csiapi.IssuerGroupKey:     "cert-manager.io",
+			csiapi.DNSNamesKey:        "foo.bar",
+			csiapi.ExtraExtensionsKey: "1.3.6.1.4.1.311.20.2=DAlXZWJTZXJ2ZXI=",
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+			csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:         "256",
+		},
+	}
+
+	client := cmfake.NewSimpleClientset()
+	signOnCreate(t, client)
+
+	c := &CertManager{
+		cmClient:        client,
+		issuanceTimeout: time.Second * 5,
+		clock:           clock.RealClock{},
+	}
+
+	if _, err := c.CreateNewCertificate(context.TODO(), vol, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cr, err := client.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get(vol.ID, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr, err := pki.DecodeX509CertificateRequestBytes(cr.Spec.CSRPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expExts, err := util.ParseExtraExtensions(vol.Attributes[csiapi.ExtraExtensionsKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exts := util.CSRExtraExtensions(csr); !util.ExtensionsMatch(expExts, exts) {
+		t.Errorf("unexpected extra extensions in request, exp=%v got=%v", expExts, exts)
+	}
+
+	if err := util.CertificateRequestMatchesSpec(cr, vol.Attributes); err != nil {
+		t.Errorf("expected request to match volume spec: %s", err)
+	}
+
+	// A change of the extensions should no longer match, so re-issue.
+	vol.Attributes[csiapi.ExtraExtensionsKey] = "1.3.6.1.4.1.311.20.2=DAlXZWJTZXJ2ZXI=,1.2.3=BQA="
+	if err := util.CertificateRequestMatchesSpec(cr, vol.Attributes); err == nil {
+		t.Error("expected request with different extensions to not match volume spec")
+	}
+}
+
+func TestCreateNewCertificateKeyDeliveryFIFO(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-key-fifo-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "test-issuer",
+			csiapi.DNSNamesKey:        "foo.bar",
+			csiapi.KeyDeliveryKey:     csiapi.FIFOKeyDelivery,
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+			csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:         "256",
+		},
+	}
+
+	client := cmfake.NewSimpleClientset()
+	signOnCreate(t, client)
+
+	c := &CertManager{
+		cmClient:        client,
+		issuanceTimeout: time.Second * 5,
+		clock:           clock.RealClock{},
+	}
+	defer c.StopKeyDelivery(vol.ID)
+
+	cert, err := c.CreateNewCertificate(context.TODO(), vol, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(util.KeyPath(vol))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("expected private key to be a named pipe, got mode %s", fi.Mode())
+	}
+
+	if _, err := readKeyBundle(vol); err == nil {
+		t.Error("expected private key delivered through a named pipe to not be readable back")
+	}
+
+	keyBytes, err := ioutil.ReadFile(util.KeyPath(vol))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sk, _, err := util.DecodePrivateKey(keyBytes, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match, err := util.PublicKeysEqual(sk.Public(), cert.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Error("expected the delivered private key to be of the certificate")
+	}
+}
+
+func TestCreateNewCertificateTouchFileOnRenew(t *testing.T) {
+	for name, atomic := range map[string]bool{
+		"the touch file should be touched after issuance":                        false,
+		"the touch file should be touched after issuance with the atomic layout": true,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-touch-file-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.CSIPodNamespaceKey:  "test-namespace",
+					csiapi.IssuerNameKey:       "test-issuer",
+					csiapi.DNSNamesKey:         "foo.bar",
+					csiapi.CertFileKey:         "crt.pem",
+					csiapi.KeyFileKey:          "key.pem",
+					csiapi.KeyAlgorithmKey:     csiapi.ECDSAKeyAlgorithm,
+					csiapi.KeySizeKey:          "256",
+					csiapi.TouchFileOnRenewKey: "rotated",
+				},
+			}
+
+			if atomic {
+				if err := util.InitAtomicLayout(util.MountPath(vol), false); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			client := cmfake.NewSimpleClientset()
+			signOnCreate(t, client)
+
+			c := &CertManager{
+				cmClient:        client,
+				issuanceTimeout: time.Second * 5,
+				clock:           clock.RealClock{},
+			}
+
+			if _, err := c.CreateNewCertificate(context.TODO(), vol, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			touchFi, err := os.Stat(util.TouchFilePath(vol))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if touchFi.Size() != 0 {
+				t.Errorf("expected touch file to be empty, got %d bytes", touchFi.Size())
+			}
+
+			for _, path := range []string{util.CertPath(vol), util.KeyPath(vol)} {
+				fi, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if touchFi.ModTime().Before(fi.ModTime()) {
+					t.Errorf("expected touch file to be touched after %s was written, touched=%s written=%s",
+						path, touchFi.ModTime(), fi.ModTime())
+				}
+			}
+		})
+	}
+}
+
+func TestCreateNewCertificateInvalidIssuedCertificate(t *testing.T) {
+	for name, test := range map[string]struct {
+		certificate []byte
+		expErr      string
+	}{
+		"an empty certificate should error and write nothing": {
+			certificate: nil,
+			expErr:      "issuer returned an empty certificate for CertificateRequest test-namespace/test-id",
+		},
+		"an invalid certificate should error and write nothing": {
+			certificate: []byte("not a certificate"),
+			expErr:      "issuer returned an invalid certificate for CertificateRequest test-namespace/test-id",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-invalid-cert-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.IssuerNameKey:      "test-issuer",
+					csiapi.DNSNamesKey:        "foo.bar",
+					csiapi.CertFileKey:        "crt.pem",
+					csiapi.KeyFileKey:         "key.pem",
+					csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+					csiapi.KeySizeKey:         "256",
+				},
+			}
+
+			client := cmfake.NewSimpleClientset()
+			client.PrependReactor("create", "certificaterequests", func(action coretesting.Action) (bool, runtime.Object, error) {
+				cr := action.(coretesting.CreateAction).GetObject().(*cmapi.CertificateRequest)
+				cr.Status.Certificate = test.certificate
+				cr.Status.Conditions = []cmapi.CertificateRequestCondition{
+					{Type: cmapi.CertificateRequestConditionReady, Status: cmmeta.ConditionTrue},
+				}
+
+				return false, nil, nil
+			})
+
+			c := &CertManager{
+				cmClient:        client,
+				issuanceTimeout: time.Second * 5,
+				clock:           clock.RealClock{},
+			}
+
+			_, err = c.CreateNewCertificate(context.TODO(), vol, nil)
+			if err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Fatalf("unexpected error, exp=%q got=%v", test.expErr, err)
+			}
+
+			if _, err := os.Stat(util.CertPath(vol)); !os.IsNotExist(err) {
+				t.Errorf("expected no certificate file to be written, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateNewCertificateIssuanceTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-issuance-timeout-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Requests are never signed, so never become ready.
+	c := &CertManager{
+		cmClient:        cmfake.NewSimpleClientset(),
+		issuanceTimeout: time.Millisecond * 100,
+		clock:           clock.RealClock{},
+	}
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "test-issuer",
+			csiapi.DNSNamesKey:        "foo.bar",
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+			csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:         "256",
+		},
+	}
+
+	keyBundle, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = c.CreateNewCertificate(context.TODO(), vol, keyBundle)
+	if err == nil {
+		t.Fatal("expected issuance to time out, got no error")
+	}
+
+	if !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("expected deadline exceeded error, got=%s", err)
+	}
+
+	if since := time.Since(start); since > time.Second*5 {
+		t.Errorf("expected issuance to stop at the timeout, took %s", since)
+	}
+
+	if _, err := os.Stat(util.CertPath(vol)); !os.IsNotExist(err) {
+		t.Errorf("expected no certificate to be written, got: %v", err)
+	}
+}
+
+func TestRecordIssuancePhases(t *testing.T) {
+	for name, test := range map[string]struct {
+		sign      bool
+		expErr    bool
+		expPhases map[string]uint64
+	}{
+		"a successful issuance should observe each phase": {
+			sign: true,
+			expPhases: map[string]uint64{
+				metrics.PhaseCreate: 1,
+				metrics.PhaseWait:   1,
+				metrics.PhaseWrite:  1,
+			},
+		},
+		"a timed out issuance should only observe the create phase": {
+			sign:   false,
+			expErr: true,
+			expPhases: map[string]uint64{
+				metrics.PhaseCreate: 1,
+				metrics.PhaseWait:   0,
+				metrics.PhaseWrite:  0,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-issuance-phases-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			client := cmfake.NewSimpleClientset()
+			if test.sign {
+				signOnCreate(t, client)
+			}
+
+			c := &CertManager{
+				cmClient:        client,
+				issuanceTimeout: time.Millisecond * 100,
+				clock:           clock.RealClock{},
+			}
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.IssuerNameKey:      "test-issuer",
+					csiapi.DNSNamesKey:        "foo.bar",
+					csiapi.CertFileKey:        "crt.pem",
+					csiapi.KeyFileKey:         "key.pem",
+					csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+					csiapi.KeySizeKey:         "256",
+				},
+			}
+
+			keyBundle, err := util.NewECDSAKey(256)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			observations := func(phase string) uint64 {
+				var m dto.Metric
+				observer := metrics.IssuancePhaseDuration.WithLabelValues(phase)
+				if err := observer.(prometheus.Metric).Write(&m); err != nil {
+					t.Fatal(err)
+				}
+				return m.GetHistogram().GetSampleCount()
+			}
+
+			before := make(map[string]uint64)
+			for phase := range test.expPhases {
+				before[phase] = observations(phase)
+			}
+
+			_, err = c.CreateNewCertificate(context.TODO(), vol, keyBundle)
+			if test.expErr != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			for phase, exp := range test.expPhases {
+				if n := observations(phase) - before[phase]; n != exp {
+					t.Errorf("unexpected number of %s phase observations, exp=%d got=%d", phase, exp, n)
+				}
+			}
+		})
+	}
+}
+
+// signCertificateRequest self signs the CSR of the CertificateRequest with the
+// key, and marks it Ready.
+func signCertificateRequest(t *testing.T, signKey *util.KeyBundle, cr *cmapi.CertificateRequest) {
+	csr, err := pki.DecodeX509CertificateRequestBytes(cr.Spec.CSRPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, csr.PublicKey, signKey.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cr.Status.Certificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	cr.Status.Conditions = []cmapi.CertificateRequestCondition{
+		{Type: cmapi.CertificateRequestConditionReady, Status: cmmeta.ConditionTrue},
+	}
+}
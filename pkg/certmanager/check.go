@@ -0,0 +1,135 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"time"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+const (
+	checkNamePrefix = "cert-manager-csi-check-"
+	checkDuration   = time.Hour
+)
+
+// Check performs an end to end issuance against the given issuer by creating
+// a throwaway CertificateRequest in namespace, and waiting for it to be
+// signed. The result of each step is written to w. The CertificateRequest is
+// always deleted before returning.
+func (c *CertManager) Check(ctx context.Context, w io.Writer, namespace string, issuerRef cmmeta.ObjectReference) error {
+	gv := cmapi.SchemeGroupVersion.String()
+
+	resources, err := c.cmClient.Discovery().ServerResourcesForGroupVersion(gv)
+	if err != nil {
+		return checkFailed(w, "failed to reach cert-manager API %s: %s", gv, err)
+	}
+	checkOK(w, "reached cert-manager API %s", gv)
+
+	var found bool
+	for _, r := range resources.APIResources {
+		if r.Name == "certificaterequests" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return checkFailed(w, "certificaterequests resource not served by %s, is the CRD installed?", gv)
+	}
+	checkOK(w, "resolved certificaterequests.%s", cmapi.SchemeGroupVersion.Group)
+
+	keyBundle, err := util.NewRSAKey(2048)
+	if err != nil {
+		return checkFailed(w, "failed to generate private key: %s", err)
+	}
+
+	name := checkNamePrefix + rand.String(5)
+
+	csrPEM, err := util.EncodeCSR(&x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName: name,
+		},
+		PublicKey:          keyBundle.PrivateKey.Public(),
+		PublicKeyAlgorithm: keyBundle.PublicKeyAlgorithm,
+		SignatureAlgorithm: keyBundle.SignatureAlgorithm,
+	}, keyBundle.PrivateKey)
+	if err != nil {
+		return checkFailed(w, "failed to encode certificate signing request: %s", err)
+	}
+
+	cr := &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    c.setManagedLabel(nil),
+		},
+		Spec: cmapi.CertificateRequestSpec{
+			CSRPEM: csrPEM,
+			Duration: &metav1.Duration{
+				Duration: checkDuration,
+			},
+			IssuerRef: issuerRef,
+		},
+	}
+
+	cr, err = c.certificateRequests(namespace).Create(cr)
+	if err != nil {
+		return checkFailed(w, "failed to create CertificateRequest %s/%s: %s", namespace, name, err)
+	}
+	checkOK(w, "created CertificateRequest %s/%s", namespace, name)
+
+	defer func() {
+		err := c.certificateRequests(namespace).Delete(name, &metav1.DeleteOptions{})
+		if err != nil {
+			checkFailed(w, "failed to delete CertificateRequest %s/%s: %s", namespace, name, err)
+			return
+		}
+		checkOK(w, "deleted CertificateRequest %s/%s", namespace, name)
+	}()
+
+	cr, err = c.waitForCertificateRequestReady(ctx, name, namespace, c.issuanceTimeout)
+	if err != nil {
+		if cr != nil {
+			for _, cond := range cr.Status.Conditions {
+				fmt.Fprintf(w, "      condition %s=%s reason=%q message=%q\n",
+					cond.Type, cond.Status, cond.Reason, cond.Message)
+			}
+		}
+
+		return checkFailed(w, "CertificateRequest %s/%s was not signed by %s %q: %s",
+			namespace, name, issuerRef.Kind, issuerRef.Name, err)
+	}
+
+	cert, err := pki.DecodeX509CertificateBytes(cr.Status.Certificate)
+	if err != nil {
+		return checkFailed(w, "failed to decode signed certificate: %s", err)
+	}
+
+	ok, err := util.PublicKeysEqual(keyBundle.PrivateKey.Public(), cert.PublicKey)
+	if err != nil || !ok {
+		return checkFailed(w, "signed certificate does not match the requested private key")
+	}
+
+	checkOK(w, "certificate signed by %q, valid until %s", cert.Issuer.String(), cert.NotAfter.Format(time.RFC3339))
+
+	return nil
+}
+
+func checkOK(w io.Writer, format string, a ...interface{}) {
+	fmt.Fprintf(w, "[ok]   "+format+"\n", a...)
+}
+
+func checkFailed(w io.Writer, format string, a ...interface{}) error {
+	err := fmt.Errorf(format, a...)
+	fmt.Fprintf(w, "[fail] %s\n", err)
+	return err
+}
@@ -0,0 +1,141 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	coretesting "k8s.io/client-go/testing"
+
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestCheck(t *testing.T) {
+	signKey, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(cr *cmapi.CertificateRequest) {
+		block, _ := pem.Decode(cr.Spec.CSRPEM)
+		csr, err := x509.ParseCertificateRequest(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      csr.Subject,
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+
+		certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, csr.PublicKey, signKey.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cr.Status.Certificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+		cr.Status.Conditions = []cmapi.CertificateRequestCondition{
+			{Type: cmapi.CertificateRequestConditionReady, Status: cmmeta.ConditionTrue},
+		}
+	}
+
+	fail := func(cr *cmapi.CertificateRequest) {
+		cr.Status.Conditions = []cmapi.CertificateRequestCondition{
+			{
+				Type:    cmapi.CertificateRequestConditionReady,
+				Status:  cmmeta.ConditionFalse,
+				Reason:  cmapi.CertificateRequestReasonFailed,
+				Message: "issuer not found",
+			},
+		}
+	}
+
+	certificateRequests := []*metav1.APIResourceList{
+		{
+			GroupVersion: cmapi.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{
+				{Name: "certificaterequests"},
+			},
+		},
+	}
+
+	for name, test := range map[string]struct {
+		resources []*metav1.APIResourceList
+		issue     func(*cmapi.CertificateRequest)
+		expErr    string
+		expDelete bool
+	}{
+		"if the cert-manager API is not served then should error": {
+			resources: nil,
+			expErr:    `failed to reach cert-manager API cert-manager.io/v1alpha2: GroupVersion "cert-manager.io/v1alpha2" not found`,
+			expDelete: false,
+		},
+		"if certificaterequests are not served then should error": {
+			resources: []*metav1.APIResourceList{
+				{GroupVersion: cmapi.SchemeGroupVersion.String()},
+			},
+			expErr:    "certificaterequests resource not served by cert-manager.io/v1alpha2, is the CRD installed?",
+			expDelete: false,
+		},
+		"if the request fails then should error and delete the request": {
+			resources: certificateRequests,
+			issue:     fail,
+			expErr:    "certificate request marked as failed: issuer not found",
+			expDelete: true,
+		},
+		"if the request is signed then should succeed and delete the request": {
+			resources: certificateRequests,
+			issue:     sign,
+			expErr:    "",
+			expDelete: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := cmfake.NewSimpleClientset()
+			client.Resources = test.resources
+			client.PrependReactor("create", "certificaterequests", func(action coretesting.Action) (bool, runtime.Object, error) {
+				test.issue(action.(coretesting.CreateAction).GetObject().(*cmapi.CertificateRequest))
+				return false, nil, nil
+			})
+
+			c := &CertManager{
+				cmClient:        client,
+				issuanceTimeout: time.Second * 5,
+			}
+
+			var out bytes.Buffer
+			err := c.Check(context.TODO(), &out, "test-namespace", cmmeta.ObjectReference{
+				Name: "test-issuer",
+				Kind: "Issuer",
+			})
+
+			if (err == nil) != (len(test.expErr) == 0) || (err != nil && !strings.Contains(err.Error(), test.expErr)) {
+				t.Errorf("unexpected error, exp=%q got=%v", test.expErr, err)
+			}
+
+			var deleted bool
+			for _, action := range client.Actions() {
+				if action.GetVerb() == "delete" {
+					deleted = true
+				}
+			}
+
+			if deleted != test.expDelete {
+				t.Errorf("unexpected delete, exp=%t got=%t\n%s", test.expDelete, deleted, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package certmanager
+
+import (
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AttributesConfigMap returns the data of the given attributes ConfigMap, or
+// nil if it does not exist.
+func (c *CertManager) AttributesConfigMap(namespace, name string) (map[string]string, error) {
+	cm, err := c.kubeClient.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cm.Data, nil
+}
@@ -0,0 +1,120 @@
+package certmanager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/apis/validation"
+)
+
+// downwardAPIFilePollInterval is how often a file of the downward API
+// directory is checked for while waiting for it to be written.
+var downwardAPIFilePollInterval = time.Millisecond * 500
+
+// readDownwardAPIFiles returns the attributes with the common name and SANs
+// read from the volume's downward API files added. The attributes are returned
+// as is if none are read from files.
+func (c *CertManager) readDownwardAPIFiles(attr map[string]string) (map[string]string, error) {
+	var keys []string
+	for _, k := range csiapi.DownwardAPIFileKeys {
+		if len(attr[k]) > 0 {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		return attr, nil
+	}
+
+	sanAttr := make(map[string]string, len(attr))
+	for k, v := range attr {
+		sanAttr[k] = v
+	}
+
+	for _, k := range keys {
+		values, err := c.readDownwardAPIFile(attr[k])
+		if err != nil {
+			return nil, err
+		}
+
+		if err := validation.ValidateDownwardAPIFileValues(k, values); err != nil {
+			return nil, fmt.Errorf("invalid %s file %q: %s", k, attr[k], err)
+		}
+
+		switch k {
+		case csiapi.CommonNameFromFileKey:
+			sanAttr[csiapi.CommonNameKey] = values[0]
+		case csiapi.DNSNamesFromFileKey:
+			sanAttr[csiapi.DNSNamesKey] = appendList(sanAttr[csiapi.DNSNamesKey], values)
+		case csiapi.IPSANsFromFileKey:
+			sanAttr[csiapi.IPSANsKey] = appendList(sanAttr[csiapi.IPSANsKey], values)
+		}
+	}
+
+	if err := validation.ValidateMaxSANs(sanAttr, c.maxSANs); err != nil {
+		return nil, err
+	}
+
+	return sanAttr, nil
+}
+
+// readDownwardAPIFile returns the comma or whitespace separated values of the
+// file, waiting for it to be written if it doesn't yet exist or is empty.
+func (c *CertManager) readDownwardAPIFile(name string) ([]string, error) {
+	path := filepath.Join(c.downwardAPIDir, name)
+
+	var values []string
+	var readErr error
+	read := func() (bool, error) {
+		var b []byte
+		b, readErr = ioutil.ReadFile(path)
+		if readErr != nil {
+			return !os.IsNotExist(readErr), nil
+		}
+
+		values = downwardAPIValues(b)
+
+		return len(values) > 0, nil
+	}
+
+	var err error
+	if c.downwardAPIFileTimeout > 0 {
+		err = wait.PollImmediate(downwardAPIFilePollInterval, c.downwardAPIFileTimeout, read)
+	} else if done, _ := read(); !done {
+		err = wait.ErrWaitTimeout
+	}
+
+	if err == wait.ErrWaitTimeout {
+		return nil, fmt.Errorf("file %s has not been written after %s", path, c.downwardAPIFileTimeout)
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read file %s: %s", path, readErr)
+	}
+
+	return values, nil
+}
+
+// appendList appends the values to the comma separated list.
+func appendList(list string, values []string) string {
+	if len(list) == 0 {
+		return strings.Join(values, ",")
+	}
+
+	return list + "," + strings.Join(values, ",")
+}
+
+// downwardAPIValues splits the contents of a downward API file into its comma
+// or whitespace separated values.
+func downwardAPIValues(b []byte) []string {
+	return strings.FieldsFunc(string(b), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
@@ -0,0 +1,151 @@
+package certmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestReadDownwardAPIFiles(t *testing.T) {
+	defer func(d time.Duration) { downwardAPIFilePollInterval = d }(downwardAPIFilePollInterval)
+	downwardAPIFilePollInterval = time.Millisecond * 10
+
+	for name, test := range map[string]struct {
+		attr       map[string]string
+		files      map[string]string
+		writeAfter time.Duration
+		maxSANs    int
+		expAttr    map[string]string
+		expErr     bool
+	}{
+		"if no files are read then the attributes should be returned as is": {
+			attr: map[string]string{
+				csiapi.IPSANsKey: "1.2.3.4",
+			},
+			expAttr: map[string]string{
+				csiapi.IPSANsKey: "1.2.3.4",
+			},
+		},
+		"if files are present then their values should be added": {
+			attr: map[string]string{
+				csiapi.IPSANsKey:             "1.2.3.4",
+				csiapi.IPSANsFromFileKey:     "pod-ip",
+				csiapi.DNSNamesFromFileKey:   "dns-names",
+				csiapi.CommonNameFromFileKey: "common-name",
+			},
+			files: map[string]string{
+				"pod-ip":      "10.0.0.1\n",
+				"dns-names":   "foo.bar, bar.foo",
+				"common-name": "foo.bar\n",
+			},
+			expAttr: map[string]string{
+				csiapi.IPSANsKey:             "1.2.3.4,10.0.0.1",
+				csiapi.DNSNamesKey:           "foo.bar,bar.foo",
+				csiapi.CommonNameKey:         "foo.bar",
+				csiapi.IPSANsFromFileKey:     "pod-ip",
+				csiapi.DNSNamesFromFileKey:   "dns-names",
+				csiapi.CommonNameFromFileKey: "common-name",
+			},
+		},
+		"if a file is written while waiting then its values should be added": {
+			attr: map[string]string{
+				csiapi.IPSANsFromFileKey: "pod-ip",
+			},
+			files: map[string]string{
+				"pod-ip": "10.0.0.1",
+			},
+			writeAfter: time.Millisecond * 100,
+			expAttr: map[string]string{
+				csiapi.IPSANsKey:         "10.0.0.1",
+				csiapi.IPSANsFromFileKey: "pod-ip",
+			},
+		},
+		"if a file is never written then error": {
+			attr: map[string]string{
+				csiapi.IPSANsFromFileKey: "pod-ip",
+			},
+			expErr: true,
+		},
+		"if a file is empty then error": {
+			attr: map[string]string{
+				csiapi.IPSANsFromFileKey: "pod-ip",
+			},
+			files: map[string]string{
+				"pod-ip": "\n",
+			},
+			expErr: true,
+		},
+		"if a file contains an invalid ip address then error": {
+			attr: map[string]string{
+				csiapi.IPSANsFromFileKey: "pod-ip",
+			},
+			files: map[string]string{
+				"pod-ip": "not-an-ip",
+			},
+			expErr: true,
+		},
+		"if a file contains multiple common names then error": {
+			attr: map[string]string{
+				csiapi.CommonNameFromFileKey: "common-name",
+			},
+			files: map[string]string{
+				"common-name": "foo.bar bar.foo",
+			},
+			expErr: true,
+		},
+		"if a file exceeds the maximum SANs then error": {
+			attr: map[string]string{
+				csiapi.DNSNamesKey:         "foo.bar",
+				csiapi.DNSNamesFromFileKey: "dns-names",
+			},
+			files: map[string]string{
+				"dns-names": "bar.foo,baz.foo",
+			},
+			maxSANs: 2,
+			expErr:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-downward-api-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			writeFiles := func() {
+				for name, data := range test.files {
+					if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+
+			if test.writeAfter > 0 {
+				timer := time.AfterFunc(test.writeAfter, writeFiles)
+				defer timer.Stop()
+			} else {
+				writeFiles()
+			}
+
+			c := &CertManager{
+				downwardAPIDir:         dir,
+				downwardAPIFileTimeout: time.Millisecond * 500,
+				maxSANs:                test.maxSANs,
+			}
+
+			attr, err := c.readDownwardAPIFiles(test.attr)
+			if test.expErr != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			if !test.expErr && !reflect.DeepEqual(attr, test.expAttr) {
+				t.Errorf("unexpected attributes, exp=%v got=%v", test.expAttr, attr)
+			}
+		})
+	}
+}
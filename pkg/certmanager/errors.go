@@ -0,0 +1,131 @@
+package certmanager
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	certificateRequestsResource = "certificaterequests.cert-manager.io"
+	issuersResource             = "issuers.cert-manager.io"
+	clusterIssuersResource      = "clusterissuers.cert-manager.io"
+	secretsResource             = "secrets"
+	serviceAccountTokenResource = "serviceaccounts/token"
+	podsResource                = "pods"
+)
+
+// APIError is an error from the API server that will not resolve by retrying
+// straight away, such as the driver's ServiceAccount lacking RBAC. It holds
+// the gRPC code the error should be returned to the kubelet with.
+type APIError struct {
+	Code      codes.Code
+	Verb      string
+	Resource  string
+	Namespace string
+	Err       error
+}
+
+func (e *APIError) Error() string {
+	scope := "cluster wide"
+	if len(e.Namespace) > 0 {
+		scope = fmt.Sprintf("in namespace %q", e.Namespace)
+	}
+
+	switch {
+	case e.Code == codes.PermissionDenied:
+		return fmt.Sprintf("forbidden to %s %s %s, grant the driver's ServiceAccount the %q verb on %q %s: %s",
+			e.Verb, e.Resource, scope, e.Verb, e.Resource, scope, e.Err)
+	case isQuotaExceeded(e.Err):
+		return fmt.Sprintf("resource quota exceeded to %s %s %s, raise the quota or remove unused %s: %s",
+			e.Verb, e.Resource, scope, e.Resource, e.Err)
+	default:
+		return fmt.Sprintf("too many requests to %s %s %s, the API server is rate limiting the driver: %s",
+			e.Verb, e.Resource, scope, e.Err)
+	}
+}
+
+// GRPCStatus returns the gRPC status of the error, so that it keeps its code
+// when returned from a gRPC handler.
+func (e *APIError) GRPCStatus() *status.Status {
+	return status.New(e.Code, e.Error())
+}
+
+// apiError returns an APIError if err is a forbidden or too many requests
+// response from the API server to the verb on the resource, otherwise err.
+// Exceeded resource quotas are forbidden responses, but are exhausted
+// resources rather than missing permissions.
+func apiError(err error, verb, resource, namespace string) error {
+	var code codes.Code
+	switch {
+	case isQuotaExceeded(err), k8sErrors.IsTooManyRequests(err):
+		code = codes.ResourceExhausted
+	case k8sErrors.IsForbidden(err):
+		code = codes.PermissionDenied
+	default:
+		return err
+	}
+
+	return &APIError{
+		Code:      code,
+		Verb:      verb,
+		Resource:  resource,
+		Namespace: namespace,
+		Err:       err,
+	}
+}
+
+// RequestError is an error issuing the certificate of a volume once its
+// CertificateRequest exists, such as the request failing or timing out. It
+// names the request so that it may be inspected.
+type RequestError struct {
+	Namespace string
+	Name      string
+	Err       error
+}
+
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+// requestError returns err as a RequestError of the named CertificateRequest.
+func requestError(namespace, name string, err error) error {
+	return &RequestError{
+		Namespace: namespace,
+		Name:      name,
+		Err:       err,
+	}
+}
+
+// CertificateRequestOf returns the namespace and name of the
+// CertificateRequest err is of, if it is a RequestError.
+func CertificateRequestOf(err error) (string, string, bool) {
+	reqErr, ok := err.(*RequestError)
+	if !ok {
+		return "", "", false
+	}
+
+	return reqErr.Namespace, reqErr.Name, true
+}
+
+// APIErrorCode returns the gRPC code of err if it is an APIError, or a
+// RequestError of one.
+func APIErrorCode(err error) (codes.Code, bool) {
+	if reqErr, ok := err.(*RequestError); ok {
+		err = reqErr.Err
+	}
+
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		return codes.OK, false
+	}
+
+	return apiErr.Code, true
+}
+
+func isQuotaExceeded(err error) bool {
+	return k8sErrors.IsForbidden(err) && strings.Contains(err.Error(), "exceeded quota")
+}
@@ -0,0 +1,172 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	coretesting "k8s.io/client-go/testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestAPIError(t *testing.T) {
+	crResource := schema.GroupResource{Group: "cert-manager.io", Resource: "certificaterequests"}
+
+	for name, test := range map[string]struct {
+		err       error
+		namespace string
+		expCode   codes.Code
+		expAPIErr bool
+		expMsg    string
+	}{
+		"a forbidden error should be permission denied": {
+			err:       k8sErrors.NewForbidden(crResource, "test-id", errors.New("rbac")),
+			namespace: "test-namespace",
+			expCode:   codes.PermissionDenied,
+			expAPIErr: true,
+			expMsg:    `forbidden to create certificaterequests.cert-manager.io in namespace "test-namespace", grant the driver's ServiceAccount the "create" verb on "certificaterequests.cert-manager.io" in namespace "test-namespace"`,
+		},
+		"a forbidden error of a cluster scoped resource should be cluster wide": {
+			err:       k8sErrors.NewForbidden(crResource, "test-id", errors.New("rbac")),
+			expCode:   codes.PermissionDenied,
+			expAPIErr: true,
+			expMsg:    `grant the driver's ServiceAccount the "create" verb on "certificaterequests.cert-manager.io" cluster wide`,
+		},
+		"an exceeded quota should be resource exhausted": {
+			err:       k8sErrors.NewForbidden(crResource, "test-id", errors.New("exceeded quota: test-quota")),
+			namespace: "test-namespace",
+			expCode:   codes.ResourceExhausted,
+			expAPIErr: true,
+			expMsg:    `resource quota exceeded to create certificaterequests.cert-manager.io in namespace "test-namespace"`,
+		},
+		"too many requests should be resource exhausted": {
+			err:       k8sErrors.NewTooManyRequests("slow down", 1),
+			namespace: "test-namespace",
+			expCode:   codes.ResourceExhausted,
+			expAPIErr: true,
+			expMsg:    `too many requests to create certificaterequests.cert-manager.io in namespace "test-namespace"`,
+		},
+		"other errors should be returned as is": {
+			err:       k8sErrors.NewInternalError(errors.New("etcd down")),
+			namespace: "test-namespace",
+			expAPIErr: false,
+			expMsg:    "etcd down",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := apiError(test.err, "create", certificateRequestsResource, test.namespace)
+
+			code, ok := APIErrorCode(err)
+			if ok != test.expAPIErr {
+				t.Fatalf("unexpected api error, exp=%t got=%t: %v", test.expAPIErr, ok, err)
+			}
+
+			if !test.expAPIErr {
+				if err != test.err {
+					t.Errorf("expected error to be returned as is, got=%v", err)
+				}
+				return
+			}
+
+			if code != test.expCode {
+				t.Errorf("unexpected code, exp=%s got=%s", test.expCode, code)
+			}
+
+			if got := status.Code(err); got != test.expCode {
+				t.Errorf("unexpected gRPC status code, exp=%s got=%s", test.expCode, got)
+			}
+
+			if !strings.Contains(err.Error(), test.expMsg) {
+				t.Errorf("unexpected error message, exp to contain=%s got=%s", test.expMsg, err)
+			}
+		})
+	}
+}
+
+func TestCreateNewCertificateAPIErrors(t *testing.T) {
+	crResource := schema.GroupResource{Group: "cert-manager.io", Resource: "certificaterequests"}
+
+	for name, test := range map[string]struct {
+		verb    string
+		err     error
+		expCode codes.Code
+		expMsg  string
+	}{
+		"forbidden to create requests should be permission denied": {
+			verb:    "create",
+			err:     k8sErrors.NewForbidden(crResource, "test-id", errors.New("rbac")),
+			expCode: codes.PermissionDenied,
+			expMsg:  `grant the driver's ServiceAccount the "create" verb on "certificaterequests.cert-manager.io"`,
+		},
+		"forbidden to get requests should be permission denied": {
+			verb:    "get",
+			err:     k8sErrors.NewForbidden(crResource, "test-id", errors.New("rbac")),
+			expCode: codes.PermissionDenied,
+			expMsg:  `grant the driver's ServiceAccount the "get" verb on "certificaterequests.cert-manager.io"`,
+		},
+		"too many requests on create should be resource exhausted": {
+			verb:    "create",
+			err:     k8sErrors.NewTooManyRequests("slow down", 1),
+			expCode: codes.ResourceExhausted,
+			expMsg:  "too many requests to create certificaterequests.cert-manager.io",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-api-errors-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			client := cmfake.NewSimpleClientset()
+			client.PrependReactor(test.verb, "certificaterequests", func(coretesting.Action) (bool, runtime.Object, error) {
+				return true, nil, test.err
+			})
+
+			c := &CertManager{
+				cmClient:        client,
+				issuanceTimeout: time.Second,
+				clock:           clock.RealClock{},
+			}
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.IssuerNameKey:      "test-issuer",
+					csiapi.CommonNameKey:      "foo.bar",
+					csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+					csiapi.KeySizeKey:         "256",
+				},
+			}
+
+			_, err = c.CreateNewCertificate(context.Background(), vol, nil)
+
+			code, ok := APIErrorCode(err)
+			if !ok {
+				t.Fatalf("expected api error, got=%v", err)
+			}
+
+			if code != test.expCode {
+				t.Errorf("unexpected code, exp=%s got=%s", test.expCode, code)
+			}
+
+			if !strings.Contains(err.Error(), test.expMsg) {
+				t.Errorf("unexpected error message, exp to contain=%s got=%s", test.expMsg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package certmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/metrics"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// runPostIssueHook will execute the configured post issue hook, if any, for
+// the given volume. Failures are logged and counted but never returned so
+// that a misbehaving hook can not fail issuance. The hook runs in its own
+// process group, which is killed as a whole on timeout so that processes
+// started by the hook, and holding its output open, don't outlive it.
+func (c *CertManager) runPostIssueHook(vol *csiapi.MetaData) {
+	if len(c.postIssueHook) == 0 {
+		return
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command(c.postIssueHook)
+	cmd.Env = append(os.Environ(), postIssueHookEnv(vol)...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	glog.V(4).Infof("cert-manager: running post issue hook %q for volume %s",
+		c.postIssueHook, vol.ID)
+
+	err := cmd.Start()
+	if err == nil {
+		doneCh := make(chan error, 1)
+		go func() {
+			doneCh <- cmd.Wait()
+		}()
+
+		timer := time.NewTimer(c.postIssueHookTimeout)
+		select {
+		case err = <-doneCh:
+			timer.Stop()
+		case <-timer.C:
+			// The negative pid signals the hook's whole process group.
+			if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+				glog.Errorf("cert-manager: failed to kill post issue hook %q for volume %s: %s",
+					c.postIssueHook, vol.ID, err)
+			}
+			<-doneCh
+			err = fmt.Errorf("timed out after %s", c.postIssueHookTimeout)
+		}
+	}
+
+	if err != nil {
+		metrics.PostIssueHookFailures.Inc()
+		glog.Errorf("cert-manager: post issue hook %q failed for volume %s: %s\nOutput: %s",
+			c.postIssueHook, vol.ID, err, output.Bytes())
+		return
+	}
+
+	glog.Infof("cert-manager: post issue hook %q succeeded for volume %s", c.postIssueHook, vol.ID)
+	glog.V(4).Infof("cert-manager: post issue hook output for volume %s: %s", vol.ID, output.Bytes())
+}
+
+// postIssueHookEnv returns the environment variables exposed to the post
+// issue hook describing the volume.
+func postIssueHookEnv(vol *csiapi.MetaData) []string {
+	return []string{
+		"CSI_VOLUME_ID=" + vol.ID,
+		"CSI_VOLUME_PATH=" + vol.Path,
+		"CSI_TARGET_PATH=" + vol.TargetPath,
+		"CSI_CERT_PATH=" + util.CertPath(vol),
+		"CSI_KEY_PATH=" + util.KeyPath(vol),
+		"CSI_CA_PATH=" + util.CAPath(vol),
+		"CSI_POD_NAME=" + vol.Attributes[csiapi.CSIPodNameKey],
+		"CSI_POD_NAMESPACE=" + vol.Attributes[csiapi.CSIPodNamespaceKey],
+		"CSI_POD_UID=" + vol.Attributes[csiapi.CSIPodUIDKey],
+	}
+}
@@ -0,0 +1,77 @@
+package certmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/metrics"
+)
+
+func TestRunPostIssueHook(t *testing.T) {
+	for name, test := range map[string]struct {
+		script      string
+		expFailures float64
+	}{
+		"a succeeding hook should not be counted as failed": {
+			script:      "#!/bin/sh\necho \"$CSI_VOLUME_ID\"\n",
+			expFailures: 0,
+		},
+		"a failing hook should be counted as failed": {
+			script:      "#!/bin/sh\necho failing\nexit 1\n",
+			expFailures: 1,
+		},
+		"a hook timing out should be killed with the processes it started": {
+			// The background sleep holds the hook's output open, so waiting
+			// for the hook blocks until it is killed too.
+			script:      "#!/bin/sh\nsleep 60 &\nsleep 60\n",
+			expFailures: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-hook-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			hookPath := filepath.Join(dir, "hook.sh")
+			if err := ioutil.WriteFile(hookPath, []byte(test.script), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			c := &CertManager{
+				postIssueHook:        hookPath,
+				postIssueHookTimeout: time.Millisecond * 200,
+			}
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: filepath.Join(dir, "test-id"),
+				Attributes: map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.CertFileKey:        "crt.pem",
+					csiapi.KeyFileKey:         "key.pem",
+				},
+			}
+
+			failuresBefore := testutil.ToFloat64(metrics.PostIssueHookFailures)
+
+			start := time.Now()
+			c.runPostIssueHook(vol)
+
+			if took := time.Since(start); took > time.Second*10 {
+				t.Errorf("expected the hook to be stopped on timeout, took %s", took)
+			}
+
+			if failures := testutil.ToFloat64(metrics.PostIssueHookFailures) - failuresBefore; failures != test.expFailures {
+				t.Errorf("unexpected post issue hook failures, exp=%v got=%v", test.expFailures, failures)
+			}
+		})
+	}
+}
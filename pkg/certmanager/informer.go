@@ -0,0 +1,166 @@
+package certmanager
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	cminformers "github.com/jetstack/cert-manager/pkg/client/informers/externalversions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	informerResyncPeriod = time.Minute * 10
+)
+
+// WatchCertificateRequestDeletions starts an informer on CertificateRequests
+// in all namespaces and calls onDelete with the name of each deleted
+// CertificateRequest, until stopCh is closed.
+//
+// Deletions made by the driver itself, such as when renewing, are not
+// reported, so that they don't trigger a further renewal.
+func (c *CertManager) WatchCertificateRequestDeletions(stopCh <-chan struct{}, onDelete func(name string)) {
+	c.ownDeletesMu.Lock()
+	c.watchingDeletions = true
+	c.ownDeletesMu.Unlock()
+
+	var informer cache.SharedIndexInformer
+	var start func(stopCh <-chan struct{})
+	if c.usesV1() {
+		factory := dynamicinformer.NewDynamicSharedInformerFactory(c.dynamicClient, informerResyncPeriod)
+		informer = factory.ForResource(v1CertificateRequests).Informer()
+		start = factory.Start
+	} else {
+		factory := cminformers.NewSharedInformerFactory(c.cmClient, informerResyncPeriod)
+		informer = factory.Certmanager().V1alpha2().CertificateRequests().Informer()
+		start = factory.Start
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			c.certificateRequestDeleted(obj, onDelete)
+		},
+	})
+
+	start(stopCh)
+}
+
+// certificateRequestDeleted calls onDelete with the name of the deleted
+// CertificateRequest, unless it was deleted by the driver itself.
+func (c *CertManager) certificateRequestDeleted(obj interface{}, onDelete func(name string)) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	// v1 CertificateRequests are unstructured.
+	cr, err := meta.Accessor(obj)
+	if err != nil {
+		glog.Errorf("cert-manager: unexpected object in CertificateRequest informer: %T", obj)
+		return
+	}
+
+	if c.observedOwnDelete(cr.GetNamespace(), cr.GetName()) {
+		glog.V(4).Infof("cert-manager: observed deletion of CertificateRequest %s/%s by the driver",
+			cr.GetNamespace(), cr.GetName())
+		return
+	}
+
+	glog.V(4).Infof("cert-manager: observed deletion of CertificateRequest %s/%s",
+		cr.GetNamespace(), cr.GetName())
+
+	onDelete(cr.GetName())
+}
+
+// deleteOwnCertificateRequest deletes the named CertificateRequest, recording
+// the deletion as made by the driver so that it is not reported by
+// WatchCertificateRequestDeletions.
+func (c *CertManager) deleteOwnCertificateRequest(namespace, name string) error {
+	key := namespace + "/" + name
+
+	// Recorded before deleting, since the deletion may be observed before
+	// the delete returns.
+	c.ownDeletesMu.Lock()
+	if c.watchingDeletions {
+		if c.ownDeletes == nil {
+			c.ownDeletes = make(map[string]struct{})
+		}
+		c.ownDeletes[key] = struct{}{}
+	}
+	c.ownDeletesMu.Unlock()
+
+	err := c.certificateRequests(namespace).Delete(name, &metav1.DeleteOptions{})
+	if err != nil {
+		// Nothing was deleted, so there is no deletion to be observed.
+		c.ownDeletesMu.Lock()
+		delete(c.ownDeletes, key)
+		c.ownDeletesMu.Unlock()
+	}
+
+	return err
+}
+
+// observedOwnDelete returns true, and forgets the deletion, if the named
+// CertificateRequest was deleted by the driver.
+func (c *CertManager) observedOwnDelete(namespace, name string) bool {
+	c.ownDeletesMu.Lock()
+	defer c.ownDeletesMu.Unlock()
+
+	key := namespace + "/" + name
+	if _, ok := c.ownDeletes[key]; !ok {
+		return false
+	}
+
+	delete(c.ownDeletes, key)
+
+	return true
+}
+
+// WatchNodePods starts an informer on the pods scheduled to the given node and
+// calls onUpdate with each added or updated pod, and onDelete with each
+// deleted pod, until stopCh is closed.
+func (c *CertManager) WatchNodePods(stopCh <-chan struct{}, nodeName string, onUpdate, onDelete func(pod *corev1.Pod)) {
+	factory := kubeinformers.NewSharedInformerFactoryWithOptions(c.kubeClient, informerResyncPeriod,
+		kubeinformers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+		}),
+	)
+
+	podFromObj := func(obj interface{}) (*corev1.Pod, bool) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			glog.Errorf("cert-manager: unexpected object in pod informer: %T", obj)
+		}
+
+		return pod, ok
+	}
+
+	informer := factory.Core().V1().Pods().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := podFromObj(obj); ok {
+				onUpdate(pod)
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			if pod, ok := podFromObj(obj); ok {
+				onUpdate(pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if pod, ok := podFromObj(obj); ok {
+				onDelete(pod)
+			}
+		},
+	})
+
+	factory.Start(stopCh)
+}
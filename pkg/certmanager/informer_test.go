@@ -0,0 +1,94 @@
+package certmanager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestCertificateRequestDeletedByDriver(t *testing.T) {
+	tests := map[string]func(c *CertManager, vol *csiapi.MetaData) error{
+		"a renewal's own delete should not be reported": func(c *CertManager, vol *csiapi.MetaData) error {
+			_, err := c.RenewCertificate(vol)
+			return err
+		},
+		"a delete of a request not matching the spec should not be reported": func(c *CertManager, vol *csiapi.MetaData) error {
+			vol.Attributes[csiapi.DNSNamesKey] = "foo.baz"
+			_, err := c.CreateNewCertificate(context.TODO(), vol, nil)
+			return err
+		},
+	}
+
+	for name, driverDelete := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-informer-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.IssuerNameKey:      "ca-issuer",
+					csiapi.DNSNamesKey:        "foo.bar",
+					csiapi.CertFileKey:        "crt.pem",
+					csiapi.KeyFileKey:         "key.pem",
+					csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+					csiapi.KeySizeKey:         "256",
+				},
+			}
+
+			client := cmfake.NewSimpleClientset()
+			signOnCreate(t, client)
+
+			c := &CertManager{
+				cmClient:          client,
+				issuanceTimeout:   time.Second * 5,
+				clock:             clock.RealClock{},
+				watchingDeletions: true,
+			}
+
+			var deleted []string
+			onDelete := func(name string) {
+				deleted = append(deleted, name)
+			}
+
+			if _, err := c.CreateNewCertificate(context.TODO(), vol, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			cr, err := client.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := driverDelete(c, vol); err != nil {
+				t.Fatal(err)
+			}
+
+			// The informer observes the deletion made by the driver.
+			c.certificateRequestDeleted(cache.DeletedFinalStateUnknown{Key: "test-namespace/test-id", Obj: cr}, onDelete)
+			if len(deleted) > 0 {
+				t.Fatalf("expected deletion by the driver to not be reported, got: %v", deleted)
+			}
+
+			// The re-created request is then deleted by a user.
+			c.certificateRequestDeleted(cr, onDelete)
+			if len(deleted) != 1 || deleted[0] != "test-id" {
+				t.Errorf("expected deletion by a user to be reported, got: %v", deleted)
+			}
+		})
+	}
+}
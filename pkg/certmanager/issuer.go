@@ -0,0 +1,68 @@
+package certmanager
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/jetstack/cert-manager/pkg/apis/certmanager"
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// checkIssuerReady returns an error if the issuer is not Ready, so that
+// requests fail fast rather than waiting out the issuance timeout. External
+// issuers are skipped since their readiness is not known.
+func (c *CertManager) checkIssuerReady(namespace string, issuerRef cmmeta.ObjectReference) error {
+	name := issuerRef.Name
+	kind := issuerRef.Kind
+
+	if group := issuerRef.Group; len(group) > 0 && group != certmanager.GroupName {
+		glog.V(4).Infof("cert-manager: skipping readiness check of external issuer %s.%s %q", kind, group, name)
+		return nil
+	}
+
+	var issuer cmapi.GenericIssuer
+	var err error
+	switch kind {
+	case "", cmapi.IssuerKind:
+		kind = cmapi.IssuerKind
+		issuer, err = c.getIssuer(kind, namespace, name)
+	case cmapi.ClusterIssuerKind:
+		issuer, err = c.getIssuer(kind, "", name)
+	default:
+		glog.V(4).Infof("cert-manager: skipping readiness check of unknown issuer kind %s %q", kind, name)
+		return nil
+	}
+
+	if k8sErrors.IsNotFound(err) {
+		return fmt.Errorf("%s %q not found", kind, name)
+	}
+	if err != nil {
+		resource, resourceNamespace := issuersResource, namespace
+		if kind == cmapi.ClusterIssuerKind {
+			resource, resourceNamespace = clusterIssuersResource, ""
+		}
+
+		err = apiError(err, "get", resource, resourceNamespace)
+		if _, ok := APIErrorCode(err); ok {
+			return err
+		}
+
+		return fmt.Errorf("failed to get %s %q: %s", kind, name, err)
+	}
+
+	for _, cond := range issuer.GetStatus().Conditions {
+		if cond.Type != cmapi.IssuerConditionReady {
+			continue
+		}
+
+		if cond.Status == cmmeta.ConditionTrue {
+			return nil
+		}
+
+		return fmt.Errorf("%s %q not ready: %s: %s", kind, name, cond.Reason, cond.Message)
+	}
+
+	return fmt.Errorf("%s %q not ready: no Ready condition", kind, name)
+}
@@ -0,0 +1,114 @@
+package certmanager
+
+import (
+	"testing"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCheckIssuerReady(t *testing.T) {
+	ready := cmapi.IssuerStatus{
+		Conditions: []cmapi.IssuerCondition{
+			{Type: cmapi.IssuerConditionReady, Status: cmmeta.ConditionTrue},
+		},
+	}
+
+	notReady := cmapi.IssuerStatus{
+		Conditions: []cmapi.IssuerCondition{
+			{
+				Type:    cmapi.IssuerConditionReady,
+				Status:  cmmeta.ConditionFalse,
+				Reason:  "ErrGetKeyPair",
+				Message: "secret not found",
+			},
+		},
+	}
+
+	for name, test := range map[string]struct {
+		objects []runtime.Object
+		kind    string
+		group   string
+		expErr  string
+	}{
+		"a ready Issuer should not error": {
+			objects: []runtime.Object{
+				&cmapi.Issuer{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-issuer", Namespace: "test-namespace"},
+					Status:     ready,
+				},
+			},
+			kind:   "Issuer",
+			group:  "cert-manager.io",
+			expErr: "",
+		},
+		"an Issuer that is not ready should error": {
+			objects: []runtime.Object{
+				&cmapi.Issuer{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-issuer", Namespace: "test-namespace"},
+					Status:     notReady,
+				},
+			},
+			kind:   "Issuer",
+			group:  "cert-manager.io",
+			expErr: `Issuer "test-issuer" not ready: ErrGetKeyPair: secret not found`,
+		},
+		"an Issuer in another namespace should error as not found": {
+			objects: []runtime.Object{
+				&cmapi.Issuer{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-issuer", Namespace: "foo"},
+					Status:     ready,
+				},
+			},
+			kind:   "Issuer",
+			group:  "cert-manager.io",
+			expErr: `Issuer "test-issuer" not found`,
+		},
+		"a ClusterIssuer without conditions should error": {
+			objects: []runtime.Object{
+				&cmapi.ClusterIssuer{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-issuer"},
+				},
+			},
+			kind:   "ClusterIssuer",
+			group:  "cert-manager.io",
+			expErr: `ClusterIssuer "test-issuer" not ready: no Ready condition`,
+		},
+		"a ready ClusterIssuer should not error": {
+			objects: []runtime.Object{
+				&cmapi.ClusterIssuer{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-issuer"},
+					Status:     ready,
+				},
+			},
+			kind:   "ClusterIssuer",
+			group:  "cert-manager.io",
+			expErr: "",
+		},
+		"an external issuer should be skipped": {
+			objects: nil,
+			kind:    "AWSPCAIssuer",
+			group:   "awspca.cert-manager.io",
+			expErr:  "",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := &CertManager{
+				cmClient: cmfake.NewSimpleClientset(test.objects...),
+			}
+
+			err := c.checkIssuerReady("test-namespace", cmmeta.ObjectReference{
+				Name:  "test-issuer",
+				Kind:  test.kind,
+				Group: test.group,
+			})
+
+			if (err == nil && len(test.expErr) > 0) || (err != nil && err.Error() != test.expErr) {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
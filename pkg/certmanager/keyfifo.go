@@ -0,0 +1,44 @@
+package certmanager
+
+import (
+	"fmt"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// deliverKey serves the private key of the volume through its named pipe,
+// replacing a previous key that has not yet been read.
+func (c *CertManager) deliverKey(vol *csiapi.MetaData, key []byte) error {
+	c.keyFIFOsMu.Lock()
+	defer c.keyFIFOsMu.Unlock()
+
+	if fifo, ok := c.keyFIFOs[vol.ID]; ok {
+		fifo.Stop()
+		delete(c.keyFIFOs, vol.ID)
+	}
+
+	fifo, err := util.ServeKeyFIFO(util.KeyPath(vol), key)
+	if err != nil {
+		return fmt.Errorf("failed to deliver private key of volume %s: %s", vol.ID, err)
+	}
+
+	if c.keyFIFOs == nil {
+		c.keyFIFOs = make(map[string]*util.KeyFIFO)
+	}
+	c.keyFIFOs[vol.ID] = fifo
+
+	return nil
+}
+
+// StopKeyDelivery stops serving the private key of the volume through its
+// named pipe, if not yet read.
+func (c *CertManager) StopKeyDelivery(volID string) {
+	c.keyFIFOsMu.Lock()
+	defer c.keyFIFOsMu.Unlock()
+
+	if fifo, ok := c.keyFIFOs[volID]; ok {
+		fifo.Stop()
+		delete(c.keyFIFOs, volID)
+	}
+}
@@ -0,0 +1,125 @@
+package certmanager
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// NewKey returns the private key for a new request of the volume. Volumes
+// with a key Secret use the key stored in it for their pod, which is
+// generated and stored on first use, so that the key survives the pod being
+// rescheduled. Otherwise a new key is generated.
+func (c *CertManager) NewKey(vol *csiapi.MetaData) (*util.KeyBundle, error) {
+	name := vol.Attributes[csiapi.KeySecretKey]
+	if len(name) == 0 {
+		keyBundle, err := util.NewKey(vol.Attributes)
+		if err != nil {
+			return nil, err
+		}
+
+		vol.IdentityCreated = c.clock.Now()
+
+		return keyBundle, nil
+	}
+
+	keyBundle, err := c.secretKey(vol, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get private key from secret %s/%s: %s",
+			vol.Attributes[csiapi.CSIPodNamespaceKey], name, err)
+	}
+
+	if vol.IdentityCreated.IsZero() {
+		vol.IdentityCreated = c.clock.Now()
+	}
+
+	return keyBundle, nil
+}
+
+// secretKey returns the private key stored for the pod of the volume in the
+// named Secret. If none is stored, a new key is generated and stored. Pods
+// of other nodes may store their keys in the same Secret at the same time, so
+// conflicting writes are retried.
+func (c *CertManager) secretKey(vol *csiapi.MetaData, name string) (*util.KeyBundle, error) {
+	namespace := vol.Attributes[csiapi.CSIPodNamespaceKey]
+	podName := vol.Attributes[csiapi.CSIPodNameKey]
+	secrets := c.kubeClient.CoreV1().Secrets(namespace)
+
+	var keyBundle *util.KeyBundle
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := secrets.Get(name, metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			secret = nil
+		} else if err != nil {
+			return apiError(err, "get", secretsResource, namespace)
+		}
+
+		if secret != nil {
+			if data, ok := secret.Data[podName]; ok {
+				sk, keyPEM, err := util.DecodePrivateKey(data, "")
+				if err != nil {
+					return fmt.Errorf("failed to decode private key of pod %s: %s", podName, err)
+				}
+
+				keyBundle, err = util.KeyBundleFromSigner(sk, keyPEM)
+				return err
+			}
+		}
+
+		newKey, err := util.NewKey(vol.Attributes)
+		if err != nil {
+			return err
+		}
+
+		if secret == nil {
+			_, err = secrets.Create(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Type: corev1.SecretTypeOpaque,
+				Data: map[string][]byte{
+					podName: newKey.PEM,
+				},
+			})
+
+			// Another node created the Secret first, so try again with it.
+			if k8sErrors.IsAlreadyExists(err) {
+				return k8sErrors.NewConflict(corev1.Resource("secrets"), name, err)
+			}
+			err = apiError(err, "create", secretsResource, namespace)
+		} else {
+			secret = secret.DeepCopy()
+			if secret.Data == nil {
+				secret.Data = make(map[string][]byte)
+			}
+			secret.Data[podName] = newKey.PEM
+
+			_, err = secrets.Update(secret)
+			err = apiError(err, "update", secretsResource, namespace)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		glog.Infof("cert-manager: stored new private key of pod %s in secret %s/%s",
+			podName, namespace, name)
+
+		keyBundle = newKey
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keyBundle, nil
+}
@@ -0,0 +1,121 @@
+package certmanager
+
+import (
+	"bytes"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestNewKeySecret(t *testing.T) {
+	storedKey, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := func(data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web-keys",
+				Namespace: "test-namespace",
+			},
+			Data: data,
+		}
+	}
+
+	for name, test := range map[string]struct {
+		secret    *corev1.Secret
+		expStored bool
+		expPods   []string
+	}{
+		"a missing secret should be created with a new key": {
+			expPods: []string{"web-0"},
+		},
+		"a secret without the pod's key should be updated with a new key": {
+			secret: secret(map[string][]byte{
+				"web-1": storedKey.PEM,
+			}),
+			expPods: []string{"web-0", "web-1"},
+		},
+		"a secret with the pod's key should be reused": {
+			secret: secret(map[string][]byte{
+				"web-0": storedKey.PEM,
+			}),
+			expStored: true,
+			expPods:   []string{"web-0"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var objs []runtime.Object
+			if test.secret != nil {
+				objs = append(objs, test.secret)
+			}
+			kubeClient := kubefake.NewSimpleClientset(objs...)
+
+			c := &CertManager{
+				kubeClient: kubeClient,
+				clock:      clock.RealClock{},
+			}
+
+			vol := &csiapi.MetaData{
+				ID: "test-id",
+				Attributes: map[string]string{
+					csiapi.CSIPodNameKey:      "web-0",
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.KeySecretKey:       "web-keys",
+					csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+					csiapi.KeySizeKey:         "256",
+				},
+			}
+
+			keyBundle, err := c.NewKey(vol)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if stored := bytes.Equal(keyBundle.PEM, storedKey.PEM); stored != test.expStored {
+				t.Errorf("unexpected stored key used, exp=%t got=%t", test.expStored, stored)
+			}
+
+			if vol.IdentityCreated.IsZero() {
+				t.Error("expected identity created time to be set")
+			}
+
+			got, err := kubeClient.CoreV1().Secrets("test-namespace").Get("web-keys", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got.Data) != len(test.expPods) {
+				t.Errorf("unexpected pods in secret, exp=%v got=%d", test.expPods, len(got.Data))
+			}
+
+			for _, pod := range test.expPods {
+				if _, ok := got.Data[pod]; !ok {
+					t.Errorf("expected key of pod %s in secret", pod)
+				}
+			}
+
+			if !bytes.Equal(got.Data["web-0"], keyBundle.PEM) {
+				t.Error("expected returned key to be stored in secret")
+			}
+
+			// The stored key is returned from then on.
+			again, err := c.NewKey(vol)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(again.PEM, keyBundle.PEM) {
+				t.Error("expected stored key to be reused")
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package certmanager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// holdPendingRequest keeps the private key of a CertificateRequest that was
+// still pending when waiting for it was interrupted, such as by the kubelet's
+// call timing out, so that retried publishes of the volume resume waiting for
+// the same request until --issuance-deadline after it was created. The key
+// and the creation time are written next to the volume's metadata, so are
+// kept across driver restarts. The creation time of a request that is already
+// held is kept.
+func (c *CertManager) holdPendingRequest(vol *csiapi.MetaData, cr *cmapi.CertificateRequest, keyBundle *util.KeyBundle) error {
+	if vol.RequestCreated == nil {
+		requestCreated := cr.CreationTimestamp.Time
+		if requestCreated.IsZero() {
+			requestCreated = c.clock.Now()
+		}
+
+		vol.RequestCreated = &requestCreated
+	}
+
+	if err := util.WriteFile(util.PendingKeyPath(vol), keyBundle.PEM, 0600, c.fsyncFiles); err != nil {
+		return fmt.Errorf("failed to write private key of pending request: %s", err)
+	}
+
+	if err := util.WriteMetaDataFile(vol, c.metadataInMount, c.fsyncFiles); err != nil {
+		return fmt.Errorf("failed to write metadata file: %s", err)
+	}
+
+	glog.Infof("cert-manager: CertificateRequest %s/%s still pending, resuming on retry until %s",
+		cr.Namespace, cr.Name, vol.RequestCreated.Add(c.issuanceDeadline).Format(time.RFC3339))
+
+	return nil
+}
+
+// pendingKey returns the private key of the volume's pending request, if its
+// issuance deadline has not elapsed. A request past its deadline is
+// forgotten, so that it is replaced by a new one.
+func (c *CertManager) pendingKey(vol *csiapi.MetaData) *util.KeyBundle {
+	existing, err := util.ReadMetaDataFile(util.MetaDataPath(vol))
+	if err != nil || existing.RequestCreated == nil {
+		return nil
+	}
+
+	if c.clock.Since(*existing.RequestCreated) >= c.issuanceDeadline {
+		glog.Infof("cert-manager: issuance deadline of CertificateRequest %s exceeded, re-creating it", vol.ID)
+		c.forgetPendingRequest(vol)
+		return nil
+	}
+
+	keyBytes, err := ioutil.ReadFile(util.PendingKeyPath(vol))
+	if err != nil {
+		glog.Errorf("cert-manager: failed to read private key of pending CertificateRequest %s: %s", vol.ID, err)
+		return nil
+	}
+
+	sk, keyPEM, err := util.DecodePrivateKey(keyBytes, csiapi.PEMEncoding)
+	if err != nil {
+		glog.Errorf("cert-manager: failed to decode private key of pending CertificateRequest %s: %s", vol.ID, err)
+		return nil
+	}
+
+	keyBundle, err := util.KeyBundleFromSigner(sk, keyPEM)
+	if err != nil {
+		glog.Errorf("cert-manager: failed to use private key of pending CertificateRequest %s: %s", vol.ID, err)
+		return nil
+	}
+
+	vol.IdentityCreated = existing.IdentityCreated
+	vol.RequestCreated = existing.RequestCreated
+
+	return keyBundle
+}
+
+// forgetPendingRequest forgets the pending request of the volume, removing
+// its held private key. The metadata file is rewritten without the request's
+// creation time along with the volume's files.
+func (c *CertManager) forgetPendingRequest(vol *csiapi.MetaData) {
+	vol.RequestCreated = nil
+
+	if err := os.Remove(util.PendingKeyPath(vol)); err != nil && !os.IsNotExist(err) {
+		glog.Errorf("cert-manager: failed to remove private key of pending CertificateRequest %s: %s", vol.ID, err)
+	}
+}
+
+// requestPending returns true if the CertificateRequest is neither Ready nor
+// failed.
+func requestPending(cr *cmapi.CertificateRequest) bool {
+	_, failed := util.CertificateRequestFailed(cr)
+	return !failed && !util.CertificateRequestReady(cr)
+}
@@ -0,0 +1,185 @@
+package certmanager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	coretesting "k8s.io/client-go/testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestCreateNewCertificateIssuanceDeadline(t *testing.T) {
+	for name, test := range map[string]struct {
+		deadline   time.Duration
+		step       time.Duration
+		restart    bool
+		expCreates int
+		expResumed bool
+	}{
+		"a pending request should be resumed on retry": {
+			deadline:   time.Hour,
+			step:       time.Minute,
+			expCreates: 1,
+			expResumed: true,
+		},
+		"a pending request should be resumed after a restart": {
+			deadline:   time.Hour,
+			step:       time.Minute,
+			restart:    true,
+			expCreates: 1,
+			expResumed: true,
+		},
+		"a pending request past its deadline should be re-created": {
+			deadline:   time.Hour,
+			step:       time.Hour,
+			expCreates: 2,
+			expResumed: false,
+		},
+		"a pending request should be re-created if the deadline is disabled": {
+			deadline:   0,
+			step:       time.Minute,
+			expCreates: 2,
+			expResumed: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-pending-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			// The first request is left pending, its signed status kept to
+			// be set once the first attempt has given up waiting.
+			client := cmfake.NewSimpleClientset()
+			var creates int
+			var signedStatus cmapi.CertificateRequestStatus
+			client.PrependReactor("create", "certificaterequests", func(action coretesting.Action) (bool, runtime.Object, error) {
+				creates++
+				if creates == 1 {
+					cr := action.(coretesting.CreateAction).GetObject().(*cmapi.CertificateRequest)
+					signedStatus = cr.Status
+					cr.Status = cmapi.CertificateRequestStatus{}
+				}
+				return false, nil, nil
+			})
+			signOnCreate(t, client)
+
+			fakeClock := clock.NewFakeClock(time.Now())
+			c := &CertManager{
+				cmClient:         client,
+				issuanceTimeout:  time.Millisecond * 10,
+				issuanceDeadline: test.deadline,
+				clock:            fakeClock,
+			}
+
+			newVol := func() *csiapi.MetaData {
+				return &csiapi.MetaData{
+					ID:   "test-id",
+					Path: dir,
+					Attributes: map[string]string{
+						csiapi.CSIPodNamespaceKey: "test-namespace",
+						csiapi.IssuerNameKey:      "test-issuer",
+						csiapi.IssuerKindKey:      cmapi.IssuerKind,
+						csiapi.DNSNamesKey:        "foo.bar",
+						csiapi.CertFileKey:        "crt.pem",
+						csiapi.KeyFileKey:         "key.pem",
+						csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+						csiapi.KeySizeKey:         "256",
+					},
+				}
+			}
+
+			if _, err := c.CreateNewCertificate(context.Background(), newVol(), nil); err == nil {
+				t.Fatal("expected error waiting for pending request")
+			}
+
+			var pendingKey *util.KeyBundle
+			if keyBytes, err := ioutil.ReadFile(util.PendingKeyPath(newVol())); err == nil {
+				sk, keyPEM, err := util.DecodePrivateKey(keyBytes, csiapi.PEMEncoding)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if pendingKey, err = util.KeyBundleFromSigner(sk, keyPEM); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if (pendingKey != nil) != (test.deadline > 0) {
+				t.Fatalf("unexpected held pending request, exp=%t got=%t", test.deadline > 0, pendingKey != nil)
+			}
+
+			if test.deadline > 0 {
+				meta, err := util.ReadMetaDataFile(util.MetaDataPath(newVol()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if meta.RequestCreated == nil || !meta.RequestCreated.Equal(fakeClock.Now()) {
+					t.Errorf("unexpected request created time in metadata, exp=%s got=%v", fakeClock.Now(), meta.RequestCreated)
+				}
+			}
+
+			cr, err := client.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cr.Status = signedStatus
+			if _, err := client.CertmanagerV1alpha2().CertificateRequests("test-namespace").Update(cr); err != nil {
+				t.Fatal(err)
+			}
+
+			fakeClock.Step(test.step)
+
+			if test.restart {
+				c = &CertManager{
+					cmClient:         client,
+					issuanceTimeout:  time.Millisecond * 10,
+					issuanceDeadline: test.deadline,
+					clock:            fakeClock,
+				}
+			}
+
+			cert, err := c.CreateNewCertificate(context.Background(), newVol(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if creates != test.expCreates {
+				t.Errorf("unexpected number of created requests, exp=%d got=%d", test.expCreates, creates)
+			}
+
+			if _, err := os.Stat(util.PendingKeyPath(newVol())); !os.IsNotExist(err) {
+				t.Errorf("expected private key of pending request to be removed, got: %v", err)
+			}
+
+			meta, err := util.ReadMetaDataFile(util.MetaDataPath(newVol()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta.RequestCreated != nil {
+				t.Errorf("expected request created time to be removed from metadata, got %s", meta.RequestCreated)
+			}
+
+			if pendingKey == nil {
+				return
+			}
+
+			resumed, err := util.PublicKeysEqual(pendingKey.PrivateKey.Public(), cert.PublicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resumed != test.expResumed {
+				t.Errorf("unexpected certificate of the pending request, exp=%t got=%t", test.expResumed, resumed)
+			}
+		})
+	}
+}
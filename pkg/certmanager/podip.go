@@ -0,0 +1,88 @@
+package certmanager
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// ErrPodGone is returned by PodIPs if the pod of the volume no longer exists.
+var ErrPodGone = errors.New("pod no longer exists")
+
+// PodIPs returns the current IPs of the pod of the volume. They are read from
+// the volume's ip-sans-from-file file if set, otherwise from the status of the
+// pod. No IPs are returned if the pod has not yet been assigned one.
+func (c *CertManager) PodIPs(vol *csiapi.MetaData) ([]net.IP, error) {
+	if name := vol.Attributes[csiapi.IPSANsFromFileKey]; len(name) > 0 {
+		path := filepath.Join(c.downwardAPIDir, name)
+
+		b, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %s", path, err)
+		}
+
+		return parsePodIPs(downwardAPIValues(b))
+	}
+
+	namespace := vol.Attributes[csiapi.CSIPodNamespaceKey]
+	podName := vol.Attributes[csiapi.CSIPodNameKey]
+
+	pod, err := c.kubeClient.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return nil, ErrPodGone
+	}
+	if err != nil {
+		return nil, apiError(err, "get", podsResource, namespace)
+	}
+
+	var values []string
+	for _, podIP := range pod.Status.PodIPs {
+		values = append(values, podIP.IP)
+	}
+	if len(values) == 0 && len(pod.Status.PodIP) > 0 {
+		values = append(values, pod.Status.PodIP)
+	}
+
+	return parsePodIPs(values)
+}
+
+// trackedPodIPs returns the current IPs of the pod of the volume to add to
+// its IP SANs, if it tracks its pod IP and doesn't already read them from a
+// file at issuance.
+func (c *CertManager) trackedPodIPs(vol *csiapi.MetaData) ([]net.IP, error) {
+	if vol.Attributes[csiapi.TrackPodIPKey] != "true" || len(vol.Attributes[csiapi.IPSANsFromFileKey]) > 0 {
+		return nil, nil
+	}
+
+	ips, err := c.PodIPs(vol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod ip: %s", err)
+	}
+
+	return ips, nil
+}
+
+func parsePodIPs(values []string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, value := range values {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid pod ip %q", value)
+		}
+
+		ips = append(ips, ip)
+	}
+
+	return ips, nil
+}
@@ -0,0 +1,111 @@
+package certmanager
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestPodIPs(t *testing.T) {
+	for name, test := range map[string]struct {
+		pod    *corev1.Pod
+		attr   map[string]string
+		files  map[string]string
+		expIPs []net.IP
+		expErr error
+	}{
+		"the pod ips should be returned": {
+			pod: &corev1.Pod{
+				Status: corev1.PodStatus{
+					PodIP: "10.0.0.1",
+					PodIPs: []corev1.PodIP{
+						{IP: "10.0.0.1"}, {IP: "fd00::1"},
+					},
+				},
+			},
+			expIPs: []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")},
+		},
+		"the pod ip should be returned if the pod has no pod ips": {
+			pod: &corev1.Pod{
+				Status: corev1.PodStatus{
+					PodIP: "10.0.0.1",
+				},
+			},
+			expIPs: []net.IP{net.ParseIP("10.0.0.1")},
+		},
+		"no ips should be returned if the pod has not been assigned one": {
+			pod:    new(corev1.Pod),
+			expIPs: nil,
+		},
+		"a pod that no longer exists should error": {
+			expErr: ErrPodGone,
+		},
+		"the ips should be read from the ip sans file if set": {
+			attr: map[string]string{
+				csiapi.IPSANsFromFileKey: "ips",
+			},
+			files: map[string]string{
+				"ips": "10.0.0.2\n",
+			},
+			expIPs: []net.IP{net.ParseIP("10.0.0.2")},
+		},
+		"no ips should be returned if the ip sans file does not exist": {
+			attr: map[string]string{
+				csiapi.IPSANsFromFileKey: "ips",
+			},
+			expIPs: nil,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-pod-ips-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			for name, data := range test.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var objects []runtime.Object
+			if test.pod != nil {
+				test.pod.ObjectMeta = metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}
+				objects = append(objects, test.pod)
+			}
+
+			c := &CertManager{
+				kubeClient:     kubefake.NewSimpleClientset(objects...),
+				downwardAPIDir: dir,
+			}
+
+			attr := map[string]string{
+				csiapi.CSIPodNameKey:      "test-pod",
+				csiapi.CSIPodNamespaceKey: "test-ns",
+			}
+			for k, v := range test.attr {
+				attr[k] = v
+			}
+
+			ips, err := c.PodIPs(&csiapi.MetaData{ID: "test-id", Attributes: attr})
+			if err != test.expErr {
+				t.Fatalf("unexpected error, exp=%v got=%v", test.expErr, err)
+			}
+
+			if !reflect.DeepEqual(ips, test.expIPs) {
+				t.Errorf("unexpected ips, exp=%s got=%s", test.expIPs, ips)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package certmanager
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/glog"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/metrics"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// prewarmedKey is the private key of a pre-created CertificateRequest, held
+// until its volume is published.
+type prewarmedKey struct {
+	keyBundle *util.KeyBundle
+	created   time.Time
+}
+
+// PrewarmCertificateRequest creates the CertificateRequest of a volume that
+// is yet to be published, so that it may already be Ready by the time it is.
+// The private key is only held in memory until the volume is published. An
+// existing request is left as is, as are volumes whose key is held by the
+// workload or the signer plugin, and volumes reading request fields from
+// files that may only be written once the pod has started.
+func (c *CertManager) PrewarmCertificateRequest(vol *csiapi.MetaData) error {
+	if !util.WritesPrivateKey(vol) {
+		return nil
+	}
+
+	for _, k := range csiapi.DownwardAPIFileKeys {
+		if len(vol.Attributes[k]) > 0 {
+			return nil
+		}
+	}
+
+	c.prewarmMu.Lock()
+	defer c.prewarmMu.Unlock()
+
+	if _, ok := c.prewarmedKeys[vol.ID]; ok {
+		return nil
+	}
+
+	namespace := vol.Attributes[csiapi.CSIPodNamespaceKey]
+
+	_, err := c.certificateRequests(namespace).Get(vol.ID, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !k8sErrors.IsNotFound(err) {
+		return apiError(err, "get", certificateRequestsResource, namespace)
+	}
+
+	keyBundle, err := c.NewKey(vol)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.createCertificateRequest(context.Background(), vol, keyBundle); err != nil {
+		return err
+	}
+
+	if c.prewarmedKeys == nil {
+		c.prewarmedKeys = make(map[string]prewarmedKey)
+	}
+	c.prewarmedKeys[vol.ID] = prewarmedKey{
+		keyBundle: keyBundle,
+		created:   vol.IdentityCreated,
+	}
+
+	glog.Infof("cert-manager: pre-created CertificateRequest %s/%s before publish", namespace, vol.ID)
+
+	return nil
+}
+
+// takePrewarmedKey returns the private key of the pre-created request of the
+// volume, if any, and forgets it.
+func (c *CertManager) takePrewarmedKey(vol *csiapi.MetaData) *util.KeyBundle {
+	c.prewarmMu.Lock()
+	defer c.prewarmMu.Unlock()
+
+	key, ok := c.prewarmedKeys[vol.ID]
+	if !ok {
+		return nil
+	}
+
+	delete(c.prewarmedKeys, vol.ID)
+	vol.IdentityCreated = key.created
+
+	return key.keyBundle
+}
+
+// ForgetPrewarmedRequest deletes the pre-created request of a volume that
+// will no longer be published, such as when its pod is deleted beforehand.
+// Requests of published volumes are not touched.
+func (c *CertManager) ForgetPrewarmedRequest(volID, namespace string) error {
+	c.prewarmMu.Lock()
+	defer c.prewarmMu.Unlock()
+
+	if _, ok := c.prewarmedKeys[volID]; !ok {
+		return nil
+	}
+
+	delete(c.prewarmedKeys, volID)
+
+	err := c.certificateRequests(namespace).Delete(volID, &metav1.DeleteOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return apiError(err, "delete", certificateRequestsResource, namespace)
+	}
+	if err == nil {
+		c.recordRequestDeleted(namespace, metrics.DeleteReasonUnpublish)
+	}
+
+	glog.Infof("cert-manager: deleted pre-created CertificateRequest %s/%s of unpublished volume", namespace, volID)
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package certmanager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	coretesting "k8s.io/client-go/testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestPrewarmCertificateRequest(t *testing.T) {
+	for name, test := range map[string]struct {
+		existing   bool
+		expCreates int
+		expReused  bool
+	}{
+		"a pre-created request should be consumed on publish": {
+			existing:   false,
+			expCreates: 1,
+			expReused:  true,
+		},
+		"an existing request should be left as is": {
+			existing:   true,
+			expCreates: 1,
+			expReused:  false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-prewarm-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			client := cmfake.NewSimpleClientset()
+			if test.existing {
+				client = cmfake.NewSimpleClientset(&cmapi.CertificateRequest{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-id",
+						Namespace: "test-namespace",
+					},
+				})
+			}
+			signOnCreate(t, client)
+
+			now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+			c := &CertManager{
+				cmClient:        client,
+				issuanceTimeout: time.Second * 5,
+				clock:           clock.NewFakeClock(now),
+			}
+
+			newVol := func() *csiapi.MetaData {
+				return &csiapi.MetaData{
+					ID:   "test-id",
+					Path: dir,
+					Attributes: map[string]string{
+						csiapi.CSIPodNamespaceKey: "test-namespace",
+						csiapi.IssuerNameKey:      "test-issuer",
+						csiapi.IssuerKindKey:      cmapi.IssuerKind,
+						csiapi.DNSNamesKey:        "foo.bar",
+						csiapi.CertFileKey:        "crt.pem",
+						csiapi.KeyFileKey:         "key.pem",
+						csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+						csiapi.KeySizeKey:         "256",
+					},
+				}
+			}
+
+			if err := c.PrewarmCertificateRequest(newVol()); err != nil {
+				t.Fatal(err)
+			}
+
+			var prewarmedKey *util.KeyBundle
+			if key, ok := c.prewarmedKeys["test-id"]; ok {
+				prewarmedKey = key.keyBundle
+			}
+			if (prewarmedKey != nil) != test.expReused {
+				t.Fatalf("unexpected pre-created request, exp=%t got=%t", test.expReused, prewarmedKey != nil)
+			}
+
+			vol := newVol()
+			cert, err := c.CreateNewCertificate(context.Background(), vol, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var creates int
+			for _, action := range client.Actions() {
+				if action.Matches("create", "certificaterequests") {
+					creates++
+				}
+			}
+			if creates != test.expCreates {
+				t.Errorf("unexpected number of created requests, exp=%d got=%d", test.expCreates, creates)
+			}
+
+			if _, ok := c.prewarmedKeys["test-id"]; ok {
+				t.Error("expected pre-created key to be consumed")
+			}
+
+			if !test.expReused {
+				return
+			}
+
+			reused, err := util.PublicKeysEqual(prewarmedKey.PrivateKey.Public(), cert.PublicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reused {
+				t.Error("expected certificate of the pre-created request")
+			}
+
+			if !vol.IdentityCreated.Equal(now) {
+				t.Errorf("unexpected identity created time, exp=%s got=%s", now, vol.IdentityCreated)
+			}
+		})
+	}
+}
+
+func TestForgetPrewarmedRequest(t *testing.T) {
+	client := cmfake.NewSimpleClientset()
+	signOnCreate(t, client)
+
+	c := &CertManager{
+		cmClient: client,
+		clock:    clock.RealClock{},
+	}
+
+	vol := &csiapi.MetaData{
+		ID: "test-id",
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "test-issuer",
+			csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:         "256",
+		},
+	}
+
+	if err := c.ForgetPrewarmedRequest(vol.ID, "test-namespace"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range client.Actions() {
+		if action.Matches("delete", "certificaterequests") {
+			t.Errorf("unexpected delete of a request that was not pre-created: %v", action.(coretesting.DeleteAction).GetName())
+		}
+	}
+
+	if err := c.PrewarmCertificateRequest(vol); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ForgetPrewarmedRequest(vol.ID, "test-namespace"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get(vol.ID, metav1.GetOptions{})
+	if !k8sErrors.IsNotFound(err) {
+		t.Errorf("expected pre-created request to be deleted, got: %v", err)
+	}
+
+	if _, ok := c.prewarmedKeys[vol.ID]; ok {
+		t.Error("expected pre-created key to be forgotten")
+	}
+}
@@ -0,0 +1,126 @@
+package certmanager
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// stagedCA is the CA of an issuer, shared by the volumes staged for it so
+// that it is fetched once rather than for every pod.
+type stagedCA struct {
+	ca   []byte
+	vols map[string]struct{}
+}
+
+// stagedCAKey returns the key of an issuer in the staged CA cache. Issuers
+// are keyed by the namespace of the volume, whereas ClusterIssuers are shared
+// by all namespaces.
+func stagedCAKey(namespace string, issuerRef cmmeta.ObjectReference) string {
+	if issuerRef.Kind == cmapi.ClusterIssuerKind {
+		namespace = ""
+	}
+
+	return fmt.Sprintf("%s/%s.%s/%s", namespace, issuerRef.Kind, issuerRef.Group, issuerRef.Name)
+}
+
+// StageCA caches the CA of the volume's issuer until the volume is unstaged.
+// The CA is fetched from a Ready CertificateRequest of the issuer, unless
+// already cached for another staged volume. Returns the CA, or nil if no
+// request of the issuer has been issued yet, in which case the CA of the
+// first request issued is cached.
+func (c *CertManager) StageCA(volID string, attr map[string]string) ([]byte, error) {
+	namespace := attr[csiapi.CSIPodNamespaceKey]
+	issuerRef, _ := issuerRefs(attr)
+	key := stagedCAKey(namespace, issuerRef)
+
+	c.stagedCAsMu.Lock()
+	defer c.stagedCAsMu.Unlock()
+
+	if c.stagedCAs == nil {
+		c.stagedCAs = make(map[string]*stagedCA)
+	}
+
+	entry, ok := c.stagedCAs[key]
+	if !ok {
+		entry = &stagedCA{vols: make(map[string]struct{})}
+	}
+
+	if len(entry.ca) == 0 {
+		ca, err := c.fetchIssuerCA(namespace, issuerRef)
+		if err != nil {
+			return nil, err
+		}
+		entry.ca = ca
+	}
+
+	entry.vols[volID] = struct{}{}
+	c.stagedCAs[key] = entry
+
+	glog.V(4).Infof("cert-manager: volume %s staged for the ca of %s %q, shared by %d volumes",
+		volID, issuerRef.Kind, issuerRef.Name, len(entry.vols))
+
+	return entry.ca, nil
+}
+
+// UnstageCA releases the CA cached for the volume, removing it from the cache
+// once no staged volume of its issuer remains.
+func (c *CertManager) UnstageCA(volID string) {
+	c.stagedCAsMu.Lock()
+	defer c.stagedCAsMu.Unlock()
+
+	for key, entry := range c.stagedCAs {
+		if _, ok := entry.vols[volID]; !ok {
+			continue
+		}
+
+		delete(entry.vols, volID)
+		if len(entry.vols) == 0 {
+			glog.V(4).Infof("cert-manager: no volumes staged for the ca of %s, removing it", key)
+			delete(c.stagedCAs, key)
+		}
+	}
+}
+
+// stagedCAOf returns the CA to write to the volume of a request of the given
+// issuer. The CA of the request is cached if the issuer is staged, so that it
+// is kept current, and the staged CA is used if the request has none.
+func (c *CertManager) stagedCAOf(namespace string, issuerRef cmmeta.ObjectReference, ca []byte) []byte {
+	c.stagedCAsMu.Lock()
+	defer c.stagedCAsMu.Unlock()
+
+	entry, ok := c.stagedCAs[stagedCAKey(namespace, issuerRef)]
+	if !ok {
+		return ca
+	}
+
+	if len(ca) > 0 {
+		entry.ca = ca
+	}
+
+	return entry.ca
+}
+
+// fetchIssuerCA returns the CA of a Ready CertificateRequest of the issuer
+// in the namespace, or nil if there is none.
+func (c *CertManager) fetchIssuerCA(namespace string, issuerRef cmmeta.ObjectReference) ([]byte, error) {
+	crs, err := c.certificateRequests(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, apiError(err, "list", certificateRequestsResource, namespace)
+	}
+
+	for i := range crs.Items {
+		cr := &crs.Items[i]
+		if cr.Spec.IssuerRef == issuerRef && util.CertificateRequestReady(cr) && len(cr.Status.CA) > 0 {
+			return cr.Status.CA, nil
+		}
+	}
+
+	return nil, nil
+}
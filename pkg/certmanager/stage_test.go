@@ -0,0 +1,96 @@
+package certmanager
+
+import (
+	"bytes"
+	"testing"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestStageCA(t *testing.T) {
+	issuerRef := cmmeta.ObjectReference{
+		Name:  "ca-issuer",
+		Kind:  cmapi.IssuerKind,
+		Group: "cert-manager.io",
+	}
+
+	client := cmfake.NewSimpleClientset(&cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-id",
+			Namespace: "test-namespace",
+		},
+		Spec: cmapi.CertificateRequestSpec{
+			IssuerRef: issuerRef,
+		},
+		Status: cmapi.CertificateRequestStatus{
+			CA: []byte("staged-ca"),
+			Conditions: []cmapi.CertificateRequestCondition{
+				{Type: cmapi.CertificateRequestConditionReady, Status: cmmeta.ConditionTrue},
+			},
+		},
+	})
+
+	c := &CertManager{
+		cmClient: client,
+		clock:    clock.RealClock{},
+	}
+
+	attr := map[string]string{
+		csiapi.CSIPodNamespaceKey: "test-namespace",
+		csiapi.IssuerNameKey:      issuerRef.Name,
+		csiapi.IssuerKindKey:      issuerRef.Kind,
+		csiapi.IssuerGroupKey:     issuerRef.Group,
+	}
+
+	for _, volID := range []string{"vol-1", "vol-2"} {
+		ca, err := c.StageCA(volID, attr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(ca, []byte("staged-ca")) {
+			t.Errorf("unexpected staged ca of %s, exp=staged-ca got=%s", volID, ca)
+		}
+	}
+
+	var lists int
+	for _, action := range client.Actions() {
+		if action.Matches("list", "certificaterequests") {
+			lists++
+		}
+	}
+	if lists != 1 {
+		t.Errorf("expected the ca to be fetched once for both volumes, got %d fetches", lists)
+	}
+
+	// A request without a CA gets the staged CA, whereas the CA of a request
+	// replaces it.
+	if ca := c.stagedCAOf("test-namespace", issuerRef, nil); !bytes.Equal(ca, []byte("staged-ca")) {
+		t.Errorf("expected staged ca for request without a ca, got %s", ca)
+	}
+
+	if ca := c.stagedCAOf("test-namespace", issuerRef, []byte("new-ca")); !bytes.Equal(ca, []byte("new-ca")) {
+		t.Errorf("expected ca of request to be used, got %s", ca)
+	}
+
+	if ca := c.stagedCAOf("test-namespace", issuerRef, nil); !bytes.Equal(ca, []byte("new-ca")) {
+		t.Errorf("expected ca of request to be staged, got %s", ca)
+	}
+
+	// The CA is kept until the last volume of the issuer is unstaged.
+	c.UnstageCA("vol-1")
+	if ca := c.stagedCAOf("test-namespace", issuerRef, nil); !bytes.Equal(ca, []byte("new-ca")) {
+		t.Errorf("expected ca to be kept while a volume is staged, got %s", ca)
+	}
+
+	c.UnstageCA("vol-2")
+	if len(c.stagedCAs) != 0 {
+		t.Errorf("expected staged ca to be removed once no volume is staged, got %d", len(c.stagedCAs))
+	}
+}
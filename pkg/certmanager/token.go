@@ -0,0 +1,64 @@
+package certmanager
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/golang/glog"
+	authv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// requestServiceAccountToken requests a token for the service account of the
+// volume's pod, bound to the lifetime of the pod. The token is requested for
+// the volume's audience, or the first configured audience if not set.
+func (c *CertManager) requestServiceAccountToken(vol *csiapi.MetaData) (string, error) {
+	attr := vol.Attributes
+	namespace := attr[csiapi.CSIPodNamespaceKey]
+	saName := attr[csiapi.CSIServiceAccountNameKey]
+
+	if len(c.tokenAudiences) == 0 {
+		return "", fmt.Errorf("service account tokens are disabled, no --token-audience configured")
+	}
+
+	audience := c.tokenAudiences[0]
+	if aud, ok := attr[csiapi.ServiceAccountTokenAudienceKey]; ok && len(aud) > 0 {
+		audience = aud
+	}
+
+	tr := &authv1.TokenRequest{
+		Spec: authv1.TokenRequestSpec{
+			Audiences: []string{audience},
+			BoundObjectRef: &authv1.BoundObjectReference{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       attr[csiapi.CSIPodNameKey],
+				UID:        types.UID(attr[csiapi.CSIPodUIDKey]),
+			},
+		},
+	}
+
+	tr, err := c.kubeClient.CoreV1().ServiceAccounts(namespace).CreateToken(saName, tr)
+	if err != nil {
+		err = apiError(err, "create", serviceAccountTokenResource, namespace)
+		if _, ok := APIErrorCode(err); ok {
+			return "", err
+		}
+
+		return "", fmt.Errorf("failed to request token for service account %s/%s: %s",
+			namespace, saName, err)
+	}
+
+	glog.V(4).Infof("cert-manager: requested service account token %s/%s for volume %s expiring %s",
+		namespace, saName, vol.ID, tr.Status.ExpirationTimestamp)
+
+	return tr.Status.Token, nil
+}
+
+// tokenHash returns the SHA-256 hash of a service account token, which
+// identifies the token without disclosing it.
+func tokenHash(token string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(token)))
+}
@@ -0,0 +1,95 @@
+package certmanager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	authv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	coretesting "k8s.io/client-go/testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestCreateNewCertificateServiceAccountToken(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-token-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const token = "test-token"
+
+	kubeClient := kubefake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "serviceaccounts", func(action coretesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+
+		return true, &authv1.TokenRequest{
+			Status: authv1.TokenRequestStatus{Token: token},
+		}, nil
+	})
+
+	cmClient := cmfake.NewSimpleClientset()
+	signOnCreate(t, cmClient)
+
+	c := &CertManager{
+		cmClient:        cmClient,
+		kubeClient:      kubeClient,
+		tokenAudiences:  []string{"vault"},
+		issuanceTimeout: time.Second * 5,
+		clock:           clock.RealClock{},
+	}
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNameKey:            "test-pod",
+			csiapi.CSIPodNamespaceKey:       "test-namespace",
+			csiapi.CSIPodUIDKey:             "test-uid",
+			csiapi.CSIServiceAccountNameKey: "default",
+			csiapi.ServiceAccountTokenKey:   "true",
+			csiapi.IssuerNameKey:            "ca-issuer",
+			csiapi.DNSNamesKey:              "foo.bar",
+			csiapi.CertFileKey:              "crt.pem",
+			csiapi.KeyFileKey:               "key.pem",
+			csiapi.KeyAlgorithmKey:          csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:               "256",
+		},
+	}
+
+	if _, err := c.CreateNewCertificate(context.TODO(), vol, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cr, err := cmClient.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range cr.Annotations {
+		if strings.Contains(v, token) {
+			t.Errorf("expected token to not be set on the CertificateRequest, found in annotation %s", k)
+		}
+	}
+
+	// echo -n test-token | sha256sum
+	expHash := "sha256:4c5dc9b7708905f77f5e5d16316b5dfb425e68cb326dcd55a860e90a7707031e"
+	if got := cr.Annotations[csiapi.ServiceAccountTokenKey]; got != expHash {
+		t.Errorf("unexpected token hash annotation, exp=%s got=%s", expHash, got)
+	}
+
+	if got := cr.Annotations[csiapi.ServiceAccountAnnotationKey]; got != "test-namespace/default" {
+		t.Errorf("unexpected service account annotation, exp=test-namespace/default got=%s", got)
+	}
+}
@@ -0,0 +1,189 @@
+package certmanager
+
+import (
+	"fmt"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// v1GroupVersion is the cert-manager API version that replaced v1alpha2,
+// which is no longer served from cert-manager v1.6. The vendored cert-manager
+// client does not include it, so it is used through the dynamic client,
+// converting to and from the v1alpha2 types the driver works with.
+var v1GroupVersion = schema.GroupVersion{Group: cmapi.SchemeGroupVersion.Group, Version: "v1"}
+
+var (
+	v1CertificateRequests = v1GroupVersion.WithResource("certificaterequests")
+	v1Issuers             = v1GroupVersion.WithResource("issuers")
+	v1ClusterIssuers      = v1GroupVersion.WithResource("clusterissuers")
+)
+
+// certificateRequestClient is the part of the CertificateRequest client the
+// driver uses, implemented for both the v1alpha2 and v1 APIs.
+type certificateRequestClient interface {
+	Create(*cmapi.CertificateRequest) (*cmapi.CertificateRequest, error)
+	Get(name string, options metav1.GetOptions) (*cmapi.CertificateRequest, error)
+	List(opts metav1.ListOptions) (*cmapi.CertificateRequestList, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+}
+
+// usesV1 returns true if DetectAPIVersion selected the v1 API.
+func (c *CertManager) usesV1() bool {
+	return c.apiVersion == v1GroupVersion.String()
+}
+
+// certificateRequests returns the CertificateRequest client of the namespace
+// for the API version served by the cluster.
+func (c *CertManager) certificateRequests(namespace string) certificateRequestClient {
+	if c.usesV1() {
+		return &v1CertificateRequestClient{
+			client: c.dynamicClient.Resource(v1CertificateRequests).Namespace(namespace),
+		}
+	}
+
+	return c.cmClient.CertmanagerV1alpha2().CertificateRequests(namespace)
+}
+
+// getIssuer returns the Issuer, or the ClusterIssuer if the kind is
+// ClusterIssuer, for the API version served by the cluster.
+func (c *CertManager) getIssuer(kind, namespace, name string) (cmapi.GenericIssuer, error) {
+	if !c.usesV1() {
+		if kind == cmapi.ClusterIssuerKind {
+			return c.cmClient.CertmanagerV1alpha2().ClusterIssuers().Get(name, metav1.GetOptions{})
+		}
+
+		return c.cmClient.CertmanagerV1alpha2().Issuers(namespace).Get(name, metav1.GetOptions{})
+	}
+
+	var issuer cmapi.GenericIssuer
+	var obj *unstructured.Unstructured
+	var err error
+	if kind == cmapi.ClusterIssuerKind {
+		issuer = new(cmapi.ClusterIssuer)
+		obj, err = c.dynamicClient.Resource(v1ClusterIssuers).Get(name, metav1.GetOptions{})
+	} else {
+		issuer = new(cmapi.Issuer)
+		obj, err = c.dynamicClient.Resource(v1Issuers).Namespace(namespace).Get(name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the metadata and status, that readiness is checked from, are
+	// converted, as the issuer configurations differ between versions.
+	content := map[string]interface{}{
+		"metadata": obj.Object["metadata"],
+		"status":   obj.Object["status"],
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, issuer); err != nil {
+		return nil, fmt.Errorf("failed to convert %s from %s: %s", kind, v1GroupVersion, err)
+	}
+
+	return issuer, nil
+}
+
+// v1CertificateRequestClient is a CertificateRequest client of the v1 API.
+type v1CertificateRequestClient struct {
+	client dynamic.ResourceInterface
+}
+
+func (v *v1CertificateRequestClient) Create(cr *cmapi.CertificateRequest) (*cmapi.CertificateRequest, error) {
+	obj, err := certificateRequestToV1(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err = v.client.Create(obj, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return certificateRequestFromV1(obj)
+}
+
+func (v *v1CertificateRequestClient) Get(name string, options metav1.GetOptions) (*cmapi.CertificateRequest, error) {
+	obj, err := v.client.Get(name, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return certificateRequestFromV1(obj)
+}
+
+func (v *v1CertificateRequestClient) List(opts metav1.ListOptions) (*cmapi.CertificateRequestList, error) {
+	list, err := v.client.List(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	crs := &cmapi.CertificateRequestList{
+		ListMeta: metav1.ListMeta{
+			ResourceVersion: list.GetResourceVersion(),
+			Continue:        list.GetContinue(),
+		},
+	}
+	for i := range list.Items {
+		cr, err := certificateRequestFromV1(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+
+		crs.Items = append(crs.Items, *cr)
+	}
+
+	return crs, nil
+}
+
+func (v *v1CertificateRequestClient) Delete(name string, options *metav1.DeleteOptions) error {
+	return v.client.Delete(name, options)
+}
+
+// certificateRequestToV1 converts the CertificateRequest to the v1 API, which
+// renamed the csr field of the spec to request.
+func certificateRequestToV1(cr *cmapi.CertificateRequest) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert CertificateRequest to %s: %s", v1GroupVersion, err)
+	}
+
+	renameSpecField(content, "csr", "request")
+
+	obj := &unstructured.Unstructured{Object: content}
+	obj.SetAPIVersion(v1GroupVersion.String())
+	obj.SetKind("CertificateRequest")
+
+	return obj, nil
+}
+
+// certificateRequestFromV1 converts the CertificateRequest of the v1 API to
+// v1alpha2. Fields only in v1 are dropped.
+func certificateRequestFromV1(obj *unstructured.Unstructured) (*cmapi.CertificateRequest, error) {
+	content := obj.DeepCopy().Object
+	renameSpecField(content, "request", "csr")
+
+	cr := new(cmapi.CertificateRequest)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, cr); err != nil {
+		return nil, fmt.Errorf("failed to convert CertificateRequest from %s: %s", v1GroupVersion, err)
+	}
+	cr.TypeMeta = metav1.TypeMeta{}
+
+	return cr, nil
+}
+
+// renameSpecField renames the field of the object's spec, if set.
+func renameSpecField(content map[string]interface{}, from, to string) {
+	spec, ok := content["spec"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if v, ok := spec[from]; ok {
+		delete(spec, from)
+		spec[to] = v
+	}
+}
@@ -0,0 +1,244 @@
+package certmanager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	coretesting "k8s.io/client-go/testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestCertificateRequestV1Conversion(t *testing.T) {
+	cr := &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-id",
+			Namespace: "test-namespace",
+			Labels:    map[string]string{"foo": "bar"},
+		},
+		Spec: cmapi.CertificateRequestSpec{
+			Duration: &metav1.Duration{Duration: time.Hour},
+			IssuerRef: cmmeta.ObjectReference{
+				Name: "test-issuer",
+				Kind: cmapi.IssuerKind,
+			},
+			CSRPEM: []byte("csr"),
+			IsCA:   true,
+			Usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageServerAuth},
+		},
+		Status: cmapi.CertificateRequestStatus{
+			Conditions: []cmapi.CertificateRequestCondition{
+				{Type: cmapi.CertificateRequestConditionReady, Status: cmmeta.ConditionTrue},
+			},
+			Certificate: []byte("cert"),
+			CA:          []byte("ca"),
+		},
+	}
+
+	obj, err := certificateRequestToV1(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if obj.GetAPIVersion() != "cert-manager.io/v1" || obj.GetKind() != "CertificateRequest" {
+		t.Errorf("unexpected type, exp=cert-manager.io/v1 CertificateRequest got=%s %s",
+			obj.GetAPIVersion(), obj.GetKind())
+	}
+
+	if _, ok, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "csr"); ok {
+		t.Error("expected v1 CertificateRequest to not have spec.csr")
+	}
+	if _, ok, _ := unstructured.NestedString(obj.Object, "spec", "request"); !ok {
+		t.Error("expected v1 CertificateRequest to have spec.request")
+	}
+
+	// Fields only in v1 should be dropped.
+	if err := unstructured.SetNestedField(obj.Object, "system:serviceaccount:test-namespace:test", "spec", "username"); err != nil {
+		t.Fatal(err)
+	}
+
+	back, err := certificateRequestFromV1(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cr, back) {
+		t.Errorf("unexpected CertificateRequest after conversion, exp=%+v got=%+v", cr, back)
+	}
+}
+
+func TestCreateNewCertificateV1(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-v1-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	signKey, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	dynamicClient.PrependReactor("create", "certificaterequests", func(action coretesting.Action) (bool, runtime.Object, error) {
+		obj := action.(coretesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if obj.GetAPIVersion() != "cert-manager.io/v1" {
+			t.Errorf("unexpected CertificateRequest API version, exp=cert-manager.io/v1 got=%s", obj.GetAPIVersion())
+		}
+
+		cr, err := certificateRequestFromV1(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signCertificateRequest(t, signKey, cr)
+
+		signed, err := certificateRequestToV1(cr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj.Object = signed.Object
+
+		return false, nil, nil
+	})
+
+	cmClient := cmfake.NewSimpleClientset()
+
+	c := &CertManager{
+		cmClient:        cmClient,
+		dynamicClient:   dynamicClient,
+		apiVersion:      "cert-manager.io/v1",
+		issuanceTimeout: time.Second * 5,
+		clock:           clock.RealClock{},
+	}
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "test-issuer",
+			csiapi.DNSNamesKey:        "foo.bar",
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+			csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:         "256",
+		},
+	}
+
+	keyBundle, err := util.NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := c.CreateNewCertificate(context.TODO(), vol, keyBundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cert.DNSNames, []string{"foo.bar"}) {
+		t.Errorf("unexpected certificate dns names, exp=[foo.bar] got=%v", cert.DNSNames)
+	}
+
+	if actions := cmClient.Actions(); len(actions) > 0 {
+		t.Errorf("expected no calls to the v1alpha2 API, got %v", actions)
+	}
+
+	cr, err := c.certificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !util.CertificateRequestReady(cr) {
+		t.Errorf("expected v1 CertificateRequest to be read as Ready: %+v", cr.Status)
+	}
+
+	if err := c.certificateRequests("test-namespace").Delete("test-id", &metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckIssuerReadyV1(t *testing.T) {
+	newIssuer := func(kind, namespace, status string) *unstructured.Unstructured {
+		issuer := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"selfSigned": map[string]interface{}{},
+			},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":    "Ready",
+						"status":  status,
+						"reason":  "ErrGetKeyPair",
+						"message": "secret not found",
+					},
+				},
+			},
+		}}
+		issuer.SetAPIVersion("cert-manager.io/v1")
+		issuer.SetKind(kind)
+		issuer.SetName("test-issuer")
+		issuer.SetNamespace(namespace)
+		return issuer
+	}
+
+	for name, test := range map[string]struct {
+		objects []runtime.Object
+		kind    string
+		expErr  string
+	}{
+		"a ready Issuer should not error": {
+			objects: []runtime.Object{newIssuer(cmapi.IssuerKind, "test-namespace", "True")},
+			kind:    cmapi.IssuerKind,
+		},
+		"a not ready Issuer should error with its reason": {
+			objects: []runtime.Object{newIssuer(cmapi.IssuerKind, "test-namespace", "False")},
+			kind:    cmapi.IssuerKind,
+			expErr:  `Issuer "test-issuer" not ready: ErrGetKeyPair: secret not found`,
+		},
+		"a ready ClusterIssuer should not error": {
+			objects: []runtime.Object{newIssuer(cmapi.ClusterIssuerKind, "", "True")},
+			kind:    cmapi.ClusterIssuerKind,
+		},
+		"a missing ClusterIssuer should error": {
+			kind:   cmapi.ClusterIssuerKind,
+			expErr: `ClusterIssuer "test-issuer" not found`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := &CertManager{
+				cmClient:      cmfake.NewSimpleClientset(),
+				dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), test.objects...),
+				apiVersion:    "cert-manager.io/v1",
+			}
+
+			err := c.checkIssuerReady("test-namespace", cmmeta.ObjectReference{
+				Name: "test-issuer",
+				Kind: test.kind,
+			})
+			if len(test.expErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != test.expErr {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package certmanager
+
+import (
+	"fmt"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+)
+
+// legacyGroupName is the API group of cert-manager releases before v0.11.
+const legacyGroupName = "certmanager.k8s.io"
+
+// DetectAPIVersion checks that the cluster serves a cert-manager API version
+// the driver supports, and returns it. v1alpha2 is used if served, by
+// cert-manager v0.11 to v1.5, otherwise v1 through the dynamic client. The
+// returned error names the required versions and the versions found, if any.
+func (c *CertManager) DetectAPIVersion() (string, error) {
+	required := cmapi.SchemeGroupVersion
+
+	groups, err := c.cmClient.Discovery().ServerGroups()
+	if err != nil {
+		return "", fmt.Errorf("failed to discover API groups: %s", err)
+	}
+
+	var versions, legacyVersions []string
+	for _, group := range groups.Groups {
+		for _, v := range group.Versions {
+			switch group.Name {
+			case required.Group:
+				versions = append(versions, v.Version)
+			case legacyGroupName:
+				legacyVersions = append(legacyVersions, v.Version)
+			}
+		}
+	}
+
+	for _, v := range versions {
+		if v == required.Version {
+			c.apiVersion = required.String()
+			return c.apiVersion, nil
+		}
+	}
+
+	for _, v := range versions {
+		if v == v1GroupVersion.Version {
+			if c.dynamicClient == nil {
+				return "", fmt.Errorf("cert-manager API group %s only serves versions %v, %s requires a dynamic client",
+					required.Group, versions, v1GroupVersion)
+			}
+
+			c.apiVersion = v1GroupVersion.String()
+			return c.apiVersion, nil
+		}
+	}
+
+	switch {
+	case len(versions) > 0:
+		return "", fmt.Errorf("cert-manager API group %s only serves versions %v, %s or %s is required",
+			required.Group, versions, required, v1GroupVersion)
+
+	case len(legacyVersions) > 0:
+		return "", fmt.Errorf("only the legacy cert-manager API group %s is served with versions %v, %s is required (cert-manager v0.11 or later)",
+			legacyGroupName, legacyVersions, required)
+
+	default:
+		return "", fmt.Errorf("cert-manager API group %s not found, is cert-manager installed? %s or %s is required",
+			required.Group, required, v1GroupVersion)
+	}
+}
+
+// APIVersion returns the cert-manager API version detected by
+// DetectAPIVersion, or an empty string if not yet detected.
+func (c *CertManager) APIVersion() string {
+	return c.apiVersion
+}
@@ -0,0 +1,81 @@
+package certmanager
+
+import (
+	"testing"
+
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestDetectAPIVersion(t *testing.T) {
+	for name, test := range map[string]struct {
+		groupVersions []string
+		noDynamic     bool
+		expVersion    string
+		expErr        string
+	}{
+		"if the required version is served then should return it": {
+			groupVersions: []string{"cert-manager.io/v1alpha2", "cert-manager.io/v1alpha3"},
+			expVersion:    "cert-manager.io/v1alpha2",
+		},
+		"if only other versions are served then should error": {
+			groupVersions: []string{"cert-manager.io/v1alpha3"},
+			expErr:        "cert-manager API group cert-manager.io only serves versions [v1alpha3], cert-manager.io/v1alpha2 or cert-manager.io/v1 is required",
+		},
+		"if both v1alpha2 and v1 are served then should return v1alpha2": {
+			groupVersions: []string{"cert-manager.io/v1alpha2", "cert-manager.io/v1"},
+			expVersion:    "cert-manager.io/v1alpha2",
+		},
+		"if only v1 is served then should return it": {
+			groupVersions: []string{"cert-manager.io/v1"},
+			expVersion:    "cert-manager.io/v1",
+		},
+		"if only v1 is served without a dynamic client then should error": {
+			groupVersions: []string{"cert-manager.io/v1"},
+			noDynamic:     true,
+			expErr:        "cert-manager API group cert-manager.io only serves versions [v1], cert-manager.io/v1 requires a dynamic client",
+		},
+		"if only the legacy group is served then should error": {
+			groupVersions: []string{"certmanager.k8s.io/v1alpha1"},
+			expErr:        "only the legacy cert-manager API group certmanager.k8s.io is served with versions [v1alpha1], cert-manager.io/v1alpha2 is required (cert-manager v0.11 or later)",
+		},
+		"if cert-manager is not installed then should error": {
+			groupVersions: nil,
+			expErr:        "cert-manager API group cert-manager.io not found, is cert-manager installed? cert-manager.io/v1alpha2 or cert-manager.io/v1 is required",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := cmfake.NewSimpleClientset()
+			for _, gv := range test.groupVersions {
+				client.Resources = append(client.Resources, &metav1.APIResourceList{
+					GroupVersion: gv,
+				})
+			}
+
+			c := &CertManager{
+				cmClient: client,
+			}
+			if !test.noDynamic {
+				c.dynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			}
+
+			version, err := c.DetectAPIVersion()
+			if len(test.expErr) > 0 {
+				if err == nil || err.Error() != test.expErr {
+					t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if version != test.expVersion || c.APIVersion() != test.expVersion {
+				t.Errorf("unexpected version, exp=%s got=%s (%s)", test.expVersion, version, c.APIVersion())
+			}
+		})
+	}
+}
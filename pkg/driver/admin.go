@@ -0,0 +1,130 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// volumeStatus is the status of a volume, as served at /volumes of the admin
+// endpoint.
+type volumeStatus struct {
+	ID          string     `json:"id"`
+	Pod         string     `json:"pod"`
+	Namespace   string     `json:"namespace"`
+	IssuerName  string     `json:"issuerName"`
+	IssuerKind  string     `json:"issuerKind,omitempty"`
+	Serial      string     `json:"serial,omitempty"`
+	NotAfter    *time.Time `json:"notAfter,omitempty"`
+	Watching    bool       `json:"watching"`
+	NextRenewal *time.Time `json:"nextRenewal,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+// ServeAdmin serves the admin endpoints of the node server on the given
+// address. This function blocks.
+func (ns *NodeServer) ServeAdmin(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/volumes", ns.handleVolumes)
+
+	glog.Infof("admin: serving on %s", addr)
+
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		glog.Errorf("admin: failed to serve: %s", err)
+	}
+}
+
+// handleVolumes responds with the status of every volume on the node as a
+// JSON array.
+func (ns *NodeServer) handleVolumes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	statuses, err := ns.volumeStatuses()
+	if err != nil {
+		glog.Errorf("admin: failed to list volumes: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(statuses); err != nil {
+		glog.Errorf("admin: failed to write volumes response: %s", err)
+	}
+}
+
+// volumeStatuses returns the status of every volume under the data roots,
+// sorted by volume ID.
+func (ns *NodeServer) volumeStatuses() ([]volumeStatus, error) {
+	statuses := []volumeStatus{}
+
+	for _, root := range ns.dataRoots() {
+		files, err := ioutil.ReadDir(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read data dir %s: %s", root, err)
+		}
+
+		for _, f := range files {
+			if !f.IsDir() {
+				continue
+			}
+
+			vol, err := util.ReadMetaDataFile(filepath.Join(root, f.Name(), csiapi.MetaDataFileName))
+			if err != nil {
+				glog.V(4).Infof("admin: skipping %q: %s", f.Name(), err)
+				continue
+			}
+
+			statuses = append(statuses, ns.volumeStatus(vol))
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ID < statuses[j].ID
+	})
+
+	return statuses, nil
+}
+
+// volumeStatus returns the status of the volume from its metadata, the
+// certificate written to it, and the renewer's state of it.
+func (ns *NodeServer) volumeStatus(vol *csiapi.MetaData) volumeStatus {
+	attr := vol.Attributes
+
+	status := volumeStatus{
+		ID:         vol.ID,
+		Pod:        attr[csiapi.CSIPodNameKey],
+		Namespace:  attr[csiapi.CSIPodNamespaceKey],
+		IssuerName: attr[csiapi.IssuerNameKey],
+		IssuerKind: attr[csiapi.IssuerKindKey],
+	}
+
+	// The certificate may not have been issued yet.
+	if certBytes, err := ioutil.ReadFile(util.CertPath(vol)); err == nil {
+		cert, err := util.DecodeCertificate(certBytes, attr[csiapi.EncodingKey])
+		if err == nil {
+			status.Serial = cert.SerialNumber.Text(16)
+			status.NotAfter = &cert.NotAfter
+		}
+	}
+
+	if state, ok := ns.renewer.State(vol.ID); ok {
+		status.Watching = true
+		status.NextRenewal = &state.NextRenewal
+		status.LastError = state.LastError
+	}
+
+	return status
+}
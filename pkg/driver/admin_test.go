@@ -0,0 +1,157 @@
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestHandleVolumes(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-admin-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(0xabc),
+		Subject:      pkix.Name{CommonName: "foo.bar"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newVol := func(id, pod string) *csiapi.MetaData {
+		vol := &csiapi.MetaData{
+			ID:   id,
+			Path: filepath.Join(dir, id),
+			Attributes: map[string]string{
+				csiapi.CSIPodNameKey:      pod,
+				csiapi.CSIPodNamespaceKey: "test-namespace",
+				csiapi.IssuerNameKey:      "test-issuer",
+				csiapi.IssuerKindKey:      "ClusterIssuer",
+				csiapi.CertFileKey:        "crt.pem",
+			},
+		}
+
+		if err := os.MkdirAll(util.MountPath(vol), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := util.WriteMetaDataFile(vol, false, false); err != nil {
+			t.Fatal(err)
+		}
+
+		return vol
+	}
+
+	// An issued and watched volume, and a volume still pending issuance.
+	issued := newVol("csi-issued", "web-0")
+	newVol("csi-pending", "web-1")
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(util.CertPath(issued), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ns := &NodeServer{
+		dataRoot: dir,
+		renewer:  renew.New(dir, nil, nil),
+	}
+
+	if err := ns.renewer.WatchCert(issued, cert.NotBefore, cert.NotAfter); err != nil {
+		t.Fatal(err)
+	}
+	defer ns.renewer.KillWatcher(issued.ID)
+
+	state, _ := ns.renewer.State(issued.ID)
+
+	rec := httptest.NewRecorder()
+	ns.handleVolumes(rec, httptest.NewRequest(http.MethodGet, "/volumes", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code, exp=%d got=%d", http.StatusOK, rec.Code)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type, exp=application/json got=%s", ct)
+	}
+
+	var got []volumeStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []volumeStatus{
+		{
+			ID:          "csi-issued",
+			Pod:         "web-0",
+			Namespace:   "test-namespace",
+			IssuerName:  "test-issuer",
+			IssuerKind:  "ClusterIssuer",
+			Serial:      "abc",
+			NotAfter:    &notAfter,
+			Watching:    true,
+			NextRenewal: &state.NextRenewal,
+		},
+		{
+			ID:         "csi-pending",
+			Pod:        "web-1",
+			Namespace:  "test-namespace",
+			IssuerName: "test-issuer",
+			IssuerKind: "ClusterIssuer",
+		},
+	}
+
+	expJSON, err := json.Marshal(exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(expJSON) != string(gotJSON) {
+		t.Errorf("unexpected volumes,\nexp=%s\ngot=%s", expJSON, gotJSON)
+	}
+
+	rec = httptest.NewRecorder()
+	ns.handleVolumes(rec, httptest.NewRequest(http.MethodPost, "/volumes", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code of POST, exp=%d got=%d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
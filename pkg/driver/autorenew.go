@@ -0,0 +1,44 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+)
+
+// ReloadAutoRenew globally disables or re-enables the renewal of every
+// certificate, as set by --disable-auto-renew or the existence of
+// --disable-auto-renew-file. On error the current setting remains in use.
+func (ns *NodeServer) ReloadAutoRenew() error {
+	disabled, err := autoRenewDisabled(ns.opts)
+	if err != nil {
+		return err
+	}
+
+	ns.renewer.SetAutoRenewDisabled(disabled)
+
+	return nil
+}
+
+// autoRenewDisabled returns true if auto renewal is globally disabled by the
+// given options.
+func autoRenewDisabled(opts *options.Options) (bool, error) {
+	if opts.DisableAutoRenew {
+		return true, nil
+	}
+
+	if len(opts.DisableAutoRenewFile) == 0 {
+		return false, nil
+	}
+
+	_, err := os.Stat(opts.DisableAutoRenewFile)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check disable auto renew file: %s", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,99 @@
+package driver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+)
+
+func TestReloadAutoRenew(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-auto-renew-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "disable-auto-renew")
+
+	for name, test := range map[string]struct {
+		opts       *options.Options
+		createFile bool
+		expDisable bool
+	}{
+		"no flag or file should leave auto renewal enabled": {
+			opts:       new(options.Options),
+			expDisable: false,
+		},
+		"the flag should disable auto renewal": {
+			opts:       &options.Options{DisableAutoRenew: true},
+			expDisable: true,
+		},
+		"an existing file should disable auto renewal": {
+			opts:       &options.Options{DisableAutoRenewFile: file},
+			createFile: true,
+			expDisable: true,
+		},
+		"a missing file should leave auto renewal enabled": {
+			opts:       &options.Options{DisableAutoRenewFile: file},
+			expDisable: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer os.Remove(file)
+
+			if test.createFile {
+				if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ns := &NodeServer{
+				opts:    test.opts,
+				renewer: renew.New(dir, nil, nil),
+			}
+
+			if err := ns.ReloadAutoRenew(); err != nil {
+				t.Fatal(err)
+			}
+
+			if disabled := ns.renewer.AutoRenewDisabled(); disabled != test.expDisable {
+				t.Errorf("unexpected auto renewal disabled, exp=%t got=%t", test.expDisable, disabled)
+			}
+		})
+	}
+
+	// Removing the file and reloading, as on SIGHUP, should re-enable auto
+	// renewal.
+	ns := &NodeServer{
+		opts:    &options.Options{DisableAutoRenewFile: file},
+		renewer: renew.New(dir, nil, nil),
+	}
+
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ns.ReloadAutoRenew(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ns.renewer.AutoRenewDisabled() {
+		t.Fatal("expected auto renewal to be disabled while the file exists")
+	}
+
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ns.ReloadAutoRenew(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ns.renewer.AutoRenewDisabled() {
+		t.Error("expected auto renewal to be re-enabled once the file was removed")
+	}
+}
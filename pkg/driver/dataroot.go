@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// namedDataRoots returns the paths of the given named data roots, sorted by
+// name.
+func namedDataRoots(dataRootMap map[string]string) []string {
+	var names []string
+	for name := range dataRootMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var roots []string
+	for _, name := range names {
+		roots = append(roots, dataRootMap[name])
+	}
+
+	return roots
+}
+
+// dataRoots returns the default data root followed by the named data roots.
+func (ns *NodeServer) dataRoots() []string {
+	return append([]string{ns.dataRoot}, namedDataRoots(ns.dataRootMap)...)
+}
+
+// selectDataRoot returns the path of the named data root, or the default data
+// root if the name is empty. Named data roots are provided by the operator, so
+// must already exist and be writable.
+func (ns *NodeServer) selectDataRoot(name string) (string, error) {
+	if len(name) == 0 {
+		return ns.dataRoot, nil
+	}
+
+	root, ok := ns.dataRootMap[name]
+	if !ok {
+		return "", fmt.Errorf("unknown data root %q", name)
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("data root %q: %s", name, err)
+	}
+
+	if !info.IsDir() {
+		return "", fmt.Errorf("data root %q: %s is not a directory", name, root)
+	}
+
+	f, err := ioutil.TempFile(root, ".write-check-")
+	if err != nil {
+		return "", fmt.Errorf("data root %q is not writable: %s", name, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	return root, nil
+}
+
+// volumePath returns the directory of the volume with the given ID, in
+// whichever data root it was created. The directory in the default data root
+// is returned if it doesn't exist in any.
+func (ns *NodeServer) volumePath(volumeID string) string {
+	for _, root := range ns.dataRoots() {
+		path := filepath.Join(root, volumeID)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return filepath.Join(ns.dataRoot, volumeID)
+}
@@ -0,0 +1,119 @@
+package driver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelectDataRoot(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-data-root-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defaultRoot := filepath.Join(dir, "default")
+	encryptedRoot := filepath.Join(dir, "encrypted")
+	if err := os.Mkdir(encryptedRoot, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	notDir := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(notDir, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ns := &NodeServer{
+		dataRoot: defaultRoot,
+		dataRootMap: map[string]string{
+			"encrypted": encryptedRoot,
+			"missing":   filepath.Join(dir, "missing"),
+			"file":      notDir,
+		},
+	}
+
+	for name, test := range map[string]struct {
+		dataRoot string
+		expRoot  string
+		expErr   bool
+	}{
+		"no data root should select the default": {
+			expRoot: defaultRoot,
+		},
+		"a named data root should be selected": {
+			dataRoot: "encrypted",
+			expRoot:  encryptedRoot,
+		},
+		"an unknown data root should error": {
+			dataRoot: "tmpfs",
+			expErr:   true,
+		},
+		"a data root that doesn't exist should error": {
+			dataRoot: "missing",
+			expErr:   true,
+		},
+		"a data root that isn't a directory should error": {
+			dataRoot: "file",
+			expErr:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			root, err := ns.selectDataRoot(test.dataRoot)
+			if test.expErr != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			if root != test.expRoot {
+				t.Errorf("unexpected data root, exp=%q got=%q", test.expRoot, root)
+			}
+		})
+	}
+
+	files, err := ioutil.ReadDir(encryptedRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 0 {
+		t.Errorf("expected write check to leave no files behind, got %d", len(files))
+	}
+}
+
+func TestVolumePath(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-data-root-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defaultRoot := filepath.Join(dir, "default")
+	encryptedRoot := filepath.Join(dir, "encrypted")
+
+	for _, path := range []string{
+		filepath.Join(defaultRoot, "default-vol"),
+		filepath.Join(encryptedRoot, "encrypted-vol"),
+	} {
+		if err := os.MkdirAll(path, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ns := &NodeServer{
+		dataRoot: defaultRoot,
+		dataRootMap: map[string]string{
+			"encrypted": encryptedRoot,
+		},
+	}
+
+	for volumeID, expPath := range map[string]string{
+		"default-vol":   filepath.Join(defaultRoot, "default-vol"),
+		"encrypted-vol": filepath.Join(encryptedRoot, "encrypted-vol"),
+		"unknown-vol":   filepath.Join(defaultRoot, "unknown-vol"),
+	} {
+		if path := ns.volumePath(volumeID); path != expPath {
+			t.Errorf("unexpected path of %s, exp=%q got=%q", volumeID, expPath, path)
+		}
+	}
+}
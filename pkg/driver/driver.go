@@ -5,27 +5,39 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/golang/glog"
-	"github.com/jetstack/cert-manager-csi/pkg/util"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/status"
-)
 
-const (
-	Version = "0.1.0-alpha.1"
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
 )
 
+// Version is the version of the driver, overridden at build time with
+// -ldflags "-X github.com/jetstack/cert-manager-csi/pkg/driver.Version=...".
+var Version = "0.1.0-alpha.1"
+
 type Driver struct {
-	endpoint string
+	endpoint   string
+	serverOpts []grpc.ServerOption
 
 	ids *identityServer
 	cs  *ControllerServer
 	ns  *NodeServer
 }
 
-func New(driverName, nodeID, endpoint, dataRoot, tmpfsSize string) (*Driver, error) {
-	glog.Infof("driver: %v version: %v", driverName, Version)
+func New(opts *options.Options) (*Driver, error) {
+	glog.Infof("driver: %v version: %v", opts.DriverName, Version)
+
+	if opts.FIPSMode && !util.BoringCrypto {
+		glog.Warningf("driver: FIPS mode enabled but binary was not built with boringcrypto, key generation will not use a FIPS validated module")
+	}
+
+	dataRoot := opts.DataRoot
 
 	mntPoint, err := util.IsLikelyMountPoint(dataRoot)
 	if os.IsNotExist(err) {
@@ -38,32 +50,69 @@ func New(driverName, nodeID, endpoint, dataRoot, tmpfsSize string) (*Driver, err
 	}
 
 	if !mntPoint {
-		execErr := new(bytes.Buffer)
-		cmd := exec.Command("mount", "-F", "tmpfs", "-o", "size="+tmpfsSize+"m", "swap", dataRoot)
-		cmd.Stderr = execErr
-
-		if err := cmd.Run(); err != nil {
-			glog.Errorf("node: failed to mount data root (%s): %s",
-				execErr.String(), err)
+		if err := mountTmpfs(dataRoot, opts.TmpfsSize); err != nil {
+			glog.Errorf("node: failed to mount data root: %s", err)
 			return nil, status.Error(codes.Internal, err.Error())
 		}
 	}
 
-	ns, err := NewNodeServer(nodeID, dataRoot, tmpfsSize)
+	ns, err := NewNodeServer(opts)
 	if err != nil {
 		return nil, err
 	}
 
+	ids := NewIdentityServer(opts.DriverName, Version, ns.Probe)
+	ids.manifest = map[string]string{
+		"cert-manager-api-version": ns.cm.APIVersion(),
+	}
+
 	return &Driver{
-		endpoint: endpoint,
-		ids:      NewIdentityServer(driverName, Version),
-		cs:       NewControllerServer(),
-		ns:       ns,
+		endpoint:   opts.Endpoint,
+		serverOpts: grpcServerOptions(opts),
+		ids:        ids,
+		cs:         NewControllerServer(),
+		ns:         ns,
 	}, nil
 }
 
+// mountTmpfs mounts a tmpfs of the given size in Mbytes at the data root.
+func mountTmpfs(dataRoot, size string) error {
+	execErr := new(bytes.Buffer)
+	cmd := exec.Command("mount", "-F", "tmpfs", "-o", "size="+size+"m", "swap", dataRoot)
+	cmd.Stderr = execErr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s (%s)", err, strings.TrimSpace(execErr.String()))
+	}
+
+	return nil
+}
+
+// grpcServerOptions returns the gRPC server options configured by the given
+// driver options.
+func grpcServerOptions(opts *options.Options) []grpc.ServerOption {
+	var serverOpts []grpc.ServerOption
+
+	if opts.GRPCMaxRecvMsgSize > 0 {
+		serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(opts.GRPCMaxRecvMsgSize))
+	}
+
+	if opts.GRPCMaxSendMsgSize > 0 {
+		serverOpts = append(serverOpts, grpc.MaxSendMsgSize(opts.GRPCMaxSendMsgSize))
+	}
+
+	if opts.GRPCKeepaliveTime > 0 {
+		serverOpts = append(serverOpts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    opts.GRPCKeepaliveTime,
+			Timeout: opts.GRPCKeepaliveTimeout,
+		}))
+	}
+
+	return serverOpts
+}
+
 func (d *Driver) Run() {
-	s := NewNonBlockingGRPCServer()
+	s := NewNonBlockingGRPCServer(d.serverOpts...)
 	s.Start(d.endpoint, d.ids, d.cs, d.ns)
 	s.Wait()
 }
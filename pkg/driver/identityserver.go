@@ -1,22 +1,47 @@
 package driver
 
 import (
+	"sync"
+	"time"
+
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/golang/glog"
+	"github.com/golang/protobuf/ptypes/wrappers"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/clock"
 )
 
+// probeCacheDuration is how long the result of a probe is reused for, so that
+// rapid Probe calls don't hammer the API.
+const probeCacheDuration = time.Second * 5
+
+// ProbeFunc returns an error if the driver is not ready to serve requests.
+type ProbeFunc func() error
+
 type identityServer struct {
 	name    string
 	version string
+	probe   ProbeFunc
+
+	// manifest is returned with the plugin info.
+	manifest map[string]string
+
+	clock clock.Clock
+
+	// result of the last probe, and when it was made
+	muProbe   sync.Mutex
+	probeErr  error
+	probeTime time.Time
 }
 
-func NewIdentityServer(name, version string) *identityServer {
+func NewIdentityServer(name, version string, probe ProbeFunc) *identityServer {
 	return &identityServer{
 		name:    name,
 		version: version,
+		probe:   probe,
+		clock:   clock.RealClock{},
 	}
 }
 
@@ -34,11 +59,40 @@ func (ids *identityServer) GetPluginInfo(ctx context.Context, req *csi.GetPlugin
 	return &csi.GetPluginInfoResponse{
 		Name:          ids.name,
 		VendorVersion: ids.version,
+		Manifest:      ids.manifest,
 	}, nil
 }
 
+// Probe reports the driver as not ready while the probe fails, so that
+// kubelet backs off during outages.
 func (ids *identityServer) Probe(ctx context.Context, req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
-	return &csi.ProbeResponse{}, nil
+	if err := ids.cachedProbe(); err != nil {
+		glog.Errorf("identity: probe failed: %s", err)
+		return &csi.ProbeResponse{Ready: &wrappers.BoolValue{Value: false}}, nil
+	}
+
+	return &csi.ProbeResponse{Ready: &wrappers.BoolValue{Value: true}}, nil
+}
+
+// cachedProbe returns the result of the last probe if it was made within
+// probeCacheDuration, otherwise probes again.
+func (ids *identityServer) cachedProbe() error {
+	if ids.probe == nil {
+		return nil
+	}
+
+	ids.muProbe.Lock()
+	defer ids.muProbe.Unlock()
+
+	now := ids.clock.Now()
+	if !ids.probeTime.IsZero() && now.Sub(ids.probeTime) < probeCacheDuration {
+		return ids.probeErr
+	}
+
+	ids.probeErr = ids.probe()
+	ids.probeTime = now
+
+	return ids.probeErr
 }
 
 func (ids *identityServer) GetPluginCapabilities(ctx context.Context, req *csi.GetPluginCapabilitiesRequest) (*csi.GetPluginCapabilitiesResponse, error) {
@@ -0,0 +1,86 @@
+package driver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/net/context"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestProbe(t *testing.T) {
+	tests := map[string]struct {
+		probe    ProbeFunc
+		expReady bool
+	}{
+		"if no probe func then ready": {
+			probe:    nil,
+			expReady: true,
+		},
+		"if probe succeeds then ready": {
+			probe:    func() error { return nil },
+			expReady: true,
+		},
+		"if probe fails then not ready": {
+			probe:    func() error { return errors.New("connection refused") },
+			expReady: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ids := NewIdentityServer("csi.cert-manager.io", "v0.0.0", test.probe)
+
+			resp, err := ids.Probe(context.TODO(), &csi.ProbeRequest{})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if ready := resp.GetReady().GetValue(); ready != test.expReady {
+				t.Errorf("unexpected ready, exp=%t got=%t", test.expReady, ready)
+			}
+		})
+	}
+}
+
+func TestProbeCache(t *testing.T) {
+	var calls int
+	probeErr := errors.New("connection refused")
+
+	ids := NewIdentityServer("csi.cert-manager.io", "v0.0.0", func() error {
+		calls++
+		return probeErr
+	})
+
+	fakeClock := clock.NewFakeClock(time.Now())
+	ids.clock = fakeClock
+
+	probe := func(expReady bool, expCalls int) {
+		resp, err := ids.Probe(context.TODO(), &csi.ProbeRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if ready := resp.GetReady().GetValue(); ready != expReady {
+			t.Errorf("unexpected ready, exp=%t got=%t", expReady, ready)
+		}
+
+		if calls != expCalls {
+			t.Errorf("unexpected number of probes, exp=%d got=%d", expCalls, calls)
+		}
+	}
+
+	probe(false, 1)
+
+	// The failed result should be reused within the cache duration, even once
+	// the API has recovered.
+	probeErr = nil
+	fakeClock.Step(probeCacheDuration - time.Second)
+	probe(false, 1)
+
+	fakeClock.Step(time.Second)
+	probe(true, 2)
+	probe(true, 2)
+}
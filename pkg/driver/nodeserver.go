@@ -1,18 +1,26 @@
 package driver
 
 import (
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/golang/glog"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/wait"
 
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
 	"github.com/jetstack/cert-manager-csi/pkg/apis/defaults"
 	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
 	"github.com/jetstack/cert-manager-csi/pkg/apis/validation"
@@ -29,32 +37,235 @@ const (
 	deviceIDKey = "deviceID"
 )
 
+// asyncIssuanceRetryPeriod is the period between failed attempts to issue the
+// certificate of a volume published with async issuance.
+var asyncIssuanceRetryPeriod = time.Second * 10
+
+// mountBackoff is the backoff used when retrying failed mounts.
+var mountBackoff = wait.Backoff{
+	Duration: time.Millisecond * 100,
+	Factor:   2,
+	Steps:    4,
+}
+
 type NodeServer struct {
 	nodeID   string
 	dataRoot string
 
-	cm      *certmanager.CertManager
-	renewer *renew.Renewer
+	// named data roots volumes may select instead of dataRoot
+	dataRootMap map[string]string
+
+	opts *options.Options
+
+	cm       *certmanager.CertManager
+	renewer  *renew.Renewer
+	profiles *defaults.ProfileLoader
+	policy   *validation.IssuerPolicyLoader
+
+	sanPolicy *validation.SANPolicyLoader
+
+	// renders the names of volumes, built as before if nil
+	volumeNameTemplate *template.Template
+
+	mount   func(source, target string, options []string) error
+	unmount func(target string) error
+
+	// re-mounts the tmpfs of dataRoot, nil if the data root is not mounted
+	// by the driver
+	mountDataRoot func(dataRoot string) error
+
+	// volume directories whose deletion has been deferred by the unpublish
+	// grace period, keyed by volume ID
+	pendingDeletesMu sync.Mutex
+	pendingDeletes   map[string]*time.Timer
 }
 
-func NewNodeServer(nodeID, dataRoot, tmpfsSize string) (*NodeServer, error) {
-	cm, err := certmanager.New()
+func NewNodeServer(opts *options.Options) (*NodeServer, error) {
+	cm, err := certmanager.New(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail fast rather than failing every mount if the cluster doesn't serve
+	// the cert-manager API version the driver uses.
+	apiVersion, err := cm.DetectAPIVersion()
 	if err != nil {
 		return nil, err
 	}
 
-	renewer := renew.New(dataRoot, cm.RenewCertificate)
+	glog.Infof("cert-manager: using API version %s", apiVersion)
+
+	renewer := renew.New(opts.DataRoot, cm.RenewCertificate, cm.FetchCA)
+	renewer.SetRetryMaxBackoff(opts.RenewRetryMaxBackoff)
+	renewer.SetDiscoverConcurrency(opts.DiscoverConcurrency)
+	renewer.SetFsyncFiles(opts.FsyncFiles)
+	renewer.SetAutoRenewBeforeFraction(opts.AutoRenewBeforeFraction)
+	for _, root := range namedDataRoots(opts.DataRootMap) {
+		renewer.AddDataDir(root)
+	}
+
+	// Renewals of discovered volumes are held from the start if disabled.
+	disabled, err := autoRenewDisabled(opts)
+	if err != nil {
+		return nil, err
+	}
+	renewer.SetAutoRenewDisabled(disabled)
 
-	if err := renewer.Discover(); err != nil {
+	// Wait for existing volumes to be watched for renewal before serving, so
+	// that they are not missed if discovery fails transiently at boot.
+	if err := renewer.DiscoverWithRetry(opts.DiscoverTimeout); err != nil {
 		glog.Errorf("renewer: %s", err)
 	}
 
-	return &NodeServer{
-		nodeID:   nodeID,
-		dataRoot: dataRoot,
+	if opts.ReissueOnRequestDeletion {
+		cm.WatchCertificateRequestDeletions(wait.NeverStop, func(name string) {
+			// CertificateRequests are named after the volume ID
+			if renewer.RenewNow(name) {
+				glog.Infof("node: CertificateRequest for volume %s deleted, re-issuing", name)
+			}
+		})
+	}
+
+	var profiles *defaults.ProfileLoader
+	if len(opts.ProfilesFile) > 0 {
+		profiles, err = defaults.NewProfileLoader(opts.ProfilesFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var policy *validation.IssuerPolicyLoader
+	if len(opts.IssuerPolicyFile) > 0 {
+		policy, err = validation.NewIssuerPolicyLoader(opts.IssuerPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var sanPolicy *validation.SANPolicyLoader
+	if len(opts.SANPolicyFile) > 0 {
+		sanPolicy, err = validation.NewSANPolicyLoader(opts.SANPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var volumeNameTemplate *template.Template
+	if len(opts.VolumeNameTemplate) > 0 {
+		volumeNameTemplate, err = util.ParseVolumeNameTemplate(opts.VolumeNameTemplate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ns := &NodeServer{
+		nodeID:   opts.NodeID,
+		dataRoot: opts.DataRoot,
+		opts:     opts,
 		renewer:  renewer,
 		cm:       cm,
-	}, nil
+		profiles: profiles,
+		policy:   policy,
+		mount:    util.Mount,
+		unmount:  util.Unmount,
+
+		mountDataRoot: func(dataRoot string) error {
+			return mountTmpfs(dataRoot, opts.TmpfsSize)
+		},
+
+		sanPolicy:          sanPolicy,
+		volumeNameTemplate: volumeNameTemplate,
+		dataRootMap:        opts.DataRootMap,
+	}
+
+	// Give kubelet time to re-publish volumes of running pods before
+	// removing any left behind directories.
+	if opts.OrphanGCGrace > 0 {
+		start := time.Now()
+		time.AfterFunc(opts.OrphanGCGrace, func() {
+			if err := ns.collectOrphans(start); err != nil {
+				glog.Errorf("node: failed to collect orphaned volumes: %s", err)
+			}
+		})
+	}
+
+	if opts.PrewarmCertificateRequests {
+		cm.WatchNodePods(wait.NeverStop, opts.NodeID, ns.prewarmPod, ns.forgetPod)
+	}
+
+	if opts.ReconcileAttributesInterval > 0 {
+		go wait.Until(func() {
+			if err := ns.reconcileAttributes(); err != nil {
+				glog.Errorf("node: failed to reconcile volume attributes: %s", err)
+			}
+		}, opts.ReconcileAttributesInterval, wait.NeverStop)
+	}
+
+	if opts.ReconcileFilePermsInterval > 0 {
+		go wait.Until(func() {
+			if err := ns.reconcileFilePermissions(); err != nil {
+				glog.Errorf("node: failed to reconcile volume file permissions: %s", err)
+			}
+		}, opts.ReconcileFilePermsInterval, wait.NeverStop)
+	}
+
+	if opts.SelfHealMounts {
+		go wait.Until(func() {
+			if err := ns.healMounts(); err != nil {
+				glog.Errorf("node: failed to heal volume mounts: %s", err)
+			}
+		}, selfHealMountsInterval, wait.NeverStop)
+	}
+
+	if opts.PodIPCheckInterval > 0 {
+		go wait.Until(func() {
+			if err := ns.checkPodIPs(); err != nil {
+				glog.Errorf("node: failed to check pod ips of volumes: %s", err)
+			}
+		}, opts.PodIPCheckInterval, wait.NeverStop)
+	}
+
+	return ns, nil
+}
+
+// Probe returns an error if existing volumes have not yet been discovered,
+// or the cert-manager API cannot be reached.
+func (ns *NodeServer) Probe() error {
+	if !ns.renewer.Discovered() {
+		return errors.New("discovery of existing volumes has not yet succeeded")
+	}
+
+	return ns.cm.Probe()
+}
+
+// ReloadProfiles reloads the profiles file, if configured. On error the
+// previously loaded profiles remain in use.
+func (ns *NodeServer) ReloadProfiles() error {
+	if ns.profiles == nil {
+		return nil
+	}
+
+	return ns.profiles.Reload()
+}
+
+// ReloadIssuerPolicy reloads the issuer policy file, if configured. On error
+// the previously loaded policy remains in use.
+func (ns *NodeServer) ReloadIssuerPolicy() error {
+	if ns.policy == nil {
+		return nil
+	}
+
+	return ns.policy.Reload()
+}
+
+// ReloadSANPolicy reloads the SAN policy file, if configured. On error the
+// previously loaded policy remains in use.
+func (ns *NodeServer) ReloadSANPolicy() error {
+	if ns.sanPolicy == nil {
+		return nil
+	}
+
+	return ns.sanPolicy.Reload()
 }
 
 func (ns *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
@@ -65,85 +276,461 @@ func (ns *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	attr, err := defaults.SetDefaultAttributes(attr)
-	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+	if namespace := attr[csiapi.CSIPodNamespaceKey]; !ns.watchingNamespace(namespace) {
+		return nil, status.Error(codes.PermissionDenied,
+			fmt.Sprintf("namespace %q is not served by this driver", namespace))
 	}
 
-	if err := validation.ValidateAttributes(attr); err != nil {
-		return nil, status.Error(codes.InvalidArgument, err.Error())
+	published := attr
+
+	attr, err := ns.resolveAttributes(published)
+	if err != nil {
+		return nil, err
 	}
 
 	volID := req.GetVolumeId()
+
+	// Stop a volume republished within the unpublish grace period from being
+	// removed, so that its materials may be reused.
+	republished := ns.cancelPendingDelete(volID)
+
 	vol, err := ns.createVolume(volID, targetPath, attr)
 	if err != nil && !os.IsExist(err) {
 		glog.Error("node: failed to create volume: ", err)
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	glog.Infof("node: created volume: %s", vol.Path)
+	glog.Infof("node: created volume %s: %s", vol.Name, vol.Path)
+
+	if len(published[csiapi.AttributesConfigMapKey]) > 0 {
+		vol.PublishAttributes = published
+	}
+
+	// If the volume is already mounted with the same attributes and holds a
+	// valid certificate, then this is a re-publish and there is nothing to
+	// issue.
+	if mntPoint, err := util.IsLikelyMountPoint(targetPath); err == nil && mntPoint {
+		published, err := ns.republishMounted(vol)
+		if err != nil {
+			return nil, err
+		}
+
+		if published {
+			return &csi.NodePublishVolumeResponse{}, nil
+		}
+	}
+
+	if republished {
+		if cert, ok := existingCertificate(vol); ok {
+			glog.Infof("node: volume %s republished within unpublish grace, reusing existing certificate", vol.ID)
+
+			if err := ns.watchCert(vol, cert); err != nil {
+				return nil, err
+			}
+
+			if err := util.WriteMetaDataFile(vol, ns.opts.MetadataInMount, ns.opts.FsyncFiles); err != nil {
+				return nil, fmt.Errorf("failed to write metadata file: %s", err)
+			}
+
+			if _, err := ns.mountVolume(vol, targetPath); err != nil {
+				return nil, err
+			}
+
+			return &csi.NodePublishVolumeResponse{}, nil
+		}
+
+		glog.Infof("node: volume %s republished within unpublish grace but certificate is missing or expired, re-issuing", vol.ID)
+	}
 
 	glog.Infof("node: creating key/cert pair with cert-manager: %s", vol.Path)
 
-	keyBundle, err := util.NewRSAKey()
-	if err != nil {
+	// With async issuance the volume is mounted straight away, and the files
+	// are written once the certificate has been issued.
+	asyncIssuance := attr[csiapi.AsyncIssuanceKey] == "true"
+
+	// Async issuance needs a key up front so that it is kept across retries
+	// and may be used for the bootstrap certificate. Otherwise, a key is only
+	// generated if an existing request can't be reused. No key is generated
+	// if it is held by the workload or the signer plugin.
+	var keyBundle *util.KeyBundle
+	if asyncIssuance && util.WritesPrivateKey(vol) {
+		keyBundle, err = ns.cm.NewKey(vol)
+		if err != nil {
+			return nil, certManagerStatus(err, "failed to generate private key")
+		}
+	}
+
+	if !asyncIssuance {
+		cert, err := ns.cm.CreateNewCertificate(ctx, vol, nil)
+		if err != nil {
+			return nil, certManagerStatus(err, "failed to create new certificate")
+		}
+
+		if err := ns.watchCert(vol, cert); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := util.WriteMetaDataFile(vol, ns.opts.MetadataInMount, ns.opts.FsyncFiles); err != nil {
+		return nil, fmt.Errorf("failed to write metadata file: %s", err)
+	}
+
+	if asyncIssuance && attr[csiapi.BootstrapSelfSignedKey] == "true" {
+		if err := ns.writeBootstrapCertificate(vol, keyBundle); err != nil {
+			return nil, status.Error(codes.Internal,
+				fmt.Sprintf("failed to write bootstrap certificate: %s", err))
+		}
+	}
+
+	// A volume that was already mounted is being re-issued, so is issued
+	// asynchronously all the same.
+	if _, err := ns.mountVolume(vol, targetPath); err != nil {
 		return nil, err
 	}
 
-	cert, err := ns.cm.CreateNewCertificate(vol, keyBundle)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create new certificate: %s", err)
+	if asyncIssuance {
+		// Registered before returning, so that an unpublish straight after
+		// always stops the issuance.
+		stopCh, done := ns.renewer.StartIssuance(vol.ID)
+		go func() {
+			defer done()
+			ns.issueAsync(vol, keyBundle, stopCh)
+		}()
 	}
 
-	if s, ok := attr[csiapi.DisableAutoRenewKey]; !ok || s != "true" {
-		if err := ns.renewer.WatchCert(vol, cert.NotAfter); err != nil {
-			return nil, fmt.Errorf("failed to watch file %s:%s:%s: %s",
-				attr[csiapi.CSIPodNamespaceKey], attr[csiapi.CSIPodNameKey], vol.ID, err)
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// republishMounted handles the re-publish of a volume whose target path is
+// already mounted. Returns true if the volume was published with the same
+// attributes as stored in its metadata and holds a valid certificate, in which
+// case there is nothing to do other than making sure it is watched. Otherwise
+// its watcher is stopped and false is returned, so that its certificate is
+// re-issued and its files rewritten.
+func (ns *NodeServer) republishMounted(vol *csiapi.MetaData) (bool, error) {
+	if existing, err := util.ReadMetaDataFile(util.MetaDataPath(vol)); err == nil {
+		var changed []string
+		for k := range keys(existing.Attributes, vol.Attributes) {
+			if existing.Attributes[k] != vol.Attributes[k] {
+				changed = append(changed, k)
+			}
+		}
+
+		if len(changed) > 0 {
+			sort.Strings(changed)
+			glog.Infof("node: volume %s already published but republished with changed attributes %s, re-issuing",
+				vol.ID, strings.Join(changed, ", "))
+
+			ns.renewer.KillWatcher(vol.ID)
+
+			return false, nil
 		}
 	}
 
-	if err := util.WriteMetaDataFile(vol); err != nil {
-		return nil, fmt.Errorf("failed to write metadata file: %s", err)
+	cert, ok := existingCertificate(vol)
+	if !ok {
+		glog.Infof("node: volume %s already published but certificate is missing or expired, re-issuing", vol.ID)
+		ns.renewer.KillWatcher(vol.ID)
+
+		return false, nil
+	}
+
+	glog.Infof("node: volume %s already published with a valid certificate", vol.ID)
+
+	if !ns.renewer.IsWatching(vol.ID) {
+		if err := ns.watchCert(vol, cert); err != nil {
+			return false, err
+		}
 	}
 
+	return true, nil
+}
+
+// mountVolume read only bind mounts the directory of the volume to its target
+// path, creating both if needed. Returns false if the target path was already
+// mounted. The volume is cleaned up if mounting fails.
+func (ns *NodeServer) mountVolume(vol *csiapi.MetaData, targetPath string) (bool, error) {
 	mountPath := util.MountPath(vol)
 
 	mntPoint, err := util.IsLikelyMountPoint(targetPath)
 	if os.IsNotExist(err) {
-		if err = os.MkdirAll(targetPath, 0700); err != nil {
-			return nil, status.Error(codes.Internal,
+		if err = os.MkdirAll(targetPath, ns.opts.TargetPathPermissions); err != nil {
+			return false, status.Error(codes.Internal,
 				fmt.Sprintf("failed to create target path directory %s: %s", targetPath, err))
 		}
 
+		// MkdirAll is subject to the umask, so set the mode explicitly.
+		if err = os.Chmod(targetPath, ns.opts.TargetPathPermissions); err != nil {
+			return false, status.Error(codes.Internal,
+				fmt.Sprintf("failed to set permissions of target path directory %s: %s", targetPath, err))
+		}
+
 		mntPoint = false
 	}
 
-	if err = os.MkdirAll(mountPath, 0700); err != nil {
-		return nil, status.Error(codes.Internal,
+	if err = os.MkdirAll(mountPath, ns.opts.DirPermissions); err != nil {
+		return false, status.Error(codes.Internal,
 			fmt.Sprintf("failed to create mount path directory %s: %s", mountPath, err))
 	}
 
-	// we are already mounted so assume certs have to be written
 	if mntPoint {
-		return &csi.NodePublishVolumeResponse{}, nil
+		return false, nil
 	}
 
 	glog.V(4).Infof("node: publish volume request ~ target:%v volumeId:%v attributes:%v",
-		targetPath, volID, attr)
+		targetPath, vol.ID, vol.Attributes)
 
-	if err := util.Mount(mountPath, targetPath, []string{"ro"}); err != nil {
-		if rmErr := os.RemoveAll(vol.Path); rmErr != nil && !os.IsNotExist(rmErr) {
-			err = fmt.Errorf("failed to remove all from %s: %s,%s", vol.Path, err, rmErr)
+	if err := ns.mountWithRetry(mountPath, targetPath); err != nil {
+		if cleanErr := ns.cleanupVolume(vol); cleanErr != nil {
+			err = fmt.Errorf("%s, %s", err, cleanErr)
 		}
 
-		return nil, status.Error(codes.Internal,
+		return false, status.Error(codes.Internal,
 			fmt.Sprintf("failed to mount path %s -> %s: %s", mountPath, targetPath, err))
 	}
 
 	glog.V(2).Infof("node: mount successful %s:%s:%s",
-		attr[csiapi.CSIPodNamespaceKey], attr[csiapi.CSIPodNameKey], vol.ID)
+		vol.Attributes[csiapi.CSIPodNamespaceKey], vol.Attributes[csiapi.CSIPodNameKey], vol.ID)
 
-	return &csi.NodePublishVolumeResponse{}, nil
+	return true, nil
+}
+
+// resolveAttributes returns the attributes of a volume published with the
+// given attributes, after applying the overrides of its attributes ConfigMap,
+// its profile and defaults. The published attributes are not modified.
+func (ns *NodeServer) resolveAttributes(published map[string]string) (map[string]string, error) {
+	attr := make(map[string]string, len(published))
+	for k, v := range published {
+		attr[k] = v
+	}
+
+	if name := published[csiapi.AttributesConfigMapKey]; len(name) > 0 {
+		if err := ns.applyAttributesConfigMap(attr, name); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	if ns.profiles != nil {
+		var err error
+		attr, err = ns.profiles.Profiles().Apply(attr)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	attr, err := defaults.SetDefaultAttributes(attr, ns.opts)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Always record the node the volume is published on so that node scoped
+	// issuers may use it.
+	attr[csiapi.NodeIDKey] = ns.nodeID
+
+	if err := validation.ValidateAttributes(attr, ns.opts); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := validation.CheckCertificateType(attr, ns.opts.AllowedCertificateTypes); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if ns.policy != nil {
+		if err := ns.policy.Policy().Check(attr); err != nil {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+
+	if ns.sanPolicy != nil {
+		if err := ns.sanPolicy.Policy().Check(attr); err != nil {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+
+	if ns.opts.EnforcePodIdentitySANs {
+		if err := validation.CheckPodIdentitySANs(attr, ns.opts.ClusterDomain); err != nil {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+
+	return attr, nil
+}
+
+// mountWithRetry read only bind mounts the source to the target with the
+// configured mount options, retrying with backoff on transient mount errors.
+func (ns *NodeServer) mountWithRetry(source, target string) error {
+	var mountErr error
+
+	err := wait.ExponentialBackoff(mountBackoff, func() (bool, error) {
+		mountErr = ns.mount(source, target, ns.opts.MountOptions)
+		if mountErr != nil {
+			glog.Errorf("node: failed to mount %s -> %s, retrying: %s",
+				source, target, mountErr)
+			return false, nil
+		}
+
+		return true, nil
+	})
+
+	if err == wait.ErrWaitTimeout {
+		return mountErr
+	}
+
+	return err
+}
+
+// cleanupVolume removes everything created for a volume, so that a retried
+// publish starts clean. It stops the renewal watcher, removes the volume
+// directory and deletes the CertificateRequest of the volume. Used when a
+// publish fails and when a volume is unpublished.
+func (ns *NodeServer) cleanupVolume(vol *csiapi.MetaData) error {
+	var errs []string
+
+	ns.renewer.KillWatcher(vol.ID)
+	ns.cm.StopKeyDelivery(vol.ID)
+
+	if err := os.RemoveAll(vol.Path); err != nil && !os.IsNotExist(err) {
+		errs = append(errs, fmt.Sprintf("failed to remove all from %s: %s", vol.Path, err))
+	}
+
+	// The namespace is unknown if the metadata file could not be read, in
+	// which case the request is left to be garbage collected with its pod.
+	if len(vol.Attributes[csiapi.CSIPodNamespaceKey]) > 0 {
+		if err := ns.cm.DeleteCertificateRequest(vol); err != nil {
+			errs = append(errs, err.Error())
+		}
+	} else if !ns.cm.SetsOwnerReference() {
+		glog.Warningf("node: namespace of volume %s unknown, its CertificateRequest must be deleted manually", vol.ID)
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// existingCertificate returns the certificate written to the volume, and true
+// if both it and the private key exist and the certificate has not expired.
+// The private key is not required if it is not written to the volume.
+// Bootstrap certificates are never considered valid.
+func existingCertificate(vol *csiapi.MetaData) (*x509.Certificate, bool) {
+	if util.WritesPrivateKey(vol) {
+		if _, err := os.Stat(util.KeyPath(vol)); err != nil {
+			return nil, false
+		}
+	}
+
+	certBytes, err := ioutil.ReadFile(util.CertPath(vol))
+	if err != nil {
+		return nil, false
+	}
+
+	cert, err := util.DecodeCertificate(certBytes, vol.Attributes[csiapi.EncodingKey])
+	if err != nil {
+		glog.Errorf("node: failed to decode existing certificate of volume %s: %s", vol.ID, err)
+		return nil, false
+	}
+
+	// Bootstrap certificates are placeholders and never count as valid.
+	if cert.Subject.CommonName == util.BootstrapCommonName || time.Now().After(cert.NotAfter) {
+		return nil, false
+	}
+
+	return cert, true
+}
+
+// watchCert starts watching the certificate of the volume for renewal, unless
+// auto renewal has been disabled.
+func (ns *NodeServer) watchCert(vol *csiapi.MetaData, cert *x509.Certificate) error {
+	if s, ok := vol.Attributes[csiapi.DisableAutoRenewKey]; ok && s == "true" {
+		return nil
+	}
+
+	if err := ns.renewer.WatchCert(vol, cert.NotBefore, cert.NotAfter); err != nil {
+		return fmt.Errorf("failed to watch file %s:%s:%s: %s",
+			vol.Attributes[csiapi.CSIPodNamespaceKey], vol.Attributes[csiapi.CSIPodNameKey], vol.ID, err)
+	}
+
+	return nil
+}
+
+// writeBootstrapCertificate writes a self signed placeholder certificate and
+// its key to the volume. They are replaced once the real certificate has been
+// issued.
+func (ns *NodeServer) writeBootstrapCertificate(vol *csiapi.MetaData, keyBundle *util.KeyBundle) error {
+	certPEM, err := util.BootstrapCertificate(vol.Attributes, keyBundle)
+	if err != nil {
+		return err
+	}
+
+	keyBytes, err := util.EncodeVolumeFile(keyBundle.PEM, vol.Attributes)
+	if err != nil {
+		return err
+	}
+
+	certBytes, err := util.EncodeVolumeFile(certPEM, vol.Attributes)
+	if err != nil {
+		return err
+	}
+
+	err = util.WriteDataFiles(vol, map[string][]byte{
+		vol.Attributes[csiapi.KeyFileKey]:  keyBytes,
+		vol.Attributes[csiapi.CertFileKey]: certBytes,
+	}, ns.opts.FsyncFiles)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("node: bootstrap certificate written for volume %s", vol.ID)
+
+	return nil
+}
+
+// issueAsync issues the certificate of an already mounted volume, retrying
+// until it succeeds or stopCh is closed by the volume being unpublished.
+func (ns *NodeServer) issueAsync(vol *csiapi.MetaData, keyBundle *util.KeyBundle, stopCh <-chan struct{}) {
+	// Cancel an in flight request once stopped.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	wait.PollImmediateUntil(asyncIssuanceRetryPeriod, func() (bool, error) {
+		cert, err := ns.cm.CreateNewCertificate(ctx, vol, keyBundle)
+
+		// The volume was unpublished while the request was in flight, so
+		// must not be watched.
+		select {
+		case <-stopCh:
+			return true, nil
+		default:
+		}
+
+		if err != nil {
+			glog.Errorf("node: async issuance of volume %s failed, retrying: %s", vol.ID, err)
+			return false, nil
+		}
+
+		glog.Infof("node: async issuance of volume %s complete", vol.ID)
+
+		if err := ns.watchCert(vol, cert); err != nil {
+			glog.Errorf("node: %s", err)
+		}
+
+		return true, nil
+	}, stopCh)
+
+	select {
+	case <-stopCh:
+		glog.Infof("node: volume %s unpublished, stopping async issuance", vol.ID)
+	default:
+	}
 }
 
 func (ns *NodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
@@ -163,22 +750,56 @@ func (ns *NodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 	ns.renewer.KillWatcher(volumeID)
 
 	// Unmounting the image
-	err := util.Unmount(targetPath)
-	if err != nil {
-		return nil, nil
+	if err := ns.unmount(targetPath); err != nil {
+		return nil, status.Error(codes.Internal,
+			fmt.Sprintf("failed to unmount %s: %s", targetPath, err))
 	}
 	glog.V(4).Infof("node: volume %s/%s has been unmounted.", targetPath, volumeID)
 
 	glog.V(4).Infof("node: deleting volume %s", volumeID)
 
-	path := filepath.Join(ns.dataRoot, volumeID)
-	if err := os.RemoveAll(path); err != nil && !os.IsNotExist(err) {
-		return nil, err
+	path := ns.volumePath(volumeID)
+
+	vol, err := util.ReadMetaDataFile(filepath.Join(path, csiapi.MetaDataFileName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("node: failed to read metadata file of volume %s: %s", volumeID, err)
+		}
+
+		vol = &csiapi.MetaData{
+			ID:   volumeID,
+			Path: path,
+		}
+	}
+
+	if ns.opts.UnpublishGrace > 0 {
+		ns.deferCleanupVolume(vol, ns.opts.UnpublishGrace)
+		return &csi.NodeUnpublishVolumeResponse{}, nil
+	}
+
+	if err := ns.cleanupVolume(vol); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
+// watchingNamespace returns true if pods in the given namespace are served by
+// this driver.
+func (ns *NodeServer) watchingNamespace(namespace string) bool {
+	if len(ns.opts.WatchNamespaces) == 0 {
+		return true
+	}
+
+	for _, n := range ns.opts.WatchNamespaces {
+		if n == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (ns *NodeServer) validateVolumeAttributes(req *csi.NodePublishVolumeRequest) error {
 	var errs []string
 
@@ -223,30 +844,50 @@ func (ns *NodeServer) validateVolumeAttributes(req *csi.NodePublishVolumeRequest
 // path or err if one occurs.
 func (ns *NodeServer) createVolume(id, targetPath string,
 	attr map[string]string) (*csiapi.MetaData, error) {
-	podName := attr[csiapi.CSIPodNameKey]
-
-	name := util.BuildVolumeName(podName, id)
-	path := filepath.Join(ns.dataRoot, id)
+	name, err := util.RenderVolumeName(ns.volumeNameTemplate, attr, id)
+	if err != nil {
+		return nil, err
+	}
 
-	err := os.MkdirAll(path, 0700)
+	root, err := ns.selectDataRoot(attr[csiapi.DataRootKey])
 	if err != nil {
 		return nil, err
 	}
+	path := filepath.Join(root, id)
+
+	if err := os.MkdirAll(path, ns.opts.DirPermissions); err != nil {
+		return nil, err
+	}
 
 	vol := &csiapi.MetaData{
-		ID:         id,
-		Name:       name,
-		Size:       maxStorageCapacity,
-		Path:       path,
-		TargetPath: targetPath,
-		Attributes: attr,
+		ID:          id,
+		Name:        name,
+		Size:        maxStorageCapacity,
+		Path:        path,
+		TargetPath:  targetPath,
+		Attributes:  attr,
+		KeyExternal: len(ns.opts.SignerPlugin) > 0,
 	}
 
-	return vol, nil
-}
+	// Carry over when the private key of an already published volume was
+	// generated.
+	if existing, err := util.ReadMetaDataFile(util.MetaDataPath(vol)); err == nil {
+		vol.IdentityCreated = existing.IdentityCreated
+	}
 
-func (ns *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if ns.opts.AtomicDirLayout {
+		mountPath := util.MountPath(vol)
+
+		if err := os.MkdirAll(mountPath, ns.opts.DirPermissions); err != nil {
+			return nil, err
+		}
+
+		if err := util.InitAtomicLayout(mountPath, ns.opts.FsyncFiles); err != nil {
+			return nil, fmt.Errorf("failed to set up atomic layout: %s", err)
+		}
+	}
+
+	return vol, nil
 }
 
 func (ns *NodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
@@ -257,8 +898,21 @@ func (ns *NodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoReque
 	}, nil
 }
 
+// NodeGetCapabilities advertises STAGE_UNSTAGE_VOLUME, so that the CA of the
+// issuer of staged volumes is cached for the pods publishing them. Inline
+// ephemeral volumes are never staged, so their CA is written per volume.
 func (ns *NodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	return &csi.NodeGetCapabilitiesResponse{
+		Capabilities: []*csi.NodeServiceCapability{
+			{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{
+						Type: csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
+					},
+				},
+			},
+		},
+	}, nil
 }
 
 func (ns *NodeServer) NodeGetVolumeStats(ctx context.Context, in *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
@@ -269,6 +923,20 @@ func (ns *NodeServer) NodeExpandVolume(ctx context.Context, in *csi.NodeExpandVo
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
-func (ns *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+// certManagerStatus returns the gRPC status of an error from cert-manager,
+// prefixed with msg. Errors from the API server, such as the driver lacking
+// RBAC, keep their code so that the cause is clear from the pod's events.
+// Errors of a CertificateRequest name it, so that it may be inspected.
+func certManagerStatus(err error, msg string) error {
+	code, ok := certmanager.APIErrorCode(err)
+	if !ok {
+		code = codes.Internal
+	}
+
+	if namespace, name, ok := certmanager.CertificateRequestOf(err); ok {
+		return status.Error(code, fmt.Sprintf("%s: CertificateRequest %s/%s: %s, inspect with `kubectl get certificaterequest %s -n %s -o yaml`",
+			msg, namespace, name, err, name, namespace))
+	}
+
+	return status.Error(code, fmt.Sprintf("%s: %s", msg, err))
 }
@@ -1,15 +1,41 @@
 package driver
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	coretesting "k8s.io/client-go/testing"
 
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
 	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/apis/validation"
+	"github.com/jetstack/cert-manager-csi/pkg/certmanager"
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
 )
 
 func TestValidateNodeServerAttributes(t *testing.T) {
@@ -136,6 +162,9 @@ func TestCreateDeleteVolume(t *testing.T) {
 
 	ns := &NodeServer{
 		dataRoot: dir,
+		opts: &options.Options{
+			DirPermissions: 0750,
+		},
 	}
 
 	id := "test-id"
@@ -145,12 +174,30 @@ func TestCreateDeleteVolume(t *testing.T) {
 		csiapi.CSIPodNamespaceKey: "test-namespace",
 	}
 
-	_, err = ns.createVolume(id, targetPath, attr)
+	vol, err := ns.createVolume(id, targetPath, attr)
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
+	if exp := "cert-manager-csi-test-pod-test-id"; vol.Name != exp {
+		t.Errorf("unexpected volume name, exp=%s got=%s", exp, vol.Name)
+	}
+
+	ns.volumeNameTemplate, err = util.ParseVolumeNameTemplate("{{.Namespace}}.{{.PodName}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vol, err = ns.createVolume(id, targetPath, attr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := "test-namespace.test-pod"; vol.Name != exp {
+		t.Errorf("unexpected templated volume name, exp=%s got=%s", exp, vol.Name)
+	}
+
 	path := filepath.Join(dir, "test-id")
 
 	t.Logf("expecting path: %s", path)
@@ -168,4 +215,744 @@ func TestCreateDeleteVolume(t *testing.T) {
 		return
 	}
 
+	if perm := f.Mode().Perm(); perm != 0750 {
+		t.Errorf("expected volume directory to have permissions 0750, got %#o",
+			perm)
+	}
+
+}
+
+func TestPublishMountFailureCleanup(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-mount-failure-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dataRoot := filepath.Join(dir, "data")
+	targetPath := filepath.Join(dir, "target")
+
+	defer func(b wait.Backoff) { mountBackoff = b }(mountBackoff)
+	mountBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+
+	cmClient := cmfake.NewSimpleClientset(&cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-id",
+			Namespace: "test-namespace",
+		},
+	})
+
+	cm, err := certmanager.NewWithClient(cmClient, kubefake.NewSimpleClientset(), new(options.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mountCalls int
+	ns := &NodeServer{
+		nodeID:   "test-node",
+		dataRoot: dataRoot,
+		opts: &options.Options{
+			DirPermissions:        0700,
+			TargetPathPermissions: 0700,
+		},
+		cm:      cm,
+		renewer: renew.New(dataRoot, nil, nil),
+		mount: func(source, target string, options []string) error {
+			mountCalls++
+			return errors.New("device or resource busy")
+		},
+	}
+
+	_, err = ns.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{
+		VolumeId:   "test-id",
+		TargetPath: targetPath,
+		VolumeContext: map[string]string{
+			csiapi.CSIPodNameKey:      "test-pod",
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "ca-issuer",
+			csiapi.AsyncIssuanceKey:   "true",
+		},
+		VolumeCapability: &csi.VolumeCapability{},
+	})
+	if err == nil {
+		t.Fatal("expected publish to fail when mount fails")
+	}
+
+	if mountCalls != mountBackoff.Steps {
+		t.Errorf("unexpected number of mount attempts, exp=%d got=%d",
+			mountBackoff.Steps, mountCalls)
+	}
+
+	if _, err := os.Stat(filepath.Join(dataRoot, "test-id")); !os.IsNotExist(err) {
+		t.Errorf("expected volume directory to be removed, got: %v", err)
+	}
+
+	_, err = cmClient.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+	if !k8sErrors.IsNotFound(err) {
+		t.Errorf("expected CertificateRequest to be deleted, got: %v", err)
+	}
+}
+
+func TestCleanupVolumeOwnerReference(t *testing.T) {
+	for name, setOwnerReference := range map[string]bool{
+		"with owner reference the request should be deleted":    true,
+		"without owner reference the request should be deleted": false,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-cleanup-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			cmClient := cmfake.NewSimpleClientset(&cmapi.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-id",
+					Namespace: "test-namespace",
+				},
+			})
+
+			cm, err := certmanager.NewWithClient(cmClient, kubefake.NewSimpleClientset(), &options.Options{
+				SetOwnerReference: setOwnerReference,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ns := &NodeServer{
+				dataRoot: dir,
+				opts:     new(options.Options),
+				cm:       cm,
+				renewer:  renew.New(dir, nil, nil),
+			}
+
+			err = ns.cleanupVolume(&csiapi.MetaData{
+				ID:   "test-id",
+				Path: filepath.Join(dir, "test-id"),
+				Attributes: map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = cmClient.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+			if !k8sErrors.IsNotFound(err) {
+				t.Errorf("expected CertificateRequest to be deleted, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestPublishTargetPathPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-target-path-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dataRoot := filepath.Join(dir, "data")
+	targetPath := filepath.Join(dir, "target")
+
+	cm, err := certmanager.NewWithClient(cmfake.NewSimpleClientset(), kubefake.NewSimpleClientset(), new(options.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns := &NodeServer{
+		nodeID:   "test-node",
+		dataRoot: dataRoot,
+		opts: &options.Options{
+			DirPermissions:        0700,
+			TargetPathPermissions: 0770,
+		},
+		cm:      cm,
+		renewer: renew.New(dataRoot, nil, nil),
+		mount: func(source, target string, options []string) error {
+			return nil
+		},
+	}
+
+	_, err = ns.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{
+		VolumeId:   "test-id",
+		TargetPath: targetPath,
+		VolumeContext: map[string]string{
+			csiapi.CSIPodNameKey:      "test-pod",
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "ca-issuer",
+			csiapi.AsyncIssuanceKey:   "true",
+		},
+		VolumeCapability: &csi.VolumeCapability{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ns.renewer.KillWatcher("test-id")
+
+	f, err := os.Stat(targetPath)
+	if err != nil {
+		t.Fatalf("expected target path to have been created: %s", err)
+	}
+
+	if perm := f.Mode().Perm(); perm != 0770 {
+		t.Errorf("expected target path to have permissions 0770, got %#o", perm)
+	}
+}
+
+func TestPublishWatchNamespaces(t *testing.T) {
+	for name, test := range map[string]struct {
+		watchNamespaces []string
+		namespace       string
+		expWatching     bool
+	}{
+		"no watch namespaces should serve all namespaces": {
+			nil, "test-namespace", true,
+		},
+		"a listed namespace should be served": {
+			[]string{"foo", "test-namespace"}, "test-namespace", true,
+		},
+		"an unlisted namespace should not be served": {
+			[]string{"foo", "bar"}, "test-namespace", false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ns := &NodeServer{
+				opts: &options.Options{
+					WatchNamespaces: test.watchNamespaces,
+				},
+			}
+
+			if watching := ns.watchingNamespace(test.namespace); watching != test.expWatching {
+				t.Errorf("unexpected watching namespace, exp=%t got=%t", test.expWatching, watching)
+			}
+
+			if test.expWatching {
+				return
+			}
+
+			_, err := ns.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{
+				VolumeId:   "test-id",
+				TargetPath: "test-target-path",
+				VolumeContext: map[string]string{
+					csiapi.CSIPodNameKey:      "test-pod",
+					csiapi.CSIPodNamespaceKey: test.namespace,
+					csiapi.IssuerNameKey:      "ca-issuer",
+				},
+				VolumeCapability: &csi.VolumeCapability{},
+			})
+			if code := status.Code(err); code != codes.PermissionDenied {
+				t.Errorf("expected publish to be denied, got code=%s err=%v", code, err)
+			}
+		})
+	}
+}
+
+func TestPublishIssuerPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-issuer-policy-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "policy.json")
+	policyJSON := `{"namespaces": {"test-namespace": [{"name": "allowed-issuer"}]}}`
+	if err := ioutil.WriteFile(path, []byte(policyJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := validation.NewIssuerPolicyLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns := &NodeServer{
+		opts:   new(options.Options),
+		policy: policy,
+	}
+
+	_, err = ns.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{
+		VolumeId:   "test-id",
+		TargetPath: "test-target-path",
+		VolumeContext: map[string]string{
+			csiapi.CSIPodNameKey:      "test-pod",
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "ca-issuer",
+		},
+		VolumeCapability: &csi.VolumeCapability{},
+	})
+	if code := status.Code(err); code != codes.PermissionDenied {
+		t.Errorf("expected publish to be denied, got code=%s err=%v", code, err)
+	}
+}
+
+func TestPublishAllowedCertificateTypes(t *testing.T) {
+	ns := &NodeServer{
+		opts: &options.Options{
+			AllowedCertificateTypes: []string{"client"},
+		},
+	}
+
+	_, err := ns.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{
+		VolumeId:   "test-id",
+		TargetPath: "test-target-path",
+		VolumeContext: map[string]string{
+			csiapi.CSIPodNameKey:      "test-pod",
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "ca-issuer",
+			csiapi.CertificateTypeKey: csiapi.ServerCertificateType,
+		},
+		VolumeCapability: &csi.VolumeCapability{},
+	})
+	if code := status.Code(err); code != codes.PermissionDenied {
+		t.Errorf("expected publish to be denied, got code=%s err=%v", code, err)
+	}
+}
+
+func TestPublishSANPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-san-policy-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "policy.json")
+	policyJSON := `{"namespaces": {"test-namespace": ["test.example.com"]}}`
+	if err := ioutil.WriteFile(path, []byte(policyJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sanPolicy, err := validation.NewSANPolicyLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns := &NodeServer{
+		opts:      new(options.Options),
+		sanPolicy: sanPolicy,
+	}
+
+	_, err = ns.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{
+		VolumeId:   "test-id",
+		TargetPath: "test-target-path",
+		VolumeContext: map[string]string{
+			csiapi.CSIPodNameKey:      "test-pod",
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "ca-issuer",
+			csiapi.DNSNamesKey:        "foo.test.example.com,bank.example.com",
+		},
+		VolumeCapability: &csi.VolumeCapability{},
+	})
+	if code := status.Code(err); code != codes.PermissionDenied {
+		t.Fatalf("expected publish to be denied, got code=%s err=%v", code, err)
+	}
+
+	if msg := status.Convert(err).Message(); !strings.Contains(msg, `"bank.example.com"`) {
+		t.Errorf("expected denial to name the offending dns name, got: %s", msg)
+	}
+}
+
+func TestPublishCertificateRequestForbidden(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-forbidden-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmClient := cmfake.NewSimpleClientset()
+	cmClient.PrependReactor("create", "certificaterequests", func(coretesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8sErrors.NewForbidden(schema.GroupResource{Group: "cert-manager.io", Resource: "certificaterequests"},
+			"test-id", errors.New("rbac"))
+	})
+
+	cm, err := certmanager.NewWithClient(cmClient, kubefake.NewSimpleClientset(), new(options.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns := &NodeServer{
+		nodeID:   "test-node",
+		dataRoot: dir,
+		opts:     new(options.Options),
+		cm:       cm,
+		renewer:  renew.New(dir, nil, nil),
+	}
+
+	_, err = ns.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{
+		VolumeId:   "test-id",
+		TargetPath: filepath.Join(dir, "target"),
+		VolumeContext: map[string]string{
+			csiapi.CSIPodNameKey:      "test-pod",
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "ca-issuer",
+		},
+		VolumeCapability: &csi.VolumeCapability{},
+	})
+	if code := status.Code(err); code != codes.PermissionDenied {
+		t.Errorf("expected publish to be denied, got code=%s err=%v", code, err)
+	}
+}
+
+func TestPublishCertificateRequestFailed(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-failed-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The issuer marks each request as failed as soon as it is created.
+	cmClient := cmfake.NewSimpleClientset()
+	cmClient.PrependReactor("create", "certificaterequests", func(action coretesting.Action) (bool, runtime.Object, error) {
+		cr := action.(coretesting.CreateAction).GetObject().(*cmapi.CertificateRequest)
+		cr.Status.Conditions = []cmapi.CertificateRequestCondition{{
+			Type:    cmapi.CertificateRequestConditionReady,
+			Reason:  "Failed",
+			Message: "issuer rejected request",
+		}}
+		return false, nil, nil
+	})
+
+	cm, err := certmanager.NewWithClient(cmClient, kubefake.NewSimpleClientset(), new(options.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns := &NodeServer{
+		nodeID:   "test-node",
+		dataRoot: dir,
+		opts:     new(options.Options),
+		cm:       cm,
+		renewer:  renew.New(dir, nil, nil),
+	}
+
+	_, err = ns.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{
+		VolumeId:   "test-id",
+		TargetPath: filepath.Join(dir, "target"),
+		VolumeContext: map[string]string{
+			csiapi.CSIPodNameKey:      "test-pod",
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "ca-issuer",
+			csiapi.KeyAlgorithmKey:    csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:         "256",
+		},
+		VolumeCapability: &csi.VolumeCapability{},
+	})
+	if err == nil {
+		t.Fatal("expected publish to fail")
+	}
+
+	for _, exp := range []string{
+		"CertificateRequest test-namespace/test-id",
+		"kubectl get certificaterequest test-id -n test-namespace -o yaml",
+		"issuer rejected request",
+	} {
+		if !strings.Contains(status.Convert(err).Message(), exp) {
+			t.Errorf("expected error to contain %q, got=%v", exp, err)
+		}
+	}
+}
+
+func TestExistingCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genCert := func(cn string, notAfter time.Time) []byte {
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    time.Now().Add(-time.Hour * 2),
+			NotAfter:     notAfter,
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+
+	tests := map[string]struct {
+		cert, key []byte
+		expOK     bool
+	}{
+		"if no files then not ok": {
+			expOK: false,
+		},
+		"if no key then not ok": {
+			cert:  genCert("foo", time.Now().Add(time.Hour)),
+			expOK: false,
+		},
+		"if expired certificate then not ok": {
+			cert:  genCert("foo", time.Now().Add(-time.Hour)),
+			key:   []byte("key"),
+			expOK: false,
+		},
+		"if bootstrap certificate then not ok": {
+			cert:  genCert("bootstrap", time.Now().Add(time.Hour)),
+			key:   []byte("key"),
+			expOK: false,
+		},
+		"if valid certificate and key then ok": {
+			cert:  genCert("foo", time.Now().Add(time.Hour)),
+			key:   []byte("key"),
+			expOK: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-existing-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.CertFileKey: "crt.pem",
+					csiapi.KeyFileKey:  "key.pem",
+				},
+			}
+
+			if err := os.MkdirAll(filepath.Join(dir, "data"), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			for path, data := range map[string][]byte{
+				filepath.Join(dir, "data", "crt.pem"): test.cert,
+				filepath.Join(dir, "data", "key.pem"): test.key,
+			} {
+				if data == nil {
+					continue
+				}
+
+				if err := ioutil.WriteFile(path, data, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, ok := existingCertificate(vol); ok != test.expOK {
+				t.Errorf("unexpected result, exp=%t got=%t", test.expOK, ok)
+			}
+		})
+	}
+}
+
+func TestRepublishMounted(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "foo"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	for name, test := range map[string]struct {
+		attr         map[string]string
+		removeCert   bool
+		expPublished bool
+		expWatching  bool
+	}{
+		"unchanged attributes with a valid certificate should be a no-op": {
+			attr:         map[string]string{csiapi.DNSNamesKey: "foo.bar"},
+			expPublished: true,
+			expWatching:  true,
+		},
+		"changed attributes should be re-issued": {
+			attr:         map[string]string{csiapi.DNSNamesKey: "foo.bar,bar.foo"},
+			expPublished: false,
+			expWatching:  false,
+		},
+		"added attributes should be re-issued": {
+			attr: map[string]string{
+				csiapi.DNSNamesKey: "foo.bar",
+				csiapi.IPSANsKey:   "1.2.3.4",
+			},
+			expPublished: false,
+			expWatching:  false,
+		},
+		"unchanged attributes with a missing certificate should be re-issued": {
+			attr:         map[string]string{csiapi.DNSNamesKey: "foo.bar"},
+			removeCert:   true,
+			expPublished: false,
+			expWatching:  false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-republish-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			newVol := func(extra map[string]string) *csiapi.MetaData {
+				attr := map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.CSIPodNameKey:      "test-pod",
+					csiapi.IssuerNameKey:      "ca-issuer",
+					csiapi.CertFileKey:        "crt.pem",
+					csiapi.KeyFileKey:         "key.pem",
+				}
+				for k, v := range extra {
+					attr[k] = v
+				}
+
+				return &csiapi.MetaData{
+					ID:         "test-id",
+					Path:       filepath.Join(dir, "test-id"),
+					Attributes: attr,
+				}
+			}
+
+			stored := newVol(map[string]string{csiapi.DNSNamesKey: "foo.bar"})
+			if err := util.WriteMetaDataFile(stored, false, false); err != nil {
+				t.Fatal(err)
+			}
+
+			files := map[string][]byte{"key.pem": []byte("key")}
+			if !test.removeCert {
+				files["crt.pem"] = certPEM
+			}
+			if err := util.WriteDataFiles(stored, files, false); err != nil {
+				t.Fatal(err)
+			}
+
+			renewer := renew.New(dir, nil, nil)
+			if err := renewer.WatchCert(stored, tmpl.NotBefore, tmpl.NotAfter); err != nil {
+				t.Fatal(err)
+			}
+			defer renewer.KillWatcher(stored.ID)
+
+			ns := &NodeServer{
+				nodeID:   "test-node",
+				dataRoot: dir,
+				opts:     new(options.Options),
+				renewer:  renewer,
+			}
+
+			published, err := ns.republishMounted(newVol(test.attr))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if published != test.expPublished {
+				t.Errorf("unexpected published, exp=%t got=%t", test.expPublished, published)
+			}
+
+			if watching := renewer.IsWatching(stored.ID); watching != test.expWatching {
+				t.Errorf("unexpected watching, exp=%t got=%t", test.expWatching, watching)
+			}
+		})
+	}
+}
+
+func TestUnpublishStopsAsyncIssuance(t *testing.T) {
+	defer func(d time.Duration) { asyncIssuanceRetryPeriod = d }(asyncIssuanceRetryPeriod)
+	asyncIssuanceRetryPeriod = time.Millisecond * 10
+
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-async-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dataRoot := filepath.Join(dir, "data")
+	targetPath := filepath.Join(dir, "target")
+
+	// Issuance never succeeds, so is retried until stopped.
+	cmClient := cmfake.NewSimpleClientset()
+	cmClient.PrependReactor("create", "certificaterequests", func(coretesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("issuer unavailable")
+	})
+
+	createCount := func() int {
+		var n int
+		for _, action := range cmClient.Actions() {
+			if action.Matches("create", "certificaterequests") {
+				n++
+			}
+		}
+		return n
+	}
+
+	cm, err := certmanager.NewWithClient(cmClient, kubefake.NewSimpleClientset(), new(options.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The volume directory is kept during the grace period, so must not be
+	// what stops the issuance.
+	ns := &NodeServer{
+		nodeID:   "test-node",
+		dataRoot: dataRoot,
+		opts: &options.Options{
+			DirPermissions:        0700,
+			TargetPathPermissions: 0700,
+			UnpublishGrace:        time.Hour,
+		},
+		cm:      cm,
+		renewer: renew.New(dataRoot, nil, nil),
+		mount: func(source, target string, options []string) error {
+			return nil
+		},
+		unmount: func(target string) error {
+			return nil
+		},
+	}
+	defer ns.cancelPendingDelete("test-id")
+
+	_, err = ns.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{
+		VolumeId:   "test-id",
+		TargetPath: targetPath,
+		VolumeContext: map[string]string{
+			csiapi.CSIPodNameKey:      "test-pod",
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.IssuerNameKey:      "ca-issuer",
+			csiapi.DNSNamesKey:        "foo.bar",
+			csiapi.AsyncIssuanceKey:   "true",
+		},
+		VolumeCapability: &csi.VolumeCapability{},
+	})
+	if err != nil {
+		t.Fatalf("expected publish to return before issuance: %s", err)
+	}
+
+	err = wait.PollImmediate(time.Millisecond*10, time.Second*5, func() (bool, error) {
+		return createCount() > 1, nil
+	})
+	if err != nil {
+		t.Fatal("expected async issuance to be retried")
+	}
+
+	_, err = ns.NodeUnpublishVolume(context.TODO(), &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "test-id",
+		TargetPath: targetPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Allow for an attempt in flight during unpublish.
+	time.Sleep(asyncIssuanceRetryPeriod * 5)
+	n := createCount()
+
+	time.Sleep(asyncIssuanceRetryPeriod * 10)
+	if got := createCount(); got != n {
+		t.Errorf("expected async issuance to stop once unpublished, got %d further attempts", got-n)
+	}
 }
@@ -0,0 +1,112 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// collectOrphans removes volume directories under the data roots that were
+// last modified before the given time, and whose target path is no longer
+// mounted. These are left behind if the driver crashes during unpublish.
+// Directories modified after the given time are skipped since their volume may
+// be in the process of being published, as are directories not named after a
+// volume ID. Volumes in the unpublish grace period are left to be deleted once
+// it has passed.
+func (ns *NodeServer) collectOrphans(before time.Time) error {
+	var errs []string
+	for _, root := range ns.dataRoots() {
+		if err := ns.collectDataRootOrphans(root, before); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func (ns *NodeServer) collectDataRootOrphans(root string, before time.Time) error {
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("failed to read data dir: %s", err)
+	}
+
+	for _, f := range files {
+		if !f.IsDir() || !util.IsVolumeID(f.Name()) || f.ModTime().After(before) {
+			continue
+		}
+
+		path := filepath.Join(root, f.Name())
+
+		// Unpublished volumes are removed once their grace period has passed.
+		if ns.pendingDelete(f.Name()) {
+			glog.V(4).Infof("node: volume directory is pending deletion: %q", path)
+			continue
+		}
+
+		vol, orphan, reason := isOrphan(path)
+		if !orphan {
+			glog.V(4).Infof("node: volume directory is in use: %q", path)
+			continue
+		}
+
+		glog.Infof("node: removing orphaned volume directory %q: %s", path, reason)
+
+		if vol == nil {
+			vol = &csiapi.MetaData{ID: f.Name()}
+		}
+		vol.Path = path
+
+		if err := ns.cleanupVolume(vol); err != nil {
+			glog.Errorf("node: failed to remove orphaned volume directory %q: %s",
+				path, err)
+		}
+	}
+
+	return nil
+}
+
+// isOrphan returns true, and the reason why, if the volume at the given path
+// is no longer mounted into a pod. The metadata of the volume is returned if
+// it could be read.
+func isOrphan(path string) (*csiapi.MetaData, bool, string) {
+	metaData, err := util.ReadMetaDataFile(filepath.Join(path, csiapi.MetaDataFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, true, "no metadata file"
+		}
+
+		// Don't remove volumes we can't reason about.
+		glog.Errorf("node: failed to read metadata file of %q: %s", path, err)
+		return nil, false, ""
+	}
+
+	mntPoint, err := util.IsLikelyMountPoint(metaData.TargetPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return metaData, true, fmt.Sprintf("target path %q does not exist", metaData.TargetPath)
+		}
+
+		glog.Errorf("node: failed to check target path %q of %q: %s",
+			metaData.TargetPath, path, err)
+		return metaData, false, ""
+	}
+
+	if !mntPoint {
+		return metaData, true, fmt.Sprintf("target path %q is not mounted", metaData.TargetPath)
+	}
+
+	return metaData, false, ""
+}
@@ -0,0 +1,139 @@
+package driver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/certmanager"
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestCollectOrphans(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-orphans-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Volume directories are named after their volume ID.
+	volID := func(name string) string {
+		return util.BuildVolumeID("test-uid", name)
+	}
+
+	cmClient := cmfake.NewSimpleClientset(&cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      volID("unmounted-target"),
+			Namespace: "test-namespace",
+		},
+	})
+
+	cm, err := certmanager.NewWithClient(cmClient, kubefake.NewSimpleClientset(), new(options.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pendingDelete := time.AfterFunc(time.Hour, func() {})
+	defer pendingDelete.Stop()
+
+	ns := &NodeServer{
+		dataRoot:       dir,
+		cm:             cm,
+		renewer:        renew.New(dir, nil, nil),
+		pendingDeletes: map[string]*time.Timer{volID("pending-delete"): pendingDelete},
+	}
+
+	writeVol := func(name, targetPath string) {
+		path := filepath.Join(dir, volID(name))
+		if err := os.MkdirAll(path, 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(targetPath) == 0 {
+			return
+		}
+
+		b, err := json.Marshal(&csiapi.MetaData{
+			ID:         volID(name),
+			Path:       path,
+			TargetPath: targetPath,
+			Attributes: map[string]string{
+				csiapi.CSIPodNamespaceKey: "test-namespace",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(path, csiapi.MetaDataFileName), b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notMounted, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-target-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(notMounted)
+
+	writeVol("no-metadata", "")
+	writeVol("no-target", filepath.Join(dir, "does-not-exist"))
+	writeVol("unmounted-target", notMounted)
+	writeVol("pending-delete", notMounted)
+	// /proc is always a mount point.
+	writeVol("mounted-target", "/proc")
+
+	// Directories not named after a volume ID are never volumes.
+	if err := os.Mkdir(filepath.Join(dir, "lost+found"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ns.collectOrphans(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expExists := range map[string]bool{
+		"no-metadata":      false,
+		"no-target":        false,
+		"unmounted-target": false,
+		"mounted-target":   true,
+		"pending-delete":   true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, volID(name)))
+		if exists := err == nil; exists != expExists {
+			t.Errorf("%s: unexpected volume directory existence, exp=%t got=%t",
+				name, expExists, exists)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "lost+found")); err != nil {
+		t.Errorf("expected non-volume directory to not be removed: %s", err)
+	}
+
+	_, err = cmClient.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get(volID("unmounted-target"), metav1.GetOptions{})
+	if err == nil {
+		t.Error("expected CertificateRequest of orphaned volume to be deleted")
+	}
+
+	// Directories modified after the given time should not be removed.
+	writeVol("new-volume", "")
+
+	if err := ns.collectOrphans(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, volID("new-volume"))); err != nil {
+		t.Errorf("expected new volume directory to not be removed: %s", err)
+	}
+}
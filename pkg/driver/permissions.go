@@ -0,0 +1,74 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// reconcileFilePermissions resets the permissions and ownership of the data
+// files of all watched volumes under the data roots, should something else on
+// the node have changed them.
+func (ns *NodeServer) reconcileFilePermissions() error {
+	var errs []string
+	for _, root := range ns.dataRoots() {
+		if err := ns.reconcileDataRootFilePermissions(root); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func (ns *NodeServer) reconcileDataRootFilePermissions(root string) error {
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("failed to read data dir: %s", err)
+	}
+
+	uid, gid := os.Geteuid(), os.Getegid()
+
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+
+		vol, err := util.ReadMetaDataFile(filepath.Join(root, f.Name(), csiapi.MetaDataFileName))
+		if err != nil {
+			glog.V(4).Infof("node: skipping file permissions reconcile of %q: %s", f.Name(), err)
+			continue
+		}
+
+		// Volumes being published or unpublished are not watched, and their
+		// files may be being written or removed.
+		if !ns.renewer.IsWatching(vol.ID) {
+			continue
+		}
+
+		for _, path := range util.DataFilePaths(vol) {
+			changed, err := util.ReconcileFilePermissions(path, util.DataFilePermissions, uid, gid)
+			if err != nil {
+				glog.Errorf("node: failed to reconcile permissions of %s of volume %s: %s", path, vol.ID, err)
+				continue
+			}
+
+			if changed {
+				glog.Infof("node: reset drifted permissions of %s of volume %s", path, vol.ID)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package driver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestReconcileFilePermissions(t *testing.T) {
+	for name, test := range map[string]struct {
+		watched  bool
+		expPerms os.FileMode
+	}{
+		"drifted permissions of a watched volume should be reset": {
+			watched:  true,
+			expPerms: util.DataFilePermissions,
+		},
+		"drifted permissions of a volume not watched should be left alone": {
+			watched:  false,
+			expPerms: 0644,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-file-perms-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			ns := &NodeServer{
+				dataRoot: dir,
+				opts:     new(options.Options),
+				renewer:  renew.New(dir, nil, nil),
+			}
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: filepath.Join(dir, "test-id"),
+				Attributes: map[string]string{
+					csiapi.CertFileKey: "crt.pem",
+					csiapi.KeyFileKey:  "key.pem",
+				},
+			}
+			writeTestCertificate(t, vol)
+
+			if test.watched {
+				now := time.Now()
+				if err := ns.renewer.WatchCert(vol, now.Add(-time.Hour), now.Add(time.Hour)); err != nil {
+					t.Fatal(err)
+				}
+				defer ns.renewer.KillWatcher(vol.ID)
+			}
+
+			for _, path := range []string{util.CertPath(vol), util.KeyPath(vol)} {
+				if err := os.Chmod(path, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := ns.reconcileFilePermissions(); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, path := range []string{util.CertPath(vol), util.KeyPath(vol)} {
+				fi, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if fi.Mode().Perm() != test.expPerms {
+					t.Errorf("unexpected permissions of %s, exp=%s got=%s",
+						path, test.expPerms, fi.Mode().Perm())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/certmanager"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// checkPodIPs triggers the renewal of the certificates of watched volumes
+// tracking their pod IP, whose pod has an IP not in the certificate.
+func (ns *NodeServer) checkPodIPs() error {
+	var errs []string
+	for _, root := range ns.dataRoots() {
+		if err := ns.checkDataRootPodIPs(root); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func (ns *NodeServer) checkDataRootPodIPs(root string) error {
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("failed to read data dir: %s", err)
+	}
+
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+
+		vol, err := util.ReadMetaDataFile(filepath.Join(root, f.Name(), csiapi.MetaDataFileName))
+		if err != nil {
+			glog.V(4).Infof("node: skipping pod ip check of %q: %s", f.Name(), err)
+			continue
+		}
+
+		if vol.Attributes[csiapi.TrackPodIPKey] != "true" || !ns.renewer.IsWatching(vol.ID) {
+			continue
+		}
+
+		podIPs, err := ns.cm.PodIPs(vol)
+		if err == certmanager.ErrPodGone {
+			glog.V(4).Infof("node: skipping pod ip check of volume %s: %s", vol.ID, err)
+			continue
+		}
+		if err != nil {
+			glog.Errorf("node: failed to get pod ip of volume %s: %s", vol.ID, err)
+			continue
+		}
+
+		// An expired certificate is already being renewed.
+		cert, ok := existingCertificate(vol)
+		if !ok || podIPsIssued(podIPs, cert.IPAddresses) {
+			continue
+		}
+
+		glog.Infof("node: ip of pod of volume %s changed to %s, re-issuing", vol.ID, podIPs)
+		ns.renewer.RenewNow(vol.ID)
+	}
+
+	return nil
+}
+
+// podIPsIssued returns true if all of the pod IPs are in the IPs of the
+// certificate. No pod IPs, such as before the pod is assigned one, are
+// always issued.
+func podIPsIssued(podIPs, certIPs []net.IP) bool {
+	for _, podIP := range podIPs {
+		var found bool
+		for _, certIP := range certIPs {
+			if podIP.Equal(certIP) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,122 @@
+package driver
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/certmanager"
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+)
+
+func TestCheckPodIPs(t *testing.T) {
+	for name, test := range map[string]struct {
+		pod        *corev1.Pod
+		trackPodIP bool
+		expRenew   bool
+	}{
+		"a pod ip not in the certificate should re-issue": {
+			pod: &corev1.Pod{
+				Status: corev1.PodStatus{PodIP: "10.0.0.1"},
+			},
+			trackPodIP: true,
+			expRenew:   true,
+		},
+		"a pod ip of a volume not tracking it should not re-issue": {
+			pod: &corev1.Pod{
+				Status: corev1.PodStatus{PodIP: "10.0.0.1"},
+			},
+			trackPodIP: false,
+			expRenew:   false,
+		},
+		"a pod not yet assigned an ip should not re-issue": {
+			pod:        new(corev1.Pod),
+			trackPodIP: true,
+			expRenew:   false,
+		},
+		"a pod that no longer exists should not re-issue": {
+			trackPodIP: true,
+			expRenew:   false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-pod-ips-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			var objects []runtime.Object
+			if test.pod != nil {
+				test.pod.ObjectMeta = metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"}
+				objects = append(objects, test.pod)
+			}
+
+			cm, err := certmanager.NewWithClient(cmfake.NewSimpleClientset(), kubefake.NewSimpleClientset(objects...), new(options.Options))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			renewed := make(chan struct{}, 1)
+			renF := func(vol *csiapi.MetaData) (*x509.Certificate, error) {
+				renewed <- struct{}{}
+				return &x509.Certificate{NotAfter: time.Now().Add(time.Hour)}, nil
+			}
+
+			ns := &NodeServer{
+				dataRoot: dir,
+				opts:     new(options.Options),
+				cm:       cm,
+				renewer:  renew.New(dir, renF, nil),
+			}
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: filepath.Join(dir, "test-id"),
+				Attributes: map[string]string{
+					csiapi.CSIPodNameKey:      "test-pod",
+					csiapi.CSIPodNamespaceKey: "test-ns",
+					csiapi.CertFileKey:        "crt.pem",
+					csiapi.KeyFileKey:         "key.pem",
+					csiapi.RenewBeforeKey:     "1m",
+				},
+			}
+			if test.trackPodIP {
+				vol.Attributes[csiapi.TrackPodIPKey] = "true"
+			}
+			writeTestCertificate(t, vol)
+
+			now := time.Now()
+			if err := ns.renewer.WatchCert(vol, now.Add(-time.Hour), now.Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			defer ns.renewer.KillWatcher(vol.ID)
+
+			if err := ns.checkPodIPs(); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-renewed:
+				if !test.expRenew {
+					t.Error("expected the certificate not to be re-issued")
+				}
+			case <-time.After(time.Second / 2):
+				if test.expRenew {
+					t.Error("expected the certificate to be re-issued")
+				}
+			}
+		})
+	}
+}
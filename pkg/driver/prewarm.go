@@ -0,0 +1,112 @@
+package driver
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// ephemeralVolumeID returns the ID kubelet gives the inline CSI volume of the
+// given name in a pod.
+func ephemeralVolumeID(podUID types.UID, volumeName string) string {
+	return fmt.Sprintf("csi-%x", sha256.Sum256([]byte(string(podUID)+volumeName)))
+}
+
+// prewarmPod pre-creates the CertificateRequests of the volumes of this
+// driver in a pod that is yet to start, so that they may be Ready by the time
+// the volumes are published.
+func (ns *NodeServer) prewarmPod(pod *corev1.Pod) {
+	if pod.Status.Phase != corev1.PodPending || !ns.watchingNamespace(pod.Namespace) {
+		return
+	}
+
+	for _, volume := range ns.podVolumes(pod) {
+		volID := ephemeralVolumeID(pod.UID, volume.Name)
+		if ns.renewer.IsWatching(volID) {
+			continue
+		}
+
+		// The volume context kubelet publishes the volume with.
+		published := make(map[string]string, len(volume.CSI.VolumeAttributes)+5)
+		for k, v := range volume.CSI.VolumeAttributes {
+			published[k] = v
+		}
+		published[csiapi.CSIPodNameKey] = pod.Name
+		published[csiapi.CSIPodNamespaceKey] = pod.Namespace
+		published[csiapi.CSIPodUIDKey] = string(pod.UID)
+		published[csiapi.CSIServiceAccountNameKey] = pod.Spec.ServiceAccountName
+		published[csiapi.CSIEphemeralKey] = "true"
+
+		attr, err := ns.resolveAttributes(published)
+		if err != nil {
+			glog.Errorf("node: not pre-creating CertificateRequest of volume %s of pod %s/%s: %s",
+				volID, pod.Namespace, pod.Name, err)
+			continue
+		}
+
+		root, err := ns.selectDataRoot(attr[csiapi.DataRootKey])
+		if err != nil {
+			glog.Errorf("node: not pre-creating CertificateRequest of volume %s of pod %s/%s: %s",
+				volID, pod.Namespace, pod.Name, err)
+			continue
+		}
+
+		// The volume has already been published.
+		path := filepath.Join(root, volID)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+
+		name, err := util.RenderVolumeName(ns.volumeNameTemplate, attr, volID)
+		if err != nil {
+			glog.Errorf("node: not pre-creating CertificateRequest of volume %s of pod %s/%s: %s",
+				volID, pod.Namespace, pod.Name, err)
+			continue
+		}
+
+		vol := &csiapi.MetaData{
+			ID:          volID,
+			Name:        name,
+			Path:        path,
+			Attributes:  attr,
+			KeyExternal: len(ns.opts.SignerPlugin) > 0,
+		}
+
+		if err := ns.cm.PrewarmCertificateRequest(vol); err != nil {
+			glog.Errorf("node: failed to pre-create CertificateRequest of volume %s of pod %s/%s: %s",
+				volID, pod.Namespace, pod.Name, err)
+		}
+	}
+}
+
+// forgetPod deletes the pre-created CertificateRequests of the volumes of a
+// deleted pod that were never published.
+func (ns *NodeServer) forgetPod(pod *corev1.Pod) {
+	for _, volume := range ns.podVolumes(pod) {
+		volID := ephemeralVolumeID(pod.UID, volume.Name)
+		if err := ns.cm.ForgetPrewarmedRequest(volID, pod.Namespace); err != nil {
+			glog.Errorf("node: failed to delete pre-created CertificateRequest of volume %s of pod %s/%s: %s",
+				volID, pod.Namespace, pod.Name, err)
+		}
+	}
+}
+
+// podVolumes returns the inline CSI volumes of this driver in the pod.
+func (ns *NodeServer) podVolumes(pod *corev1.Pod) []corev1.Volume {
+	var volumes []corev1.Volume
+	for _, volume := range pod.Spec.Volumes {
+		if volume.CSI != nil && volume.CSI.Driver == ns.opts.DriverName {
+			volumes = append(volumes, volume)
+		}
+	}
+
+	return volumes
+}
@@ -0,0 +1,109 @@
+package driver
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/certmanager"
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+)
+
+func TestPrewarmPod(t *testing.T) {
+	for name, test := range map[string]struct {
+		phase      corev1.PodPhase
+		driver     string
+		expRequest bool
+	}{
+		"a pending pod should have its request pre-created": {
+			phase:      corev1.PodPending,
+			driver:     "csi.cert-manager.io",
+			expRequest: true,
+		},
+		"a running pod should not have its request pre-created": {
+			phase:      corev1.PodRunning,
+			driver:     "csi.cert-manager.io",
+			expRequest: false,
+		},
+		"a volume of another driver should not have a request pre-created": {
+			phase:      corev1.PodPending,
+			driver:     "other.csi.driver",
+			expRequest: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-prewarm-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			opts := &options.Options{DriverName: "csi.cert-manager.io"}
+
+			cmClient := cmfake.NewSimpleClientset()
+			cm, err := certmanager.NewWithClient(cmClient, kubefake.NewSimpleClientset(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ns := &NodeServer{
+				nodeID:   "test-node",
+				dataRoot: dir,
+				opts:     opts,
+				cm:       cm,
+				renewer:  renew.New(dir, nil, nil),
+			}
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "test-namespace",
+					UID:       "test-uid",
+				},
+				Spec: corev1.PodSpec{
+					NodeName: "test-node",
+					Volumes: []corev1.Volume{{
+						Name: "tls",
+						VolumeSource: corev1.VolumeSource{
+							CSI: &corev1.CSIVolumeSource{
+								Driver: test.driver,
+								VolumeAttributes: map[string]string{
+									csiapi.IssuerNameKey: "ca-issuer",
+									csiapi.DNSNamesKey:   "foo.bar",
+								},
+							},
+						},
+					}},
+				},
+				Status: corev1.PodStatus{Phase: test.phase},
+			}
+
+			ns.prewarmPod(pod)
+
+			volID := ephemeralVolumeID(pod.UID, "tls")
+			_, err = cmClient.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get(volID, metav1.GetOptions{})
+			if test.expRequest != (err == nil) {
+				t.Fatalf("unexpected pre-created request, exp=%t got err=%v", test.expRequest, err)
+			}
+
+			if !test.expRequest {
+				return
+			}
+
+			ns.forgetPod(pod)
+
+			_, err = cmClient.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get(volID, metav1.GetOptions{})
+			if !k8sErrors.IsNotFound(err) {
+				t.Errorf("expected pre-created request to be deleted with the pod, got: %v", err)
+			}
+		})
+	}
+}
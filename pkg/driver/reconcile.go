@@ -0,0 +1,203 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// renewalAttributes only change how a certificate is renewed, so may be
+// reconciled into a live volume without re-issuing its certificate.
+var renewalAttributes = map[string]bool{
+	csiapi.RenewBeforeKey:       true,
+	csiapi.RenewAtKey:           true,
+	csiapi.RenewScheduleKey:     true,
+	csiapi.DisableAutoRenewKey:  true,
+	csiapi.CARefreshIntervalKey: true,
+}
+
+// applyAttributesConfigMap overrides the given attributes with the data of the
+// named ConfigMap in the pod's namespace. A ConfigMap that does not exist
+// overrides nothing.
+func (ns *NodeServer) applyAttributesConfigMap(attr map[string]string, name string) error {
+	namespace := attr[csiapi.CSIPodNamespaceKey]
+
+	data, err := ns.cm.AttributesConfigMap(namespace, name)
+	if err != nil {
+		return fmt.Errorf("failed to get attributes configmap %s/%s: %s", namespace, name, err)
+	}
+
+	var errs []string
+	for k, v := range data {
+		if !strings.HasPrefix(k, "csi.cert-manager.io/") ||
+			k == csiapi.AttributesConfigMapKey || k == csiapi.NodeIDKey || k == csiapi.DataRootKey {
+			errs = append(errs, fmt.Sprintf("%q may not be set", k))
+			continue
+		}
+
+		attr[k] = v
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("attributes configmap %s/%s: %s", namespace, name,
+			strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// reconcileAttributes reconciles the attributes ConfigMaps of all volumes
+// under the data roots into the live volumes.
+func (ns *NodeServer) reconcileAttributes() error {
+	var errs []string
+	for _, root := range ns.dataRoots() {
+		if err := ns.reconcileDataRootAttributes(root); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func (ns *NodeServer) reconcileDataRootAttributes(root string) error {
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("failed to read data dir: %s", err)
+	}
+
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+
+		vol, err := util.ReadMetaDataFile(filepath.Join(root, f.Name(), csiapi.MetaDataFileName))
+		if err != nil {
+			glog.V(4).Infof("node: skipping reconcile of %q: %s", f.Name(), err)
+			continue
+		}
+
+		if len(vol.PublishAttributes) == 0 {
+			continue
+		}
+
+		if err := ns.reconcileVolume(vol); err != nil {
+			glog.Errorf("node: failed to reconcile attributes of volume %s: %s", vol.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// reconcileVolume re-resolves the attributes of the volume, and applies any
+// changes. Changes to how the certificate is renewed are applied by watching
+// the certificate again. Any other change requires the certificate to be
+// re-issued, which is only done with --reissue-on-attribute-change. Volumes
+// unpublished and awaiting deletion are left alone.
+func (ns *NodeServer) reconcileVolume(vol *csiapi.MetaData) error {
+	if ns.pendingDelete(vol.ID) {
+		glog.V(4).Infof("node: skipping reconcile of volume %s pending deletion", vol.ID)
+		return nil
+	}
+
+	// The certificate has not yet been issued, so the attributes are
+	// reconciled once it has.
+	oldCert, ok := existingCertificate(vol)
+	if !ok {
+		return nil
+	}
+
+	attr, err := ns.resolveAttributes(vol.PublishAttributes)
+	if err != nil {
+		return err
+	}
+
+	var changed, reissue []string
+	for k := range keys(vol.Attributes, attr) {
+		if vol.Attributes[k] == attr[k] {
+			continue
+		}
+
+		changed = append(changed, k)
+		if !renewalAttributes[k] {
+			reissue = append(reissue, k)
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	sort.Strings(changed)
+	sort.Strings(reissue)
+
+	if len(reissue) > 0 && !ns.opts.ReissueOnAttributeChange {
+		return fmt.Errorf("changes to %s require the certificate to be re-issued, which is disabled without --reissue-on-attribute-change",
+			strings.Join(reissue, ", "))
+	}
+
+	glog.Infof("node: reconciling changed attributes of volume %s: %s",
+		vol.ID, strings.Join(changed, ", "))
+
+	newVol := *vol
+	newVol.Attributes = attr
+
+	// The watcher of the old attributes is stopped first so that it does not
+	// renew the certificate alongside the re-issuance. The metadata file is
+	// written with the re-issued certificate, so a failed re-issuance is
+	// retried on the next reconcile, the old certificate being watched until
+	// then.
+	watching := ns.renewer.IsWatching(vol.ID)
+	ns.renewer.KillWatcher(vol.ID)
+
+	rewatch := func() {
+		if !watching {
+			return
+		}
+
+		if err := ns.watchCert(vol, oldCert); err != nil {
+			glog.Errorf("node: failed to watch certificate of volume %s again: %s", vol.ID, err)
+		}
+	}
+
+	if len(reissue) > 0 {
+		if _, err := ns.cm.RenewCertificate(&newVol); err != nil {
+			rewatch()
+			return fmt.Errorf("failed to re-issue certificate: %s", err)
+		}
+	} else if err := util.WriteMetaDataFile(&newVol, ns.opts.MetadataInMount, ns.opts.FsyncFiles); err != nil {
+		rewatch()
+		return fmt.Errorf("failed to write metadata file: %s", err)
+	}
+
+	cert, ok := existingCertificate(&newVol)
+	if !ok {
+		return fmt.Errorf("no valid certificate to watch after reconcile")
+	}
+
+	return ns.watchCert(&newVol, cert)
+}
+
+// keys returns the union of the keys of the given maps.
+func keys(maps ...map[string]string) map[string]struct{} {
+	ks := make(map[string]struct{})
+	for _, m := range maps {
+		for k := range m {
+			ks[k] = struct{}{}
+		}
+	}
+
+	return ks
+}
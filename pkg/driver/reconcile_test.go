@@ -0,0 +1,313 @@
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	coretesting "k8s.io/client-go/testing"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/certmanager"
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestApplyAttributesConfigMap(t *testing.T) {
+	configMap := func(data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-attributes",
+				Namespace: "test-namespace",
+			},
+			Data: data,
+		}
+	}
+
+	for name, test := range map[string]struct {
+		configMap *corev1.ConfigMap
+		expAttr   map[string]string
+		expErr    bool
+	}{
+		"a missing configmap should override nothing": {
+			expAttr: map[string]string{
+				csiapi.CSIPodNamespaceKey: "test-namespace",
+				csiapi.RenewBeforeKey:     "1h",
+			},
+		},
+		"configmap data should override attributes": {
+			configMap: configMap(map[string]string{
+				csiapi.RenewBeforeKey: "2h",
+				csiapi.DNSNamesKey:    "foo.bar",
+			}),
+			expAttr: map[string]string{
+				csiapi.CSIPodNamespaceKey: "test-namespace",
+				csiapi.RenewBeforeKey:     "2h",
+				csiapi.DNSNamesKey:        "foo.bar",
+			},
+		},
+		"configmap data of other keys should error": {
+			configMap: configMap(map[string]string{
+				csiapi.CSIPodNamespaceKey: "other-namespace",
+			}),
+			expErr: true,
+		},
+		"configmap data of the configmap key should error": {
+			configMap: configMap(map[string]string{
+				csiapi.AttributesConfigMapKey: "other-attributes",
+			}),
+			expErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset()
+			if test.configMap != nil {
+				kubeClient = kubefake.NewSimpleClientset(test.configMap)
+			}
+
+			cm, err := certmanager.NewWithClient(cmfake.NewSimpleClientset(), kubeClient, new(options.Options))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ns := &NodeServer{cm: cm}
+
+			attr := map[string]string{
+				csiapi.CSIPodNamespaceKey: "test-namespace",
+				csiapi.RenewBeforeKey:     "1h",
+			}
+
+			err = ns.applyAttributesConfigMap(attr, "test-attributes")
+			if test.expErr != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			if test.expErr {
+				return
+			}
+
+			if len(attr) != len(test.expAttr) {
+				t.Errorf("unexpected attributes, exp=%v got=%v", test.expAttr, attr)
+			}
+
+			for k, v := range test.expAttr {
+				if attr[k] != v {
+					t.Errorf("unexpected attribute %s, exp=%q got=%q", k, v, attr[k])
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileVolume(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "foo.bar"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	for name, test := range map[string]struct {
+		data           map[string]string
+		reissue        bool
+		watching       bool
+		pendingDelete  bool
+		reissueErr     bool
+		expErr         bool
+		expRenewBefore string
+		expWatching    bool
+	}{
+		"no changes should leave the volume as is": {
+			expRenewBefore: "",
+		},
+		"a changed renew-before should be applied without re-issuance": {
+			data: map[string]string{
+				csiapi.RenewBeforeKey: "30m",
+			},
+			expRenewBefore: "30m",
+			expWatching:    true,
+		},
+		"a changed issuer should be rejected without --reissue-on-attribute-change": {
+			data: map[string]string{
+				csiapi.RenewBeforeKey: "30m",
+				csiapi.IssuerNameKey:  "other-issuer",
+			},
+			expErr:         true,
+			expRenewBefore: "",
+		},
+		"a volume pending deletion should be left as is": {
+			data: map[string]string{
+				csiapi.RenewBeforeKey: "30m",
+			},
+			pendingDelete:  true,
+			expRenewBefore: "",
+		},
+		"a failed re-issuance should stop the old watcher first and watch it again": {
+			data: map[string]string{
+				csiapi.IssuerNameKey: "other-issuer",
+			},
+			reissue:        true,
+			watching:       true,
+			reissueErr:     true,
+			expErr:         true,
+			expRenewBefore: "",
+			expWatching:    true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-reconcile-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			kubeClient := kubefake.NewSimpleClientset()
+
+			opts := &options.Options{
+				ReconcileAttributesInterval: time.Minute,
+				ReissueOnAttributeChange:    test.reissue,
+			}
+
+			var ns *NodeServer
+			var watchingOnReissue bool
+
+			cmClient := cmfake.NewSimpleClientset()
+			if test.reissueErr {
+				// The request of the old certificate is deleted first when
+				// re-issuing.
+				cmClient.PrependReactor("delete", "certificaterequests", func(action coretesting.Action) (bool, runtime.Object, error) {
+					watchingOnReissue = ns.renewer.IsWatching("test-id")
+					return true, nil, errors.New("connection refused")
+				})
+			}
+
+			cm, err := certmanager.NewWithClient(cmClient, kubeClient, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ns = &NodeServer{
+				nodeID:   "test-node",
+				dataRoot: dir,
+				opts:     opts,
+				cm:       cm,
+				renewer:  renew.New(dir, nil, nil),
+			}
+
+			published := map[string]string{
+				csiapi.CSIPodNameKey:          "test-pod",
+				csiapi.CSIPodNamespaceKey:     "test-namespace",
+				csiapi.IssuerNameKey:          "test-issuer",
+				csiapi.CommonNameKey:          "foo.bar",
+				csiapi.DurationKey:            "1h",
+				csiapi.AttributesConfigMapKey: "test-attributes",
+			}
+
+			attr, err := ns.resolveAttributes(published)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			vol := &csiapi.MetaData{
+				ID:                "test-id",
+				Path:              filepath.Join(dir, "test-id"),
+				Attributes:        attr,
+				PublishAttributes: published,
+			}
+
+			if err := os.MkdirAll(util.MountPath(vol), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			for path, data := range map[string][]byte{
+				util.CertPath(vol): certPEM,
+				util.KeyPath(vol):  []byte("key"),
+			} {
+				if err := ioutil.WriteFile(path, data, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := util.WriteMetaDataFile(vol, false, false); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.watching {
+				if err := ns.renewer.WatchCert(vol, tmpl.NotBefore, tmpl.NotAfter); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if test.pendingDelete {
+				timer := time.AfterFunc(time.Hour, func() {})
+				defer timer.Stop()
+
+				ns.pendingDeletes = map[string]*time.Timer{vol.ID: timer}
+			}
+
+			if test.data != nil {
+				_, err := kubeClient.CoreV1().ConfigMaps("test-namespace").Create(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-attributes",
+						Namespace: "test-namespace",
+					},
+					Data: test.data,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = ns.reconcileVolume(vol)
+			if test.expErr != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			got, err := util.ReadMetaDataFile(util.MetaDataPath(vol))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rb := got.Attributes[csiapi.RenewBeforeKey]; rb != test.expRenewBefore {
+				t.Errorf("unexpected renew-before, exp=%q got=%q", test.expRenewBefore, rb)
+			}
+
+			if w := ns.renewer.IsWatching(vol.ID); w != test.expWatching {
+				t.Errorf("unexpected watching, exp=%t got=%t", test.expWatching, w)
+			}
+
+			if watchingOnReissue {
+				t.Error("expected the old watcher to be stopped before re-issuing")
+			}
+
+			ns.renewer.KillWatcher(vol.ID)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// selfHealMountsInterval is the interval the mounts of volumes are checked at
+// with --self-heal-mounts.
+var selfHealMountsInterval = time.Second * 30
+
+// healMounts re-mounts the tmpfs of the data root and watched volumes under the
+// data roots whose target path is no longer mounted, such as when unmounted by
+// other tooling on the node, and re-issues those whose files have gone
+// missing.
+func (ns *NodeServer) healMounts() error {
+	var errs []string
+	if err := ns.healDataRoot(); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	for _, root := range ns.dataRoots() {
+		if err := ns.healDataRootMounts(root); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// healDataRoot re-mounts the tmpfs of the default data root if it is no longer
+// mounted, losing the files of every volume in it. The metadata files of the
+// watched volumes are restored from the renewer, their target paths are bound
+// to the new tmpfs, and their certificates re-issued.
+func (ns *NodeServer) healDataRoot() error {
+	if ns.mountDataRoot == nil {
+		return nil
+	}
+
+	mntPoint, err := util.IsLikelyMountPoint(ns.dataRoot)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check data root %s is mounted: %s", ns.dataRoot, err)
+	}
+	if mntPoint {
+		return nil
+	}
+
+	glog.Warningf("node: data root %s is no longer mounted, re-mounting and restoring volumes", ns.dataRoot)
+
+	if err := os.MkdirAll(ns.dataRoot, 0700); err != nil {
+		return fmt.Errorf("failed to create data root directory %s: %s", ns.dataRoot, err)
+	}
+
+	if err := ns.mountDataRoot(ns.dataRoot); err != nil {
+		return fmt.Errorf("failed to re-mount data root %s: %s", ns.dataRoot, err)
+	}
+
+	for _, vol := range ns.renewer.WatchedVolumes() {
+		if filepath.Dir(vol.Path) != ns.dataRoot || len(vol.TargetPath) == 0 {
+			continue
+		}
+
+		if err := ns.restoreVolume(vol); err != nil {
+			glog.Errorf("node: failed to restore volume %s: %s", vol.ID, err)
+			continue
+		}
+
+		glog.Infof("node: restored volume %s in re-mounted data root, re-issuing", vol.ID)
+		ns.renewer.RenewNow(vol.ID)
+	}
+
+	return nil
+}
+
+// restoreVolume re-writes the metadata file of the watched volume into the
+// re-mounted data root, and binds its target path to it, replacing the mount
+// of the lost data root.
+func (ns *NodeServer) restoreVolume(vol *csiapi.MetaData) error {
+	mountPath := util.MountPath(vol)
+	if err := os.MkdirAll(mountPath, ns.opts.DirPermissions); err != nil {
+		return fmt.Errorf("failed to create mount path directory %s: %s", mountPath, err)
+	}
+
+	if err := util.WriteMetaDataFile(vol, ns.opts.MetadataInMount, ns.opts.FsyncFiles); err != nil {
+		return fmt.Errorf("failed to write metadata file: %s", err)
+	}
+
+	mntPoint, err := util.IsLikelyMountPoint(vol.TargetPath)
+	if os.IsNotExist(err) {
+		// The pod is being torn down.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if mntPoint {
+		if err := ns.unmount(vol.TargetPath); err != nil {
+			return fmt.Errorf("failed to unmount %s: %s", vol.TargetPath, err)
+		}
+	}
+
+	if err := ns.mountWithRetry(mountPath, vol.TargetPath); err != nil {
+		return fmt.Errorf("failed to mount path %s -> %s: %s", mountPath, vol.TargetPath, err)
+	}
+
+	return nil
+}
+
+func (ns *NodeServer) healDataRootMounts(root string) error {
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("failed to read data dir: %s", err)
+	}
+
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+
+		vol, err := util.ReadMetaDataFile(filepath.Join(root, f.Name(), csiapi.MetaDataFileName))
+		if err != nil {
+			glog.V(4).Infof("node: skipping mount check of %q: %s", f.Name(), err)
+			continue
+		}
+
+		// Volumes being published or unpublished are not watched, and their
+		// target path may be being mounted or unmounted.
+		if len(vol.TargetPath) == 0 || !ns.renewer.IsWatching(vol.ID) {
+			continue
+		}
+
+		if err := ns.healMount(vol); err != nil {
+			glog.Errorf("node: failed to heal mount of volume %s: %s", vol.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// healMount re-mounts the volume at its target path if it is no longer
+// mounted, and triggers the re-issuance of its certificate if its files are
+// missing.
+func (ns *NodeServer) healMount(vol *csiapi.MetaData) error {
+	mntPoint, err := util.IsLikelyMountPoint(vol.TargetPath)
+	if os.IsNotExist(err) {
+		// The pod is being torn down.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !mntPoint {
+		glog.Infof("node: volume %s is no longer mounted at %s, re-mounting", vol.ID, vol.TargetPath)
+
+		mountPath := util.MountPath(vol)
+		if err := os.MkdirAll(mountPath, ns.opts.DirPermissions); err != nil {
+			return fmt.Errorf("failed to create mount path directory %s: %s", mountPath, err)
+		}
+
+		if err := ns.mountWithRetry(mountPath, vol.TargetPath); err != nil {
+			return fmt.Errorf("failed to mount path %s -> %s: %s", mountPath, vol.TargetPath, err)
+		}
+
+		// The volume may have been unpublished while it was being mounted.
+		if !ns.renewer.IsWatching(vol.ID) {
+			return ns.unmount(vol.TargetPath)
+		}
+	}
+
+	if _, ok := existingCertificate(vol); !ok {
+		glog.Infof("node: files of volume %s are missing, re-issuing", vol.ID)
+		ns.renewer.RenewNow(vol.ID)
+	}
+
+	return nil
+}
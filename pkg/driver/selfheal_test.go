@@ -0,0 +1,223 @@
+package driver
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestHealMounts(t *testing.T) {
+	for name, test := range map[string]struct {
+		watched      bool
+		noTargetPath bool
+		removeFiles  bool
+		expMount     bool
+		expRenew     bool
+	}{
+		"a watched volume no longer mounted should be re-mounted": {
+			watched:  true,
+			expMount: true,
+			expRenew: false,
+		},
+		"a watched volume whose files are missing should be re-mounted and re-issued": {
+			watched:     true,
+			removeFiles: true,
+			expMount:    true,
+			expRenew:    true,
+		},
+		"a volume whose target path no longer exists should be left alone": {
+			watched:      true,
+			noTargetPath: true,
+			expMount:     false,
+			expRenew:     false,
+		},
+		"a volume not watched should be left alone": {
+			watched:  false,
+			expMount: false,
+			expRenew: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-self-heal-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			renewed := make(chan struct{}, 1)
+			renF := func(vol *csiapi.MetaData) (*x509.Certificate, error) {
+				renewed <- struct{}{}
+				return &x509.Certificate{NotAfter: time.Now().Add(time.Hour)}, nil
+			}
+
+			var mounted []string
+			ns := &NodeServer{
+				dataRoot: filepath.Join(dir, "data"),
+				opts:     &options.Options{DirPermissions: 0700},
+				renewer:  renew.New(filepath.Join(dir, "data"), renF, nil),
+				mount: func(source, target string, options []string) error {
+					mounted = append(mounted, source+" -> "+target)
+					return nil
+				},
+				unmount: func(target string) error {
+					return nil
+				},
+			}
+
+			vol := &csiapi.MetaData{
+				ID:         "test-id",
+				Path:       filepath.Join(dir, "data", "test-id"),
+				TargetPath: filepath.Join(dir, "target"),
+				Attributes: map[string]string{
+					csiapi.CertFileKey:    "crt.pem",
+					csiapi.KeyFileKey:     "key.pem",
+					csiapi.RenewBeforeKey: "1m",
+				},
+			}
+			writeTestCertificate(t, vol)
+
+			// The target path exists but, being a plain directory, is not
+			// mounted.
+			if !test.noTargetPath {
+				if err := os.MkdirAll(vol.TargetPath, 0700); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if test.removeFiles {
+				for _, path := range []string{util.CertPath(vol), util.KeyPath(vol)} {
+					if err := os.Remove(path); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			if test.watched {
+				now := time.Now()
+				if err := ns.renewer.WatchCert(vol, now.Add(-time.Hour), now.Add(time.Hour)); err != nil {
+					t.Fatal(err)
+				}
+				defer ns.renewer.KillWatcher(vol.ID)
+			}
+
+			if err := ns.healMounts(); err != nil {
+				t.Fatal(err)
+			}
+
+			expMounted := util.MountPath(vol) + " -> " + vol.TargetPath
+			if test.expMount && (len(mounted) != 1 || mounted[0] != expMounted) {
+				t.Errorf("expected volume to be re-mounted with %q, got=%v", expMounted, mounted)
+			}
+			if !test.expMount && len(mounted) > 0 {
+				t.Errorf("expected volume not to be re-mounted, got=%v", mounted)
+			}
+
+			select {
+			case <-renewed:
+				if !test.expRenew {
+					t.Error("expected the certificate not to be re-issued")
+				}
+			case <-time.After(time.Second / 2):
+				if test.expRenew {
+					t.Error("expected the certificate to be re-issued")
+				}
+			}
+		})
+	}
+}
+
+func TestHealMountsDataRoot(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-self-heal-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dataRoot := filepath.Join(dir, "data")
+
+	renewed := make(chan struct{}, 1)
+	renF := func(vol *csiapi.MetaData) (*x509.Certificate, error) {
+		renewed <- struct{}{}
+		return &x509.Certificate{NotAfter: time.Now().Add(time.Hour)}, nil
+	}
+
+	var mountedDataRoots, mounted []string
+	ns := &NodeServer{
+		dataRoot: dataRoot,
+		opts:     &options.Options{DirPermissions: 0700},
+		renewer:  renew.New(dataRoot, renF, nil),
+		mount: func(source, target string, options []string) error {
+			mounted = append(mounted, source+" -> "+target)
+			return nil
+		},
+		unmount: func(target string) error {
+			return nil
+		},
+		mountDataRoot: func(dataRoot string) error {
+			mountedDataRoots = append(mountedDataRoots, dataRoot)
+			return nil
+		},
+	}
+
+	vol := &csiapi.MetaData{
+		ID:         "test-id",
+		Path:       filepath.Join(dataRoot, "test-id"),
+		TargetPath: filepath.Join(dir, "target"),
+		Attributes: map[string]string{
+			csiapi.CertFileKey:    "crt.pem",
+			csiapi.KeyFileKey:     "key.pem",
+			csiapi.RenewBeforeKey: "1m",
+		},
+	}
+	writeTestCertificate(t, vol)
+
+	if err := os.MkdirAll(vol.TargetPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if err := ns.renewer.WatchCert(vol, now.Add(-time.Hour), now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	defer ns.renewer.KillWatcher(vol.ID)
+
+	// The tmpfs of the data root, and so every volume's files, are lost.
+	if err := os.RemoveAll(dataRoot); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ns.healMounts(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mountedDataRoots) != 1 || mountedDataRoots[0] != dataRoot {
+		t.Errorf("expected data root %s to be re-mounted, got=%v", dataRoot, mountedDataRoots)
+	}
+
+	meta, err := util.ReadMetaDataFile(util.MetaDataPath(vol))
+	if err != nil {
+		t.Fatalf("expected metadata file to be restored: %s", err)
+	}
+	if meta.ID != vol.ID || meta.TargetPath != vol.TargetPath {
+		t.Errorf("unexpected restored metadata, exp=%+v got=%+v", vol, meta)
+	}
+
+	expMounted := util.MountPath(vol) + " -> " + vol.TargetPath
+	if len(mounted) == 0 || mounted[0] != expMounted {
+		t.Errorf("expected volume to be re-mounted with %q, got=%v", expMounted, mounted)
+	}
+
+	select {
+	case <-renewed:
+	case <-time.After(time.Second * 5):
+		t.Error("expected the certificate to be re-issued")
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"runtime/debug"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/kubernetes-csi/csi-lib-utils/protosanitizer"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 )
@@ -27,14 +31,20 @@ type NonBlockingGRPCServer interface {
 	ForceStop()
 }
 
-func NewNonBlockingGRPCServer() NonBlockingGRPCServer {
-	return &nonBlockingGRPCServer{}
+// NewNonBlockingGRPCServer returns a new NonBlockingGRPCServer. The given
+// server options are applied in addition to the default logging and panic
+// recovery interceptor.
+func NewNonBlockingGRPCServer(serverOpts ...grpc.ServerOption) NonBlockingGRPCServer {
+	return &nonBlockingGRPCServer{
+		serverOpts: serverOpts,
+	}
 }
 
 // NonBlocking server
 type nonBlockingGRPCServer struct {
-	wg     sync.WaitGroup
-	server *grpc.Server
+	wg         sync.WaitGroup
+	server     *grpc.Server
+	serverOpts []grpc.ServerOption
 }
 
 func (s *nonBlockingGRPCServer) Start(endpoint string, ids csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer) {
@@ -77,9 +87,9 @@ func (s *nonBlockingGRPCServer) serve(endpoint string, ids csi.IdentityServer, c
 		glog.Fatalf("Failed to listen: %v", err)
 	}
 
-	opts := []grpc.ServerOption{
+	opts := append([]grpc.ServerOption{
 		grpc.UnaryInterceptor(logGRPC),
-	}
+	}, s.serverOpts...)
 	server := grpc.NewServer(opts...)
 	s.server = server
 
@@ -109,14 +119,49 @@ func parseEndpoint(ep string) (string, string, error) {
 	return "", "", fmt.Errorf("Invalid endpoint: %v", ep)
 }
 
+// volumeRequest is implemented by the CSI requests of a volume.
+type volumeRequest interface {
+	GetVolumeId() string
+}
+
+// logGRPC logs each call with its volume, duration and resulting code. Panics
+// are recovered by recoverGRPC, since only a single unary interceptor may be
+// set.
 func logGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	glog.V(3).Infof("server: call: %s", info.FullMethod)
+	start := time.Now()
+
+	var volumeID string
+	if r, ok := req.(volumeRequest); ok {
+		volumeID = r.GetVolumeId()
+	}
+
+	glog.V(3).Infof("server: call: %s volume=%q", info.FullMethod, volumeID)
 	glog.V(5).Infof("server: request: %s", protosanitizer.StripSecrets(req))
-	resp, err := handler(ctx, req)
+
+	resp, err := recoverGRPC(ctx, req, info, handler)
+
+	duration := time.Since(start)
 	if err != nil {
-		glog.Errorf("server: error: %v", err)
+		glog.Errorf("server: %s volume=%q code=%s duration=%s error: %v",
+			info.FullMethod, volumeID, status.Code(err), duration, err)
 	} else {
+		glog.V(3).Infof("server: %s volume=%q code=%s duration=%s",
+			info.FullMethod, volumeID, codes.OK, duration)
 		glog.V(5).Infof("server: response: %s", protosanitizer.StripSecrets(resp))
 	}
+
 	return resp, err
 }
+
+// recoverGRPC recovers from panics of the handler, returning them as an
+// Internal error so that they don't take down the server.
+func recoverGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			glog.Errorf("server: panic in %s: %v\n%s", info.FullMethod, r, debug.Stack())
+			resp, err = nil, status.Errorf(codes.Internal, "panic in %s: %v", info.FullMethod, r)
+		}
+	}()
+
+	return handler(ctx, req)
+}
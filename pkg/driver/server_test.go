@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLogGRPC(t *testing.T) {
+	info := &grpc.UnaryServerInfo{
+		FullMethod: "/csi.v1.Node/NodePublishVolume",
+	}
+
+	for name, test := range map[string]struct {
+		handler grpc.UnaryHandler
+		expCode codes.Code
+	}{
+		"a successful handler should return OK": {
+			handler: func(context.Context, interface{}) (interface{}, error) {
+				return &csi.NodePublishVolumeResponse{}, nil
+			},
+			expCode: codes.OK,
+		},
+		"a failing handler should return its code": {
+			handler: func(context.Context, interface{}) (interface{}, error) {
+				return nil, status.Error(codes.InvalidArgument, "bad request")
+			},
+			expCode: codes.InvalidArgument,
+		},
+		"a panicking handler should return Internal": {
+			handler: func(context.Context, interface{}) (interface{}, error) {
+				var m map[string]string
+				m["foo"] = "bar"
+				return nil, nil
+			},
+			expCode: codes.Internal,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := &csi.NodePublishVolumeRequest{VolumeId: "test-id"}
+
+			_, err := logGRPC(context.TODO(), req, info, test.handler)
+			if code := status.Code(err); code != test.expCode {
+				t.Errorf("unexpected code, exp=%s got=%s (%v)", test.expCode, code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"fmt"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jetstack/cert-manager-csi/pkg/apis/defaults"
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// NodeStageVolume caches the CA of the volume's issuer, so that it is fetched
+// once and shared by every pod the volume is published to. The certificate
+// and key are still issued per pod when published.
+func (ns *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
+	volumeID := req.GetVolumeId()
+
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "volume ID missing in request")
+	}
+
+	if len(req.GetStagingTargetPath()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "staging target path missing in request")
+	}
+
+	// Defaulted as when published, so that the issuer is the same.
+	attr := make(map[string]string)
+	for k, v := range req.GetVolumeContext() {
+		attr[k] = v
+	}
+
+	attr, err := defaults.SetDefaultAttributes(attr, ns.opts)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if len(attr[csiapi.IssuerNameKey]) == 0 {
+		return nil, status.Error(codes.InvalidArgument,
+			fmt.Sprintf("%s field required", csiapi.IssuerNameKey))
+	}
+
+	ca, err := ns.cm.StageCA(volumeID, attr)
+	if err != nil {
+		return nil, certManagerStatus(err, "failed to fetch ca of issuer")
+	}
+
+	if len(ca) == 0 {
+		glog.Infof("node: volume %s staged, ca of issuer %q cached once first issued", volumeID, attr[csiapi.IssuerNameKey])
+	} else {
+		glog.Infof("node: volume %s staged with cached ca of issuer %q", volumeID, attr[csiapi.IssuerNameKey])
+	}
+
+	return &csi.NodeStageVolumeResponse{}, nil
+}
+
+// NodeUnstageVolume releases the CA cached for the volume when it was staged.
+func (ns *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
+	volumeID := req.GetVolumeId()
+
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "volume ID missing in request")
+	}
+
+	if len(req.GetStagingTargetPath()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "staging target path missing in request")
+	}
+
+	ns.cm.UnstageCA(volumeID)
+
+	glog.Infof("node: volume %s unstaged", volumeID)
+
+	return &csi.NodeUnstageVolumeResponse{}, nil
+}
@@ -0,0 +1,122 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/certmanager"
+)
+
+func TestNodeGetCapabilitiesStaging(t *testing.T) {
+	ns := new(NodeServer)
+
+	resp, err := ns.NodeGetCapabilities(context.TODO(), new(csi.NodeGetCapabilitiesRequest))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, c := range resp.GetCapabilities() {
+		if c.GetRpc().GetType() == csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME {
+			return
+		}
+	}
+
+	t.Errorf("expected STAGE_UNSTAGE_VOLUME to be advertised")
+}
+
+func TestStageVolume(t *testing.T) {
+	cmClient := cmfake.NewSimpleClientset(&cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-id",
+			Namespace: "test-namespace",
+		},
+		Spec: cmapi.CertificateRequestSpec{
+			IssuerRef: cmmeta.ObjectReference{
+				Name:  "ca-issuer",
+				Kind:  cmapi.IssuerKind,
+				Group: "cert-manager.io",
+			},
+		},
+		Status: cmapi.CertificateRequestStatus{
+			CA: []byte("staged-ca"),
+			Conditions: []cmapi.CertificateRequestCondition{
+				{Type: cmapi.CertificateRequestConditionReady, Status: cmmeta.ConditionTrue},
+			},
+		},
+	})
+
+	cm, err := certmanager.NewWithClient(cmClient, kubefake.NewSimpleClientset(), new(options.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns := &NodeServer{
+		nodeID: "test-node",
+		opts:   new(options.Options),
+		cm:     cm,
+	}
+
+	tests := map[string]struct {
+		req     *csi.NodeStageVolumeRequest
+		expCode codes.Code
+	}{
+		"if no volume ID then invalid argument": {
+			req: &csi.NodeStageVolumeRequest{
+				StagingTargetPath: "/staging",
+			},
+			expCode: codes.InvalidArgument,
+		},
+		"if no staging target path then invalid argument": {
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId: "test-id",
+			},
+			expCode: codes.InvalidArgument,
+		},
+		"if no issuer then invalid argument": {
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "test-id",
+				StagingTargetPath: "/staging",
+			},
+			expCode: codes.InvalidArgument,
+		},
+		"if issuer set then the volume should be staged": {
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          "test-id",
+				StagingTargetPath: "/staging",
+				VolumeContext: map[string]string{
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.IssuerNameKey:      "ca-issuer",
+				},
+			},
+			expCode: codes.OK,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ns.NodeStageVolume(context.TODO(), test.req)
+			if code := status.Code(err); code != test.expCode {
+				t.Errorf("unexpected status code, exp=%s got=%s (%v)", test.expCode, code, err)
+			}
+		})
+	}
+
+	_, err = ns.NodeUnstageVolume(context.TODO(), &csi.NodeUnstageVolumeRequest{
+		VolumeId:          "test-id",
+		StagingTargetPath: "/staging",
+	})
+	if err != nil {
+		t.Errorf("unexpected error unstaging volume: %s", err)
+	}
+}
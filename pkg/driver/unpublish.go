@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// deferCleanupVolume cleans up an unpublished volume once the grace period
+// has passed, unless it is republished before then.
+func (ns *NodeServer) deferCleanupVolume(vol *csiapi.MetaData, grace time.Duration) {
+	ns.pendingDeletesMu.Lock()
+	defer ns.pendingDeletesMu.Unlock()
+
+	if ns.pendingDeletes == nil {
+		ns.pendingDeletes = make(map[string]*time.Timer)
+	}
+
+	if timer, ok := ns.pendingDeletes[vol.ID]; ok {
+		timer.Stop()
+	}
+
+	glog.V(4).Infof("node: deferring deletion of volume %s for %s", vol.ID, grace)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(grace, func() {
+		// Hold the lock while cleaning up so that a concurrent republish
+		// waits for the volume to be removed before creating it afresh.
+		ns.pendingDeletesMu.Lock()
+		defer ns.pendingDeletesMu.Unlock()
+
+		// The volume has been republished, or unpublished again.
+		if ns.pendingDeletes[vol.ID] != timer {
+			return
+		}
+		delete(ns.pendingDeletes, vol.ID)
+
+		glog.V(4).Infof("node: deleting volume %s after unpublish grace", vol.ID)
+
+		if err := ns.cleanupVolume(vol); err != nil {
+			glog.Errorf("node: failed to clean up unpublished volume %s: %s", vol.ID, err)
+		}
+	})
+
+	ns.pendingDeletes[vol.ID] = timer
+}
+
+// cancelPendingDelete stops the deferred deletion of an unpublished volume.
+// Returns true if the volume was pending deletion.
+func (ns *NodeServer) cancelPendingDelete(volID string) bool {
+	ns.pendingDeletesMu.Lock()
+	defer ns.pendingDeletesMu.Unlock()
+
+	timer, ok := ns.pendingDeletes[volID]
+	if !ok {
+		return false
+	}
+
+	timer.Stop()
+	delete(ns.pendingDeletes, volID)
+
+	return true
+}
+
+// pendingDelete returns true if the volume has been unpublished and its
+// deletion deferred by the unpublish grace period.
+func (ns *NodeServer) pendingDelete(volID string) bool {
+	ns.pendingDeletesMu.Lock()
+	defer ns.pendingDeletesMu.Unlock()
+
+	_, ok := ns.pendingDeletes[volID]
+	return ok
+}
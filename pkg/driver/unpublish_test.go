@@ -0,0 +1,330 @@
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	cmfake "github.com/jetstack/cert-manager/pkg/client/clientset/versioned/fake"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/certmanager"
+	"github.com/jetstack/cert-manager-csi/pkg/renew"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+func TestUnpublishGrace(t *testing.T) {
+	for name, republish := range map[string]bool{
+		"if not republished the volume should be deleted after the grace period": false,
+		"if republished the volume should be reused and not deleted":             true,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-unpublish-grace-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			dataRoot := filepath.Join(dir, "data")
+			targetPath := filepath.Join(dir, "target")
+
+			cmClient := cmfake.NewSimpleClientset(&cmapi.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-id",
+					Namespace: "test-namespace",
+				},
+			})
+
+			cm, err := certmanager.NewWithClient(cmClient, kubefake.NewSimpleClientset(), new(options.Options))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			grace := time.Millisecond * 50
+			ns := &NodeServer{
+				nodeID:   "test-node",
+				dataRoot: dataRoot,
+				opts: &options.Options{
+					DirPermissions:        0700,
+					TargetPathPermissions: 0700,
+					UnpublishGrace:        grace,
+				},
+				cm:      cm,
+				renewer: renew.New(dataRoot, nil, nil),
+				mount: func(source, target string, options []string) error {
+					return nil
+				},
+				unmount: func(target string) error {
+					return nil
+				},
+			}
+			defer ns.renewer.KillWatcher("test-id")
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: filepath.Join(dataRoot, "test-id"),
+				Attributes: map[string]string{
+					csiapi.CSIPodNameKey:      "test-pod",
+					csiapi.CSIPodNamespaceKey: "test-namespace",
+					csiapi.CertFileKey:        "crt.pem",
+					csiapi.KeyFileKey:         "key.pem",
+				},
+			}
+			writeTestCertificate(t, vol)
+
+			_, err = ns.NodeUnpublishVolume(context.TODO(), &csi.NodeUnpublishVolumeRequest{
+				VolumeId:   "test-id",
+				TargetPath: targetPath,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := os.Stat(vol.Path); err != nil {
+				t.Fatalf("expected volume directory to be kept during the grace period: %s", err)
+			}
+
+			if republish {
+				_, err = ns.NodePublishVolume(context.TODO(), &csi.NodePublishVolumeRequest{
+					VolumeId:   "test-id",
+					TargetPath: targetPath,
+					VolumeContext: map[string]string{
+						csiapi.CSIPodNameKey:      "test-pod",
+						csiapi.CSIPodNamespaceKey: "test-namespace",
+						csiapi.IssuerNameKey:      "ca-issuer",
+					},
+					VolumeCapability: &csi.VolumeCapability{},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				time.Sleep(grace * 2)
+
+				if _, err := os.Stat(util.CertPath(vol)); err != nil {
+					t.Errorf("expected certificate of republished volume to be kept: %s", err)
+				}
+
+				for _, action := range cmClient.Actions() {
+					if action.Matches("create", "certificaterequests") || action.Matches("delete", "certificaterequests") {
+						t.Errorf("unexpected %s of CertificateRequest of republished volume", action.GetVerb())
+					}
+				}
+
+				if !ns.renewer.IsWatching("test-id") {
+					t.Error("expected republished volume to be watched for renewal")
+				}
+
+				return
+			}
+
+			err = wait.PollImmediate(time.Millisecond*10, time.Second*5, func() (bool, error) {
+				_, err := os.Stat(vol.Path)
+				return os.IsNotExist(err), nil
+			})
+			if err != nil {
+				t.Fatalf("expected volume directory to be removed after the grace period: %s", err)
+			}
+
+			_, err = cmClient.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+			if !k8sErrors.IsNotFound(err) {
+				t.Errorf("expected CertificateRequest to be deleted, got: %v", err)
+			}
+		})
+	}
+}
+
+// writeTestCertificate writes a valid self signed certificate, its key and
+// the metadata file of the volume.
+func writeTestCertificate(t *testing.T, vol *csiapi.MetaData) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "foo"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(util.MountPath(vol), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, data := range map[string][]byte{
+		util.CertPath(vol): pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		util.KeyPath(vol):  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	} {
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := util.WriteMetaDataFile(vol, false, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnpublishDeletesCertificateRequest(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-unpublish-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dataRoot := filepath.Join(dir, "data")
+
+	cmClient := cmfake.NewSimpleClientset(&cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-id",
+			Namespace: "test-namespace",
+		},
+	})
+
+	cm, err := certmanager.NewWithClient(cmClient, kubefake.NewSimpleClientset(), new(options.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var unmounted string
+	ns := &NodeServer{
+		nodeID:   "test-node",
+		dataRoot: dataRoot,
+		opts:     new(options.Options),
+		cm:       cm,
+		renewer:  renew.New(dataRoot, nil, nil),
+		unmount: func(target string) error {
+			unmounted = target
+			return nil
+		},
+	}
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: filepath.Join(dataRoot, "test-id"),
+		Attributes: map[string]string{
+			csiapi.CSIPodNameKey:      "test-pod",
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+		},
+	}
+	writeTestCertificate(t, vol)
+
+	targetPath := filepath.Join(dir, "target")
+	_, err = ns.NodeUnpublishVolume(context.TODO(), &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "test-id",
+		TargetPath: targetPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if unmounted != targetPath {
+		t.Errorf("unexpected unmount target, exp=%q got=%q", targetPath, unmounted)
+	}
+
+	if _, err := os.Stat(vol.Path); !os.IsNotExist(err) {
+		t.Errorf("expected volume directory to be removed, got: %v", err)
+	}
+
+	_, err = cmClient.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+	if !k8sErrors.IsNotFound(err) {
+		t.Errorf("expected CertificateRequest to be deleted, got: %v", err)
+	}
+}
+
+func TestUnpublishUnmountError(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-unpublish-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dataRoot := filepath.Join(dir, "data")
+
+	cmClient := cmfake.NewSimpleClientset(&cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-id",
+			Namespace: "test-namespace",
+		},
+	})
+
+	cm, err := certmanager.NewWithClient(cmClient, kubefake.NewSimpleClientset(), new(options.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns := &NodeServer{
+		nodeID:   "test-node",
+		dataRoot: dataRoot,
+		opts:     new(options.Options),
+		cm:       cm,
+		renewer:  renew.New(dataRoot, nil, nil),
+		unmount: func(target string) error {
+			return errors.New("device or resource busy")
+		},
+	}
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: filepath.Join(dataRoot, "test-id"),
+		Attributes: map[string]string{
+			csiapi.CSIPodNameKey:      "test-pod",
+			csiapi.CSIPodNamespaceKey: "test-namespace",
+			csiapi.CertFileKey:        "crt.pem",
+			csiapi.KeyFileKey:         "key.pem",
+		},
+	}
+	writeTestCertificate(t, vol)
+
+	_, err = ns.NodeUnpublishVolume(context.TODO(), &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "test-id",
+		TargetPath: filepath.Join(dir, "target"),
+	})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected internal error when unmount fails, got: %v", err)
+	}
+
+	// The volume is still mounted, so should be left for kubelet to retry.
+	if _, err := os.Stat(vol.Path); err != nil {
+		t.Errorf("expected volume directory to be kept: %s", err)
+	}
+
+	_, err = cmClient.CertmanagerV1alpha2().CertificateRequests("test-namespace").Get("test-id", metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("expected CertificateRequest to be kept, got: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "certmanagercsi"
+
+	// Issuance phases observed by IssuancePhaseDuration.
+	PhaseCreate = "create"
+	PhaseWait   = "wait"
+	PhaseWrite  = "write"
+
+	// Operations and results counted by Issuance.
+	OperationIssue = "issue"
+	OperationRenew = "renew"
+	ResultSuccess  = "success"
+	ResultError    = "error"
+
+	// Reasons counted by CertificateRequestsDeleted.
+	DeleteReasonMismatch  = "mismatch"
+	DeleteReasonRenew     = "renew"
+	DeleteReasonFallback  = "fallback"
+	DeleteReasonUnpublish = "unpublish"
+)
+
+var (
+	// PostIssueHookFailures counts the number of post issue hook executions
+	// that have failed or timed out.
+	PostIssueHookFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "post_issue_hook_failures_total",
+			Help:      "Number of post issue hook executions that have failed.",
+		},
+	)
+
+	// DurationTruncated counts the certificates issued with a shorter
+	// duration than requested.
+	DurationTruncated = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "duration_truncated_total",
+			Help:      "Number of certificates issued with a shorter duration than requested.",
+		},
+	)
+
+	// IssuancePhaseDuration observes the time spent in each phase of
+	// issuing a certificate.
+	IssuancePhaseDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "issuance_phase_seconds",
+			Help:      "Time taken for each phase of certificate issuance.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+		},
+		[]string{"phase"},
+	)
+
+	// IssuedCertificates counts the certificates issued by each issuer, and
+	// whether the issuer was used as a fallback.
+	IssuedCertificates = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "issued_certificates_total",
+			Help:      "Number of certificates issued by each issuer.",
+		},
+		[]string{"issuer_name", "issuer_kind", "issuer_group", "fallback"},
+	)
+
+	// Issuance counts the successes and errors of issuing and renewing
+	// certificates by the issuer of the volume. The namespace is empty unless
+	// high cardinality metrics are enabled.
+	Issuance = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "issuance_total",
+			Help:      "Number of certificate issuances and renewals by issuer and result.",
+		},
+		[]string{"operation", "issuer_name", "issuer_kind", "namespace", "result"},
+	)
+
+	// CertificateRequestsCreated counts the CertificateRequests created for
+	// volumes. The namespace is empty unless high cardinality metrics are
+	// enabled.
+	CertificateRequestsCreated = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "certificaterequests_created_total",
+			Help:      "Number of CertificateRequests created for volumes.",
+		},
+		[]string{"namespace"},
+	)
+
+	// CertificateRequestsDeleted counts the CertificateRequests of volumes
+	// deleted by the driver, by why they were deleted. The namespace is empty
+	// unless high cardinality metrics are enabled.
+	CertificateRequestsDeleted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "certificaterequests_deleted_total",
+			Help:      "Number of CertificateRequests of volumes deleted by reason.",
+		},
+		[]string{"namespace", "reason"},
+	)
+
+	// NextRenewalTimestamp is the time each watched volume's certificate is
+	// scheduled to be renewed. A value in the past means renewal is overdue.
+	// The series of a volume is removed once it is no longer watched.
+	NextRenewalTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "next_renewal_timestamp_seconds",
+			Help:      "Unix time the certificate of a volume is scheduled to be renewed.",
+		},
+		[]string{"volume_id"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		PostIssueHookFailures,
+		DurationTruncated,
+		IssuancePhaseDuration,
+		IssuedCertificates,
+		Issuance,
+		CertificateRequestsCreated,
+		CertificateRequestsDeleted,
+		NextRenewalTimestamp,
+	)
+}
+
+// Serve will serve the registered Prometheus metrics on the given address
+// at /metrics. This function blocks.
+func Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	glog.Infof("metrics: serving on %s", addr)
+
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		glog.Errorf("metrics: failed to serve: %s", err)
+	}
+}
@@ -1,156 +1,435 @@
 package renew
 
 import (
+	"bytes"
 	"crypto/x509"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang/glog"
-	"github.com/jetstack/cert-manager/pkg/util/pki"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/metrics"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
 )
 
 type Renewer struct {
-	dataDir string
+	dataDirs []string
 
 	watchingVols map[string]chan struct{}
+	renewVols    map[string]chan struct{}
 	muVol        sync.RWMutex
 
+	// Metadata of watched volumes, to restore their files from.
+	watchedMetaData map[string]*csiapi.MetaData
+
+	// Stop channels of volumes whose certificate is being issued
+	// asynchronously, closed once their watcher is killed.
+	issuingVols map[string]chan struct{}
+
+	// Scheduled renewal times and last renewal errors of watched volumes.
+	nextRenewals map[string]time.Time
+	lastErrors   map[string]string
+
 	renewFunc RenewFunc
+	caFunc    CAFunc
+
+	// clock is used to schedule renewals and CA refreshes.
+	clock clock.Clock
+
+	// discovered is closed once discovery has succeeded.
+	discovered chan struct{}
+
+	// retryMaxBackoff caps the backoff between failed renewal attempts.
+	retryMaxBackoff time.Duration
+
+	// discoverConcurrency is the number of volumes read at once during
+	// discovery.
+	discoverConcurrency int
+
+	// fsyncFiles syncs the files written to volumes to disk.
+	fsyncFiles bool
+
+	// autoRenewBeforeFraction is the fraction of a certificate's lifetime
+	// remaining when it is renewed, if the volume sets no renewal strategy.
+	autoRenewBeforeFraction float64
+
+	// autoRenewEnabled is closed once auto renewal is re-enabled after being
+	// globally disabled. It is nil while auto renewal is enabled.
+	autoRenewMu      sync.Mutex
+	autoRenewEnabled chan struct{}
+}
+
+// discoverBackoff is the backoff between failed discovery attempts.
+var discoverBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Steps:    math.MaxInt32,
+	Cap:      time.Second * 30,
 }
 
+// retryBackoff is the backoff between failed renewal attempts of a
+// certificate, capped by the renewer's retryMaxBackoff.
+var retryBackoff = wait.Backoff{
+	Duration: time.Second * 10,
+	Factor:   2,
+	Steps:    math.MaxInt32,
+}
+
+// DefaultRetryMaxBackoff is the default cap of the backoff between failed
+// renewal attempts.
+const DefaultRetryMaxBackoff = time.Minute * 5
+
+// DefaultDiscoverConcurrency is the default number of volumes read at once
+// during discovery.
+const DefaultDiscoverConcurrency = 8
+
+// finalRetryMargin is how long before expiry the final renewal attempt is
+// made, if the backoff would otherwise retry after the certificate has
+// expired.
+const finalRetryMargin = time.Second * 5
+
 type certToWatch struct {
-	base     string
-	metaData *csiapi.MetaData
-	notAfter time.Time
+	base      string
+	metaData  *csiapi.MetaData
+	notBefore time.Time
+	notAfter  time.Time
 }
 
 type RenewFunc func(vol *csiapi.MetaData) (*x509.Certificate, error)
 
-func New(dataDir string, renewFunc RenewFunc) *Renewer {
+// CAFunc returns the current PEM encoded CA of the issuer of a volume.
+type CAFunc func(vol *csiapi.MetaData) ([]byte, error)
+
+func New(dataDir string, renewFunc RenewFunc, caFunc CAFunc) *Renewer {
 	return &Renewer{
-		dataDir:      dataDir,
-		watchingVols: make(map[string]chan struct{}),
-		renewFunc:    renewFunc,
+		dataDirs:        []string{dataDir},
+		watchingVols:    make(map[string]chan struct{}),
+		renewVols:       make(map[string]chan struct{}),
+		watchedMetaData: make(map[string]*csiapi.MetaData),
+		issuingVols:     make(map[string]chan struct{}),
+		nextRenewals:    make(map[string]time.Time),
+		lastErrors:      make(map[string]string),
+		renewFunc:       renewFunc,
+		caFunc:          caFunc,
+		clock:           clock.RealClock{},
+		discovered:      make(chan struct{}),
+		retryMaxBackoff: DefaultRetryMaxBackoff,
+
+		discoverConcurrency:     DefaultDiscoverConcurrency,
+		autoRenewBeforeFraction: DefaultAutoRenewBeforeFraction,
 	}
 }
 
+// AddDataDir adds a further directory to discover volumes in. Must be called
+// before discovery.
+func (r *Renewer) AddDataDir(dir string) {
+	r.dataDirs = append(r.dataDirs, dir)
+}
+
+// SetRetryMaxBackoff sets the cap of the backoff between failed renewal
+// attempts. Must be called before any certificates are watched.
+func (r *Renewer) SetRetryMaxBackoff(d time.Duration) {
+	r.retryMaxBackoff = d
+}
+
+// SetDiscoverConcurrency sets the number of volumes read at once during
+// discovery. Must be called before discovery.
+func (r *Renewer) SetDiscoverConcurrency(n int) {
+	r.discoverConcurrency = n
+}
+
+// SetFsyncFiles sets whether the files written to volumes are synced to disk.
+// Must be called before discovery.
+func (r *Renewer) SetFsyncFiles(fsync bool) {
+	r.fsyncFiles = fsync
+}
+
+// SetAutoRenewBeforeFraction sets the fraction of a certificate's lifetime
+// remaining when it is renewed, if the volume sets no renewal strategy. Must be
+// called before any certificates are watched.
+func (r *Renewer) SetAutoRenewBeforeFraction(f float64) {
+	r.autoRenewBeforeFraction = f
+}
+
+// SetAutoRenewDisabled globally disables, or re-enables, the renewal of every
+// watched certificate. Renewals due while disabled, including those triggered
+// with RenewNow, are held until auto renewal is re-enabled. CAs are still
+// refreshed.
+func (r *Renewer) SetAutoRenewDisabled(disabled bool) {
+	r.autoRenewMu.Lock()
+	defer r.autoRenewMu.Unlock()
+
+	switch {
+	case disabled && r.autoRenewEnabled == nil:
+		glog.Warningf("renewer: AUTO RENEWAL IS GLOBALLY DISABLED, no certificates will be renewed until it is re-enabled")
+		r.autoRenewEnabled = make(chan struct{})
+
+	case !disabled && r.autoRenewEnabled != nil:
+		glog.Infof("renewer: auto renewal re-enabled, renewing certificates that became due while disabled")
+		close(r.autoRenewEnabled)
+		r.autoRenewEnabled = nil
+	}
+}
+
+// AutoRenewDisabled returns true if auto renewal is globally disabled.
+func (r *Renewer) AutoRenewDisabled() bool {
+	return r.autoRenewEnabledCh() != nil
+}
+
+// autoRenewEnabledCh returns a channel closed once auto renewal is
+// re-enabled, or nil if it is enabled.
+func (r *Renewer) autoRenewEnabledCh() <-chan struct{} {
+	r.autoRenewMu.Lock()
+	defer r.autoRenewMu.Unlock()
+
+	if r.autoRenewEnabled == nil {
+		return nil
+	}
+
+	return r.autoRenewEnabled
+}
+
+// Discover watches the certificates of all existing volumes under the data
+// dirs. Volumes that fail to be discovered don't stop the others from being
+// watched, and are returned as an error so that discovery is retried.
 func (r *Renewer) Discover() error {
-	glog.Infof("renewer: starting discovery on %q", r.dataDir)
+	glog.Infof("renewer: starting discovery on %q with concurrency %d", r.dataDirs, r.discoverConcurrency)
+
+	var errs []string
 
 	certsToWatch, err := r.walkDir()
 	if err != nil {
-		return err
+		errs = append(errs, err.Error())
 	}
 
-	var errs []string
 	for _, f := range certsToWatch {
+		// Already watched by a previous, partially failed, discovery.
+		if r.IsWatching(f.metaData.ID) {
+			continue
+		}
+
 		glog.Infof("renewer: watching new volume for certificate renewal %q", f.base)
 
-		if err := r.WatchCert(f.metaData, f.notAfter); err != nil {
-			errs = append(errs, fmt.Sprintf("%q: %s",
+		if err := r.WatchCert(f.metaData, f.notBefore, f.notAfter); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to start watching cert %q: %s",
 				f.metaData.ID, err))
 		}
 	}
 
 	if len(errs) > 0 {
-		return fmt.Errorf("failed to start watching certs: %s",
-			strings.Join(errs, ", "))
+		return errors.New(strings.Join(errs, ", "))
 	}
 
 	return nil
 }
 
+// DiscoverWithRetry runs Discover, retrying with backoff until it succeeds.
+// It returns once discovery has succeeded, or with an error once the timeout
+// has elapsed, in which case discovery is still retried in the background.
+func (r *Renewer) DiscoverWithRetry(timeout time.Duration) error {
+	go func() {
+		backoff := discoverBackoff
+
+		for {
+			err := r.Discover()
+			if err == nil {
+				break
+			}
+
+			d := backoff.Step()
+			glog.Errorf("renewer: discovery failed, retrying in %s: %s", d, err)
+			<-r.clock.After(d)
+		}
+
+		close(r.discovered)
+	}()
+
+	select {
+	case <-r.discovered:
+		return nil
+	case <-r.clock.After(timeout):
+		return fmt.Errorf("discovery did not succeed within %s, retrying in the background", timeout)
+	}
+}
+
+// Discovered returns true once discovery has succeeded.
+func (r *Renewer) Discovered() bool {
+	select {
+	case <-r.discovered:
+		return true
+	default:
+		return false
+	}
+}
+
+// walkDir reads the certificates of all volumes under the data dirs, with up
+// to discoverConcurrency volumes read at once. It returns the certificates of
+// every volume that could be read, along with the errors of those that
+// couldn't.
 func (r *Renewer) walkDir() ([]certToWatch, error) {
-	files, err := ioutil.ReadDir(r.dataDir)
+	var errs []string
+	var volPaths []string
+	for _, dataDir := range r.dataDirs {
+		paths, err := volumeDirs(dataDir)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		volPaths = append(volPaths, paths...)
+	}
+
+	concurrency := r.discoverConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu           sync.Mutex
+		wg           sync.WaitGroup
+		certsToWatch []certToWatch
+		volErrs      []string
+	)
+
+	pathCh := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for path := range pathCh {
+				cert, err := r.readVolume(path)
+
+				mu.Lock()
+				if err != nil {
+					volErrs = append(volErrs, err.Error())
+				} else if cert != nil {
+					certsToWatch = append(certsToWatch, *cert)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, path := range volPaths {
+		pathCh <- path
+	}
+	close(pathCh)
+	wg.Wait()
+
+	// Volumes are read in any order.
+	sort.Strings(volErrs)
+	sort.Slice(certsToWatch, func(i, j int) bool {
+		return certsToWatch[i].base < certsToWatch[j].base
+	})
+
+	errs = append(errs, volErrs...)
+	if len(errs) > 0 {
+		return certsToWatch, errors.New(strings.Join(errs, ", "))
+	}
+
+	return certsToWatch, nil
+}
+
+// volumeDirs returns the paths of the volume directories in the data dir.
+func volumeDirs(dataDir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dataDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read data dir: %s", err)
 	}
 
-	var errs []string
-	var certsToWatch []certToWatch
+	var paths []string
 	for _, f := range files {
-		fPath := filepath.Join(r.dataDir, f.Name())
+		fPath := filepath.Join(dataDir, f.Name())
 
 		glog.V(4).Infof("renewer: trying discovery on %q", fPath)
 
-		// not a directory or not a csi directory
-		base := filepath.Base(fPath)
-		if !f.IsDir() ||
-			!strings.HasPrefix(base, "cert-manager-csi") {
-			glog.V(4).Infof("renewer: file not a directory or doesn't have \"cert-manger-csi\" prefix: %q", f.Name())
+		// not a directory or not named after a volume ID
+		if !f.IsDir() || !util.IsVolumeID(f.Name()) {
+			glog.V(4).Infof("renewer: file not a directory or not a volume ID: %q", f.Name())
 			continue
 		}
 
-		metaPath := filepath.Join(fPath, csiapi.MetaDataFileName)
-		b, err := ioutil.ReadFile(metaPath)
-		if err != nil {
-			// meta data file doesn't exist, move on
-			if os.IsNotExist(err) {
-				glog.V(4).Infof("renewer: metadata file not found: %q", metaPath)
-				continue
-			}
+		paths = append(paths, fPath)
+	}
 
-			errs = append(errs,
-				fmt.Sprintf("failed to read metadata file: %s", err))
-			continue
-		}
+	return paths, nil
+}
 
-		metaData := new(csiapi.MetaData)
-		if err := json.Unmarshal(b, metaData); err != nil {
-			errs = append(errs,
-				fmt.Sprintf("failed to unmarshal metadata file for %q: %s", f.Name(), err.Error()))
-			continue
-		}
+// readVolume reads the metadata and certificate of the volume at the given
+// path, checking its key can be parsed. It returns nil if the directory holds
+// no metadata file.
+func (r *Renewer) readVolume(fPath string) (*certToWatch, error) {
+	base := filepath.Base(fPath)
 
-		keyBytes, err := r.readFile(fPath, metaData.Attributes[csiapi.KeyFileKey])
-		if err != nil {
-			errs = append(errs, err.Error())
-			continue
+	metaPath := filepath.Join(fPath, csiapi.MetaDataFileName)
+	metaData, err := util.ReadMetaDataFile(metaPath)
+	if err != nil {
+		// meta data file doesn't exist, move on
+		if os.IsNotExist(err) {
+			glog.V(4).Infof("renewer: metadata file not found: %q", metaPath)
+			return nil, nil
 		}
 
-		if _, err := pki.DecodePrivateKeyBytes(keyBytes); err != nil {
-			errs = append(errs, fmt.Sprintf("%q: failed to parse key file: %s",
-				f.Name(), err))
-			continue
+		return nil, fmt.Errorf("failed to read metadata file for %q: %s", base, err)
+	}
+
+	// A volume still waiting on its first CertificateRequest across retried
+	// publishes has no files yet, and is watched once it has been published.
+	if metaData.RequestCreated != nil {
+		certPath := filepath.Join(fPath, "data", metaData.Attributes[csiapi.CertFileKey])
+		if _, err := os.Stat(certPath); os.IsNotExist(err) {
+			glog.V(4).Infof("renewer: issuance of volume pending: %q", base)
+			return nil, nil
 		}
+	}
 
-		certBytes, err := r.readFile(fPath, metaData.Attributes[csiapi.CertFileKey])
+	encoding := metaData.Attributes[csiapi.EncodingKey]
+
+	// Volumes with a workload provided CSR or external key have no key
+	// file, nor do volumes delivering their key by FIFO, whose pipe would
+	// block the read until a reader took the key.
+	if util.WritesPrivateKey(metaData) && !util.DeliversKeyByFIFO(metaData) {
+		keyBytes, err := r.readFile(fPath, metaData.Attributes[csiapi.KeyFileKey])
 		if err != nil {
-			errs = append(errs, err.Error())
-			continue
+			return nil, err
 		}
 
-		cert, err := pki.DecodeX509CertificateBytes(certBytes)
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("%q: failed to parse cert file: %s",
-				f.Name(), err))
-			continue
+		if _, _, err := util.DecodePrivateKey(keyBytes, encoding); err != nil {
+			return nil, fmt.Errorf("%q: failed to parse key file: %s", base, err)
 		}
+	}
 
-		certsToWatch = append(certsToWatch, certToWatch{
-			base:     base,
-			metaData: metaData,
-			notAfter: cert.NotAfter,
-		})
+	certBytes, err := r.readFile(fPath, metaData.Attributes[csiapi.CertFileKey])
+	if err != nil {
+		return nil, err
 	}
 
-	if len(errs) > 0 {
-		return nil, errors.New(strings.Join(errs, ", "))
+	cert, err := util.DecodeCertificate(certBytes, encoding)
+	if err != nil {
+		return nil, fmt.Errorf("%q: failed to parse cert file: %s", base, err)
 	}
 
-	return certsToWatch, nil
+	return &certToWatch{
+		base:      base,
+		metaData:  metaData,
+		notBefore: cert.NotBefore,
+		notAfter:  cert.NotAfter,
+	}, nil
 }
 
-func (r *Renewer) WatchCert(metaData *csiapi.MetaData, notAfter time.Time) error {
+func (r *Renewer) WatchCert(metaData *csiapi.MetaData, notBefore, notAfter time.Time) error {
 	r.muVol.Lock()
 	defer r.muVol.Unlock()
 
@@ -160,53 +439,331 @@ func (r *Renewer) WatchCert(metaData *csiapi.MetaData, notAfter time.Time) error
 		return nil
 	}
 
-	renewBefore, err := time.ParseDuration(
-		metaData.Attributes[csiapi.RenewBeforeKey])
+	strategy, err := StrategyForAttributes(metaData.Attributes, r.autoRenewBeforeFraction)
 	if err != nil {
-		return fmt.Errorf("failed to parse renew before: %s", err)
+		return err
+	}
+
+	var caRefresh time.Duration
+	if s := metaData.Attributes[csiapi.CARefreshIntervalKey]; len(s) > 0 {
+		caRefresh, err = time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("failed to parse ca refresh interval: %s", err)
+		}
 	}
 
 	ch := make(chan struct{})
+	renewCh := make(chan struct{}, 1)
 	r.watchingVols[metaData.ID] = ch
+	r.renewVols[metaData.ID] = renewCh
+	r.watchedMetaData[metaData.ID] = metaData
 
 	glog.Infof("renewer: starting to watch certificate for renewal: %q", metaData.ID)
 
-	renewalTime := notAfter.Add(-renewBefore)
+	now := r.clock.Now()
+	renewalTime := strategy.RenewalTime(notBefore, notAfter, now)
+	r.recordNextRenewal(metaData, notAfter, renewalTime)
 
-	timer := time.NewTimer(time.Until(renewalTime))
+	timer := r.clock.NewTimer(renewalTime.Sub(now))
 
 	go func() {
-		select {
-		case <-ch:
-			timer.Stop()
-			return
-		case <-timer.C:
+		defer func() { timer.Stop() }()
+
+		var refreshCh <-chan time.Time
+		if caRefresh > 0 && r.caFunc != nil {
+			ticker := r.clock.NewTicker(caRefresh)
+			defer ticker.Stop()
+			refreshCh = ticker.C()
+		}
+
+		backoff := retryBackoff
+		backoff.Cap = r.retryMaxBackoff
+
+		// Closed once auto renewal is re-enabled, while a due renewal is
+		// held.
+		var enabledCh <-chan struct{}
+
+		var attempts int
+		for {
+			select {
+			case <-ch:
+				return
+			case <-refreshCh:
+				if err := r.refreshCA(metaData); err != nil {
+					glog.Errorf("renewer: failed to refresh ca of %q: %s",
+						metaData.ID, err)
+				}
+				continue
+			case <-enabledCh:
+			case <-renewCh:
+				glog.Infof("renewer: renewal triggered for certificate %q", metaData.ID)
+			case <-timer.C():
+			}
+
+			enabledCh = r.autoRenewEnabledCh()
+			if enabledCh != nil {
+				glog.Warningf("renewer: auto renewal globally disabled, holding renewal of certificate %q", metaData.ID)
+				continue
+			}
+
 			cert, err := r.renewFunc(metaData)
-			if err != nil {
-				glog.Errorf("renewer: failed to renew certificate %q: %s",
-					metaData.ID, err)
+			if err == nil {
+				// Remove this watcher so that the volume can be watched again
+				// once renewed, unless it was killed during the renewal.
+				r.muVol.Lock()
+				killed := r.watchingVols[metaData.ID] != ch
+				if !killed {
+					delete(r.watchingVols, metaData.ID)
+					delete(r.renewVols, metaData.ID)
+					delete(r.lastErrors, metaData.ID)
+				}
+				r.muVol.Unlock()
+
+				if killed {
+					return
+				}
+
+				if err := r.WatchCert(metaData, cert.NotBefore, cert.NotAfter); err != nil {
+					glog.Errorf("renewer: failed to watch certificate %q: %s",
+						metaData.ID, err)
+				}
+
 				return
 			}
 
-			if err := r.WatchCert(metaData, cert.NotBefore); err != nil {
-				glog.Errorf("renewer: failed to watch certificate %q: %s",
-					metaData.ID, err)
+			r.muVol.Lock()
+			if r.watchingVols[metaData.ID] == ch {
+				r.lastErrors[metaData.ID] = err.Error()
+			}
+			r.muVol.Unlock()
+
+			attempts++
+			d, final := nextRetry(&backoff, r.clock.Now(), notAfter)
+			if final {
+				glog.Errorf("renewer: failed to renew certificate %q (attempt %d), making final attempt before expiry in %s: %s",
+					metaData.ID, attempts, d, err)
+			} else {
+				glog.Errorf("renewer: failed to renew certificate %q (attempt %d), retrying in %s (max backoff %s): %s",
+					metaData.ID, attempts, d, backoff.Cap, err)
 			}
+
+			timer.Stop()
+			timer = r.clock.NewTimer(d)
 		}
 	}()
 
 	return nil
 }
 
-func (r *Renewer) KillWatcher(volID string) {
+// nextRetry returns the duration until the next renewal attempt. If the
+// backoff would retry after the certificate has expired, the retry is instead
+// brought forward to just before expiry and final is returned true. After the
+// final attempt, renewal continues to be retried with the backoff.
+func nextRetry(backoff *wait.Backoff, now, notAfter time.Time) (time.Duration, bool) {
+	d := backoff.Step()
+
+	remaining := notAfter.Sub(now)
+	if remaining <= finalRetryMargin || now.Add(d).Before(notAfter) {
+		return d, false
+	}
+
+	d = remaining - finalRetryMargin
+	if d < 0 {
+		d = 0
+	}
+
+	return d, true
+}
+
+// recordNextRenewal exposes the scheduled renewal time of the volume's
+// certificate in the volume's status file and as a metric. The previous value
+// is kept if a renewal fails so that overdue renewals can be alerted on. Must
+// be called with muVol held.
+func (r *Renewer) recordNextRenewal(metaData *csiapi.MetaData, notAfter, renewalTime time.Time) {
+	r.nextRenewals[metaData.ID] = renewalTime
+
+	metrics.NextRenewalTimestamp.WithLabelValues(metaData.ID).Set(float64(renewalTime.Unix()))
+
+	err := util.WriteStatusFile(metaData, &csiapi.Status{
+		NotAfter:      notAfter,
+		NextRenewal:   renewalTime,
+		CorrelationID: metaData.Attributes[csiapi.CorrelationIDKey],
+	}, r.fsyncFiles)
+	if err != nil {
+		glog.Errorf("renewer: failed to write status file of %q: %s",
+			metaData.ID, err)
+	}
+}
+
+// refreshCA compares the CA stored in the volume with the current CA of its
+// issuer, and overwrites the stored CA if it has changed. The certificate
+// itself is not re-issued.
+func (r *Renewer) refreshCA(metaData *csiapi.MetaData) error {
+	caPEM, err := r.caFunc(metaData)
+	if err != nil {
+		return err
+	}
+
+	if len(caPEM) == 0 {
+		return nil
+	}
+
+	caBytes, err := util.EncodeVolumeFile(caPEM, metaData.Attributes)
+	if err != nil {
+		return err
+	}
+
+	caPath := util.CAPath(metaData)
+
+	current, err := ioutil.ReadFile(caPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if bytes.Equal(current, caBytes) {
+		return nil
+	}
+
+	glog.Infof("renewer: ca of %q has changed, updating %q", metaData.ID, caPath)
+
+	files := map[string][]byte{
+		metaData.Attributes[csiapi.CAFileKey]: caBytes,
+	}
+
+	if metaData.Attributes[csiapi.SplitCAKey] == "true" {
+		caFiles, err := util.SplitCAFiles(metaData, caPEM)
+		if err != nil {
+			return err
+		}
+
+		for name, b := range caFiles {
+			files[name] = b
+		}
+	}
+
+	return util.WriteDataFiles(metaData, files, r.fsyncFiles)
+}
+
+// IsWatching returns true if the certificate of the given volume is being
+// watched for renewal.
+func (r *Renewer) IsWatching(volID string) bool {
+	r.muVol.RLock()
+	defer r.muVol.RUnlock()
+
+	_, ok := r.watchingVols[volID]
+	return ok
+}
+
+// WatchedVolumes returns a copy of the metadata of each watched volume, such
+// as to restore their metadata files from should the data root be lost.
+func (r *Renewer) WatchedVolumes() []*csiapi.MetaData {
+	r.muVol.RLock()
+	defer r.muVol.RUnlock()
+
+	vols := make([]*csiapi.MetaData, 0, len(r.watchedMetaData))
+	for _, metaData := range r.watchedMetaData {
+		vol := *metaData
+		vols = append(vols, &vol)
+	}
+
+	return vols
+}
+
+// VolumeState is the in-memory renewal state of a watched volume.
+type VolumeState struct {
+	// Time the certificate is scheduled to be renewed.
+	NextRenewal time.Time
+
+	// Error of the last failed renewal attempt, cleared once renewed.
+	LastError string
+}
+
+// State returns the renewal state of the given volume, and false if it is not
+// being watched.
+func (r *Renewer) State(volID string) (VolumeState, bool) {
+	r.muVol.RLock()
+	defer r.muVol.RUnlock()
+
+	if _, ok := r.watchingVols[volID]; !ok {
+		return VolumeState{}, false
+	}
+
+	return VolumeState{
+		NextRenewal: r.nextRenewals[volID],
+		LastError:   r.lastErrors[volID],
+	}, true
+}
+
+// RenewNow triggers an immediate renewal of the certificate of the given
+// volume, if it is being watched. Returns false if the volume is not being
+// watched.
+func (r *Renewer) RenewNow(volID string) bool {
 	r.muVol.RLock()
 	defer r.muVol.RUnlock()
 
+	renewCh, ok := r.renewVols[volID]
+	if !ok {
+		return false
+	}
+
+	select {
+	case renewCh <- struct{}{}:
+	default:
+		// renewal already triggered
+	}
+
+	return true
+}
+
+// StartIssuance returns a channel that is closed once the watcher of the
+// volume is killed, so that an asynchronous issuance of the volume stops when
+// it is unpublished or re-issued. The returned func must be called once the
+// issuance has finished.
+func (r *Renewer) StartIssuance(volID string) (<-chan struct{}, func()) {
+	r.muVol.Lock()
+	defer r.muVol.Unlock()
+
+	// An earlier issuance of the volume is superseded by this one.
+	if ch, ok := r.issuingVols[volID]; ok {
+		close(ch)
+	}
+
+	ch := make(chan struct{})
+	r.issuingVols[volID] = ch
+
+	return ch, func() {
+		r.muVol.Lock()
+		defer r.muVol.Unlock()
+
+		if r.issuingVols[volID] == ch {
+			delete(r.issuingVols, volID)
+		}
+	}
+}
+
+func (r *Renewer) KillWatcher(volID string) {
+	r.muVol.Lock()
+	defer r.muVol.Unlock()
+
 	ch, ok := r.watchingVols[volID]
 	if ok {
 		glog.Infof("renewer: killing watcher for %q", volID)
 		close(ch)
+		delete(r.watchingVols, volID)
+		delete(r.renewVols, volID)
+		delete(r.watchedMetaData, volID)
 	}
+
+	if ch, ok := r.issuingVols[volID]; ok {
+		glog.Infof("renewer: stopping issuance of %q", volID)
+		close(ch)
+		delete(r.issuingVols, volID)
+	}
+
+	delete(r.nextRenewals, volID)
+	delete(r.lastErrors, volID)
+
+	metrics.NextRenewalTimestamp.DeleteLabelValues(volID)
 }
 
 func (r *Renewer) readFile(rootPath, path string) ([]byte, error) {
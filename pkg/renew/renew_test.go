@@ -13,12 +13,18 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/jetstack/cert-manager/pkg/util/pki"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/metrics"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
 )
 
 type walkDirT struct {
@@ -45,6 +51,11 @@ type certKeyPair struct {
 func TestWalkDir(t *testing.T) {
 	keyCertPair1 := genKeyCertPair(t)
 	keyCertPair2 := genKeyCertPair(t)
+	requestCreated := time.Now()
+
+	// Volume directories are named after their volume ID.
+	volID1 := util.BuildVolumeID("test-uid", "test-1")
+	volID2 := util.BuildVolumeID("test-uid", "test-2")
 
 	tests := map[string]walkDirT{
 		"if no directories exist then nothing returned and no error": {
@@ -56,7 +67,7 @@ func TestWalkDir(t *testing.T) {
 		"if no metadata file then nothing returned and no error": {
 			volDirs: []volDir{
 				{
-					name:     "test-1",
+					name:     volID1,
 					cert:     nil,
 					key:      nil,
 					certPath: "cert.pem",
@@ -70,7 +81,7 @@ func TestWalkDir(t *testing.T) {
 		"if no cert and key data is bad then error": {
 			volDirs: []volDir{
 				{
-					name: "test-1",
+					name: volID1,
 					cert: []byte("foo"),
 					key:  []byte("bar"),
 					metaData: &csiapi.MetaData{
@@ -82,13 +93,13 @@ func TestWalkDir(t *testing.T) {
 				},
 			},
 			expCertsToWatch: nil,
-			expError:        errors.New(`"cert-manager-csi-test-1": failed to parse key file: error decoding private key PEM block`),
+			expError:        fmt.Errorf("%q: failed to parse key file: error decoding private key PEM block", volID1),
 		},
 
 		"if key but bad cert data then error": {
 			volDirs: []volDir{
 				{
-					name: "test-1",
+					name: volID1,
 					cert: []byte("foo"),
 					key:  keyCertPair1.pkData,
 					metaData: &csiapi.MetaData{
@@ -100,13 +111,48 @@ func TestWalkDir(t *testing.T) {
 				},
 			},
 			expCertsToWatch: nil,
-			expError:        errors.New(`"cert-manager-csi-test-1": failed to parse cert file: error decoding cert PEM block`),
+			expError:        fmt.Errorf("%q: failed to parse cert file: error decoding cert PEM block", volID1),
+		},
+
+		"if a directory is not named after a volume ID then it should be skipped": {
+			volDirs: []volDir{
+				{
+					name: "lost+found",
+					cert: []byte("foo"),
+					key:  []byte("bar"),
+					metaData: &csiapi.MetaData{
+						Attributes: map[string]string{
+							csiapi.KeyFileKey:  "key.pem",
+							csiapi.CertFileKey: "cert.pem",
+						},
+					},
+				},
+			},
+			expCertsToWatch: nil,
+			expError:        nil,
+		},
+
+		"if a volume's first request is pending then nothing returned and no error": {
+			volDirs: []volDir{
+				{
+					name: volID1,
+					metaData: &csiapi.MetaData{
+						Attributes: map[string]string{
+							csiapi.KeyFileKey:  "key.pem",
+							csiapi.CertFileKey: "cert.pem",
+						},
+						RequestCreated: &requestCreated,
+					},
+				},
+			},
+			expCertsToWatch: nil,
+			expError:        nil,
 		},
 
 		"if a single cert key pair exist then return pair to watch": {
 			volDirs: []volDir{
 				{
-					name: "test-1",
+					name: volID1,
 					cert: keyCertPair1.certData,
 					key:  keyCertPair1.pkData,
 					metaData: &csiapi.MetaData{
@@ -119,23 +165,24 @@ func TestWalkDir(t *testing.T) {
 			},
 			expCertsToWatch: []certToWatch{
 				{
-					"cert-manager-csi-test-1",
+					volID1,
 					&csiapi.MetaData{
 						Attributes: map[string]string{
 							csiapi.KeyFileKey:  "key.pem",
 							csiapi.CertFileKey: "cert.pem",
 						},
 					},
+					keyCertPair1.cert.NotBefore,
 					keyCertPair1.cert.NotAfter,
 				},
 			},
 			expError: nil,
 		},
 
-		"if one volume good but the other bad then error": {
+		"if one volume good but the other bad then error, but return the good volume": {
 			volDirs: []volDir{
 				{
-					name: "test-1",
+					name: volID1,
 					cert: keyCertPair1.certData,
 					key:  keyCertPair1.pkData,
 					metaData: &csiapi.MetaData{
@@ -146,7 +193,7 @@ func TestWalkDir(t *testing.T) {
 					},
 				},
 				{
-					name: "test-2",
+					name: volID2,
 					cert: keyCertPair1.certData,
 					key:  []byte("foo"),
 					metaData: &csiapi.MetaData{
@@ -157,14 +204,26 @@ func TestWalkDir(t *testing.T) {
 					},
 				},
 			},
-			expCertsToWatch: nil,
-			expError:        errors.New(`"cert-manager-csi-test-2": failed to parse key file: error decoding private key PEM block`),
+			expCertsToWatch: []certToWatch{
+				{
+					volID1,
+					&csiapi.MetaData{
+						Attributes: map[string]string{
+							csiapi.KeyFileKey:  "key.pem",
+							csiapi.CertFileKey: "cert.pem",
+						},
+					},
+					keyCertPair1.cert.NotBefore,
+					keyCertPair1.cert.NotAfter,
+				},
+			},
+			expError: fmt.Errorf("%q: failed to parse key file: error decoding private key PEM block", volID2),
 		},
 
 		"two good volumes should return two watches": {
 			volDirs: []volDir{
 				{
-					name: "test-1",
+					name: volID1,
 					cert: keyCertPair1.certData,
 					key:  keyCertPair1.pkData,
 					metaData: &csiapi.MetaData{
@@ -175,7 +234,7 @@ func TestWalkDir(t *testing.T) {
 					},
 				},
 				{
-					name: "test-2",
+					name: volID2,
 					cert: keyCertPair2.certData,
 					key:  keyCertPair2.pkData,
 					metaData: &csiapi.MetaData{
@@ -188,23 +247,25 @@ func TestWalkDir(t *testing.T) {
 			},
 			expCertsToWatch: []certToWatch{
 				{
-					"cert-manager-csi-test-1",
+					volID1,
 					&csiapi.MetaData{
 						Attributes: map[string]string{
 							csiapi.KeyFileKey:  "key.pem",
 							csiapi.CertFileKey: "cert.pem",
 						},
 					},
+					keyCertPair1.cert.NotBefore,
 					keyCertPair1.cert.NotAfter,
 				},
 				{
-					"cert-manager-csi-test-2",
+					volID2,
 					&csiapi.MetaData{
 						Attributes: map[string]string{
 							csiapi.KeyFileKey:  "foo.bar",
 							csiapi.CertFileKey: "bar.foo",
 						},
 					},
+					keyCertPair2.cert.NotBefore,
 					keyCertPair2.cert.NotAfter,
 				},
 			},
@@ -222,8 +283,6 @@ func TestWalkDir(t *testing.T) {
 			defer os.RemoveAll(dir)
 
 			for _, v := range test.volDirs {
-				v.name = fmt.Sprintf("cert-manager-csi-%s", v.name)
-
 				volPath := filepath.Join(dir, v.name)
 				if err := os.Mkdir(volPath, 0700); err != nil {
 					t.Error(err)
@@ -253,7 +312,7 @@ func TestWalkDir(t *testing.T) {
 				}
 			}
 
-			r := New(dir, nil)
+			r := New(dir, nil, nil)
 			certsToWatch, err := r.walkDir()
 			errMatch(t, test.expError, err)
 
@@ -274,6 +333,590 @@ type watchFileT struct {
 	watchingVols map[string]chan struct{}
 }
 
+func TestRenewNow(t *testing.T) {
+	called := make(chan struct{}, 1)
+
+	renF := func(vol *csiapi.MetaData) (*x509.Certificate, error) {
+		called <- struct{}{}
+		return &x509.Certificate{
+			NotAfter: time.Now().Add(time.Hour),
+		}, nil
+	}
+
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-renew-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := New(dir, renF, nil)
+
+	if r.RenewNow("test-id") {
+		t.Errorf("expected RenewNow to return false for volume not being watched")
+	}
+
+	metaData := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.RenewBeforeKey: "1m",
+		},
+	}
+
+	if err := r.WatchCert(metaData, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !r.RenewNow("test-id") {
+		t.Errorf("expected RenewNow to return true for volume being watched")
+	}
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("expected renewal to be triggered")
+	}
+
+	// The volume should be watched again after renewal.
+	time.Sleep(time.Second / 10)
+	r.muVol.RLock()
+	_, ok := r.watchingVols["test-id"]
+	r.muVol.RUnlock()
+	if !ok {
+		t.Errorf("expected volume to be watched again after renewal")
+	}
+
+	r.KillWatcher("test-id")
+}
+
+func TestStartIssuance(t *testing.T) {
+	r := New(os.TempDir(), nil, nil)
+
+	closed := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	stopCh1, done1 := r.StartIssuance("test-id")
+	if closed(stopCh1) {
+		t.Fatal("expected issuance to not be stopped once started")
+	}
+
+	// A second issuance of the volume supersedes the first.
+	stopCh2, done2 := r.StartIssuance("test-id")
+	if !closed(stopCh1) {
+		t.Error("expected first issuance to be stopped by the second")
+	}
+
+	// The first issuance finishing must not forget the second.
+	done1()
+
+	r.KillWatcher("test-id")
+	if !closed(stopCh2) {
+		t.Error("expected issuance to be stopped once the watcher is killed")
+	}
+
+	// Finishing an issuance after it has been stopped is a no-op.
+	done2()
+	r.KillWatcher("test-id")
+}
+
+func TestDiscoverConcurrency(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-renew-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyCertPair := genKeyCertPair(t)
+
+	// Many good volumes, and a bad volume that shouldn't stop them being
+	// watched.
+	const numVols = 100
+	volID := func(i int) string {
+		return util.BuildVolumeID("test-uid", fmt.Sprintf("vol-%03d", i))
+	}
+
+	for i := 0; i <= numVols; i++ {
+		volPath := filepath.Join(dir, volID(i))
+		if err := os.MkdirAll(filepath.Join(volPath, "data"), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		metaData := &csiapi.MetaData{
+			ID:   volID(i),
+			Path: volPath,
+			Attributes: map[string]string{
+				csiapi.KeyFileKey:     "key.pem",
+				csiapi.CertFileKey:    "cert.pem",
+				csiapi.RenewBeforeKey: "1m",
+			},
+		}
+
+		metaDataData, err := json.Marshal(metaData)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key := keyCertPair.pkData
+		if i == numVols {
+			key = []byte("foo")
+		}
+
+		maybeWriteVolData(t, filepath.Join(volPath, "metadata.json"), metaDataData)
+		maybeWriteVolData(t, filepath.Join(volPath, "data", "cert.pem"), keyCertPair.certData)
+		maybeWriteVolData(t, filepath.Join(volPath, "data", "key.pem"), key)
+	}
+
+	// Directories not named after a volume ID should never be handed to a
+	// worker, even if they hold volume data that would fail to be read.
+	for _, name := range []string{"lost+found", "cert-manager-csi-000"} {
+		volPath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Join(volPath, "data"), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		metaDataData, err := json.Marshal(&csiapi.MetaData{
+			ID:   name,
+			Path: volPath,
+			Attributes: map[string]string{
+				csiapi.KeyFileKey:  "key.pem",
+				csiapi.CertFileKey: "cert.pem",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		maybeWriteVolData(t, filepath.Join(volPath, "metadata.json"), metaDataData)
+		maybeWriteVolData(t, filepath.Join(volPath, "data", "cert.pem"), []byte("foo"))
+		maybeWriteVolData(t, filepath.Join(volPath, "data", "key.pem"), []byte("bar"))
+	}
+
+	r := New(dir, nil, nil)
+	r.SetDiscoverConcurrency(4)
+
+	expErr := fmt.Sprintf("%q: failed to parse key file: error decoding private key PEM block", volID(numVols))
+	if err := r.Discover(); err == nil || err.Error() != expErr {
+		t.Errorf("unexpected error, exp=%s got=%v", expErr, err)
+	}
+
+	for i := 0; i < numVols; i++ {
+		id := volID(i)
+		if !r.IsWatching(id) {
+			t.Errorf("expected volume %s to be watched", id)
+		}
+		defer r.KillWatcher(id)
+	}
+
+	if r.IsWatching(volID(numVols)) {
+		t.Error("expected bad volume not to be watched")
+	}
+
+	// A retried discovery should leave the watched volumes as they are.
+	if err := r.Discover(); err == nil || err.Error() != expErr {
+		t.Errorf("unexpected error on retry, exp=%s got=%v", expErr, err)
+	}
+}
+
+func TestDiscoverFIFOKeyVolume(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-renew-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyCertPair := genKeyCertPair(t)
+
+	volPath := filepath.Join(dir, util.BuildVolumeID("test-uid", "test-1"))
+	if err := os.MkdirAll(filepath.Join(volPath, "data"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	metaData := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: volPath,
+		Attributes: map[string]string{
+			csiapi.KeyFileKey:     "key.pem",
+			csiapi.CertFileKey:    "cert.pem",
+			csiapi.KeyDeliveryKey: csiapi.FIFOKeyDelivery,
+		},
+	}
+
+	metaDataData, err := json.Marshal(metaData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maybeWriteVolData(t, filepath.Join(volPath, "metadata.json"), metaDataData)
+	maybeWriteVolData(t, filepath.Join(volPath, "data", "cert.pem"), keyCertPair.certData)
+
+	// The key is a pipe with no writer, so reading it would block
+	// discovery.
+	if err := syscall.Mkfifo(filepath.Join(volPath, "data", "key.pem"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New(dir, nil, nil)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- r.Discover()
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected discovery to not block on the volume's key fifo")
+	}
+	defer r.KillWatcher("test-id")
+
+	if !r.IsWatching("test-id") {
+		t.Error("expected volume delivering its key by fifo to be watched")
+	}
+}
+
+func TestDiscoverWithRetry(t *testing.T) {
+	defer func(b wait.Backoff) { discoverBackoff = b }(discoverBackoff)
+	discoverBackoff = wait.Backoff{Duration: time.Millisecond * 10, Factor: 1}
+
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-discover-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Discovery fails until the data dir exists.
+	dataDir := filepath.Join(dir, "data")
+	r := New(dataDir, nil, nil)
+
+	if err := r.DiscoverWithRetry(time.Millisecond * 50); err == nil {
+		t.Fatal("expected discovery to time out while the data dir does not exist")
+	}
+
+	if r.Discovered() {
+		t.Fatal("expected discovery to not have succeeded")
+	}
+
+	if err := os.Mkdir(dataDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// Discovery should be retried in the background until it succeeds.
+	err = wait.PollImmediate(time.Millisecond*10, time.Second, func() (bool, error) {
+		return r.Discovered(), nil
+	})
+	if err != nil {
+		t.Errorf("expected discovery to succeed once the data dir exists: %s", err)
+	}
+
+	r = New(dataDir, nil, nil)
+	if err := r.DiscoverWithRetry(time.Second); err != nil {
+		t.Errorf("expected discovery to succeed straight away: %s", err)
+	}
+
+	if !r.Discovered() {
+		t.Error("expected discovery to have succeeded")
+	}
+}
+
+func TestRefreshCA(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-renew-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caPEM := []byte("new-ca\n")
+
+	r := New(dir, nil, func(*csiapi.MetaData) ([]byte, error) {
+		return caPEM, nil
+	})
+
+	metaData := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.CAFileKey: "ca.pem",
+		},
+	}
+
+	caPath := filepath.Join(dir, "data", "ca.pem")
+	if err := os.MkdirAll(filepath.Dir(caPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	maybeWriteVolData(t, caPath, []byte("old-ca"))
+
+	if err := r.refreshCA(metaData); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != string(caPEM) {
+		t.Errorf("expected ca file to be updated, exp=%s got=%s", caPEM, b)
+	}
+
+	// An empty CA from the issuer should leave the existing file untouched.
+	caPEM = nil
+	if err := r.refreshCA(metaData); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = ioutil.ReadFile(caPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "new-ca\n" {
+		t.Errorf("expected ca file to be unchanged, got=%s", b)
+	}
+}
+
+func TestNextRenewal(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-renew-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := New(dir, nil, nil)
+
+	metaData := &csiapi.MetaData{
+		ID:   "test-next-renewal",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.RenewBeforeKey:   "1h",
+			csiapi.CorrelationIDKey: "test-correlation-id",
+		},
+	}
+
+	notAfter := time.Now().Add(time.Hour * 3).Truncate(time.Second)
+	expRenewal := notAfter.Add(-time.Hour)
+
+	if err := r.WatchCert(metaData, notAfter.Add(-time.Hour*4), notAfter); err != nil {
+		t.Fatal(err)
+	}
+	gauge := metrics.NextRenewalTimestamp.WithLabelValues(metaData.ID)
+	if got := testutil.ToFloat64(gauge); got != float64(expRenewal.Unix()) {
+		t.Errorf("unexpected next renewal metric, exp=%d got=%f", expRenewal.Unix(), got)
+	}
+
+	status, err := util.ReadStatusFile(util.StatusPath(metaData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !status.NextRenewal.Equal(expRenewal) {
+		t.Errorf("unexpected next renewal in status file, exp=%s got=%s", expRenewal, status.NextRenewal)
+	}
+
+	if !status.NotAfter.Equal(notAfter) {
+		t.Errorf("unexpected not after in status file, exp=%s got=%s", notAfter, status.NotAfter)
+	}
+
+	if status.CorrelationID != "test-correlation-id" {
+		t.Errorf("unexpected correlation id in status file, exp=test-correlation-id got=%s", status.CorrelationID)
+	}
+
+	// The series of the volume is removed once it is no longer watched.
+	r.KillWatcher(metaData.ID)
+	if metrics.NextRenewalTimestamp.DeleteLabelValues(metaData.ID) {
+		t.Error("expected next renewal metric of volume to be removed once no longer watched")
+	}
+}
+
+func TestWatchCertClock(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-renew-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, test := range map[string]struct {
+		attr       map[string]string
+		notBefore  time.Time
+		notAfter   time.Time
+		expRenewIn time.Duration
+	}{
+		"renew-before should fire the duration before expiry": {
+			attr: map[string]string{
+				csiapi.RenewBeforeKey: "30h",
+			},
+			notBefore:  start,
+			notAfter:   start.Add(time.Hour * 90),
+			expRenewIn: time.Hour * 60,
+		},
+		"renew-at should fire at the percentage of the lifetime": {
+			attr: map[string]string{
+				csiapi.RenewAtKey: "50%",
+			},
+			notBefore:  start,
+			notAfter:   start.Add(time.Hour * 90),
+			expRenewIn: time.Hour * 45,
+		},
+		"renew-schedule should fire at the last scheduled time before two thirds of the lifetime": {
+			attr: map[string]string{
+				csiapi.RenewScheduleKey: "30 6 * * *",
+			},
+			notBefore:  start,
+			notAfter:   start.Add(time.Hour * 90),
+			expRenewIn: time.Hour*54 + time.Minute*30,
+		},
+		"a renewal time in the past should fire immediately": {
+			attr: map[string]string{
+				csiapi.RenewBeforeKey: "30h",
+			},
+			notBefore:  start.Add(-time.Hour * 80),
+			notAfter:   start.Add(time.Hour * 10),
+			expRenewIn: 0,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fakeClock := clock.NewFakeClock(start)
+			renewCh := make(chan struct{}, 1)
+
+			r := New(dir, func(*csiapi.MetaData) (*x509.Certificate, error) {
+				renewCh <- struct{}{}
+				return &x509.Certificate{
+					NotBefore: fakeClock.Now(),
+					NotAfter:  fakeClock.Now().Add(time.Hour * 90),
+				}, nil
+			}, nil)
+			r.clock = fakeClock
+
+			metaData := &csiapi.MetaData{
+				ID:         "test-clock",
+				Path:       dir,
+				Attributes: test.attr,
+			}
+
+			if err := r.WatchCert(metaData, test.notBefore, test.notAfter); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := wait.PollImmediate(time.Millisecond*10, time.Second*5, func() (bool, error) {
+				return fakeClock.HasWaiters(), nil
+			}); err != nil {
+				t.Fatal("renewer never waited on the clock")
+			}
+
+			if test.expRenewIn > 0 {
+				fakeClock.Step(test.expRenewIn - time.Second)
+
+				select {
+				case <-renewCh:
+					t.Fatalf("renewal fired before %s", test.expRenewIn)
+				case <-time.After(time.Millisecond * 100):
+				}
+
+				fakeClock.Step(time.Second)
+			} else {
+				// Fake timers only fire once the clock is stepped.
+				fakeClock.Step(0)
+			}
+
+			select {
+			case <-renewCh:
+			case <-time.After(time.Second * 5):
+				t.Fatalf("renewal did not fire after %s", test.expRenewIn)
+			}
+
+			// The renewed certificate should be watched again.
+			if err := wait.PollImmediate(time.Millisecond*10, time.Second*5, func() (bool, error) {
+				return r.IsWatching(metaData.ID), nil
+			}); err != nil {
+				t.Fatal("renewed certificate was not watched again")
+			}
+
+			r.KillWatcher(metaData.ID)
+		})
+	}
+}
+
+func TestWatchCertAutoRenewDisabled(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-renew-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(start)
+	renewCh := make(chan struct{}, 1)
+
+	r := New(dir, func(*csiapi.MetaData) (*x509.Certificate, error) {
+		renewCh <- struct{}{}
+		return &x509.Certificate{
+			NotBefore: fakeClock.Now(),
+			NotAfter:  fakeClock.Now().Add(time.Hour * 90),
+		}, nil
+	}, nil)
+	r.clock = fakeClock
+
+	r.SetAutoRenewDisabled(true)
+	if !r.AutoRenewDisabled() {
+		t.Fatal("expected auto renewal to be disabled")
+	}
+
+	metaData := &csiapi.MetaData{
+		ID:   "test-disabled",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.RenewBeforeKey: "30h",
+		},
+	}
+
+	// The certificate is due for renewal straight away.
+	if err := r.WatchCert(metaData, start.Add(-time.Hour*80), start.Add(time.Hour*10)); err != nil {
+		t.Fatal(err)
+	}
+	defer r.KillWatcher(metaData.ID)
+
+	if err := wait.PollImmediate(time.Millisecond*10, time.Second*5, func() (bool, error) {
+		return fakeClock.HasWaiters(), nil
+	}); err != nil {
+		t.Fatal("renewer never waited on the clock")
+	}
+	fakeClock.Step(0)
+
+	select {
+	case <-renewCh:
+		t.Fatal("renewal fired while auto renewal was disabled")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	if !r.RenewNow(metaData.ID) {
+		t.Fatal("expected volume to be watched")
+	}
+
+	select {
+	case <-renewCh:
+		t.Fatal("triggered renewal fired while auto renewal was disabled")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	r.SetAutoRenewDisabled(false)
+
+	select {
+	case <-renewCh:
+	case <-time.After(time.Second * 5):
+		t.Fatal("held renewal did not fire once auto renewal was re-enabled")
+	}
+}
+
 func TestWatchCert(t *testing.T) {
 	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-renew-")
 	if err != nil {
@@ -320,22 +963,25 @@ func TestWatchCert(t *testing.T) {
 					return nil, errors.New("go unepexted call")
 				}
 
-				return new(x509.Certificate), nil
+				return &x509.Certificate{
+					NotAfter: time.Now().Add(time.Hour),
+				}, nil
 			}
 
-			r := New(dir, renF)
+			r := New(dir, renF, nil)
 			if test.watchingVols != nil {
 				r.watchingVols = test.watchingVols
 			}
 
 			metaData := &csiapi.MetaData{
-				ID: "test-id",
+				ID:   "test-id",
+				Path: dir,
 				Attributes: map[string]string{
 					csiapi.RenewBeforeKey: test.renewBefore,
 				},
 			}
 
-			err := r.WatchCert(metaData, time.Now().Add(time.Second/2))
+			err := r.WatchCert(metaData, time.Now(), time.Now().Add(time.Second/2))
 			errMatch(t, test.expError, err)
 
 			if test.killWatcher == true {
@@ -410,6 +1056,11 @@ func certsToWatchMatch(t *testing.T, exp, got []certToWatch) {
 			break
 		}
 
+		if exp[i].notBefore.String() != got[i].notBefore.String() {
+			missmatch = true
+			break
+		}
+
 		if exp[i].notAfter.String() != got[i].notAfter.String() {
 			missmatch = true
 			break
@@ -446,3 +1097,161 @@ func sortCertsToWatch(certsToWatch []certToWatch) {
 		return certsToWatch[i].base < certsToWatch[j].base
 	})
 }
+
+func TestNextRetry(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, test := range map[string]struct {
+		steps    int
+		notAfter time.Time
+		expRetry time.Duration
+		expFinal bool
+	}{
+		"the first retry should use the initial backoff": {
+			notAfter: now.Add(time.Hour),
+			expRetry: time.Second * 10,
+		},
+		"the backoff should grow exponentially": {
+			steps:    3,
+			notAfter: now.Add(time.Hour),
+			expRetry: time.Second * 80,
+		},
+		"the backoff should be capped": {
+			steps:    10,
+			notAfter: now.Add(time.Hour),
+			expRetry: time.Minute * 5,
+		},
+		"a retry after expiry should be brought forward to a final attempt": {
+			steps:    10,
+			notAfter: now.Add(time.Minute),
+			expRetry: time.Minute - finalRetryMargin,
+			expFinal: true,
+		},
+		"a retry within the final margin should use the backoff": {
+			steps:    10,
+			notAfter: now.Add(finalRetryMargin),
+			expRetry: time.Minute * 5,
+		},
+		"a retry of an expired certificate should use the backoff": {
+			notAfter: now.Add(-time.Hour),
+			expRetry: time.Second * 10,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			backoff := retryBackoff
+			backoff.Cap = DefaultRetryMaxBackoff
+
+			for i := 0; i < test.steps; i++ {
+				backoff.Step()
+			}
+
+			d, final := nextRetry(&backoff, now, test.notAfter)
+			if d != test.expRetry || final != test.expFinal {
+				t.Errorf("unexpected retry, exp=%s,%t got=%s,%t",
+					test.expRetry, test.expFinal, d, final)
+			}
+		})
+	}
+}
+
+func TestWatchCertRetryBackoff(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-renew-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(start)
+
+	// Renewal fails until the fourth attempt.
+	renewCh := make(chan int, 1)
+	var attempts int
+	r := New(dir, func(*csiapi.MetaData) (*x509.Certificate, error) {
+		attempts++
+		renewCh <- attempts
+
+		if attempts < 4 {
+			return nil, errors.New("issuer down")
+		}
+
+		return &x509.Certificate{
+			NotBefore: fakeClock.Now(),
+			NotAfter:  fakeClock.Now().Add(time.Hour * 90),
+		}, nil
+	}, nil)
+	r.clock = fakeClock
+	r.SetRetryMaxBackoff(time.Second * 30)
+
+	metaData := &csiapi.MetaData{
+		ID:   "test-retry",
+		Path: dir,
+		Attributes: map[string]string{
+			csiapi.RenewBeforeKey: "30h",
+		},
+	}
+
+	if err := r.WatchCert(metaData, start.Add(-time.Hour*80), start.Add(time.Hour*10)); err != nil {
+		t.Fatal(err)
+	}
+
+	waitAndStep := func(d time.Duration) {
+		if err := wait.PollImmediate(time.Millisecond*10, time.Second*5, func() (bool, error) {
+			return fakeClock.HasWaiters(), nil
+		}); err != nil {
+			t.Fatal("renewer never waited on the clock")
+		}
+
+		fakeClock.Step(d)
+	}
+
+	expectAttempt := func(exp int) {
+		select {
+		case got := <-renewCh:
+			if got != exp {
+				t.Fatalf("unexpected attempt, exp=%d got=%d", exp, got)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("renewal attempt %d did not fire", exp)
+		}
+	}
+
+	expectNoAttempt := func() {
+		select {
+		case got := <-renewCh:
+			t.Fatalf("renewal attempt %d fired before the backoff", got)
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+
+	waitAndStep(0)
+	expectAttempt(1)
+
+	if err := wait.PollImmediate(time.Millisecond*10, time.Second*5, func() (bool, error) {
+		state, _ := r.State(metaData.ID)
+		return state.LastError == "issuer down", nil
+	}); err != nil {
+		t.Fatal("failed renewal was not recorded as the last error")
+	}
+
+	// Backoff should grow from 10s to 20s, then be capped at 30s.
+	for i, backoff := range []time.Duration{time.Second * 10, time.Second * 20, time.Second * 30} {
+		waitAndStep(backoff - time.Second)
+		expectNoAttempt()
+
+		fakeClock.Step(time.Second)
+		expectAttempt(i + 2)
+	}
+
+	if err := wait.PollImmediate(time.Millisecond*10, time.Second*5, func() (bool, error) {
+		return r.IsWatching(metaData.ID), nil
+	}); err != nil {
+		t.Fatal("renewed certificate was not watched again")
+	}
+
+	if state, _ := r.State(metaData.ID); len(state.LastError) > 0 {
+		t.Errorf("unexpected last error after renewal: %s", state.LastError)
+	}
+
+	r.KillWatcher(metaData.ID)
+}
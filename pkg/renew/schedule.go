@@ -0,0 +1,162 @@
+package renew
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScheduleSearch bounds how far into the future a schedule is searched for
+// its next time.
+const maxScheduleSearch = time.Hour * 24 * 366 * 5
+
+// Schedule is a cron like schedule of minute, hour, day of month, month and
+// day of week fields, evaluated in UTC. Each field may be '*', a value, a
+// range 'a-b', a step '*/n' or 'a-b/n', or a comma separated list of these.
+type Schedule struct {
+	minute, hour, dom, month, dow map[int]bool
+
+	// Whether the day of month or day of week fields are '*'.
+	domAny, dowAny bool
+}
+
+type scheduleField struct {
+	name     string
+	min, max int
+}
+
+var scheduleFields = []scheduleField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// ParseSchedule parses a cron like schedule of five space separated fields.
+func ParseSchedule(s string) (*Schedule, error) {
+	fields := strings.Fields(s)
+	if len(fields) != len(scheduleFields) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(scheduleFields), len(fields))
+	}
+
+	var values []map[int]bool
+	for i, field := range fields {
+		v, err := parseScheduleField(field, scheduleFields[i])
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return &Schedule{
+		minute: values[0],
+		hour:   values[1],
+		dom:    values[2],
+		month:  values[3],
+		dow:    values[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+func parseScheduleField(s string, f scheduleField) (map[int]bool, error) {
+	values := make(map[int]bool)
+
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rng = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %s field %q", f.name, part)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in %s field %q", f.name, part)
+			}
+
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid range in %s field %q", f.name, part)
+				}
+			} else if step > 1 {
+				// 'a/n' means every n starting from a.
+				end = f.max
+			}
+		}
+
+		if start < f.min || end > f.max || start > end {
+			return nil, fmt.Errorf("%s field %q out of range %d-%d", f.name, part, f.min, f.max)
+		}
+
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
+
+// Next returns the first time of the schedule after t, or the zero time if
+// the schedule never fires.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	end := t.Add(maxScheduleSearch)
+
+	for t.Before(end) {
+		if !s.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !s.hour[t.Hour()] {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+
+		if !s.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// matchDay follows cron in matching either the day of month or day of week
+// if both are restricted.
+func (s *Schedule) matchDay(t time.Time) bool {
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
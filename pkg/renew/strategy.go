@@ -0,0 +1,132 @@
+package renew
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// defaultRenewAt is the fraction of a certificate's lifetime after which it
+// is renewed if a schedule does not fire beforehand, or no strategy is set.
+const defaultRenewAt = 2.0 / 3
+
+// DefaultAutoRenewBeforeFraction is the default fraction of a certificate's
+// lifetime remaining when it is renewed, if no strategy is set.
+const DefaultAutoRenewBeforeFraction = 1.0 / 3
+
+// Strategy decides when a certificate should be renewed.
+type Strategy interface {
+	// RenewalTime returns the time a certificate valid from notBefore until
+	// notAfter should be renewed, given the current time.
+	RenewalTime(notBefore, notAfter, now time.Time) time.Time
+}
+
+// renewBefore renews a fixed duration before the certificate expires. If the
+// certificate's lifetime is no longer than the duration, such as when the
+// issuer issued a shorter certificate than requested, it falls back to the
+// default fraction of the lifetime rather than renewing straight away.
+type renewBefore time.Duration
+
+func (r renewBefore) RenewalTime(notBefore, notAfter, now time.Time) time.Time {
+	if notAfter.Sub(notBefore) <= time.Duration(r) {
+		return renewAt(defaultRenewAt).RenewalTime(notBefore, notAfter, now)
+	}
+
+	return notAfter.Add(-time.Duration(r))
+}
+
+// renewAt renews once a fraction of the certificate's lifetime has passed.
+type renewAt float64
+
+func (r renewAt) RenewalTime(notBefore, notAfter, _ time.Time) time.Time {
+	lifetime := notAfter.Sub(notBefore)
+	return notBefore.Add(time.Duration(float64(lifetime) * float64(r)))
+}
+
+// renewSchedule renews at the last time of the schedule before two thirds of
+// the certificate's lifetime has passed, or at two thirds if the schedule
+// does not fire before then.
+type renewSchedule struct {
+	schedule *Schedule
+}
+
+func (r renewSchedule) RenewalTime(notBefore, notAfter, now time.Time) time.Time {
+	deadline := renewAt(defaultRenewAt).RenewalTime(notBefore, notAfter, now)
+
+	renewal := deadline
+	for t := r.schedule.Next(now); !t.IsZero() && !t.After(deadline); t = r.schedule.Next(t) {
+		renewal = t
+	}
+
+	return renewal
+}
+
+// StrategyForAttributes returns the renewal strategy selected by the given
+// volume attributes. At most one of renew-before, renew-at and renew-schedule
+// may be set. If none are set, certificates are renewed once the given
+// fraction of their lifetime remains. This is derived from the issued
+// certificate, so follows an issuer that shortens the requested duration.
+func StrategyForAttributes(attr map[string]string, autoRenewBeforeFraction float64) (Strategy, error) {
+	var set []string
+	for _, k := range []string{csiapi.RenewBeforeKey, csiapi.RenewAtKey, csiapi.RenewScheduleKey} {
+		if len(attr[k]) > 0 {
+			set = append(set, k)
+		}
+	}
+
+	if len(set) > 1 {
+		return nil, fmt.Errorf("only one of %s may be set", strings.Join(set, ", "))
+	}
+
+	if s := attr[csiapi.RenewAtKey]; len(s) > 0 {
+		at, err := ParseRenewAt(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse renew at: %s", err)
+		}
+
+		return renewAt(at), nil
+	}
+
+	if s := attr[csiapi.RenewScheduleKey]; len(s) > 0 {
+		schedule, err := ParseSchedule(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse renew schedule: %s", err)
+		}
+
+		return renewSchedule{schedule}, nil
+	}
+
+	if len(set) == 0 {
+		return renewAt(1 - autoRenewBeforeFraction), nil
+	}
+
+	before, err := time.ParseDuration(attr[csiapi.RenewBeforeKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse renew before: %s", err)
+	}
+
+	return renewBefore(before), nil
+}
+
+// ParseRenewAt parses a percentage of a certificate's lifetime, such as
+// "66%", into a fraction.
+func ParseRenewAt(s string) (float64, error) {
+	if !strings.HasSuffix(s, "%") {
+		return 0, errors.New("must be a percentage ending in '%'")
+	}
+
+	p, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+
+	if p <= 0 || p >= 100 {
+		return 0, fmt.Errorf("percentage must be between 0%% and 100%% exclusive, got %q", s)
+	}
+
+	return p / 100, nil
+}
@@ -0,0 +1,188 @@
+package renew
+
+import (
+	"testing"
+	"time"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestStrategyForAttributes(t *testing.T) {
+	notBefore := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(time.Hour * 90)
+	now := notBefore.Add(time.Minute)
+
+	for name, test := range map[string]struct {
+		attr       map[string]string
+		fraction   float64
+		expErr     string
+		expRenewal time.Time
+	}{
+		"renew-before should renew the duration before expiry": {
+			attr: map[string]string{
+				csiapi.RenewBeforeKey: "30h",
+			},
+			expRenewal: notAfter.Add(-time.Hour * 30),
+		},
+		"renew-before not shorter than the lifetime should renew at two thirds of the lifetime": {
+			// The issuer issued a shorter certificate than the requested
+			// duration renew-before was defaulted from.
+			attr: map[string]string{
+				csiapi.RenewBeforeKey: "100h",
+			},
+			expRenewal: notBefore.Add(time.Hour * 60),
+		},
+		"renew-at should renew at the percentage of the lifetime": {
+			attr: map[string]string{
+				csiapi.RenewAtKey: "50%",
+			},
+			expRenewal: notBefore.Add(time.Hour * 45),
+		},
+		"renew-schedule should renew at the last time before two thirds of the lifetime": {
+			// Every day at 06:30, two thirds of the lifetime is 60h.
+			attr: map[string]string{
+				csiapi.RenewScheduleKey: "30 6 * * *",
+			},
+			expRenewal: time.Date(2020, time.January, 3, 6, 30, 0, 0, time.UTC),
+		},
+		"renew-schedule not firing before two thirds of the lifetime should renew then": {
+			// Every 1st of March.
+			attr: map[string]string{
+				csiapi.RenewScheduleKey: "0 0 1 3 *",
+			},
+			expRenewal: notBefore.Add(time.Hour * 60),
+		},
+		"no strategy should renew at two thirds of the lifetime": {
+			attr:       map[string]string{},
+			expRenewal: notBefore.Add(time.Hour * 60),
+		},
+		"no strategy should renew once the auto renew before fraction of the lifetime remains": {
+			attr:       map[string]string{},
+			fraction:   0.1,
+			expRenewal: notAfter.Add(-time.Hour * 9),
+		},
+		"more than one strategy should error": {
+			attr: map[string]string{
+				csiapi.RenewBeforeKey: "30h",
+				csiapi.RenewAtKey:     "50%",
+			},
+			expErr: "only one of csi.cert-manager.io/renew-before, csi.cert-manager.io/renew-at may be set",
+		},
+		"a bad percentage should error": {
+			attr: map[string]string{
+				csiapi.RenewAtKey: "150%",
+			},
+			expErr: `failed to parse renew at: percentage must be between 0% and 100% exclusive, got "150%"`,
+		},
+		"a bad schedule should error": {
+			attr: map[string]string{
+				csiapi.RenewScheduleKey: "* * *",
+			},
+			expErr: "failed to parse renew schedule: expected 5 fields, got 3",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fraction := test.fraction
+			if fraction == 0 {
+				fraction = DefaultAutoRenewBeforeFraction
+			}
+
+			strategy, err := StrategyForAttributes(test.attr, fraction)
+			if len(test.expErr) > 0 {
+				if err == nil || err.Error() != test.expErr {
+					t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if renewal := strategy.RenewalTime(notBefore, notAfter, now); !renewal.Equal(test.expRenewal) {
+				t.Errorf("unexpected renewal time, exp=%s got=%s", test.expRenewal, renewal)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// Wednesday
+	from := time.Date(2020, time.January, 1, 10, 15, 30, 0, time.UTC)
+
+	for name, test := range map[string]struct {
+		schedule string
+		expNext  time.Time
+	}{
+		"every minute should fire the next minute": {
+			"* * * * *",
+			time.Date(2020, time.January, 1, 10, 16, 0, 0, time.UTC),
+		},
+		"a step should fire at the next multiple": {
+			"*/20 * * * *",
+			time.Date(2020, time.January, 1, 10, 20, 0, 0, time.UTC),
+		},
+		"a passed hour should fire the next day": {
+			"0 9 * * *",
+			time.Date(2020, time.January, 2, 9, 0, 0, 0, time.UTC),
+		},
+		"a day of week should fire on that day": {
+			"0 3 * * 0",
+			time.Date(2020, time.January, 5, 3, 0, 0, 0, time.UTC),
+		},
+		"a list and range should fire at the first match": {
+			"0 1,12-14 * * *",
+			time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC),
+		},
+		"both day of month and week should fire on either": {
+			"0 0 15 * 5",
+			time.Date(2020, time.January, 3, 0, 0, 0, 0, time.UTC),
+		},
+		"a day that never exists should never fire": {
+			"0 0 30 2 *",
+			time.Time{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, err := ParseSchedule(test.schedule)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if next := s.Next(from); !next.Equal(test.expNext) {
+				t.Errorf("unexpected next time, exp=%s got=%s", test.expNext, next)
+			}
+		})
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for name, test := range map[string]struct {
+		schedule string
+		expErr   string
+	}{
+		"a value out of range should error": {
+			"60 * * * *",
+			`minute field "60" out of range 0-59`,
+		},
+		"an inverted range should error": {
+			"* 5-2 * * *",
+			`hour field "5-2" out of range 0-23`,
+		},
+		"a zero step should error": {
+			"*/0 * * * *",
+			`invalid step in minute field "*/0"`,
+		},
+		"a non numeric value should error": {
+			"* * * jan *",
+			`invalid value in month field "jan"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSchedule(test.schedule)
+			if err == nil || err.Error() != test.expErr {
+				t.Errorf("unexpected error, exp=%s got=%v", test.expErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	api "github.com/jetstack/cert-manager-csi/pkg/signer/api/v1alpha1"
+)
+
+// Interface provides the signers of volume CSRs, whose private keys are held
+// by the provider and never written to the volume.
+type Interface interface {
+	// Signer returns the signer of the given volume. Calls to the returned
+	// signer are bound to the given context.
+	Signer(ctx context.Context, vol *csiapi.MetaData) (crypto.Signer, error)
+
+	// Probe returns an error if the provider is not ready to sign.
+	Probe(ctx context.Context) error
+}
+
+// hashNames are the hash functions signers may be asked to sign digests of.
+var hashNames = map[crypto.Hash]string{
+	crypto.SHA256: "SHA256",
+	crypto.SHA384: "SHA384",
+	crypto.SHA512: "SHA512",
+}
+
+// Plugin delegates signing to a signer plugin served on a unix socket.
+type Plugin struct {
+	client api.SignerClient
+}
+
+var _ Interface = &Plugin{}
+
+// NewPlugin connects to the signer plugin at the given unix:// endpoint, and
+// probes it within the given timeout.
+func NewPlugin(endpoint string, timeout time.Duration) (*Plugin, error) {
+	path, err := ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.Dial(path, grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, "unix", addr)
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to signer plugin %s: %s", endpoint, err)
+	}
+
+	p := &Plugin{
+		client: api.NewSignerClient(conn),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := p.Probe(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// ParseEndpoint returns the socket path of the given unix:// endpoint.
+func ParseEndpoint(endpoint string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(endpoint), "unix://") {
+		return "", fmt.Errorf("signer plugin endpoint must be a unix:// socket, got %q", endpoint)
+	}
+
+	path := endpoint[len("unix://"):]
+	if !strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("signer plugin socket path must be absolute, got %q", endpoint)
+	}
+
+	return path, nil
+}
+
+// Probe returns an error if the plugin is not ready to sign.
+func (p *Plugin) Probe(ctx context.Context) error {
+	if _, err := p.client.Probe(ctx, new(api.ProbeRequest)); err != nil {
+		return fmt.Errorf("failed to probe signer plugin: %s", err)
+	}
+
+	return nil
+}
+
+// Signer returns a signer of the key the plugin holds for the given volume.
+func (p *Plugin) Signer(ctx context.Context, vol *csiapi.MetaData) (crypto.Signer, error) {
+	size, err := strconv.Atoi(vol.Attributes[csiapi.KeySizeKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key size: %s", err)
+	}
+
+	resp, err := p.client.GetPublicKey(ctx, &api.GetPublicKeyRequest{
+		VolumeId:     vol.ID,
+		KeyAlgorithm: vol.Attributes[csiapi.KeyAlgorithmKey],
+		KeySize:      int32(size),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public key of volume %s from signer plugin: %s", vol.ID, err)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key of volume %s from signer plugin: %s", vol.ID, err)
+	}
+
+	return &pluginSigner{
+		ctx:      ctx,
+		client:   p.client,
+		volumeID: vol.ID,
+		public:   pub,
+	}, nil
+}
+
+// pluginSigner is a crypto.Signer of a key held by a signer plugin.
+type pluginSigner struct {
+	ctx      context.Context
+	client   api.SignerClient
+	volumeID string
+	public   crypto.PublicKey
+}
+
+func (s *pluginSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *pluginSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, fmt.Errorf("signer plugin does not support RSA-PSS signatures")
+	}
+
+	hash, ok := hashNames[opts.HashFunc()]
+	if !ok {
+		return nil, fmt.Errorf("signer plugin does not support hash function %d", opts.HashFunc())
+	}
+
+	resp, err := s.client.Sign(s.ctx, &api.SignRequest{
+		VolumeId: s.volumeID,
+		Digest:   digest,
+		Hash:     hash,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with signer plugin: %s", err)
+	}
+
+	return resp.Signature, nil
+}
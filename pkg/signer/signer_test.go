@@ -0,0 +1,153 @@
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+	"google.golang.org/grpc"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+	api "github.com/jetstack/cert-manager-csi/pkg/signer/api/v1alpha1"
+	"github.com/jetstack/cert-manager-csi/pkg/util"
+)
+
+// testPlugin holds a single ECDSA key for every volume.
+type testPlugin struct {
+	key *ecdsa.PrivateKey
+}
+
+func (p *testPlugin) Probe(context.Context, *api.ProbeRequest) (*api.ProbeResponse, error) {
+	return new(api.ProbeResponse), nil
+}
+
+func (p *testPlugin) GetPublicKey(_ context.Context, req *api.GetPublicKeyRequest) (*api.GetPublicKeyResponse, error) {
+	if req.KeyAlgorithm != csiapi.ECDSAKeyAlgorithm || req.KeySize != 256 {
+		return nil, errors.New("unsupported key")
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(p.key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.GetPublicKeyResponse{PublicKey: der}, nil
+}
+
+func (p *testPlugin) Sign(_ context.Context, req *api.SignRequest) (*api.SignResponse, error) {
+	if req.Hash != "SHA256" {
+		return nil, errors.New("unexpected hash")
+	}
+
+	sig, err := p.key.Sign(rand.Reader, req.Digest, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.SignResponse{Signature: sig}, nil
+}
+
+func TestPlugin(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-signer-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(dir, "signer.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer()
+	api.RegisterSignerServer(server, &testPlugin{key: key})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	plugin, err := NewPlugin("unix://"+socket, time.Second*5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := plugin.Signer(context.TODO(), &csiapi.MetaData{
+		ID: "test-id",
+		Attributes: map[string]string{
+			csiapi.KeyAlgorithmKey: csiapi.ECDSAKeyAlgorithm,
+			csiapi.KeySizeKey:      "256",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csrPEM, err := util.EncodeCSR(&x509.CertificateRequest{
+		DNSNames:  []string{"foo.bar"},
+		PublicKey: signer.Public(),
+	}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr, err := pki.DecodeX509CertificateRequestBytes(csrPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		t.Errorf("expected CSR signed by plugin to be valid: %s", err)
+	}
+
+	ok, err := pki.PublicKeyMatchesCSR(key.Public(), csr)
+	if err != nil || !ok {
+		t.Errorf("expected CSR to be of the plugin's key: %v", err)
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	for name, test := range map[string]struct {
+		endpoint string
+		expPath  string
+		expErr   bool
+	}{
+		"a unix socket should parse": {
+			endpoint: "unix:///run/signer/signer.sock",
+			expPath:  "/run/signer/signer.sock",
+		},
+		"a tcp endpoint should error": {
+			endpoint: "tcp://127.0.0.1:9000",
+			expErr:   true,
+		},
+		"a relative socket path should error": {
+			endpoint: "unix://signer.sock",
+			expErr:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path, err := ParseEndpoint(test.endpoint)
+			if test.expErr != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			if path != test.expPath {
+				t.Errorf("unexpected path, exp=%q got=%q", test.expPath, path)
+			}
+		})
+	}
+}
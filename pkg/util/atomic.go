@@ -0,0 +1,254 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+const (
+	// atomicDataLink is the symlink pointing to the current version of the
+	// files in an atomic directory layout, as used by Kubernetes Secret
+	// volumes.
+	atomicDataLink    = "..data"
+	atomicDataTmpLink = "..data_tmp"
+)
+
+// IsAtomicLayout returns true if the given directory uses the atomic layout.
+func IsAtomicLayout(dir string) bool {
+	fi, err := os.Lstat(filepath.Join(dir, atomicDataLink))
+	return err == nil && fi.Mode()&os.ModeSymlink != 0
+}
+
+// InitAtomicLayout sets up the atomic layout in the given directory with no
+// files, if not already set up.
+func InitAtomicLayout(dir string, fsync bool) error {
+	if IsAtomicLayout(dir) {
+		return nil
+	}
+
+	return writeAtomic(dir, nil, 0600, fsync)
+}
+
+// WriteDataFiles writes the given files, keyed by their path relative to the
+// volume's mount directory. If the volume uses the atomic layout, all files
+// are swapped in together and files not given are kept from the current
+// version. Otherwise, each file is written atomically on its own. Files given
+// with nil data are removed. Files are synced to disk if fsync is true.
+func WriteDataFiles(vol *csiapi.MetaData, files map[string][]byte, fsync bool) error {
+	dir := MountPath(vol)
+
+	if IsAtomicLayout(dir) {
+		return writeAtomic(dir, files, DataFilePermissions, fsync)
+	}
+
+	for name, b := range files {
+		if b == nil {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+
+			continue
+		}
+
+		if err := WriteFile(filepath.Join(dir, name), b, DataFilePermissions, fsync); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeAtomic writes a new timestamped version directory containing the
+// given files, and files of the current version not given, then atomically
+// swaps the ..data symlink to it. Files given with nil data are left out of
+// the new version. The top level entries of the directory are
+// symlinks through ..data so that readers never see a mix of versions.
+func writeAtomic(dir string, files map[string][]byte, perm os.FileMode, fsync bool) error {
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		return err
+	}
+
+	oldVersion, err := os.Readlink(filepath.Join(dir, atomicDataLink))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	newVersionPath, err := ioutil.TempDir(dir, time.Now().UTC().Format("..2006_01_02_15_04_05."))
+	if err != nil {
+		return err
+	}
+	newVersion := filepath.Base(newVersionPath)
+
+	if err := populateVersion(dir, oldVersion, newVersionPath, files, perm, fsync); err != nil {
+		os.RemoveAll(newVersionPath)
+		return err
+	}
+
+	if err := syncDir(newVersionPath, fsync); err != nil {
+		os.RemoveAll(newVersionPath)
+		return err
+	}
+
+	tmpLink := filepath.Join(dir, atomicDataTmpLink)
+	os.Remove(tmpLink)
+	if err := os.Symlink(newVersion, tmpLink); err != nil {
+		os.RemoveAll(newVersionPath)
+		return err
+	}
+
+	if err := os.Rename(tmpLink, filepath.Join(dir, atomicDataLink)); err != nil {
+		os.Remove(tmpLink)
+		os.RemoveAll(newVersionPath)
+		return err
+	}
+
+	if err := linkVersionEntries(dir, newVersionPath); err != nil {
+		return err
+	}
+
+	if err := removeStaleEntries(dir, newVersionPath); err != nil {
+		return err
+	}
+
+	if err := syncDir(dir, fsync); err != nil {
+		return err
+	}
+
+	if len(oldVersion) > 0 && oldVersion != newVersion {
+		if err := os.RemoveAll(filepath.Join(dir, oldVersion)); err != nil {
+			return fmt.Errorf("failed to remove old version %q: %s", oldVersion, err)
+		}
+	}
+
+	return nil
+}
+
+// populateVersion writes the files, and files of the old version not given,
+// into the new version directory.
+func populateVersion(dir, oldVersion, newVersionPath string, files map[string][]byte, perm os.FileMode, fsync bool) error {
+	cleaned := make(map[string][]byte, len(files))
+	for name, b := range files {
+		cleaned[filepath.Clean(name)] = b
+	}
+
+	write := func(name string, b []byte) error {
+		path := filepath.Join(newVersionPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0744); err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+		if err != nil {
+			return err
+		}
+
+		return writeAndClose(f, b, fsync)
+	}
+
+	if len(oldVersion) > 0 {
+		oldVersionPath := filepath.Join(dir, oldVersion)
+
+		err := filepath.Walk(oldVersionPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+
+			name, err := filepath.Rel(oldVersionPath, path)
+			if err != nil {
+				return err
+			}
+
+			if _, ok := cleaned[name]; ok {
+				return nil
+			}
+
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			return write(name, b)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	for name, b := range cleaned {
+		if b == nil {
+			continue
+		}
+
+		if err := write(name, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// linkVersionEntries ensures each top level entry of the version is linked
+// through ..data from the directory, replacing any file in its place.
+func linkVersionEntries(dir, versionPath string) error {
+	entries, err := ioutil.ReadDir(versionPath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+		target := filepath.Join(atomicDataLink, name)
+
+		if current, err := os.Readlink(path); err == nil && current == target {
+			continue
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+
+		if err := os.Symlink(target, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeStaleEntries removes the top level symlinks of the directory through
+// ..data to entries which are not in the version.
+func removeStaleEntries(dir, versionPath string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Mode()&os.ModeSymlink == 0 || strings.HasPrefix(name, "..") {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		if target, err := os.Readlink(path); err != nil || target != filepath.Join(atomicDataLink, name) {
+			continue
+		}
+
+		if _, err := os.Lstat(filepath.Join(versionPath, name)); !os.IsNotExist(err) {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestWriteDataFilesAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-atomic-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+	}
+	mountPath := MountPath(vol)
+
+	if err := InitAtomicLayout(mountPath, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsAtomicLayout(mountPath) {
+		t.Fatal("expected atomic layout after init")
+	}
+
+	expFiles := func(exp map[string]string) {
+		for name, content := range exp {
+			b, err := ioutil.ReadFile(filepath.Join(mountPath, name))
+			if err != nil {
+				t.Errorf("failed to read %s: %s", name, err)
+				continue
+			}
+
+			if string(b) != content {
+				t.Errorf("unexpected content of %s, exp=%s got=%s", name, content, b)
+			}
+		}
+	}
+
+	err = WriteDataFiles(vol, map[string][]byte{
+		"crt.pem":     []byte("cert-1"),
+		"key.pem":     []byte("key-1"),
+		"bar/ca.pem":  []byte("ca-1"),
+		"./chain.pem": []byte("chain-1"),
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expFiles(map[string]string{
+		"crt.pem":    "cert-1",
+		"key.pem":    "key-1",
+		"bar/ca.pem": "ca-1",
+		"chain.pem":  "chain-1",
+	})
+
+	for _, name := range []string{"crt.pem", "key.pem", "bar", "chain.pem"} {
+		target, err := os.Readlink(filepath.Join(mountPath, name))
+		if err != nil {
+			t.Errorf("expected %s to be a symlink: %s", name, err)
+			continue
+		}
+
+		if exp := filepath.Join("..data", name); target != exp {
+			t.Errorf("unexpected link target of %s, exp=%s got=%s", name, exp, target)
+		}
+	}
+
+	// Files not given should be carried over from the previous version.
+	err = WriteDataFiles(vol, map[string][]byte{
+		"bar/ca.pem": []byte("ca-2"),
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expFiles(map[string]string{
+		"crt.pem":    "cert-1",
+		"key.pem":    "key-1",
+		"bar/ca.pem": "ca-2",
+		"chain.pem":  "chain-1",
+	})
+
+	// Files given with nil data should be removed, along with their links.
+	err = WriteDataFiles(vol, map[string][]byte{
+		"chain.pem": nil,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expFiles(map[string]string{
+		"crt.pem":    "cert-1",
+		"key.pem":    "key-1",
+		"bar/ca.pem": "ca-2",
+	})
+
+	if _, err := os.Lstat(filepath.Join(mountPath, "chain.pem")); !os.IsNotExist(err) {
+		t.Errorf("expected chain.pem to be removed, got: %v", err)
+	}
+
+	// Only the current version directory should remain.
+	matches, err := filepath.Glob(filepath.Join(mountPath, "..20*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(matches) != 1 {
+		t.Errorf("expected a single version directory, got=%v", matches)
+	}
+}
+
+func TestWriteDataFilesNonAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-atomic-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := &csiapi.MetaData{
+		ID:   "test-id",
+		Path: dir,
+	}
+
+	err = WriteDataFiles(vol, map[string][]byte{
+		"crt.pem": []byte("cert-1"),
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if IsAtomicLayout(MountPath(vol)) {
+		t.Error("expected no atomic layout if not initialised")
+	}
+
+	fi, err := os.Lstat(filepath.Join(MountPath(vol), "crt.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !fi.Mode().IsRegular() {
+		t.Errorf("expected crt.pem to be a regular file, got mode %s", fi.Mode())
+	}
+
+	err = WriteDataFiles(vol, map[string][]byte{
+		"crt.pem": nil,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(MountPath(vol), "crt.pem")); !os.IsNotExist(err) {
+		t.Errorf("expected crt.pem given with nil data to be removed, got: %v", err)
+	}
+}
+
+func TestWriteDataFilesFsync(t *testing.T) {
+	for name, atomic := range map[string]bool{
+		"synced files should be written with the atomic layout":    true,
+		"synced files should be written without the atomic layout": false,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-fsync-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+			}
+			mountPath := MountPath(vol)
+
+			if atomic {
+				if err := InitAtomicLayout(mountPath, true); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			files := map[string][]byte{
+				"crt.pem":    []byte("cert"),
+				"key.pem":    []byte("key"),
+				"sub/ca.pem": []byte("ca"),
+			}
+
+			if err := WriteDataFiles(vol, files, true); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, exp := range files {
+				b, err := ioutil.ReadFile(filepath.Join(mountPath, name))
+				if err != nil {
+					t.Errorf("failed to read %s: %s", name, err)
+					continue
+				}
+
+				if string(b) != string(exp) {
+					t.Errorf("unexpected content of %s, exp=%q got=%q", name, exp, b)
+				}
+			}
+
+			if err := WriteMetaDataFile(vol, false, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := ReadMetaDataFile(MetaDataPath(vol)); err != nil {
+				t.Errorf("failed to read synced metadata file: %s", err)
+			}
+		})
+	}
+}
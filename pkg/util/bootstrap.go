@@ -0,0 +1,66 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+const (
+	// BootstrapCommonName is the common name of placeholder certificates
+	// written while waiting for the real certificate to be issued.
+	BootstrapCommonName = "bootstrap"
+
+	bootstrapDuration = time.Hour
+)
+
+// BootstrapCertificate returns a short lived, PEM encoded, self signed
+// certificate for the given key, with the SANs requested by the volume
+// attributes. It is used as a placeholder until the real certificate has
+// been issued.
+func BootstrapCertificate(attr map[string]string, keyBundle *KeyBundle) ([]byte, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %s", err)
+	}
+
+	uris, err := ParseURISANs(attr)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := RenderDNSNamesTemplate(attr)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName: BootstrapCommonName,
+		},
+		DNSNames:           append(ParseStringList(attr[csiapi.DNSNamesKey]), rendered...),
+		IPAddresses:        ParseIPAddresses(attr[csiapi.IPSANsKey]),
+		URIs:               uris,
+		NotBefore:          now,
+		NotAfter:           now.Add(bootstrapDuration),
+		KeyUsage:           x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		SignatureAlgorithm: keyBundle.SignatureAlgorithm,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl,
+		keyBundle.PrivateKey.Public(), keyBundle.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bootstrap certificate: %s", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
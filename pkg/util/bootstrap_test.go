@@ -0,0 +1,48 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestBootstrapCertificate(t *testing.T) {
+	keyBundle, err := NewECDSAKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attr := map[string]string{
+		csiapi.DNSNamesKey: "a.example.com,b.example.com",
+		csiapi.IPSANsKey:   "192.0.0.1",
+	}
+
+	certPEM, err := BootstrapCertificate(attr, keyBundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := pki.DecodeX509CertificateBytes(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cert.Subject.CommonName != BootstrapCommonName {
+		t.Errorf("unexpected common name, exp=%s got=%s",
+			BootstrapCommonName, cert.Subject.CommonName)
+	}
+
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		t.Errorf("expected certificate to be self signed: %s", err)
+	}
+
+	if !StringsMatch(cert.DNSNames, []string{"a.example.com", "b.example.com"}) {
+		t.Errorf("unexpected dns names: %v", cert.DNSNames)
+	}
+
+	if !IPAddressesMatch(cert.IPAddresses, ParseIPAddresses("192.0.0.1")) {
+		t.Errorf("unexpected ip addresses: %v", cert.IPAddresses)
+	}
+}
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// CASerial returns a random, positive 128 bit serial number as newline
+// terminated upper case hex, in the format of an OpenSSL CA `serial` file. It
+// is random so that certificates signed by each issuance of a CA do not reuse
+// serial numbers.
+func CASerial() ([]byte, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate serial: %s", err)
+	}
+
+	// Serial numbers must be positive.
+	b[0] &= 0x7f
+
+	return []byte(fmt.Sprintf("%X\n", b)), nil
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestCASerial(t *testing.T) {
+	serial, err := CASerial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(serial) != 33 || serial[32] != '\n' {
+		t.Fatalf("expected 32 hex characters and a newline, got %q", serial)
+	}
+
+	n, ok := new(big.Int).SetString(string(serial[:32]), 16)
+	if !ok || n.Sign() < 0 {
+		t.Errorf("expected a positive hex serial, got %q", serial)
+	}
+
+	if !bytes.Equal(serial, bytes.ToUpper(serial)) {
+		t.Errorf("expected upper case hex, got %q", serial)
+	}
+
+	other, err := CASerial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(serial, other) {
+		t.Error("expected serials to be random")
+	}
+}
@@ -0,0 +1,120 @@
+package util
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// BuildChain returns a PEM encoded certificate chain ordered from the leaf,
+// through any intermediates, to the root. The leaf is the first certificate
+// in certPEM, and the remaining certificates may come from any of the inputs
+// in any order. Duplicate certificates are removed. Certificates which are not
+// part of the chain of the leaf are appended at the end in the order given.
+func BuildChain(certPEM, caPEM, rootPEM []byte) ([]byte, error) {
+	var certs []*x509.Certificate
+	for _, b := range [][]byte{certPEM, caPEM, rootPEM} {
+		cs, err := decodeCertificates(b)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, cs...)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates to build chain from")
+	}
+
+	// Remove duplicates, keeping the first occurrence.
+	var pool []*x509.Certificate
+	for _, cert := range certs {
+		if !containsCertificate(pool, cert) {
+			pool = append(pool, cert)
+		}
+	}
+
+	chain := []*x509.Certificate{pool[0]}
+	pool = pool[1:]
+
+	for {
+		last := chain[len(chain)-1]
+
+		// Stop at a self signed certificate.
+		if bytes.Equal(last.RawIssuer, last.RawSubject) {
+			break
+		}
+
+		i := issuerIndex(pool, last)
+		if i < 0 {
+			break
+		}
+
+		chain = append(chain, pool[i])
+		pool = append(pool[:i], pool[i+1:]...)
+	}
+
+	chain = append(chain, pool...)
+
+	var out []byte
+	for _, cert := range chain {
+		out = append(out, pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: cert.Raw,
+		})...)
+	}
+
+	return out, nil
+}
+
+// issuerIndex returns the index of the certificate in the pool which issued
+// the given certificate, or -1 if none did.
+func issuerIndex(pool []*x509.Certificate, cert *x509.Certificate) int {
+	for i, candidate := range pool {
+		if !bytes.Equal(candidate.RawSubject, cert.RawIssuer) {
+			continue
+		}
+
+		if cert.CheckSignatureFrom(candidate) == nil {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func containsCertificate(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func decodeCertificates(b []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	for rest := b; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %s", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
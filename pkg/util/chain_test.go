@@ -0,0 +1,128 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func TestBuildChain(t *testing.T) {
+	root := genTestCert(t, "root", true, nil)
+	intermediate := genTestCert(t, "intermediate", true, root)
+	leaf := genTestCert(t, "leaf", false, intermediate)
+
+	join := func(certs ...*testCert) []byte {
+		var b []byte
+		for _, c := range certs {
+			b = append(b, c.pem...)
+		}
+		return b
+	}
+
+	tests := map[string]struct {
+		certPEM, caPEM, rootPEM []byte
+		expChain                []byte
+		expError                bool
+	}{
+		"intermediate only ca should return leaf and intermediate": {
+			certPEM:  leaf.pem,
+			caPEM:    intermediate.pem,
+			expChain: join(leaf, intermediate),
+		},
+		"intermediate only ca with configured root should return full chain": {
+			certPEM:  leaf.pem,
+			caPEM:    intermediate.pem,
+			rootPEM:  root.pem,
+			expChain: join(leaf, intermediate, root),
+		},
+		"full chain ca in reverse order should be reordered": {
+			certPEM:  leaf.pem,
+			caPEM:    join(root, intermediate),
+			expChain: join(leaf, intermediate, root),
+		},
+		"duplicate certificates should be removed": {
+			certPEM:  join(leaf, intermediate),
+			caPEM:    join(intermediate, root),
+			rootPEM:  root.pem,
+			expChain: join(leaf, intermediate, root),
+		},
+		"root only ca should be appended after the leaf": {
+			certPEM:  leaf.pem,
+			caPEM:    root.pem,
+			expChain: join(leaf, root),
+		},
+		"no certificates should error": {
+			expError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			chain, err := BuildChain(test.certPEM, test.caPEM, test.rootPEM)
+			if test.expError != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expError, err)
+			}
+
+			if string(chain) != string(test.expChain) {
+				t.Errorf("unexpected chain, exp=%s got=%s", test.expChain, chain)
+			}
+		})
+	}
+}
+
+func genTestCert(t *testing.T, cn string, isCA bool, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	}
+
+	parentCert, parentKey := tmpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
@@ -0,0 +1,195 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// EncodeFile converts PEM data into the on disk encoding. If the encoding is
+// DER, the DER bytes of every PEM block are concatenated.
+func EncodeFile(pemBytes []byte, encoding string) ([]byte, error) {
+	if encoding != csiapi.DEREncoding {
+		return pemBytes, nil
+	}
+
+	var der []byte
+	for rest := pemBytes; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		der = append(der, block.Bytes...)
+	}
+
+	if len(der) == 0 {
+		return nil, errors.New("failed to decode any PEM blocks to encode as DER")
+	}
+
+	return der, nil
+}
+
+// EncodeVolumeFile converts PEM data into the on disk encoding of the volume
+// with the given attributes. PEM files are normalized, see NormalizePEM, and
+// those of volumes of the windows OS profile have CRLF line endings.
+func EncodeVolumeFile(pemBytes []byte, attr map[string]string) ([]byte, error) {
+	b, err := EncodeFile(pemBytes, attr[csiapi.EncodingKey])
+	if err != nil {
+		return nil, err
+	}
+
+	if attr[csiapi.EncodingKey] == csiapi.DEREncoding {
+		return b, nil
+	}
+
+	b = NormalizePEM(b)
+
+	if attr[csiapi.OSProfileKey] == csiapi.WindowsOSProfile {
+		b = CRLF(b)
+	}
+
+	return b, nil
+}
+
+// NormalizePEM returns the PEM data with blank lines between blocks removed,
+// every block on lines of its own and ending with exactly one newline, since
+// some TLS libraries reject files ending with none or several. Blank lines
+// inside a block, such as after its headers, are kept.
+func NormalizePEM(b []byte) []byte {
+	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+
+	// Blocks concatenated without a newline share a line.
+	b = bytes.Replace(b, []byte("----------BEGIN "), []byte("-----\n-----BEGIN "), -1)
+
+	var out []byte
+	var inBlock bool
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if !inBlock && len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		if bytes.HasPrefix(line, []byte("-----BEGIN ")) {
+			inBlock = true
+		} else if bytes.HasPrefix(line, []byte("-----END ")) {
+			inBlock = false
+		}
+
+		out = append(out, line...)
+		out = append(out, '\n')
+	}
+
+	return out
+}
+
+// CRLF returns the text with every line ending converted to CRLF.
+func CRLF(b []byte) []byte {
+	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+	return bytes.Replace(b, []byte("\n"), []byte("\r\n"), -1)
+}
+
+// DecodeCertificate decodes certificate file data written with the given
+// encoding.
+func DecodeCertificate(b []byte, encoding string) (*x509.Certificate, error) {
+	if encoding != csiapi.DEREncoding {
+		return pki.DecodeX509CertificateBytes(b)
+	}
+
+	certs, err := x509.ParseCertificates(b)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding cert DER: %s", err)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("error decoding cert DER: no certificates found")
+	}
+
+	return certs[0], nil
+}
+
+// privateKeyBlockTypes are the PEM block types of the private key encodings
+// that can be decoded, in the order DER encoded keys are tried.
+var privateKeyBlockTypes = []string{"RSA PRIVATE KEY", "EC PRIVATE KEY", "PRIVATE KEY"}
+
+// DecodePrivateKey decodes private key file data written with the given
+// encoding. It returns the private key as well as its PEM encoding. The
+// decoder is chosen from the PEM block type, so PKCS#1, SEC 1 (EC) and PKCS#8
+// keys are all accepted. DER data carries no type so each is tried in turn.
+func DecodePrivateKey(b []byte, encoding string) (crypto.Signer, []byte, error) {
+	if encoding != csiapi.DEREncoding {
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, nil, errors.New("error decoding private key PEM block")
+		}
+
+		sk, err := parsePrivateKey(block.Type, block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return sk, b, nil
+	}
+
+	var errs []string
+	for _, blockType := range privateKeyBlockTypes {
+		sk, err := parsePrivateKey(blockType, b)
+		if err == nil {
+			return sk, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: b}), nil
+		}
+
+		errs = append(errs, err.Error())
+	}
+
+	return nil, nil, fmt.Errorf("error decoding private key DER: %s", strings.Join(errs, ", "))
+}
+
+// parsePrivateKey parses the DER of a private key using the decoder of the
+// given PEM block type.
+func parsePrivateKey(blockType string, der []byte) (crypto.Signer, error) {
+	switch blockType {
+	case "RSA PRIVATE KEY":
+		sk, err := x509.ParsePKCS1PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing pkcs#1 private key: %s", err)
+		}
+
+		if err := sk.Validate(); err != nil {
+			return nil, fmt.Errorf("rsa private key failed validation: %s", err)
+		}
+
+		return sk, nil
+
+	case "EC PRIVATE KEY":
+		sk, err := x509.ParseECPrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing ec private key: %s", err)
+		}
+
+		return sk, nil
+
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing pkcs#8 private key: %s", err)
+		}
+
+		sk, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("error parsing pkcs#8 private key: unsupported key type %T", key)
+		}
+
+		return sk, nil
+
+	default:
+		return nil, fmt.Errorf("unknown private key PEM block type %q", blockType)
+	}
+}
@@ -0,0 +1,125 @@
+package util
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// oidSubjectAltName is the extension the SANs of a request are encoded in,
+// which is built from the volume's SAN attributes.
+var oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+// ParseExtraExtensions parses a comma separated list of OID=value pairs into
+// non-critical request extensions. Values are the base64 encoded DER of the
+// extension value. An empty string returns no extensions.
+func ParseExtraExtensions(s string) ([]pkix.Extension, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	var exts []pkix.Extension
+	for _, kv := range strings.Split(s, ",") {
+		split := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(split) != 2 || len(split[0]) == 0 {
+			return nil, fmt.Errorf("expected oid=value pair, got %q", kv)
+		}
+
+		oid, err := ParseOID(split[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if oid.Equal(oidSubjectAltName) {
+			return nil, fmt.Errorf("the subject alternative name extension %s may not be set, use the SAN attributes instead", oid)
+		}
+
+		for _, ext := range exts {
+			if ext.Id.Equal(oid) {
+				return nil, fmt.Errorf("extension %s set more than once", oid)
+			}
+		}
+
+		value, err := base64.StdEncoding.DecodeString(split[1])
+		if err != nil {
+			return nil, fmt.Errorf("value of extension %s is not valid base64: %s", oid, err)
+		}
+
+		var raw asn1.RawValue
+		if rest, err := asn1.Unmarshal(value, &raw); err != nil {
+			return nil, fmt.Errorf("value of extension %s is not valid DER: %s", oid, err)
+		} else if len(rest) > 0 {
+			return nil, fmt.Errorf("value of extension %s has trailing data after DER", oid)
+		}
+
+		exts = append(exts, pkix.Extension{Id: oid, Value: value})
+	}
+
+	return exts, nil
+}
+
+// ParseOID parses an object identifier in dotted decimal notation.
+func ParseOID(s string) (asn1.ObjectIdentifier, error) {
+	arcs := strings.Split(s, ".")
+	if len(arcs) < 2 {
+		return nil, fmt.Errorf("invalid oid %q, expected at least two arcs", s)
+	}
+
+	oid := make(asn1.ObjectIdentifier, len(arcs))
+	for i, arc := range arcs {
+		n, err := strconv.ParseUint(arc, 10, 31)
+		if err != nil || (len(arc) > 1 && arc[0] == '0') {
+			return nil, fmt.Errorf("invalid oid %q, arc %q is not a non-negative integer", s, arc)
+		}
+
+		oid[i] = int(n)
+	}
+
+	if oid[0] > 2 || (oid[0] < 2 && oid[1] > 39) {
+		return nil, fmt.Errorf("invalid oid %q, first arcs out of range", s)
+	}
+
+	return oid, nil
+}
+
+// CSRExtraExtensions returns the extensions of the CSR other than those
+// built from the request's SANs.
+func CSRExtraExtensions(csr *x509.CertificateRequest) []pkix.Extension {
+	var exts []pkix.Extension
+	for _, ext := range csr.Extensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			exts = append(exts, ext)
+		}
+	}
+
+	return exts
+}
+
+// ExtensionsMatch returns true if both contain the same extensions, in any
+// order.
+func ExtensionsMatch(a, b []pkix.Extension) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, extA := range a {
+		var found bool
+		for _, extB := range b {
+			if extA.Id.Equal(extB.Id) && extA.Critical == extB.Critical && bytes.Equal(extA.Value, extB.Value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func TestParseExtraExtensions(t *testing.T) {
+	for name, test := range map[string]struct {
+		exts   string
+		expIDs []asn1.ObjectIdentifier
+		expErr bool
+	}{
+		"an empty string should return no extensions": {
+			exts: "",
+		},
+		"valid extensions should be parsed": {
+			exts: "1.3.6.1.4.1.311.20.2=DAlXZWJTZXJ2ZXI=, 1.2.3=BQA=",
+			expIDs: []asn1.ObjectIdentifier{
+				{1, 3, 6, 1, 4, 1, 311, 20, 2},
+				{1, 2, 3},
+			},
+		},
+		"a missing value should error": {
+			exts:   "1.2.3",
+			expErr: true,
+		},
+		"an oid with a single arc should error": {
+			exts:   "1=BQA=",
+			expErr: true,
+		},
+		"an oid with a non numeric arc should error": {
+			exts:   "1.2.foo=BQA=",
+			expErr: true,
+		},
+		"an oid with an out of range first arc should error": {
+			exts:   "3.1=BQA=",
+			expErr: true,
+		},
+		"the subject alternative name extension should error": {
+			exts:   "2.5.29.17=BQA=",
+			expErr: true,
+		},
+		"a duplicate extension should error": {
+			exts:   "1.2.3=BQA=,1.2.3=BQA=",
+			expErr: true,
+		},
+		"an invalid base64 value should error": {
+			exts:   "1.2.3=not base64",
+			expErr: true,
+		},
+		"a truncated DER value should error": {
+			exts:   "1.2.3=DBRXZWJTZXJ2ZXI=",
+			expErr: true,
+		},
+		"a DER value with trailing data should error": {
+			exts:   "1.2.3=DAlXZWJTZXJ2ZXIA",
+			expErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			exts, err := ParseExtraExtensions(test.exts)
+			if test.expErr != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			if len(exts) != len(test.expIDs) {
+				t.Fatalf("unexpected number of extensions, exp=%d got=%d", len(test.expIDs), len(exts))
+			}
+
+			for i, ext := range exts {
+				if !ext.Id.Equal(test.expIDs[i]) || ext.Critical {
+					t.Errorf("unexpected extension, exp=%s got=%s (critical=%t)", test.expIDs[i], ext.Id, ext.Critical)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// keyFIFOPollInterval is how often a key FIFO is checked for a reader.
+var keyFIFOPollInterval = time.Millisecond * 100
+
+// DeliversKeyByFIFO returns true if the private key of the volume is
+// delivered through a named pipe rather than written to a file.
+func DeliversKeyByFIFO(vol *csiapi.MetaData) bool {
+	return vol.Attributes[csiapi.KeyDeliveryKey] == csiapi.FIFOKeyDelivery
+}
+
+// KeyFIFO delivers a private key through a named pipe. The key is only held
+// in memory, and written once to the first reader to open the pipe.
+type KeyFIFO struct {
+	path   string
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// ServeKeyFIFO creates a named pipe at the path, replacing any regular file,
+// and writes the key to the first reader to open it. Until then the pipe is
+// polled for a reader, so that delivery may be stopped while no reader is
+// present.
+func ServeKeyFIFO(path string, key []byte) (*KeyFIFO, error) {
+	if err := mkfifo(path, 0600); err != nil {
+		return nil, err
+	}
+
+	f := &KeyFIFO{
+		path:   path,
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+
+	go f.serve(key)
+
+	return f, nil
+}
+
+func (f *KeyFIFO) serve(key []byte) {
+	defer close(f.doneCh)
+
+	ticker := time.NewTicker(keyFIFOPollInterval)
+	defer ticker.Stop()
+
+	for {
+		delivered, err := writeFIFO(f.path, key)
+		if err != nil {
+			glog.Errorf("util: failed to deliver private key through %s, retrying: %s", f.path, err)
+		}
+
+		if delivered {
+			glog.V(4).Infof("util: private key delivered through %s", f.path)
+			return
+		}
+
+		select {
+		case <-f.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops waiting for a reader to deliver the key to, if it has not yet
+// been delivered.
+func (f *KeyFIFO) Stop() {
+	select {
+	case <-f.stopCh:
+	default:
+		close(f.stopCh)
+	}
+
+	<-f.doneCh
+}
+
+// Done returns true once the key has been written to a reader, or
+// delivery stopped.
+func (f *KeyFIFO) Done() bool {
+	select {
+	case <-f.doneCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// mkfifo creates a named pipe at the path, unless one already exists. Any
+// other file at the path is replaced.
+func mkfifo(path string, perm os.FileMode) error {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeNamedPipe != 0 {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	if err := syscall.Mkfifo(path, uint32(perm)); err != nil {
+		return fmt.Errorf("failed to create named pipe %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// writeFIFO writes the data to the reader of the named pipe. Returns false if
+// no reader has the pipe open.
+func writeFIFO(path string, data []byte) (bool, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.ENXIO {
+			return false, nil
+		}
+
+		return false, err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
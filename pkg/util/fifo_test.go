@@ -0,0 +1,86 @@
+package util
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServeKeyFIFO(t *testing.T) {
+	defer func(d time.Duration) { keyFIFOPollInterval = d }(keyFIFOPollInterval)
+	keyFIFOPollInterval = time.Millisecond * 10
+
+	for name, test := range map[string]struct {
+		existingFile bool
+		read         bool
+	}{
+		"a reader should be delivered the key": {
+			read: true,
+		},
+		"an existing regular file should be replaced by the pipe": {
+			existingFile: true,
+			read:         true,
+		},
+		"delivery should stop without a reader": {
+			read: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-fifo-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "key.pem")
+			if test.existingFile {
+				if err := ioutil.WriteFile(path, []byte("old key"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			key := []byte("test key")
+			fifo, err := ServeKeyFIFO(path, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fifo.Stop()
+
+			fi, err := os.Lstat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode()&os.ModeNamedPipe == 0 {
+				t.Fatalf("expected a named pipe, got mode %s", fi.Mode())
+			}
+
+			if !test.read {
+				fifo.Stop()
+
+				if !fifo.Done() {
+					t.Error("expected delivery to be done once stopped")
+				}
+
+				return
+			}
+
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, key) {
+				t.Errorf("unexpected key read, exp=%q got=%q", key, got)
+			}
+
+			select {
+			case <-fifo.doneCh:
+			case <-time.After(time.Second * 5):
+				t.Error("expected delivery to be done once read")
+			}
+		})
+	}
+}
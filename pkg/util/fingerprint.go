@@ -0,0 +1,22 @@
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// Fingerprint returns the SHA-256 fingerprint of the DER encoded certificate
+// as newline terminated, colon separated upper case hex, matching the output
+// of `openssl x509 -noout -fingerprint -sha256`.
+func Fingerprint(cert *x509.Certificate) []byte {
+	sum := sha256.Sum256(cert.Raw)
+
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return []byte(strings.Join(hex, ":") + "\n")
+}
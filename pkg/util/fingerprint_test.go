@@ -0,0 +1,34 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+)
+
+const fingerprintTestCert = `-----BEGIN CERTIFICATE-----
+MIIBmjCCAT+gAwIBAgIUWjFHvfJC4UqIzxU1aw2kqyxuCNkwCgYIKoZIzj0EAwIw
+IjEgMB4GA1UEAwwXZmluZ2VycHJpbnQuZXhhbXBsZS5jb20wHhcNMjYxMDE2MDE1
+NDA0WhcNMzYxMDEzMDE1NDA0WjAiMSAwHgYDVQQDDBdmaW5nZXJwcmludC5leGFt
+cGxlLmNvbTBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABBDJej65kjcQfDOKTp5s
+/4UlQfhPXoEupBUPkOT533uT4FVdHj/hlOSmtLK7HyuC1IVdtgv0wCfEy+R4zm5v
+l4mjUzBRMB0GA1UdDgQWBBQPO5Sse68azxaD2qX88ph2zAF5uDAfBgNVHSMEGDAW
+gBQPO5Sse68azxaD2qX88ph2zAF5uDAPBgNVHRMBAf8EBTADAQH/MAoGCCqGSM49
+BAMCA0kAMEYCIQDI+aNqjc06qRCf9exbsHup+R3Y4IxGB6V+i/TP+AU5ZwIhANW8
+CwxwFmPEnUsrGaDS1qul7BmMySPTpVIkhstPzPkH
+-----END CERTIFICATE-----
+`
+
+func TestFingerprint(t *testing.T) {
+	cert, err := pki.DecodeX509CertificateBytes([]byte(fingerprintTestCert))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// openssl x509 -noout -fingerprint -sha256
+	exp := "CF:59:05:EE:AE:F6:57:3A:52:C9:C2:2B:AC:81:26:51:6F:27:CC:2D:43:2C:F8:57:00:F4:92:33:3D:61:9B:31\n"
+
+	if got := string(Fingerprint(cert)); got != exp {
+		t.Errorf("unexpected fingerprint, exp=%q got=%q", exp, got)
+	}
+}
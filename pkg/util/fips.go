@@ -0,0 +1,8 @@
+//go:build !boringcrypto
+// +build !boringcrypto
+
+package util
+
+// BoringCrypto reports whether the binary was built against the BoringCrypto
+// FIPS validated module.
+const BoringCrypto = false
@@ -0,0 +1,13 @@
+//go:build boringcrypto
+// +build boringcrypto
+
+package util
+
+import (
+	// Restrict crypto/tls to FIPS approved settings.
+	_ "crypto/tls/fipsonly"
+)
+
+// BoringCrypto reports whether the binary was built against the BoringCrypto
+// FIPS validated module.
+const BoringCrypto = true
@@ -1,14 +1,20 @@
 package util
 
 import (
+	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
 )
@@ -20,8 +26,33 @@ type KeyBundle struct {
 	PEM                []byte
 }
 
-func NewRSAKey() (*KeyBundle, error) {
-	sk, err := rsa.GenerateKey(rand.Reader, 2048)
+// NewKey generates a new private key using the key algorithm and key size
+// set in the given volume attributes.
+func NewKey(attr map[string]string) (*KeyBundle, error) {
+	size, err := strconv.Atoi(attr[csiapi.KeySizeKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key size: %s", err)
+	}
+
+	switch attr[csiapi.KeyAlgorithmKey] {
+	case csiapi.RSAKeyAlgorithm:
+		return NewRSAKey(size)
+	case csiapi.ECDSAKeyAlgorithm:
+		return NewECDSAKey(size)
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q", attr[csiapi.KeyAlgorithmKey])
+	}
+}
+
+// WritesPrivateKey returns true if the private key of the volume is written
+// to it. Workloads providing their own CSR hold the key, as does the signer
+// plugin for external keys.
+func WritesPrivateKey(vol *csiapi.MetaData) bool {
+	return len(vol.Attributes[csiapi.CSRFileKey]) == 0 && !vol.KeyExternal
+}
+
+func NewRSAKey(size int) (*KeyBundle, error) {
+	sk, err := rsa.GenerateKey(rand.Reader, size)
 	if err != nil {
 		return nil, err
 	}
@@ -41,12 +72,159 @@ func NewRSAKey() (*KeyBundle, error) {
 	}, nil
 }
 
-func WriteFile(path string, b []byte, perm os.FileMode) error {
-	if err := os.MkdirAll(filepath.Dir(path), 0744); err != nil {
+func NewECDSAKey(size int) (*KeyBundle, error) {
+	curve, sigAlg, err := ecdsaCurve(size)
+	if err != nil {
+		return nil, err
+	}
+
+	sk, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalECPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM := pem.EncodeToMemory(
+		&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		},
+	)
+
+	return &KeyBundle{
+		PrivateKey:         sk,
+		SignatureAlgorithm: sigAlg,
+		PublicKeyAlgorithm: x509.ECDSA,
+		PEM:                keyPEM,
+	}, nil
+}
+
+// KeyBundleFromSigner builds a KeyBundle for an existing private key and its
+// PEM encoding.
+func KeyBundleFromSigner(sk crypto.Signer, keyPEM []byte) (*KeyBundle, error) {
+	switch k := sk.(type) {
+	case *rsa.PrivateKey:
+		return &KeyBundle{
+			PrivateKey:         k,
+			SignatureAlgorithm: x509.SHA256WithRSA,
+			PublicKeyAlgorithm: x509.RSA,
+			PEM:                keyPEM,
+		}, nil
+
+	case *ecdsa.PrivateKey:
+		_, sigAlg, err := ecdsaCurve(k.Curve.Params().BitSize)
+		if err != nil {
+			return nil, err
+		}
+
+		return &KeyBundle{
+			PrivateKey:         k,
+			SignatureAlgorithm: sigAlg,
+			PublicKeyAlgorithm: x509.ECDSA,
+			PEM:                keyPEM,
+		}, nil
+
+	case ed25519.PrivateKey:
+		return &KeyBundle{
+			PrivateKey:         k,
+			SignatureAlgorithm: x509.PureEd25519,
+			PublicKeyAlgorithm: x509.Ed25519,
+			PEM:                keyPEM,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", sk)
+	}
+}
+
+func ecdsaCurve(size int) (elliptic.Curve, x509.SignatureAlgorithm, error) {
+	switch size {
+	case 256:
+		return elliptic.P256(), x509.ECDSAWithSHA256, nil
+	case 384:
+		return elliptic.P384(), x509.ECDSAWithSHA384, nil
+	case 521:
+		return elliptic.P521(), x509.ECDSAWithSHA512, nil
+	default:
+		return nil, x509.UnknownSignatureAlgorithm,
+			fmt.Errorf("unsupported ecdsa key size %d", size)
+	}
+}
+
+// WriteFile atomically writes the data to the given path by first writing to
+// a temporary file in the same directory and renaming it into place, so that
+// readers never observe a partially written file. If fsync is true, the file
+// and the directory it is renamed into are synced to disk so that they
+// survive a node crash; unnecessary when the data root is on tmpfs.
+func WriteFile(path string, b []byte, perm os.FileMode, fsync bool) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	if err := writeAndClose(f, b, fsync); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return syncDir(dir, fsync)
+}
+
+// writeAndClose writes the data to the file, syncing it if fsync is true, and
+// closes it.
+func writeAndClose(f *os.File, b []byte, fsync bool) error {
+	if _, err := f.Write(b); err != nil {
+		f.Close()
 		return err
 	}
 
-	return ioutil.WriteFile(path, b, perm)
+	if fsync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+// syncDir syncs the directory, persisting renames into it, if fsync is true.
+func syncDir(dir string, fsync bool) error {
+	if !fsync {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+
+	return d.Close()
 }
 
 func KeyPath(vol *csiapi.MetaData) string {
@@ -60,3 +238,32 @@ func CertPath(vol *csiapi.MetaData) string {
 func CAPath(vol *csiapi.MetaData) string {
 	return filepath.Join(vol.Path, "data", vol.Attributes[csiapi.CAFileKey])
 }
+
+func ChainPath(vol *csiapi.MetaData) string {
+	return filepath.Join(vol.Path, "data", vol.Attributes[csiapi.ChainFileKey])
+}
+
+func TouchFilePath(vol *csiapi.MetaData) string {
+	return filepath.Join(vol.Path, "data", vol.Attributes[csiapi.TouchFileOnRenewKey])
+}
+
+func TokenPath(vol *csiapi.MetaData) string {
+	return filepath.Join(vol.Path, "data", vol.Attributes[csiapi.ServiceAccountTokenFileKey])
+}
+
+// PublicKeysEqual returns true if both public keys are the same. Keys are
+// compared by their PKIX encoding so that every key type that can be decoded
+// is supported.
+func PublicKeysEqual(a, b crypto.PublicKey) (bool, error) {
+	aDER, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false, err
+	}
+
+	bDER, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(aDER, bDER), nil
+}
@@ -2,12 +2,18 @@ package util
 
 import (
 	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/jetstack/cert-manager-csi/pkg/apis"
 	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
 	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
 	"github.com/jetstack/cert-manager/pkg/util/pki"
@@ -23,14 +29,103 @@ func BuildVolumeID(podUID, volSourceSpecName string) string {
 	return fmt.Sprintf("csi-%x", result)
 }
 
-func WriteMetaDataFile(vol *csiapi.MetaData) error {
+// IsVolumeID returns true if the name has the format of the volume IDs built
+// by BuildVolumeID, so that other directories in the data root, such as
+// lost+found, are never taken for volumes.
+func IsVolumeID(name string) bool {
+	if !strings.HasPrefix(name, "csi-") {
+		return false
+	}
+
+	hash := strings.TrimPrefix(name, "csi-")
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// MetaDataPath returns the path of the node private metadata file of the
+// volume.
+func MetaDataPath(vol *csiapi.MetaData) string {
+	return filepath.Join(vol.Path, csiapi.MetaDataFileName)
+}
+
+// PendingKeyPath returns the path of the node private file holding the
+// private key of the volume's pending CertificateRequest.
+func PendingKeyPath(vol *csiapi.MetaData) string {
+	return filepath.Join(vol.Path, csiapi.PendingKeyFileName)
+}
+
+// ReadMetaDataFile reads and decodes the metadata file at the given path.
+// Only the node private copy of the metadata, see MetaDataPath, should ever
+// be read since the copy in the mount may be modified by the application.
+func ReadMetaDataFile(path string) (*csiapi.MetaData, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vol := new(csiapi.MetaData)
+	if err := json.Unmarshal(b, vol); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata file: %s", err)
+	}
+
+	return vol, nil
+}
+
+// WriteMetaDataFile writes the volume metadata to the node private volume
+// directory. The metadata includes all volume attributes, so is only copied
+// into the application visible mount path if inMount is true. Otherwise, any
+// copy previously written to the mount path is removed. Files are synced to
+// disk if fsync is true.
+func WriteMetaDataFile(vol *csiapi.MetaData, inMount, fsync bool) error {
 	b, err := json.Marshal(vol)
 	if err != nil {
 		return err
 	}
 
-	metaPath := filepath.Join(vol.Path, csiapi.MetaDataFileName)
-	return WriteFile(metaPath, b, 0600)
+	if err := WriteFile(MetaDataPath(vol), b, 0600, fsync); err != nil {
+		return err
+	}
+
+	mountMetaPath := filepath.Join(MountPath(vol), csiapi.MetaDataFileName)
+
+	if inMount {
+		return WriteFile(mountMetaPath, b, 0600, fsync)
+	}
+
+	if err := os.Remove(mountMetaPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// SpecHash returns a hash of the driver attributes of a volume. Volumes with
+// the same set of driver attributes will always produce the same hash.
+func SpecHash(attr map[string]string) string {
+	var keys []string
+	for k := range attr {
+		if strings.HasPrefix(k, apis.GroupName+"/") {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\n", k, attr[k])
+	}
+
+	// The rendered names depend on the pod as well as the template.
+	if names, err := RenderDNSNamesTemplate(attr); err == nil && len(names) > 0 {
+		fmt.Fprintf(h, "rendered-dns-names=%s\n", strings.Join(names, ","))
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
 func CertificateRequestMatchesSpec(cr *cmapi.CertificateRequest, attr map[string]string) error {
@@ -85,10 +180,33 @@ func CertificateRequestMatchesSpec(cr *cmapi.CertificateRequest, attr map[string
 		if err != nil {
 			errs = append(errs, fmt.Sprintf("failed to parse attribute duration %q: %s",
 				duration, err))
+		} else if cr.Spec.Duration == nil {
+			errs = append(errs, fmt.Sprintf("unexpected requested duration, exp=%s got=none",
+				durationT))
 		} else if durationT != cr.Spec.Duration.Duration {
 			errs = append(errs, fmt.Sprintf("unexpected requested duration, exp=%s got=%s",
 				durationT, cr.Spec.Duration.Duration))
 		}
+	} else if cr.Spec.Duration != nil {
+		errs = append(errs, fmt.Sprintf("unexpected requested duration, exp=none got=%s",
+			cr.Spec.Duration.Duration))
+	}
+
+	var expUsages, gotUsages []string
+	for _, usage := range ParseKeyUsages(attr) {
+		expUsages = append(expUsages, string(usage))
+	}
+	for _, usage := range cr.Spec.Usages {
+		gotUsages = append(gotUsages, string(usage))
+	}
+	if !StringsMatch(expUsages, gotUsages) {
+		errs = append(errs, fmt.Sprintf("key usages do not match, exp=%s got=%s",
+			expUsages, gotUsages))
+	}
+
+	if nodeID := attr[csiapi.NodeIDKey]; nodeID != cr.Annotations[csiapi.NodeIDKey] {
+		errs = append(errs, fmt.Sprintf("node id does not match, exp=%s got=%s",
+			nodeID, cr.Annotations[csiapi.NodeIDKey]))
 	}
 
 	csr, err := pki.DecodeX509CertificateRequestBytes(
@@ -103,19 +221,43 @@ func CertificateRequestMatchesSpec(cr *cmapi.CertificateRequest, attr map[string
 				commonName, csr.Subject.CommonName))
 		}
 
-		dnsNames := ParseDNSNames(attr[csiapi.DNSNamesKey])
-		if !StringsMatch(dnsNames, csr.DNSNames) {
+		if pkAlg := publicKeyAlgorithm(attr[csiapi.KeyAlgorithmKey]); pkAlg != csr.PublicKeyAlgorithm {
+			errs = append(errs, fmt.Sprintf("public key algorithm does not match, exp=%s got=%s",
+				pkAlg, csr.PublicKeyAlgorithm))
+		}
+
+		serialNumber := attr[csiapi.SubjectSerialNumberKey]
+		if serialNumber != csr.Subject.SerialNumber {
+			errs = append(errs, fmt.Sprintf("subject serial number does not match, exp=%s got=%s",
+				serialNumber, csr.Subject.SerialNumber))
+		}
+
+		streetAddresses := ParseStringList(attr[csiapi.SubjectStreetAddressesKey])
+		if !StringsMatch(streetAddresses, csr.Subject.StreetAddress) {
+			errs = append(errs, fmt.Sprintf("subject street addresses do not match, exp=%s got=%s",
+				streetAddresses, csr.Subject.StreetAddress))
+		}
+
+		dnsNames, err := DNSNames(attr)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else if !StringsMatch(dnsNames, csr.DNSNames) {
 			errs = append(errs, fmt.Sprintf("dns names do not match, exp=%s got=%s",
 				dnsNames, csr.DNSNames))
 		}
 
-		ips := ParseIPAddresses(attr[csiapi.IPSANsKey])
-		if !IPAddressesMatch(ips, csr.IPAddresses) {
+		// The IPs of the pod of volumes tracking it follow those of the
+		// attributes.
+		ips, csrIPs := ParseIPAddresses(attr[csiapi.IPSANsKey]), csr.IPAddresses
+		if attr[csiapi.TrackPodIPKey] == "true" && len(csrIPs) > len(ips) {
+			csrIPs = csrIPs[:len(ips)]
+		}
+		if !IPAddressesMatch(ips, csrIPs) {
 			errs = append(errs, fmt.Sprintf("ip addresses do not match, exp=%v got=%v",
 				ips, csr.IPAddresses))
 		}
 
-		uris, err := ParseURIs(attr[csiapi.URISANsKey])
+		uris, err := ParseURISANs(attr)
 		if err != nil {
 			errs = append(errs, fmt.Sprintf("failed to parse URIs in attributes: %s",
 				err))
@@ -123,6 +265,14 @@ func CertificateRequestMatchesSpec(cr *cmapi.CertificateRequest, attr map[string
 			errs = append(errs, fmt.Sprintf("uris do not match, exp=%v got=%v",
 				uris, csr.URIs))
 		}
+
+		extensions, err := ParseExtraExtensions(attr[csiapi.ExtraExtensionsKey])
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to parse extra extensions in attributes: %s",
+				err))
+		} else if !ExtensionsMatch(extensions, CSRExtraExtensions(csr)) {
+			errs = append(errs, "extra extensions do not match")
+		}
 	}
 
 	if len(errs) > 0 {
@@ -133,6 +283,15 @@ func CertificateRequestMatchesSpec(cr *cmapi.CertificateRequest, attr map[string
 	return nil
 }
 
+func publicKeyAlgorithm(algorithm string) x509.PublicKeyAlgorithm {
+	switch algorithm {
+	case csiapi.ECDSAKeyAlgorithm:
+		return x509.ECDSA
+	default:
+		return x509.RSA
+	}
+}
+
 func BoolPointer(b bool) *bool {
 	return &b
 }
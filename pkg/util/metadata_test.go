@@ -0,0 +1,108 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestWriteMetaDataFile(t *testing.T) {
+	for name, test := range map[string]struct {
+		inMount      bool
+		staleInMount bool
+		expInMountMD bool
+	}{
+		"if not in mount then a stale metadata file in the mount path should be removed": {
+			inMount:      false,
+			staleInMount: true,
+			expInMountMD: false,
+		},
+		"if not in mount then metadata should only be written to the volume directory": {
+			inMount:      false,
+			expInMountMD: false,
+		},
+		"if in mount then metadata should also be written to the mount path": {
+			inMount:      true,
+			expInMountMD: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-metadata-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.IssuerNameKey: "test-issuer",
+				},
+			}
+
+			if test.staleInMount {
+				if err := WriteFile(filepath.Join(MountPath(vol), csiapi.MetaDataFileName), []byte("{}"), 0600, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := WriteMetaDataFile(vol, test.inMount, false); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadMetaDataFile(filepath.Join(dir, csiapi.MetaDataFileName))
+			if err != nil {
+				t.Fatalf("expected metadata file in volume directory: %s", err)
+			}
+
+			if !reflect.DeepEqual(vol, got) {
+				t.Errorf("unexpected metadata, exp=%+v got=%+v", vol, got)
+			}
+
+			_, err = os.Stat(filepath.Join(MountPath(vol), csiapi.MetaDataFileName))
+			if test.expInMountMD && err != nil {
+				t.Errorf("expected metadata file in mount path: %s", err)
+			}
+
+			if !test.expInMountMD && !os.IsNotExist(err) {
+				t.Errorf("expected no metadata file in mount path, got=%v", err)
+			}
+		})
+	}
+}
+
+func TestIsVolumeID(t *testing.T) {
+	for name, test := range map[string]struct {
+		name  string
+		expOK bool
+	}{
+		"a built volume ID should be a volume ID": {
+			name:  BuildVolumeID("test-uid", "tls"),
+			expOK: true,
+		},
+		"lost+found should not be a volume ID": {
+			name:  "lost+found",
+			expOK: false,
+		},
+		"a prefixed name that is not a hash should not be a volume ID": {
+			name:  "csi-test-id",
+			expOK: false,
+		},
+		"a hash without the prefix should not be a volume ID": {
+			name:  strings.TrimPrefix(BuildVolumeID("test-uid", "tls"), "csi-"),
+			expOK: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if ok := IsVolumeID(test.name); ok != test.expOK {
+				t.Errorf("unexpected IsVolumeID(%q), exp=%t got=%t", test.name, test.expOK, ok)
+			}
+		})
+	}
+}
@@ -41,8 +41,10 @@ func IsLikelyMountPoint(file string) (bool, error) {
 	return false, nil
 }
 
+// Mount read only bind mounts the source to the target with the given
+// additional mount options.
 func Mount(source, target string, options []string) error {
-	err := doMount(source, target, nil)
+	err := doMount(source, target, options)
 	if err != nil {
 		return err
 	}
@@ -82,8 +84,9 @@ func doMount(source, target string, options []string) error {
 func makeMountArgs(source, target string, options []string) []string {
 	// Build mount command as follows:
 	//   mount [-t $fstype] [-o $options] [$source] $target
+	// The mount is always a read only bind mount, whatever the options.
 	mountArgs := []string{}
-	options = append(options, "bind", "ro")
+	options = append(options[:len(options):len(options)], "bind", "ro")
 	mountArgs = append(mountArgs, "-o", strings.Join(options, ","))
 	if len(source) > 0 {
 		mountArgs = append(mountArgs, source)
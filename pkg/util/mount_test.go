@@ -0,0 +1,29 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMakeMountArgs(t *testing.T) {
+	for name, test := range map[string]struct {
+		options []string
+		expArgs []string
+	}{
+		"no options should read only bind mount": {
+			options: nil,
+			expArgs: []string{"-o", "bind,ro", "/source", "/target"},
+		},
+		"options should be added to the read only bind mount": {
+			options: []string{"nosuid", "nodev", "rslave"},
+			expArgs: []string{"-o", "nosuid,nodev,rslave,bind,ro", "/source", "/target"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			args := makeMountArgs("/source", "/target", test.options)
+			if !reflect.DeepEqual(args, test.expArgs) {
+				t.Errorf("unexpected mount args, exp=%v got=%v", test.expArgs, args)
+			}
+		})
+	}
+}
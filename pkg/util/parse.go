@@ -1,15 +1,30 @@
 package util
 
 import (
+	"fmt"
 	"net"
 	"net/url"
 	"strings"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
 )
 
 func ParseDNSNames(dnsNames string) []string {
 	return strings.Split(dnsNames, ",")
 }
 
+// ParseStringList splits a comma separated list of strings. An empty string
+// returns a nil slice.
+func ParseStringList(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
+
 func ParseIPAddresses(ips string) []net.IP {
 	if len(ips) == 0 {
 		return nil
@@ -20,7 +35,7 @@ func ParseIPAddresses(ips string) []net.IP {
 	var ipAddresses []net.IP
 
 	for _, ipName := range ipsS {
-		ip := net.ParseIP(ipName)
+		ip := net.ParseIP(strings.TrimSpace(ipName))
 		if ip != nil {
 			ipAddresses = append(ipAddresses, ip)
 		}
@@ -50,6 +65,102 @@ func ParseURIs(uris string) ([]*url.URL, error) {
 	return urisURL, nil
 }
 
+// ParseURISANs returns the URI SANs requested by the given volume attributes.
+// If a node URI SAN prefix is set, the node ID appended to the prefix is also
+// included.
+func ParseURISANs(attr map[string]string) ([]*url.URL, error) {
+	uris, err := ParseURIs(attr[csiapi.URISANsKey])
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix := attr[csiapi.NodeURISANPrefixKey]; len(prefix) > 0 {
+		uri, err := url.Parse(prefix + attr[csiapi.NodeIDKey])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse node uri: %s", err)
+		}
+
+		uris = append(uris, uri)
+	}
+
+	return uris, nil
+}
+
+// extendedKeyUsages are the key usages requested as extended key usages.
+var extendedKeyUsages = map[cmapi.KeyUsage]bool{
+	cmapi.UsageAny:             true,
+	cmapi.UsageServerAuth:      true,
+	cmapi.UsageClientAuth:      true,
+	cmapi.UsageCodeSigning:     true,
+	cmapi.UsageEmailProtection: true,
+	cmapi.UsageSMIME:           true,
+	cmapi.UsageIPsecEndSystem:  true,
+	cmapi.UsageIPsecTunnel:     true,
+	cmapi.UsageIPsecUser:       true,
+	cmapi.UsageTimestamping:    true,
+	cmapi.UsageOCSPSigning:     true,
+	cmapi.UsageMicrosoftSGC:    true,
+	cmapi.UsageNetscapSGC:      true,
+}
+
+// CertificateType returns the type of certificate, of client, server or
+// peer, that the key usages of the attributes request. A certificate with no
+// extended key usages, or the any usage, may be used for both client and
+// server auth so is a peer certificate. A certificate for neither has no
+// type.
+func CertificateType(attr map[string]string) string {
+	var server, client, extended bool
+	for _, usage := range ParseKeyUsages(attr) {
+		switch usage {
+		case cmapi.UsageAny:
+			server, client = true, true
+		case cmapi.UsageServerAuth:
+			server = true
+		case cmapi.UsageClientAuth:
+			client = true
+		}
+
+		if extendedKeyUsages[usage] {
+			extended = true
+		}
+	}
+
+	switch {
+	case !extended, server && client:
+		return csiapi.PeerCertificateType
+	case server:
+		return csiapi.ServerCertificateType
+	case client:
+		return csiapi.ClientCertificateType
+	default:
+		return ""
+	}
+}
+
+// ParseKeyUsages returns the key usages requested by the given volume
+// attributes. Explicit key usages are returned as is, otherwise the usages are
+// expanded from the certificate type. Returns nil if neither is set.
+func ParseKeyUsages(attr map[string]string) []cmapi.KeyUsage {
+	if usages := ParseStringList(attr[csiapi.KeyUsagesKey]); len(usages) > 0 {
+		var keyUsages []cmapi.KeyUsage
+		for _, usage := range usages {
+			keyUsages = append(keyUsages, cmapi.KeyUsage(strings.TrimSpace(usage)))
+		}
+		return keyUsages
+	}
+
+	switch attr[csiapi.CertificateTypeKey] {
+	case csiapi.ServerCertificateType:
+		return append(cmapi.DefaultKeyUsages(), cmapi.UsageServerAuth)
+	case csiapi.ClientCertificateType:
+		return append(cmapi.DefaultKeyUsages(), cmapi.UsageClientAuth)
+	case csiapi.PeerCertificateType:
+		return append(cmapi.DefaultKeyUsages(), cmapi.UsageServerAuth, cmapi.UsageClientAuth)
+	}
+
+	return nil
+}
+
 func IPAddressesMatch(a, b []net.IP) bool {
 	if len(a) != len(b) {
 		return false
@@ -77,3 +188,23 @@ func URIsMatch(a, b []*url.URL) bool {
 
 	return true
 }
+
+// ParseKeyValues parses a comma separated list of key=value pairs into a map.
+func ParseKeyValues(kvs string) (map[string]string, error) {
+	if len(kvs) == 0 {
+		return nil, nil
+	}
+
+	kvMap := make(map[string]string)
+
+	for _, kv := range strings.Split(kvs, ",") {
+		split := strings.SplitN(kv, "=", 2)
+		if len(split) != 2 || len(split[0]) == 0 {
+			return nil, fmt.Errorf("expected key=value pair, got %q", kv)
+		}
+
+		kvMap[split[0]] = split[1]
+	}
+
+	return kvMap, nil
+}
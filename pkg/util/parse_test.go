@@ -0,0 +1,42 @@
+package util
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPAddressesMatchNormalization(t *testing.T) {
+	for name, test := range map[string]struct {
+		attr     string
+		csr      []net.IP
+		expMatch bool
+	}{
+		"ipv4 should match its 4 byte form": {
+			"192.0.0.1",
+			[]net.IP{net.ParseIP("192.0.0.1").To4()},
+			true,
+		},
+		"ipv6 should match regardless of textual form": {
+			"2001:0db8:0000:0000:0000:0000:0000:0001",
+			[]net.IP{net.ParseIP("2001:db8::1")},
+			true,
+		},
+		"surrounding spaces should be ignored": {
+			"192.0.0.1, 2001:db8::1",
+			[]net.IP{net.ParseIP("192.0.0.1"), net.ParseIP("2001:db8::1")},
+			true,
+		},
+		"different addresses should not match": {
+			"2001:db8::1",
+			[]net.IP{net.ParseIP("2001:db8::2")},
+			false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			match := IPAddressesMatch(ParseIPAddresses(test.attr), test.csr)
+			if match != test.expMatch {
+				t.Errorf("unexpected match, exp=%t got=%t", test.expMatch, match)
+			}
+		})
+	}
+}
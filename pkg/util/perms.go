@@ -0,0 +1,62 @@
+package util
+
+import (
+	"os"
+	"syscall"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// DataFilePermissions are the permissions the certificate, key and CA files
+// of a volume are written with.
+const DataFilePermissions os.FileMode = 0600
+
+// DataFilePaths returns the paths of the certificate, key, CA and chain files
+// the volume is configured with.
+func DataFilePaths(vol *csiapi.MetaData) []string {
+	var paths []string
+	for k, path := range map[string]func(*csiapi.MetaData) string{
+		csiapi.CertFileKey:  CertPath,
+		csiapi.KeyFileKey:   KeyPath,
+		csiapi.CAFileKey:    CAPath,
+		csiapi.ChainFileKey: ChainPath,
+	} {
+		if len(vol.Attributes[k]) > 0 {
+			paths = append(paths, path(vol))
+		}
+	}
+
+	return paths
+}
+
+// ReconcileFilePermissions sets the permissions and ownership of the file,
+// following symlinks, if they have drifted from those given. Returns true if
+// the file was changed. A file that does not exist is not changed.
+func ReconcileFilePermissions(path string, perm os.FileMode, uid, gid int) (bool, error) {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var changed bool
+
+	if fi.Mode().Perm() != perm {
+		if err := os.Chmod(path, perm); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok &&
+		(int(stat.Uid) != uid || int(stat.Gid) != gid) {
+		if err := os.Chown(path, uid, gid); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
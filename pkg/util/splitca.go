@@ -0,0 +1,62 @@
+package util
+
+import (
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// SplitCAFileName returns the name of the i'th file a CA bundle is split
+// into, numbered from the name of the CA file. For example ca.crt is split
+// into ca-0.crt, ca-1.crt and so on.
+func SplitCAFileName(caFile string, i int) string {
+	ext := filepath.Ext(caFile)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(caFile, ext), i, ext)
+}
+
+// SplitCAFiles returns the files the PEM encoded CA bundle of the volume is
+// split into, each holding one certificate in the volume's encoding. Numbered
+// files of a previous, longer, bundle are returned with nil data so that
+// WriteDataFiles removes them.
+func SplitCAFiles(vol *csiapi.MetaData, caPEM []byte) (map[string][]byte, error) {
+	caFile := vol.Attributes[csiapi.CAFileKey]
+	files := make(map[string][]byte)
+
+	var i int
+	for rest := caPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		b, err := EncodeVolumeFile(pem.EncodeToMemory(block), vol.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode ca certificate %d: %s", i, err)
+		}
+
+		files[SplitCAFileName(caFile, i)] = b
+		i++
+	}
+
+	// Files are numbered contiguously, so stale files end at the first
+	// number that doesn't exist.
+	for ; ; i++ {
+		name := SplitCAFileName(caFile, i)
+		if _, err := os.Lstat(filepath.Join(MountPath(vol), name)); err != nil {
+			break
+		}
+
+		files[name] = nil
+	}
+
+	return files, nil
+}
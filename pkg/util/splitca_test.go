@@ -0,0 +1,99 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestSplitCAFileName(t *testing.T) {
+	for caFile, exp := range map[string]string{
+		"ca.crt":     "ca-1.crt",
+		"ca":         "ca-1",
+		"bar/foo.ca": "bar/foo-1.ca",
+		"certs.d/ca": "certs.d/ca-1",
+	} {
+		if got := SplitCAFileName(caFile, 1); got != exp {
+			t.Errorf("unexpected split ca file name of %q, exp=%s got=%s", caFile, exp, got)
+		}
+	}
+}
+
+func TestSplitCAFiles(t *testing.T) {
+	root := genTestCert(t, "root", true, nil)
+	intermediate := genTestCert(t, "intermediate", true, root)
+
+	for name, test := range map[string]struct {
+		caPEM    []byte
+		existing []string
+		expFiles map[string][]byte
+	}{
+		"a single certificate should be written to a single file": {
+			caPEM: root.pem,
+			expFiles: map[string][]byte{
+				"ca-0.crt": root.pem,
+			},
+		},
+		"each certificate of a bundle should be written to its own file in order": {
+			caPEM: append(append([]byte{}, intermediate.pem...), root.pem...),
+			expFiles: map[string][]byte{
+				"ca-0.crt": intermediate.pem,
+				"ca-1.crt": root.pem,
+			},
+		},
+		"stale files of a longer bundle should be removed": {
+			caPEM:    root.pem,
+			existing: []string{"ca-0.crt", "ca-1.crt", "ca-2.crt"},
+			expFiles: map[string][]byte{
+				"ca-0.crt": root.pem,
+				"ca-1.crt": nil,
+				"ca-2.crt": nil,
+			},
+		},
+		"an empty bundle should remove all files": {
+			existing: []string{"ca-0.crt"},
+			expFiles: map[string][]byte{
+				"ca-0.crt": nil,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(os.TempDir(), "cert-manager-csi-split-ca-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			vol := &csiapi.MetaData{
+				ID:   "test-id",
+				Path: dir,
+				Attributes: map[string]string{
+					csiapi.CAFileKey: "ca.crt",
+				},
+			}
+
+			if err := os.MkdirAll(MountPath(vol), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range test.existing {
+				if err := ioutil.WriteFile(filepath.Join(MountPath(vol), name), []byte("stale"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			files, err := SplitCAFiles(vol, test.caPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(files, test.expFiles) {
+				t.Errorf("unexpected files, exp=%q got=%q", test.expFiles, files)
+			}
+		})
+	}
+}
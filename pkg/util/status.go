@@ -0,0 +1,39 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func StatusPath(vol *csiapi.MetaData) string {
+	return filepath.Join(vol.Path, csiapi.StatusFileName)
+}
+
+func ReadStatusFile(path string) (*csiapi.Status, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	status := new(csiapi.Status)
+	if err := json.Unmarshal(b, status); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal status file: %s", err)
+	}
+
+	return status, nil
+}
+
+// WriteStatusFile writes the status of the volume next to its metadata file,
+// outside of the application mount, syncing it to disk if fsync is true.
+func WriteStatusFile(vol *csiapi.MetaData, status *csiapi.Status, fsync bool) error {
+	b, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	return WriteFile(StatusPath(vol), b, 0600, fsync)
+}
@@ -0,0 +1,75 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// DNSNamesTemplateData is the pod metadata DNS names templates are rendered
+// with.
+type DNSNamesTemplateData struct {
+	PodName            string
+	Namespace          string
+	PodUID             string
+	ServiceAccountName string
+	NodeID             string
+}
+
+// DNSNames returns the DNS names requested by the given volume attributes,
+// followed by the names rendered from the DNS names template, if set.
+func DNSNames(attr map[string]string) ([]string, error) {
+	if len(attr[csiapi.DNSNamesTemplateKey]) == 0 {
+		return ParseDNSNames(attr[csiapi.DNSNamesKey]), nil
+	}
+
+	rendered, err := RenderDNSNamesTemplate(attr)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ParseStringList(attr[csiapi.DNSNamesKey]), rendered...), nil
+}
+
+// RenderDNSNamesTemplate renders the DNS names template of the volume with the
+// metadata of its pod, returning the comma separated names it renders to.
+// Returns nil if no template is set.
+func RenderDNSNamesTemplate(attr map[string]string) ([]string, error) {
+	text := attr[csiapi.DNSNamesTemplateKey]
+	if len(text) == 0 {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("dns-names").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dns names template: %s", err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, DNSNamesTemplateData{
+		PodName:            attr[csiapi.CSIPodNameKey],
+		Namespace:          attr[csiapi.CSIPodNamespaceKey],
+		PodUID:             attr[csiapi.CSIPodUIDKey],
+		ServiceAccountName: attr[csiapi.CSIServiceAccountNameKey],
+		NodeID:             attr[csiapi.NodeIDKey],
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render dns names template: %s", err)
+	}
+
+	var names []string
+	for _, name := range strings.Split(buf.String(), ",") {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			return nil, errors.New("dns names template rendered an empty name")
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
@@ -0,0 +1,90 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestDNSNames(t *testing.T) {
+	pod := func(attr map[string]string) map[string]string {
+		attr[csiapi.CSIPodNameKey] = "web-0"
+		attr[csiapi.CSIPodNamespaceKey] = "sandbox"
+		attr[csiapi.CSIServiceAccountNameKey] = "web"
+		return attr
+	}
+
+	for name, test := range map[string]struct {
+		attr     map[string]string
+		expNames []string
+		expErr   bool
+	}{
+		"no template should return the dns names as is": {
+			attr: pod(map[string]string{
+				csiapi.DNSNamesKey: "foo.bar,car.bar",
+			}),
+			expNames: []string{"foo.bar", "car.bar"},
+		},
+		"a template should be rendered with the pod metadata": {
+			attr: pod(map[string]string{
+				csiapi.DNSNamesTemplateKey: "{{.PodName}}.web.{{.Namespace}}.svc",
+			}),
+			expNames: []string{"web-0.web.sandbox.svc"},
+		},
+		"a template rendering many names should follow the dns names": {
+			attr: pod(map[string]string{
+				csiapi.DNSNamesKey:         "foo.bar",
+				csiapi.DNSNamesTemplateKey: "{{.PodName}}.web.{{.Namespace}}.svc, {{.ServiceAccountName}}.{{.Namespace}}.svc",
+			}),
+			expNames: []string{"foo.bar", "web-0.web.sandbox.svc", "web.sandbox.svc"},
+		},
+		"a template that fails to parse should error": {
+			attr: pod(map[string]string{
+				csiapi.DNSNamesTemplateKey: "{{.PodName",
+			}),
+			expErr: true,
+		},
+		"a template of an unknown field should error": {
+			attr: pod(map[string]string{
+				csiapi.DNSNamesTemplateKey: "{{.ServiceName}}.svc",
+			}),
+			expErr: true,
+		},
+		"a template rendering an empty name should error": {
+			attr: pod(map[string]string{
+				csiapi.DNSNamesTemplateKey: "{{.PodName}}.svc,",
+			}),
+			expErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			names, err := DNSNames(test.attr)
+			if test.expErr != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			if !reflect.DeepEqual(names, test.expNames) {
+				t.Errorf("unexpected dns names, exp=%v got=%v", test.expNames, names)
+			}
+		})
+	}
+}
+
+func TestSpecHashRenderedDNSNames(t *testing.T) {
+	attr := func(podName string) map[string]string {
+		return map[string]string{
+			csiapi.CSIPodNameKey:       podName,
+			csiapi.CSIPodNamespaceKey:  "sandbox",
+			csiapi.DNSNamesTemplateKey: "{{.PodName}}.web.{{.Namespace}}.svc",
+		}
+	}
+
+	if SpecHash(attr("web-0")) == SpecHash(attr("web-1")) {
+		t.Error("expected spec hash to differ for names rendered for different pods")
+	}
+
+	if SpecHash(attr("web-0")) != SpecHash(attr("web-0")) {
+		t.Error("expected spec hash to match for names rendered for the same pod")
+	}
+}
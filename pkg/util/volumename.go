@@ -0,0 +1,83 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+// DefaultVolumeNameTemplate renders the same names as BuildVolumeName.
+const DefaultVolumeNameTemplate = "cert-manager-csi-{{.PodName}}-{{.VolumeID}}"
+
+// VolumeNameTemplateData is the pod metadata volume name templates are
+// rendered with.
+type VolumeNameTemplateData struct {
+	PodName            string
+	Namespace          string
+	PodUID             string
+	ServiceAccountName string
+	NodeID             string
+	VolumeID           string
+}
+
+// ParseVolumeNameTemplate parses the volume name template, and errors if it
+// does not render a single line name from sample pod metadata.
+func ParseVolumeNameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("volume-name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse volume name template: %s", err)
+	}
+
+	_, err = renderVolumeName(tmpl, VolumeNameTemplateData{
+		PodName:            "pod",
+		Namespace:          "namespace",
+		PodUID:             "uid",
+		ServiceAccountName: "service-account",
+		NodeID:             "node",
+		VolumeID:           "csi-id",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// RenderVolumeName renders the name of the volume from the template with the
+// pod metadata of its attributes. If the template is nil, the name is built
+// with BuildVolumeName.
+func RenderVolumeName(tmpl *template.Template, attr map[string]string, volID string) (string, error) {
+	if tmpl == nil {
+		return BuildVolumeName(attr[csiapi.CSIPodNameKey], volID), nil
+	}
+
+	return renderVolumeName(tmpl, VolumeNameTemplateData{
+		PodName:            attr[csiapi.CSIPodNameKey],
+		Namespace:          attr[csiapi.CSIPodNamespaceKey],
+		PodUID:             attr[csiapi.CSIPodUIDKey],
+		ServiceAccountName: attr[csiapi.CSIServiceAccountNameKey],
+		NodeID:             attr[csiapi.NodeIDKey],
+		VolumeID:           volID,
+	})
+}
+
+func renderVolumeName(tmpl *template.Template, data VolumeNameTemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render volume name template: %s", err)
+	}
+
+	name := strings.TrimSpace(buf.String())
+	if len(name) == 0 {
+		return "", errors.New("volume name template rendered an empty name")
+	}
+	if strings.ContainsAny(name, "\r\n") {
+		return "", fmt.Errorf("volume name template rendered a name over multiple lines: %q", name)
+	}
+
+	return name, nil
+}
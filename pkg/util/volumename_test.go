@@ -0,0 +1,87 @@
+package util
+
+import (
+	"testing"
+
+	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
+)
+
+func TestRenderVolumeName(t *testing.T) {
+	attr := map[string]string{
+		csiapi.CSIPodNameKey:            "my-pod",
+		csiapi.CSIPodNamespaceKey:       "my-namespace",
+		csiapi.CSIPodUIDKey:             "1234",
+		csiapi.CSIServiceAccountNameKey: "my-sa",
+		csiapi.NodeIDKey:                "my-node",
+	}
+
+	for name, test := range map[string]struct {
+		template string
+		expName  string
+		expErr   bool
+	}{
+		"no template should build the name as before": {
+			expName: "cert-manager-csi-my-pod-csi-id",
+		},
+		"the default template should build the name as before": {
+			template: DefaultVolumeNameTemplate,
+			expName:  "cert-manager-csi-my-pod-csi-id",
+		},
+		"a template with the namespace should render it": {
+			template: "{{.Namespace}}/{{.PodName}}/{{.VolumeID}}",
+			expName:  "my-namespace/my-pod/csi-id",
+		},
+		"a template with the service account and node should render them": {
+			template: "{{.ServiceAccountName}}.{{.Namespace}}@{{.NodeID}}",
+			expName:  "my-sa.my-namespace@my-node",
+		},
+		"a template of an unknown field should error": {
+			template: "{{.Pod}}",
+			expErr:   true,
+		},
+		"a template which fails to parse should error": {
+			template: "{{.PodName",
+			expErr:   true,
+		},
+		"a template rendering an empty name should error": {
+			template: "{{if false}}{{.PodName}}{{end}}",
+			expErr:   true,
+		},
+		"a template rendering multiple lines should error": {
+			template: "{{.PodName}}\n{{.Namespace}}",
+			expErr:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if len(test.template) == 0 {
+				name, err := RenderVolumeName(nil, attr, "csi-id")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if name != test.expName {
+					t.Errorf("unexpected name, exp=%q got=%q", test.expName, name)
+				}
+
+				return
+			}
+
+			tmpl, err := ParseVolumeNameTemplate(test.template)
+			if test.expErr != (err != nil) {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+			if test.expErr {
+				return
+			}
+
+			name, err := RenderVolumeName(tmpl, attr, "csi-id")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if name != test.expName {
+				t.Errorf("unexpected name, exp=%q got=%q", test.expName, name)
+			}
+		})
+	}
+}
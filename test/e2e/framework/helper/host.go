@@ -13,6 +13,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/kind/pkg/cluster/nodes"
 
+	"github.com/jetstack/cert-manager-csi/cmd/app/options"
 	csidefaults "github.com/jetstack/cert-manager-csi/pkg/apis/defaults"
 	csiapi "github.com/jetstack/cert-manager-csi/pkg/apis/v1alpha1"
 	"github.com/jetstack/cert-manager-csi/pkg/util"
@@ -25,7 +26,7 @@ func (h *Helper) MetaDataCertificateKeyExistInHostPath(cr *cmapi.CertificateRequ
 	dirPath := filepath.Join(dataDir, volID)
 
 	// set defaults and csi storage attrubutes from pod
-	attr, err := csidefaults.SetDefaultAttributes(attr)
+	attr, err := csidefaults.SetDefaultAttributes(attr, new(options.Options))
 	if err != nil {
 		return fmt.Errorf("failed to set default volume attributes: %s", err)
 	}
@@ -35,6 +36,7 @@ func (h *Helper) MetaDataCertificateKeyExistInHostPath(cr *cmapi.CertificateRequ
 	attr["csi.storage.k8s.io/pod.namespace"] = pod.Namespace
 	attr["csi.storage.k8s.io/pod.uid"] = string(pod.UID)
 	attr["csi.storage.k8s.io/serviceAccount.name"] = pod.Spec.ServiceAccountName
+	attr[csiapi.NodeIDKey] = pod.Spec.NodeName
 
 	node, err := h.cfg.Environment.Node(pod.Spec.NodeName)
 	if err != nil {